@@ -0,0 +1,138 @@
+package builder
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/tinygo-org/tinygo/transform"
+)
+
+// AllocReport lists all heap allocations that remain in the program after
+// optimization, as printed by -alloc-report. It is meant to help remove heap
+// allocations from code that needs to run without them, like interrupt handlers
+// and hot loops on microcontrollers.
+type AllocReport struct {
+	Total    AllocSummary
+	Packages []AllocSummary // per package, sorted by import path
+	Allocs   []AllocSite    // sorted by position
+}
+
+// AllocSummary is the number of heap allocations in a package or the whole
+// program.
+type AllocSummary struct {
+	Package   string `json:",omitempty"`
+	Count     int    // number of allocation sites
+	Bytes     uint64 // total size of all constant-sized allocations
+	Interrupt int    // number of allocation sites reachable from an interrupt
+	Loop      int    // number of allocation sites inside a loop
+}
+
+// AllocSite is a single heap allocation in the program.
+type AllocSite struct {
+	Pos       string // file:line:column, or empty if unknown
+	Function  string
+	Package   string
+	Size      uint64 `json:",omitempty"` // zero if the size is not constant
+	Reason    string // why the object could not be allocated on the stack
+	Interrupt bool   `json:",omitempty"`
+	Loop      bool   `json:",omitempty"`
+}
+
+// newAllocReport creates a report from the list of heap allocations in the
+// program.
+func newAllocReport(allocs []transform.HeapAlloc) *AllocReport {
+	report := &AllocReport{
+		Allocs: []AllocSite{},
+	}
+	packages := make(map[string]*AllocSummary)
+	for _, alloc := range allocs {
+		site := AllocSite{
+			Function:  alloc.Function,
+			Package:   alloc.Package,
+			Size:      alloc.Size,
+			Reason:    alloc.Reason,
+			Interrupt: alloc.Interrupt,
+			Loop:      alloc.Loop,
+		}
+		if alloc.Pos.IsValid() {
+			site.Pos = alloc.Pos.String()
+		}
+		if site.Package == "" {
+			site.Package = "(unknown)"
+		}
+		report.Allocs = append(report.Allocs, site)
+
+		summary := packages[site.Package]
+		if summary == nil {
+			summary = &AllocSummary{Package: site.Package}
+			packages[site.Package] = summary
+		}
+		for _, s := range []*AllocSummary{summary, &report.Total} {
+			s.Count++
+			s.Bytes += site.Size
+			if site.Interrupt {
+				s.Interrupt++
+			}
+			if site.Loop {
+				s.Loop++
+			}
+		}
+	}
+	report.Packages = []AllocSummary{}
+	for _, summary := range packages {
+		report.Packages = append(report.Packages, *summary)
+	}
+	sort.Slice(report.Packages, func(i, j int) bool {
+		return report.Packages[i].Package < report.Packages[j].Package
+	})
+	return report
+}
+
+// WriteText writes the report as a human readable table followed by the list
+// of allocations.
+func (r *AllocReport) WriteText(w io.Writer) error {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "  count    bytes interrupt  loop | package\n")
+	fmt.Fprintf(&sb, "-------------------------------- | -------\n")
+	for _, pkg := range r.Packages {
+		fmt.Fprintf(&sb, "%7d %8d %9d %5d | %s\n", pkg.Count, pkg.Bytes, pkg.Interrupt, pkg.Loop, pkg.Package)
+	}
+	fmt.Fprintf(&sb, "-------------------------------- | -------\n")
+	fmt.Fprintf(&sb, "%7d %8d %9d %5d | total\n", r.Total.Count, r.Total.Bytes, r.Total.Interrupt, r.Total.Loop)
+	if len(r.Allocs) != 0 {
+		sb.WriteString("\n")
+	}
+	for _, site := range r.Allocs {
+		pos := site.Pos
+		if pos == "" {
+			pos = "(unknown position)"
+		}
+		size := "variable size"
+		if site.Size != 0 {
+			size = fmt.Sprintf("%d bytes", site.Size)
+		}
+		fmt.Fprintf(&sb, "%s: %s: %s, %s", pos, site.Function, size, site.Reason)
+		if site.Interrupt {
+			sb.WriteString(" [interrupt]")
+		}
+		if site.Loop {
+			sb.WriteString(" [loop]")
+		}
+		sb.WriteString("\n")
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// WriteJSON writes the report in JSON format.
+func (r *AllocReport) WriteJSON(w io.Writer) error {
+	data, err := json.MarshalIndent(r, "", "\t")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
@@ -0,0 +1,50 @@
+package builder
+
+import (
+	"encoding/json"
+	"go/token"
+	"strings"
+	"testing"
+
+	"github.com/tinygo-org/tinygo/transform"
+)
+
+func TestAllocReport(t *testing.T) {
+	report := newAllocReport([]transform.HeapAlloc{
+		{Pos: token.Position{Filename: "/src/main.go", Line: 10, Column: 5}, Function: "main.loop", Package: "main", Reason: "size is not constant", Loop: true},
+		{Pos: token.Position{Filename: "/src/main.go", Line: 20, Column: 9}, Function: "(*main.T).handle", Package: "main", Size: 16, Reason: "escapes at line 21", Interrupt: true},
+		{Function: "runtime.stringConcat", Package: "runtime", Reason: "size is not constant"},
+	})
+
+	var out strings.Builder
+	if err := report.WriteText(&out); err != nil {
+		t.Fatal(err)
+	}
+	expectedText := `  count    bytes interrupt  loop | package
+-------------------------------- | -------
+      2       16         1     1 | main
+      1        0         0     0 | runtime
+-------------------------------- | -------
+      3       16         1     1 | total
+
+/src/main.go:10:5: main.loop: variable size, size is not constant [loop]
+/src/main.go:20:9: (*main.T).handle: 16 bytes, escapes at line 21 [interrupt]
+(unknown position): runtime.stringConcat: variable size, size is not constant
+`
+	if out.String() != expectedText {
+		t.Errorf("unexpected text output:\n%s\nwant:\n%s", out.String(), expectedText)
+	}
+
+	// Check that the JSON output can be read back.
+	out.Reset()
+	if err := report.WriteJSON(&out); err != nil {
+		t.Fatal(err)
+	}
+	var decoded AllocReport
+	if err := json.Unmarshal([]byte(out.String()), &decoded); err != nil {
+		t.Fatal("could not decode JSON output:", err)
+	}
+	if decoded.Total != report.Total || len(decoded.Allocs) != 3 || decoded.Allocs[1] != report.Allocs[1] {
+		t.Errorf("unexpected JSON output:\n%s", out.String())
+	}
+}
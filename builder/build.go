@@ -65,6 +65,17 @@ type BuildResult struct {
 	// Map from path to package name. It is needed to attribute binary size to
 	// the right Go package.
 	PackagePathMap map[string]string
+
+	// The format of the output binary, like "elf", "hex" or "wasm".
+	BinaryFormat string
+
+	// The ELF file that the output binary was converted from, if it isn't the
+	// linked executable itself (for example with hex or uf2 output).
+	ConvertedFrom string
+
+	// Description of the build, only set when requested with -manifest. The
+	// artifacts still need to be added, once they're in their final location.
+	Manifest *Manifest
 }
 
 // packageAction is the struct that is serialized to JSON and hashed, to work as
@@ -109,6 +120,29 @@ func Build(pkgName, outpath, tmpdir string, config *compileopts.Config) (BuildRe
 		fmt.Printf("WORK=%s\n", tmpdir)
 	}
 
+	if config.Symtab() {
+		if err := checkSymtabSupport(config); err != nil {
+			return BuildResult{}, err
+		}
+	}
+	if config.Options.Sanitize != "" {
+		// The sanitizer runtimes in the runtime package assume a Linux
+		// address space and the shadow memory layout of these architectures.
+		if config.GOOS() != "linux" || config.Target.Libc != "musl" || (config.GOARCH() != "amd64" && config.GOARCH() != "arm64") {
+			return BuildResult{}, fmt.Errorf("-sanitize is only supported on linux/amd64 and linux/arm64, not on %s/%s", config.GOOS(), config.GOARCH())
+		}
+	}
+
+	// Load the size budget now, so that mistakes in the file are reported
+	// before doing all the work of building the program.
+	var budget *sizeBudget
+	if config.Options.SizeBudget != "" {
+		budget, err = loadSizeBudget(config.Options.SizeBudget)
+		if err != nil {
+			return BuildResult{}, err
+		}
+	}
+
 	// Look up the build cache directory, which is used to speed up incremental
 	// builds.
 	cacheDir := goenv.Get("GOCACHE")
@@ -213,7 +247,12 @@ func Build(pkgName, outpath, tmpdir string, config *compileopts.Config) (BuildRe
 		MaxStackAlloc:      config.MaxStackAlloc(),
 		NeedsStackObjects:  config.NeedsStackObjects(),
 		Debug:              !config.Options.SkipDWARF, // emit DWARF except when -internal-nodwarf is passed
+		FramePointers:      config.Symtab(),
 		PanicStrategy:      config.PanicStrategy(),
+		FuzzCoverage:       config.Fuzz(),
+		WriteBarriers:      config.GC() == "incremental",
+		GCPauseBudget:      config.GCPauseBudget().Nanoseconds(),
+		MovingGC:           config.GC() == "compacting",
 	}
 
 	// Load the target machine, which is the LLVM object that contains all
@@ -519,10 +558,53 @@ func Build(pkgName, outpath, tmpdir string, config *compileopts.Config) (BuildRe
 		}
 	}()
 	var stackSizeLoads []string
+	var programID string
+	// The optimized program is cached, unless it is needed to print
+	// diagnostics that are only produced while optimizing.
+	cacheProgram := !config.Options.PrintIR && !config.DumpSSA() && config.Options.PrintAllocs == nil
 	programJob := &compileJob{
 		description:  "link+optimize packages (LTO)",
 		dependencies: packageJobs,
-		run: func(*compileJob) error {
+		run: func(job *compileJob) error {
+			// Create a cache key for the optimized program, from the action
+			// IDs of all packages and all other parameters that influence
+			// the whole-program optimizations.
+			action := programAction{
+				CompilerBuildID: string(compilerBuildID),
+				LLVMVersion:     llvm.Version,
+				Config:          compilerConfig,
+				Options:         cacheKeyOptions(config.Options),
+				Target:          config.Target,
+				OptLevel:        optLevel,
+				GlobalValues:    globalValues,
+			}
+			for _, pkg := range lprogram.Sorted() {
+				action.Packages = append(action.Packages, packageActionIDJobs[pkg.ImportPath].result)
+			}
+			var err error
+			programID, err = hashAction(action)
+			if err != nil {
+				return err // shouldn't happen
+			}
+
+			if cacheProgram {
+				job.result = filepath.Join(cacheDir, "program-"+programID+".bc")
+				unlock := lock(job.result + ".lock")
+				defer unlock()
+
+				var info programCacheInfo
+				ok, err := readCacheInfo(job.result+".json", &info)
+				if err != nil {
+					return err
+				}
+				if ok {
+					// Already cached. The module is only loaded when it is
+					// actually needed.
+					stackSizeLoads = info.StackSizeLoads
+					return nil
+				}
+			}
+
 			// Load and link all the bitcode files. This does not yet optimize
 			// anything, it only links the bitcode files together.
 			ctx := llvm.NewContext()
@@ -590,7 +672,7 @@ func Build(pkgName, outpath, tmpdir string, config *compileopts.Config) (BuildRe
 
 			// Run all optimization passes, which are much more effective now
 			// that the optimizer can see the whole program at once.
-			err := optimizeProgram(mod, config, globalValues)
+			err = optimizeProgram(mod, config, globalValues)
 			if err != nil {
 				return err
 			}
@@ -600,10 +682,40 @@ func Build(pkgName, outpath, tmpdir string, config *compileopts.Config) (BuildRe
 			if config.AutomaticStackSize() {
 				stackSizeLoads = transform.CreateStackSizeLoads(mod, config)
 			}
+
+			if cacheProgram {
+				// Store the optimized program in the cache. The info file
+				// is written last, as it marks the cache entry as complete.
+				buf := llvm.WriteThinLTOBitcodeToMemoryBuffer(mod)
+				defer buf.Dispose()
+				err := writeCacheFile(job.result, buf.Bytes())
+				if err != nil {
+					return err
+				}
+				return writeCacheInfo(job.result+".json", programCacheInfo{
+					StackSizeLoads: stackSizeLoads,
+				})
+			}
 			return nil
 		},
 	}
 
+	// loadProgram loads the optimized program from the cache, if it wasn't
+	// created in this build.
+	loadProgram := func() error {
+		if !mod.IsNil() {
+			return nil
+		}
+		ctx := llvm.NewContext()
+		m, err := ctx.ParseBitcodeFile(programJob.result)
+		if err != nil {
+			ctx.Dispose()
+			return fmt.Errorf("failed to load cached program: %w", err)
+		}
+		mod = m
+		return nil
+	}
+
 	// Create the output directory, if needed
 	if err := os.MkdirAll(filepath.Dir(outpath), 0777); err != nil {
 		return result, err
@@ -618,9 +730,17 @@ func Build(pkgName, outpath, tmpdir string, config *compileopts.Config) (BuildRe
 		if err != nil {
 			return result, err
 		}
+		err = loadProgram()
+		if err != nil {
+			return result, err
+		}
+		if config.Options.Manifest != "" {
+			result.Manifest = newManifest(config, lprogram, packageActionIDJobs)
+		}
 		// Generate output.
 		switch outext {
 		case ".o":
+			result.BinaryFormat = "object"
 			llvmBuf, err := machine.EmitToMemoryBuffer(mod, llvm.ObjectFile)
 			if err != nil {
 				return result, err
@@ -628,10 +748,12 @@ func Build(pkgName, outpath, tmpdir string, config *compileopts.Config) (BuildRe
 			defer llvmBuf.Dispose()
 			return result, os.WriteFile(outpath, llvmBuf.Bytes(), 0666)
 		case ".bc":
+			result.BinaryFormat = "llvm-bitcode"
 			buf := llvm.WriteThinLTOBitcodeToMemoryBuffer(mod)
 			defer buf.Dispose()
 			return result, os.WriteFile(outpath, buf.Bytes(), 0666)
 		case ".ll":
+			result.BinaryFormat = "llvm-ir"
 			data := []byte(mod.String())
 			return result, os.WriteFile(outpath, data, 0666)
 		default:
@@ -649,7 +771,13 @@ func Build(pkgName, outpath, tmpdir string, config *compileopts.Config) (BuildRe
 		description:  "generate output file",
 		dependencies: []*compileJob{programJob},
 		result:       objfile,
-		run: func(*compileJob) error {
+		run: func(job *compileJob) error {
+			if programJob.result != "" {
+				// The program is stored in the cache in the same format, so
+				// it can be passed to the linker directly.
+				job.result = programJob.result
+				return nil
+			}
 			llvmBuf := llvm.WriteThinLTOBitcodeToMemoryBuffer(mod)
 			defer llvmBuf.Dispose()
 			return os.WriteFile(objfile, llvmBuf.Bytes(), 0666)
@@ -751,23 +879,31 @@ func Build(pkgName, outpath, tmpdir string, config *compileopts.Config) (BuildRe
 	}
 
 	// Strip debug information with -no-debug.
+	var stripFlags []string
 	if hasDebug && !config.Debug() {
 		if config.Target.Linker == "wasm-ld" {
 			// Don't just strip debug information, also compress relocations
 			// while we're at it. Relocations can only be compressed when debug
 			// information is stripped.
-			ldflags = append(ldflags, "--strip-debug", "--compress-relocations")
+			stripFlags = []string{"--strip-debug", "--compress-relocations"}
 		} else if config.Target.Linker == "ld.lld" {
 			// ld.lld is also used on Linux.
-			ldflags = append(ldflags, "--strip-debug")
+			stripFlags = []string{"--strip-debug"}
 		} else {
 			// Other linkers may have different flags.
 			return result, errors.New("cannot remove debug information: unknown linker: " + config.Target.Linker)
 		}
 	}
+	if !config.Symtab() {
+		// With -symtab, debug information is needed to create the symbol
+		// table so it is stripped in a separate step.
+		ldflags = append(ldflags, stripFlags...)
+	}
 
 	// Create a linker job, which links all object files together and does some
 	// extra stuff that can only be done after linking.
+	var calculatedStacks []string
+	var stackSizes map[string]functionStackSize
 	linkJob := &compileJob{
 		description:  "link",
 		dependencies: linkerDependencies,
@@ -808,20 +944,74 @@ func Build(pkgName, outpath, tmpdir string, config *compileopts.Config) (BuildRe
 				ldflags = append(ldflags,
 					"-mllvm", "--rotation-max-header-size=0")
 			}
-			if config.Options.PrintCommands != nil {
-				config.Options.PrintCommands(config.Target.Linker, ldflags...)
+
+			// Check whether this program was linked before with the same
+			// inputs, in which case the result can be copied from the cache.
+			action, err := newLinkAction(programID, config.Target.Linker, ldflags, tmpdir)
+			if err != nil {
+				return err
+			}
+			action.WasmOpt = goenv.Get("WASMOPT")
+			action.WasmTools = goenv.Get("WASMTOOLS")
+			linkID, err := hashAction(action)
+			if err != nil {
+				return err // shouldn't happen
+			}
+			cachedExecutable := filepath.Join(cacheDir, "link-"+linkID)
+			unlock := lock(cachedExecutable + ".lock")
+			defer unlock()
+			var info linkCacheInfo
+			cached, err := readCacheInfo(cachedExecutable+".json", &info)
+			if err != nil {
+				return err
+			}
+			if cached {
+				err := copyCacheFile(result.Executable, cachedExecutable)
+				if err != nil {
+					return err
+				}
+				if info.BinarySuffix != "" {
+					result.Binary = result.Executable + info.BinarySuffix
+					err := copyCacheFile(result.Binary, cachedExecutable+info.BinarySuffix)
+					if err != nil {
+						return err
+					}
+				}
+				if config.Options.PrintStacks {
+					// The stack sizes are read from the executable, but the
+					// function names are taken from the program.
+					err := loadProgram()
+					if err != nil {
+						return err
+					}
+					calculatedStacks, stackSizes, err = determineStackSizes(mod, result.Executable)
+					if err != nil {
+						return err
+					}
+				}
+				return nil
+			}
+
+			if config.Symtab() {
+				err = linkWithSymbolTable(config, compilerConfig, ldflags, stripFlags, result.Executable, tmpdir)
+			} else {
+				if config.Options.PrintCommands != nil {
+					config.Options.PrintCommands(config.Target.Linker, ldflags...)
+				}
+				err = link(config.Target.Linker, ldflags...)
 			}
-			err = link(config.Target.Linker, ldflags...)
 			if err != nil {
 				return err
 			}
 
-			var calculatedStacks []string
-			var stackSizes map[string]functionStackSize
 			if config.Options.PrintStacks || config.AutomaticStackSize() {
 				// Try to determine stack sizes at compile time.
 				// Don't do this by default as it usually doesn't work on
 				// unsupported architectures.
+				err := loadProgram()
+				if err != nil {
+					return err
+				}
 				calculatedStacks, stackSizes, err = determineStackSizes(mod, result.Executable)
 				if err != nil {
 					return err
@@ -945,44 +1135,20 @@ func Build(pkgName, outpath, tmpdir string, config *compileopts.Config) (BuildRe
 				}
 			}
 
-			// Print code size if requested.
-			if config.Options.PrintSizes != "" {
-				sizes, err := loadProgramSize(result.Executable, result.PackagePathMap)
+			// Store the linked program in the cache. The info file is written
+			// last, as it marks the cache entry as complete.
+			err = copyCacheFile(cachedExecutable, result.Executable)
+			if err != nil {
+				return err
+			}
+			info.BinarySuffix = strings.TrimPrefix(result.Binary, result.Executable)
+			if info.BinarySuffix != "" {
+				err := copyCacheFile(cachedExecutable+info.BinarySuffix, result.Binary)
 				if err != nil {
 					return err
 				}
-				switch config.Options.PrintSizes {
-				case "short":
-					fmt.Printf("   code    data     bss |   flash     ram\n")
-					fmt.Printf("%7d %7d %7d | %7d %7d\n", sizes.Code+sizes.ROData, sizes.Data, sizes.BSS, sizes.Flash(), sizes.RAM())
-				case "full":
-					if !config.Debug() {
-						fmt.Println("warning: data incomplete, remove the -no-debug flag for more detail")
-					}
-					fmt.Printf("   code  rodata    data     bss |   flash     ram | package\n")
-					fmt.Printf("------------------------------- | --------------- | -------\n")
-					for _, name := range sizes.sortedPackageNames() {
-						pkgSize := sizes.Packages[name]
-						fmt.Printf("%7d %7d %7d %7d | %7d %7d | %s\n", pkgSize.Code, pkgSize.ROData, pkgSize.Data, pkgSize.BSS, pkgSize.Flash(), pkgSize.RAM(), name)
-					}
-					fmt.Printf("------------------------------- | --------------- | -------\n")
-					fmt.Printf("%7d %7d %7d %7d | %7d %7d | total\n", sizes.Code, sizes.ROData, sizes.Data, sizes.BSS, sizes.Code+sizes.ROData+sizes.Data, sizes.Data+sizes.BSS)
-				case "html":
-					const filename = "size-report.html"
-					err := writeSizeReport(sizes, filename, pkgName)
-					if err != nil {
-						return err
-					}
-					fmt.Println("Wrote size report to", filename)
-				}
-			}
-
-			// Print goroutine stack sizes, as far as possible.
-			if config.Options.PrintStacks {
-				printStacks(calculatedStacks, stackSizes)
 			}
-
-			return nil
+			return writeCacheInfo(cachedExecutable+".json", info)
 		},
 	}
 
@@ -994,11 +1160,97 @@ func Build(pkgName, outpath, tmpdir string, config *compileopts.Config) (BuildRe
 		return result, err
 	}
 
+	if config.Options.Manifest != "" {
+		result.Manifest = newManifest(config, lprogram, packageActionIDJobs)
+	}
+
+	// Print code size if requested.
+	if config.Options.PrintSizes != "" || result.Manifest != nil || budget != nil {
+		sizes, err := loadProgramSize(result.Executable, result.PackagePathMap)
+		if err != nil {
+			return result, err
+		}
+		if result.Manifest != nil {
+			result.Manifest.setSize(sizes)
+		}
+		if config.Options.PrintSizes == "full" || config.Options.PrintSizes == "html" {
+			// Determine why each symbol is linked in, using the optimized
+			// program.
+			err := loadProgram()
+			if err != nil {
+				return result, err
+			}
+			sizes.addSymbolChains(symbolChains(mod))
+		}
+		switch config.Options.PrintSizes {
+		case "short":
+			fmt.Printf("   code    data     bss |   flash     ram\n")
+			fmt.Printf("%7d %7d %7d | %7d %7d\n", sizes.Code+sizes.ROData, sizes.Data, sizes.BSS, sizes.Flash(), sizes.RAM())
+		case "full":
+			if !config.Debug() {
+				fmt.Println("warning: data incomplete, remove the -no-debug flag for more detail")
+			}
+			fmt.Printf("   code  rodata    data     bss |   flash     ram | package\n")
+			fmt.Printf("------------------------------- | --------------- | -------\n")
+			for _, name := range sizes.sortedPackageNames() {
+				pkgSize := sizes.Packages[name]
+				fmt.Printf("%7d %7d %7d %7d | %7d %7d | %s\n", pkgSize.Code, pkgSize.ROData, pkgSize.Data, pkgSize.BSS, pkgSize.Flash(), pkgSize.RAM(), name)
+			}
+			fmt.Printf("------------------------------- | --------------- | -------\n")
+			fmt.Printf("%7d %7d %7d %7d | %7d %7d | total\n", sizes.Code, sizes.ROData, sizes.Data, sizes.BSS, sizes.Code+sizes.ROData+sizes.Data, sizes.Data+sizes.BSS)
+			if len(sizes.Symbols) != 0 {
+				fmt.Println()
+				printSymbolSizes(os.Stdout, sizes.Symbols)
+			}
+		case "html":
+			const filename = "size-report.html"
+			err := writeSizeReport(sizes, filename, pkgName)
+			if err != nil {
+				return result, err
+			}
+			fmt.Println("Wrote size report to", filename)
+		}
+
+		// Check the size budget, if there is one. This is done after printing
+		// sizes, so that the full size information is still available.
+		if budget != nil {
+			err := budget.check(sizes)
+			if err != nil {
+				return result, err
+			}
+		}
+	}
+
+	// Print the heap allocations that remain after optimization.
+	if config.Options.AllocReport == "text" || config.Options.AllocReport == "json" {
+		err := loadProgram()
+		if err != nil {
+			return result, err
+		}
+		report := newAllocReport(transform.HeapAllocs(mod, config.MaxStackAlloc()))
+		if config.Options.AllocReport == "json" {
+			err = report.WriteJSON(os.Stdout)
+		} else {
+			err = report.WriteText(os.Stdout)
+		}
+		if err != nil {
+			return result, err
+		}
+	}
+
+	// Print goroutine stack sizes, as far as possible.
+	if config.Options.PrintStacks {
+		printStacks(calculatedStacks, stackSizes)
+	}
+
 	// Get an Intel .hex file or .bin file from the .elf file.
 	outputBinaryFormat := config.BinaryFormat(outext)
+	result.BinaryFormat = outputBinaryFormat
 	switch outputBinaryFormat {
 	case "elf":
-		// do nothing, file is already in ELF format
+		// Nothing to convert, the linker output (usually ELF) is used as-is.
+		result.BinaryFormat = executableFormat(config)
+		return result, nil
 	case "hex", "bin":
 		// Extract raw binary, either encoding it as a hex file or as a raw
 		// firmware file.
@@ -1032,6 +1284,7 @@ func Build(pkgName, outpath, tmpdir string, config *compileopts.Config) (BuildRe
 	default:
 		return result, fmt.Errorf("unknown output binary format: %s", outputBinaryFormat)
 	}
+	result.ConvertedFrom = result.Executable
 
 	return result, nil
 }
@@ -0,0 +1,318 @@
+package builder
+
+// This file implements caching of the whole-program steps of a build: the
+// optimized program (after interp and transform.Optimize) and the linked
+// executable. Packages are cached separately, see packageAction.
+//
+// Both caches are content-addressed: the cache key is a hash over all the
+// inputs of the step, so a cached file is never stale and never needs to be
+// invalidated. Like the package cache, the files are stored in GOCACHE, which
+// means that GOCACHE=off disables the cache and tinygo clean removes it.
+
+import (
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/tinygo-org/tinygo/compileopts"
+	"github.com/tinygo-org/tinygo/compiler"
+)
+
+// programAction is the struct that is serialized to JSON and hashed, to work
+// as a cache key of the optimized program. It should contain all the
+// information that goes into the program after all packages are compiled.
+type programAction struct {
+	CompilerBuildID string
+	LLVMVersion     string
+	Config          *compiler.Config
+	Options         compileopts.Options
+	Target          *compileopts.TargetSpec
+	OptLevel        string
+	Packages        []string                     // action IDs of all packages, in initialization order
+	GlobalValues    map[string]map[string]string // values from -ldflags="-X ..."
+}
+
+// programCacheInfo is stored next to the optimized program in the cache, for
+// information that is determined while optimizing the program.
+type programCacheInfo struct {
+	StackSizeLoads []string
+}
+
+// linkAction is the struct that is serialized to JSON and hashed, to work as a
+// cache key of the linked executable.
+type linkAction struct {
+	ProgramID string            // cache key of the optimized program
+	Linker    string            // linker used (ld.lld, wasm-ld, etc)
+	LDFlags   []string          // linker flags, with input files replaced by their hash
+	Files     map[string]string // hashes of other files used while linking, like linker scripts
+	WasmOpt   string            // path to wasm-opt, for wasm binaries
+	WasmTools string            // path to wasm-tools, for component-model binaries
+}
+
+// linkCacheInfo is stored next to the linked executable in the cache.
+type linkCacheInfo struct {
+	// The suffix of the final binary, if it is not the executable itself
+	// (for example after running wasm-opt).
+	BinarySuffix string
+}
+
+// cacheKeyOptions returns a copy of the options with all fields cleared that
+// don't affect the build output, such as flags that are passed to the program
+// when running it or that only print information. This avoids unnecessary
+// cache misses.
+func cacheKeyOptions(options *compileopts.Options) compileopts.Options {
+	opts := *options
+	opts.Directory = ""
+	opts.Work = false
+	opts.Serial = ""
+	opts.PrintSizes = ""
+	opts.SizeBudget = ""
+	opts.PrintStacks = false
+	opts.AllocReport = ""
+	opts.PrintJSON = false
+	opts.Manifest = ""
+	opts.Programmer = ""
+	opts.OpenOCDCommands = nil
+	opts.Monitor = false
+	opts.BaudRate = 0
+	opts.Timeout = 0
+	opts.InterpTimeout = 0
+
+	// Test flags that are passed to the test binary.
+	test := &opts.TestConfig
+	test.CompileOnly = false
+	test.Verbose = false
+	test.Short = false
+	test.RunRegexp = ""
+	test.SkipRegexp = ""
+	test.Count = nil
+	test.BenchRegexp = ""
+	test.BenchTime = ""
+	test.BenchMem = false
+	test.Shuffle = ""
+	test.FuzzTime = ""
+	test.CoverProfile = ""
+	test.JSON = false
+	return opts
+}
+
+// hashAction returns the hash of the JSON representation of the given action
+// struct, to be used as a cache key.
+func hashAction(action interface{}) (string, error) {
+	buf, err := json.Marshal(action)
+	if err != nil {
+		return "", err
+	}
+	hash := sha512.Sum512_224(buf)
+	return hex.EncodeToString(hash[:]), nil
+}
+
+// Linker script commands that refer to other files.
+var linkerScriptInclude = regexp.MustCompile(`\bINCLUDE\s+"?([^"\s;]+)"?`)
+
+// newLinkAction returns the cache key for linking the given input files with
+// the given flags. Input files are replaced with their content hash and the
+// tmpdir path is replaced with a placeholder, so that the key is the same for
+// every build. Linker scripts and libraries that are referenced from the flags
+// are also hashed.
+func newLinkAction(programID, linker string, ldflags []string, tmpdir string) (*linkAction, error) {
+	action := &linkAction{
+		ProgramID: programID,
+		Linker:    linker,
+		Files:     make(map[string]string),
+	}
+
+	// Collect library search paths, used to find linker scripts and libraries.
+	var searchPaths []string
+	for i, flag := range ldflags {
+		switch {
+		case flag == "-L" && i+1 < len(ldflags):
+			searchPaths = append(searchPaths, ldflags[i+1])
+		case strings.HasPrefix(flag, "-L") && len(flag) > 2:
+			searchPaths = append(searchPaths, flag[2:])
+		}
+	}
+	find := func(name string) string {
+		if filepath.IsAbs(name) {
+			return name
+		}
+		if _, err := os.Stat(name); err == nil {
+			return name
+		}
+		for _, dir := range searchPaths {
+			path := filepath.Join(dir, name)
+			if _, err := os.Stat(path); err == nil {
+				return path
+			}
+		}
+		return ""
+	}
+
+	// Hash a linker script, and all the linker scripts it includes.
+	var addLinkerScript func(name string) error
+	addLinkerScript = func(name string) error {
+		path := find(name)
+		if path == "" {
+			// Let the linker report this error.
+			return nil
+		}
+		if _, ok := action.Files[path]; ok {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		hash := sha512.Sum512_224(data)
+		action.Files[path] = hex.EncodeToString(hash[:])
+		for _, match := range linkerScriptInclude.FindAllStringSubmatch(string(data), -1) {
+			if err := addLinkerScript(match[1]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	// Hash a library passed with -l, if it can be found.
+	addLibrary := func(name string) error {
+		for _, filename := range []string{"lib" + name + ".a", "lib" + name + ".so", name + ".lib"} {
+			if path := find(filename); path != "" {
+				hash, err := hashFile(path)
+				if err != nil {
+					return err
+				}
+				action.Files[path] = hash
+			}
+		}
+		return nil
+	}
+
+	for i := 0; i < len(ldflags); i++ {
+		flag := ldflags[i]
+		switch {
+		case flag == "-o" && i+1 < len(ldflags):
+			// The output path is different for every build.
+			action.LDFlags = append(action.LDFlags, flag)
+			i++
+			continue
+		case flag == "-T" && i+1 < len(ldflags):
+			if err := addLinkerScript(ldflags[i+1]); err != nil {
+				return nil, err
+			}
+		case strings.HasPrefix(flag, "-T") && len(flag) > 2:
+			if err := addLinkerScript(flag[2:]); err != nil {
+				return nil, err
+			}
+		case strings.HasPrefix(flag, "--script="):
+			if err := addLinkerScript(flag[len("--script="):]); err != nil {
+				return nil, err
+			}
+		case flag == "-l" && i+1 < len(ldflags):
+			if err := addLibrary(ldflags[i+1]); err != nil {
+				return nil, err
+			}
+		case strings.HasPrefix(flag, "-l") && len(flag) > 2:
+			if err := addLibrary(flag[2:]); err != nil {
+				return nil, err
+			}
+		case !strings.HasPrefix(flag, "-"):
+			// Probably an input file (object file or library). Use the hash of
+			// the contents instead of the path.
+			if st, err := os.Stat(flag); err == nil && st.Mode().IsRegular() {
+				hash, err := hashFile(flag)
+				if err != nil {
+					return nil, err
+				}
+				action.LDFlags = append(action.LDFlags, "file:"+hash)
+				continue
+			}
+		}
+		// Paths in the temporary directory are different for every build.
+		flag = strings.ReplaceAll(flag, tmpdir, "$TMPDIR")
+		action.LDFlags = append(action.LDFlags, flag)
+	}
+	return action, nil
+}
+
+// writeCacheFile atomically writes data to the given path in the cache, so
+// that other TinyGo processes never see a partially written file.
+func writeCacheFile(path string, data []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if err2 := f.Close(); err == nil {
+		err = err2
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// copyCacheFile copies the file at src to dst, atomically if dst is in the
+// cache.
+func copyCacheFile(dst, src string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	st, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	out, err := os.CreateTemp(filepath.Dir(dst), filepath.Base(dst)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, in)
+	if err2 := out.Close(); err == nil {
+		err = err2
+	}
+	if err == nil {
+		// Keep the executable bit.
+		err = os.Chmod(out.Name(), st.Mode().Perm()|0o444)
+	}
+	if err != nil {
+		os.Remove(out.Name())
+		return err
+	}
+	return os.Rename(out.Name(), dst)
+}
+
+// readCacheInfo reads the JSON file with extra information next to a cached
+// file. It returns false if the file does not exist (a cache miss).
+func readCacheInfo(path string, info interface{}) (bool, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	if err := json.Unmarshal(data, info); err != nil {
+		// Corrupt cache entry, treat it as a cache miss.
+		return false, nil
+	}
+	return true, nil
+}
+
+// writeCacheInfo writes the extra information for a cached file. It must be
+// written after the cached file itself, as its presence marks a complete cache
+// entry.
+func writeCacheInfo(path string, info interface{}) error {
+	data, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+	return writeCacheFile(path, data)
+}
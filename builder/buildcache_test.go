@@ -0,0 +1,53 @@
+package builder
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Test that the cache key for linking only depends on the contents of the
+// input files, and not on the temporary directory they are stored in.
+func TestLinkAction(t *testing.T) {
+	scripts := t.TempDir()
+	writeFile := func(path, data string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(data), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFile(filepath.Join(scripts, "main.ld"), "INCLUDE \"common.ld\"\n")
+	writeFile(filepath.Join(scripts, "common.ld"), "SECTIONS {}\n")
+
+	linkID := func(object string) string {
+		t.Helper()
+		tmpdir := t.TempDir()
+		objfile := filepath.Join(tmpdir, "main.o")
+		writeFile(objfile, object)
+		ldflags := []string{"-L", scripts, "-T", "main.ld", "-o", filepath.Join(tmpdir, "main"), objfile, "--thinlto-cache-dir=" + filepath.Join(tmpdir, "thinlto")}
+		action, err := newLinkAction("program", "ld.lld", ldflags, tmpdir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		id, err := hashAction(action)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+
+	id1 := linkID("object")
+	if id2 := linkID("object"); id1 != id2 {
+		t.Errorf("cache key differs between builds: %s != %s", id1, id2)
+	}
+	if id2 := linkID("other object"); id1 == id2 {
+		t.Error("cache key doesn't change when an input file changes")
+	}
+
+	// Changing a linker script that is included from another linker script
+	// should also result in a different cache key.
+	writeFile(filepath.Join(scripts, "common.ld"), "SECTIONS { .text : {} }\n")
+	if id2 := linkID("object"); id1 == id2 {
+		t.Error("cache key doesn't change when an included linker script changes")
+	}
+}
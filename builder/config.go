@@ -24,6 +24,18 @@ func NewConfig(options *compileopts.Options) (*compileopts.Config, error) {
 		spec.OpenOCDCommands = options.OpenOCDCommands
 	}
 
+	if options.Scheduler == "threads" && (spec.GOOS != "linux" || spec.Libc != "musl") {
+		// Goroutines are implemented using pthreads, which is only supported
+		// for Linux programs linked against musl.
+		return nil, fmt.Errorf("scheduler=threads is only supported on linux")
+	}
+
+	if options.TestConfig.FuzzRegexp != "" && spec.GOOS != "linux" && spec.GOOS != "wasip1" {
+		// Fuzzing needs a filesystem to read and write the corpus, and has only
+		// been tested on these systems.
+		return nil, fmt.Errorf("-fuzz is only supported on linux and wasip1, not on %s", spec.GOOS)
+	}
+
 	// Version range supported by TinyGo.
 	const minorMin = 19
 	const minorMax = 24
@@ -51,10 +63,17 @@ func NewConfig(options *compileopts.Options) (*compileopts.Config, error) {
 		return nil, fmt.Errorf("cannot compile with Go toolchain version go%d.%d (TinyGo was built using toolchain version %s)", gorootMajor, gorootMinor, runtime.Version())
 	}
 
-	return &compileopts.Config{
+	config := &compileopts.Config{
 		Options:        options,
 		Target:         spec,
 		GoMinorVersion: gorootMinor,
 		TestConfig:     options.TestConfig,
-	}, nil
+	}
+	if (config.GC() == "incremental" || config.GC() == "compacting") && config.Scheduler() == "threads" {
+		// The incremental GC relies on goroutines not running in parallel
+		// with a GC step, and compaction can't stop other threads from
+		// accessing the objects it moves.
+		return nil, fmt.Errorf("gc=%s is not supported with scheduler=threads", config.GC())
+	}
+	return config, nil
 }
@@ -55,3 +55,45 @@ func replaceElfSection(executable string, sectionName string, data []byte) error
 	_, err = fp.WriteAt(data, int64(section.Offset))
 	return err
 }
+
+// replaceElfSymbolData overwrites the contents of the given (data) symbol in
+// the executable. The new data must have the same size as the symbol.
+func replaceElfSymbolData(executable string, symbolName string, data []byte) error {
+	elfFile, err := elf.Open(executable)
+	if err != nil {
+		return err
+	}
+	defer elfFile.Close()
+
+	symbols, err := elfFile.Symbols()
+	if err != nil {
+		return err
+	}
+	for _, symbol := range symbols {
+		if symbol.Name != symbolName {
+			continue
+		}
+		if symbol.Size != uint64(len(data)) {
+			return fmt.Errorf("expected symbol %s to have size %d, was actually %d", symbolName, len(data), symbol.Size)
+		}
+		if int(symbol.Section) >= len(elfFile.Sections) {
+			return fmt.Errorf("symbol %s is not in a regular section", symbolName)
+		}
+		section := elfFile.Sections[symbol.Section]
+		if section.Type == elf.SHT_NOBITS || section.Size != section.FileSize {
+			return fmt.Errorf("symbol %s is not stored in the file", symbolName)
+		}
+		if symbol.Value < section.Addr || symbol.Value+symbol.Size > section.Addr+section.Size {
+			return fmt.Errorf("symbol %s is outside of section %s", symbolName, section.Name)
+		}
+
+		fp, err := os.OpenFile(executable, os.O_RDWR, 0)
+		if err != nil {
+			return err
+		}
+		defer fp.Close()
+		_, err = fp.WriteAt(data, int64(section.Offset+symbol.Value-section.Addr))
+		return err
+	}
+	return fmt.Errorf("could not find symbol %s", symbolName)
+}
@@ -0,0 +1,684 @@
+package builder
+
+// This file decodes heap dumps printed by debug.PrintHeapDump, for the tinygo
+// heapdump command. See src/runtime/heapdump_blocks.go for the format of heap
+// dumps.
+//
+// Heap objects don't store their type, so types are found by following typed
+// pointers in the same way as the GC does: starting at all globals (using the
+// DWARF debug information of the program) and then recursively through all
+// objects of which the type is known. Objects that are only referenced through
+// an unsafe.Pointer (such as map buckets and interface values) are followed
+// conservatively and are reported with an unknown type.
+
+import (
+	"bufio"
+	"debug/dwarf"
+	"debug/elf"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// HeapDump is a heap dump as printed by debug.PrintHeapDump.
+type HeapDump struct {
+	PointerSize int
+	BlockSize   uint64
+	HeaderSize  uint64 // bytes at the start of each object used by the GC
+	HeapStart   uint64
+	HeapEnd     uint64
+	Globals     []HeapDumpRange
+	Objects     []HeapDumpObject // sorted by address
+	Free        []HeapDumpRange  // runs of free blocks, sorted by address
+	memory      []heapDumpMemory // sorted by address
+}
+
+// HeapDumpRange is a range of memory in a heap dump.
+type HeapDumpRange struct {
+	Start uint64
+	End   uint64
+}
+
+// HeapDumpObject is a single object in a heap dump.
+type HeapDumpObject struct {
+	Addr   uint64 // address of the first block of the object
+	Blocks uint64
+	Layout uint64 // pointer layout with -gc=precise, 0 if not known
+}
+
+// heapDumpMemory is a contiguous range of memory for which the contents are
+// included in the heap dump.
+type heapDumpMemory struct {
+	addr uint64
+	data []byte
+}
+
+// ParseHeapDump reads a heap dump from the output of a program. Output before
+// and after the heap dump is ignored, so this can be a log of all serial output
+// of the program.
+func ParseHeapDump(r io.Reader) (*HeapDump, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1024*1024)
+	var dump *HeapDump
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if dump == nil {
+			// Look for the start of the heap dump, which may be preceded by
+			// other output on the same line.
+			index := strings.Index(line, "tinygo-heapdump ")
+			if index < 0 {
+				continue
+			}
+			fields := strings.Fields(line[index:])
+			if len(fields) != 5 || fields[1] != "1" {
+				return nil, fmt.Errorf("line %d: unsupported heap dump header: %s", lineNum, line[index:])
+			}
+			values, err := parseHeapDumpNumbers(fields[2:])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNum, err)
+			}
+			dump = &HeapDump{
+				PointerSize: int(values[0]),
+				BlockSize:   values[1],
+				HeaderSize:  values[2],
+			}
+			if dump.PointerSize != 2 && dump.PointerSize != 4 && dump.PointerSize != 8 {
+				return nil, fmt.Errorf("line %d: unsupported pointer size %d", lineNum, dump.PointerSize)
+			}
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if fields[0] == "end" {
+			sort.Slice(dump.memory, func(i, j int) bool {
+				return dump.memory[i].addr < dump.memory[j].addr
+			})
+			sort.Slice(dump.Objects, func(i, j int) bool {
+				return dump.Objects[i].Addr < dump.Objects[j].Addr
+			})
+			return dump, nil
+		}
+		if fields[0] == "mem" {
+			if len(fields) != 3 {
+				return nil, fmt.Errorf("line %d: invalid mem line", lineNum)
+			}
+			addr, err := strconv.ParseUint(fields[1], 0, 64)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNum, err)
+			}
+			data, err := hex.DecodeString(fields[2])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNum, err)
+			}
+			dump.addMemory(addr, data)
+			continue
+		}
+		values, err := parseHeapDumpNumbers(fields[1:])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNum, err)
+		}
+		switch {
+		case fields[0] == "heap" && len(values) == 2:
+			dump.HeapStart, dump.HeapEnd = values[0], values[1]
+		case fields[0] == "globals" && len(values) == 2:
+			dump.Globals = append(dump.Globals, HeapDumpRange{values[0], values[1]})
+		case fields[0] == "object" && len(values) == 3:
+			dump.Objects = append(dump.Objects, HeapDumpObject{Addr: values[0], Blocks: values[1], Layout: values[2]})
+		case fields[0] == "free" && len(values) == 2:
+			dump.Free = append(dump.Free, HeapDumpRange{values[0], values[0] + values[1]*dump.BlockSize})
+		default:
+			return nil, fmt.Errorf("line %d: unknown heap dump line: %s", lineNum, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if dump == nil {
+		return nil, fmt.Errorf("no heap dump found")
+	}
+	return nil, fmt.Errorf("heap dump is incomplete")
+}
+
+// parseHeapDumpNumbers parses all fields as (decimal or hexadecimal) numbers.
+func parseHeapDumpNumbers(fields []string) ([]uint64, error) {
+	values := make([]uint64, len(fields))
+	for i, field := range fields {
+		value, err := strconv.ParseUint(field, 0, 64)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = value
+	}
+	return values, nil
+}
+
+// addMemory adds the contents of memory at the given address.
+func (d *HeapDump) addMemory(addr uint64, data []byte) {
+	if len(d.memory) != 0 {
+		last := &d.memory[len(d.memory)-1]
+		if last.addr+uint64(len(last.data)) == addr {
+			last.data = append(last.data, data...)
+			return
+		}
+	}
+	d.memory = append(d.memory, heapDumpMemory{addr: addr, data: data})
+}
+
+// read returns the contents of memory at the given address, or nil if it is
+// not included in the heap dump.
+func (d *HeapDump) read(addr, size uint64) []byte {
+	i := sort.Search(len(d.memory), func(i int) bool {
+		return d.memory[i].addr > addr
+	}) - 1
+	if i < 0 {
+		return nil
+	}
+	mem := d.memory[i]
+	offset := addr - mem.addr
+	if offset+size > uint64(len(mem.data)) {
+		return nil
+	}
+	return mem.data[offset : offset+size]
+}
+
+// findObject returns the index of the object that contains the given address,
+// or -1 if there is no such object.
+func (d *HeapDump) findObject(addr uint64) int {
+	if addr < d.HeapStart || addr >= d.HeapEnd {
+		return -1
+	}
+	i := sort.Search(len(d.Objects), func(i int) bool {
+		return d.Objects[i].Addr > addr
+	}) - 1
+	if i < 0 || addr >= d.Objects[i].Addr+d.Objects[i].Blocks*d.BlockSize {
+		return -1
+	}
+	return i
+}
+
+// HeapReport is the result of analyzing a heap dump, as printed by tinygo
+// heapdump.
+type HeapReport struct {
+	HeapBytes   uint64         // size of the heap, excluding GC metadata
+	UsedBytes   uint64         // bytes used by objects
+	FreeBytes   uint64         // bytes in free blocks
+	LargestFree uint64         // largest run of free blocks in bytes
+	Types       []HeapUsage    // memory per type, largest first
+	Globals     []HeapUsage    // memory kept alive per global, largest first
+	FreeRuns    []HeapFreeRuns // histogram of free runs, shortest first
+}
+
+// HeapUsage is the number of objects and bytes used by a type, or kept alive
+// by a global.
+type HeapUsage struct {
+	Name    string
+	Objects int
+	Bytes   uint64
+}
+
+// HeapFreeRuns is the number of runs of free blocks with a length between
+// MinBlocks and MaxBlocks (inclusive).
+type HeapFreeRuns struct {
+	MinBlocks uint64
+	MaxBlocks uint64
+	Count     int
+}
+
+// Names used in the report for objects of which the type or global isn't
+// known.
+const (
+	heapUnknownType   = "(unknown type)"
+	heapUnknownGlobal = "(not reachable from globals)"
+)
+
+// heapGlobal is a global variable from the DWARF debug information.
+type heapGlobal struct {
+	name string
+	addr uint64
+	typ  dwarf.Type
+}
+
+// heapAnalysis contains the state while finding the types of heap objects.
+type heapAnalysis struct {
+	dump      *HeapDump
+	order     binary.ByteOrder
+	readConst func(addr, size uint64) []byte // read constant data from the binary
+	symbolAt  func(addr uint64) string       // name of the global at the address
+	types     []dwarf.Type                   // type per object, nil if not known
+	roots     []string                       // global that keeps each object alive
+	worklist  []int                          // objects that must be scanned
+}
+
+// AnalyzeHeapDump finds out which types use the memory in the heap dump, and
+// which globals keep it alive, using the debug information in the binary that
+// printed the heap dump.
+func AnalyzeHeapDump(dump *HeapDump, path string) (*HeapReport, error) {
+	file, err := elf.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	data, err := file.DWARF()
+	if err != nil {
+		return nil, fmt.Errorf("could not read debug information of %s: %w", path, err)
+	}
+	globals, err := readHeapGlobals(data, file.ByteOrder, dump.PointerSize)
+	if err != nil {
+		return nil, fmt.Errorf("could not read debug information of %s: %w", path, err)
+	}
+	symbols, err := file.Symbols()
+	if err != nil {
+		return nil, err
+	}
+	var objects []elf.Symbol
+	for _, symbol := range symbols {
+		if elf.ST_TYPE(symbol.Info) == elf.STT_OBJECT && symbol.Size != 0 {
+			objects = append(objects, symbol)
+		}
+	}
+	sort.Slice(objects, func(i, j int) bool {
+		return objects[i].Value < objects[j].Value
+	})
+	symbolAt := func(addr uint64) string {
+		i := sort.Search(len(objects), func(i int) bool {
+			return objects[i].Value > addr
+		}) - 1
+		if i < 0 || addr >= objects[i].Value+objects[i].Size {
+			return ""
+		}
+		return objects[i].Name
+	}
+	readConst := func(addr, size uint64) []byte {
+		for _, section := range file.Sections {
+			if section.Flags&elf.SHF_ALLOC == 0 || section.Type == elf.SHT_NOBITS {
+				continue
+			}
+			if addr < section.Addr || addr+size > section.Addr+section.Size {
+				continue
+			}
+			buf := make([]byte, size)
+			if _, err := section.ReadAt(buf, int64(addr-section.Addr)); err != nil {
+				return nil
+			}
+			return buf
+		}
+		return nil
+	}
+	return dump.analyze(globals, file.ByteOrder, readConst, symbolAt), nil
+}
+
+// readHeapGlobals returns all global variables with a fixed address in the
+// DWARF debug information.
+func readHeapGlobals(data *dwarf.Data, order binary.ByteOrder, pointerSize int) ([]heapGlobal, error) {
+	var globals []heapGlobal
+	r := data.Reader()
+	for {
+		entry, err := r.Next()
+		if err != nil {
+			return nil, err
+		}
+		if entry == nil {
+			break
+		}
+		if entry.Tag != dwarf.TagVariable {
+			continue
+		}
+		// Only look at globals with a location of the form DW_OP_addr <addr>.
+		const DW_OP_addr = 0x03
+		location, ok := entry.Val(dwarf.AttrLocation).([]byte)
+		if !ok || len(location) != 1+pointerSize || location[0] != DW_OP_addr {
+			continue
+		}
+		typeOffset, ok := entry.Val(dwarf.AttrType).(dwarf.Offset)
+		if !ok {
+			continue
+		}
+		typ, err := data.Type(typeOffset)
+		if err != nil {
+			continue
+		}
+		name, _ := entry.Val(dwarf.AttrName).(string)
+		globals = append(globals, heapGlobal{
+			name: name,
+			addr: readHeapDumpUint(order, location[1:]),
+			typ:  typ,
+		})
+	}
+	sort.Slice(globals, func(i, j int) bool {
+		return globals[i].addr < globals[j].addr
+	})
+	return globals, nil
+}
+
+// readHeapDumpUint reads an unsigned integer of the size of the buffer.
+func readHeapDumpUint(order binary.ByteOrder, buf []byte) uint64 {
+	switch len(buf) {
+	case 2:
+		return uint64(order.Uint16(buf))
+	case 4:
+		return uint64(order.Uint32(buf))
+	case 8:
+		return order.Uint64(buf)
+	}
+	return 0
+}
+
+// analyze finds the types of all heap objects and creates a report.
+func (d *HeapDump) analyze(globals []heapGlobal, order binary.ByteOrder, readConst func(addr, size uint64) []byte, symbolAt func(addr uint64) string) *HeapReport {
+	a := &heapAnalysis{
+		dump:      d,
+		order:     order,
+		readConst: readConst,
+		symbolAt:  symbolAt,
+		types:     make([]dwarf.Type, len(d.Objects)),
+		roots:     make([]string, len(d.Objects)),
+	}
+
+	// Find all objects that are reachable through typed pointers in globals.
+	for _, global := range globals {
+		a.scanTyped(global.addr, global.typ, global.name)
+	}
+	a.scanWorklist()
+
+	// Some globals don't have debug information (for example, globals in C
+	// code), so also look at globals conservatively.
+	for _, globals := range d.Globals {
+		a.scanConservative(globals.Start, globals.End, 0, func(addr uint64) string {
+			if name := a.symbolAt(addr); name != "" {
+				return name
+			}
+			return "(globals)"
+		})
+	}
+	a.scanWorklist()
+
+	return a.report()
+}
+
+// scanWorklist scans all objects on the worklist, until it is empty.
+func (a *heapAnalysis) scanWorklist() {
+	for len(a.worklist) != 0 {
+		index := a.worklist[len(a.worklist)-1]
+		a.worklist = a.worklist[:len(a.worklist)-1]
+		start, end := a.objectData(index)
+		root := a.roots[index]
+		if typ := a.types[index]; typ != nil {
+			if !hasHeapPointers(typ) {
+				continue
+			}
+			size := uint64(typ.Size())
+			for addr := start; addr+size <= end; addr += size {
+				a.scanTyped(addr, typ, root)
+			}
+		} else {
+			a.scanConservative(start, end, a.dump.Objects[index].Layout, func(uint64) string {
+				return root
+			})
+		}
+	}
+}
+
+// objectData returns the range of the data of the object, after the header.
+func (a *heapAnalysis) objectData(index int) (start, end uint64) {
+	obj := a.dump.Objects[index]
+	return obj.Addr + a.dump.HeaderSize, obj.Addr + obj.Blocks*a.dump.BlockSize
+}
+
+// scanTyped looks for pointers to heap objects in the value of the given type
+// at the given address.
+func (a *heapAnalysis) scanTyped(addr uint64, typ dwarf.Type, root string) {
+	switch typ := typ.(type) {
+	case *dwarf.TypedefType:
+		a.scanTyped(addr, typ.Type, root)
+	case *dwarf.QualType:
+		a.scanTyped(addr, typ.Type, root)
+	case *dwarf.StructType:
+		for _, field := range typ.Field {
+			a.scanTyped(addr+uint64(field.ByteOffset), field.Type, root)
+		}
+	case *dwarf.ArrayType:
+		elemSize := uint64(typ.Type.Size())
+		if elemSize == 0 || typ.Count <= 0 || !hasHeapPointers(typ.Type) {
+			return
+		}
+		for i := uint64(0); i < uint64(typ.Count); i++ {
+			a.scanTyped(addr+i*elemSize, typ.Type, root)
+		}
+	case *dwarf.PtrType:
+		buf := a.dump.read(addr, uint64(a.dump.PointerSize))
+		if buf == nil {
+			return
+		}
+		ptr := readHeapDumpUint(a.order, buf)
+		index := a.dump.findObject(ptr)
+		if index < 0 {
+			return
+		}
+		var elem dwarf.Type
+		if start, _ := a.objectData(index); ptr == start && typ.Type != nil && typ.Type.Size() > 0 {
+			// Pointer to the start of an object, so the object is of the
+			// pointed-to type (or an array of it).
+			elem = typ.Type
+		}
+		a.reach(index, elem, root)
+	}
+}
+
+// scanConservative looks for pointers to heap objects in all words from start
+// to end, taking the layout into account if it is known.
+func (a *heapAnalysis) scanConservative(start, end, layout uint64, root func(addr uint64) string) {
+	pointerSize := uint64(a.dump.PointerSize)
+	align := pointerSize
+	if pointerSize == 2 {
+		// AVR, where pointers are only byte aligned.
+		align = 1
+	}
+	for addr := start; addr+pointerSize <= end; addr += align {
+		if !a.mayContainPointer(layout, (addr-start)/align) {
+			continue
+		}
+		buf := a.dump.read(addr, pointerSize)
+		if buf == nil {
+			continue
+		}
+		index := a.dump.findObject(readHeapDumpUint(a.order, buf))
+		if index >= 0 && a.roots[index] == "" {
+			a.reach(index, nil, root(addr))
+		}
+	}
+}
+
+// reach marks the object as reachable from the given global. If the type is
+// not nil and the type of the object wasn't known yet, the type is set.
+func (a *heapAnalysis) reach(index int, typ dwarf.Type, root string) {
+	changed := false
+	if a.roots[index] == "" {
+		a.roots[index] = root
+		changed = true
+	}
+	if typ != nil && a.types[index] == nil {
+		a.types[index] = typ
+		changed = true
+	}
+	if changed {
+		a.worklist = append(a.worklist, index)
+	}
+}
+
+// mayContainPointer returns whether the word at the given index in an object
+// may contain a pointer, according to the layout (see gc_precise.go in the
+// runtime).
+func (a *heapAnalysis) mayContainPointer(layout, index uint64) bool {
+	if layout == 0 {
+		return true
+	}
+	pointerSize := uint64(a.dump.PointerSize)
+	if layout&1 != 0 {
+		sizeFieldBits := map[uint64]uint64{2: 4, 4: 5, 8: 6}[pointerSize]
+		size := (layout >> 1) & (1<<sizeFieldBits - 1)
+		if size == 0 {
+			return false
+		}
+		bitmap := layout >> (1 + sizeFieldBits)
+		return (bitmap>>(index%size))&1 != 0
+	}
+	buf := a.readConst(layout, pointerSize)
+	if buf == nil {
+		return true
+	}
+	size := readHeapDumpUint(a.order, buf)
+	if size == 0 {
+		return false
+	}
+	index %= size
+	bits := a.readConst(layout+pointerSize+index/8, 1)
+	if bits == nil {
+		return true
+	}
+	return (bits[0]>>(index%8))&1 != 0
+}
+
+// hasHeapPointers returns whether values of the given type may contain a
+// pointer, to avoid scanning large arrays of integers.
+func hasHeapPointers(typ dwarf.Type) bool {
+	switch typ := typ.(type) {
+	case *dwarf.TypedefType:
+		return hasHeapPointers(typ.Type)
+	case *dwarf.QualType:
+		return hasHeapPointers(typ.Type)
+	case *dwarf.StructType:
+		for _, field := range typ.Field {
+			if hasHeapPointers(field.Type) {
+				return true
+			}
+		}
+		return false
+	case *dwarf.ArrayType:
+		return hasHeapPointers(typ.Type)
+	case *dwarf.PtrType:
+		return true
+	default:
+		return false
+	}
+}
+
+// heapTypeName returns the name of the type, as it is shown in a report.
+func heapTypeName(typ dwarf.Type) string {
+	if name := typ.Common().Name; name != "" {
+		return name
+	}
+	return typ.String()
+}
+
+// report creates the report after the types of all objects have been found.
+func (a *heapAnalysis) report() *HeapReport {
+	d := a.dump
+	report := &HeapReport{
+		HeapBytes: d.HeapEnd - d.HeapStart,
+		Types:     []HeapUsage{},
+		Globals:   []HeapUsage{},
+		FreeRuns:  []HeapFreeRuns{},
+	}
+	types := make(map[string]*HeapUsage)
+	globals := make(map[string]*HeapUsage)
+	add := func(m map[string]*HeapUsage, name string, bytes uint64) {
+		usage := m[name]
+		if usage == nil {
+			usage = &HeapUsage{Name: name}
+			m[name] = usage
+		}
+		usage.Objects++
+		usage.Bytes += bytes
+	}
+	for i, obj := range d.Objects {
+		bytes := obj.Blocks * d.BlockSize
+		report.UsedBytes += bytes
+		typeName := heapUnknownType
+		if typ := a.types[i]; typ != nil {
+			typeName = heapTypeName(typ)
+			start, end := a.objectData(i)
+			if end-start >= uint64(typ.Size())+d.BlockSize {
+				// The object is too big to be a single value (even when
+				// rounded up to the block size), so it is an array.
+				typeName = "[]" + typeName
+			}
+		}
+		add(types, typeName, bytes)
+		root := a.roots[i]
+		if root == "" {
+			root = heapUnknownGlobal
+		}
+		add(globals, root, bytes)
+	}
+	for _, usage := range types {
+		report.Types = append(report.Types, *usage)
+	}
+	for _, usage := range globals {
+		report.Globals = append(report.Globals, *usage)
+	}
+	for _, usages := range [][]HeapUsage{report.Types, report.Globals} {
+		sort.Slice(usages, func(i, j int) bool {
+			if usages[i].Bytes != usages[j].Bytes {
+				return usages[i].Bytes > usages[j].Bytes
+			}
+			return usages[i].Name < usages[j].Name
+		})
+	}
+
+	// Make a histogram of the free runs, with power of two buckets.
+	for _, free := range d.Free {
+		bytes := free.End - free.Start
+		report.FreeBytes += bytes
+		if bytes > report.LargestFree {
+			report.LargestFree = bytes
+		}
+		blocks := bytes / d.BlockSize
+		bucket := 0
+		for blocks>>(bucket+1) != 0 {
+			bucket++
+		}
+		for len(report.FreeRuns) <= bucket {
+			minBlocks := uint64(1) << len(report.FreeRuns)
+			report.FreeRuns = append(report.FreeRuns, HeapFreeRuns{MinBlocks: minBlocks, MaxBlocks: minBlocks*2 - 1})
+		}
+		report.FreeRuns[bucket].Count++
+	}
+	return report
+}
+
+// WriteText writes the report as human readable tables.
+func (r *HeapReport) WriteText(w io.Writer) error {
+	var sb strings.Builder
+	writeTable := func(kind string, usages []HeapUsage) {
+		fmt.Fprintf(&sb, " objects    bytes | %s\n", kind)
+		fmt.Fprintf(&sb, "----------------- | ----\n")
+		for _, usage := range usages {
+			fmt.Fprintf(&sb, "%8d %8d | %s\n", usage.Objects, usage.Bytes, usage.Name)
+		}
+	}
+	writeTable("type", r.Types)
+	sb.WriteString("\n")
+	writeTable("global", r.Globals)
+	sb.WriteString("\n")
+	fmt.Fprintf(&sb, "  blocks    count | free runs\n")
+	fmt.Fprintf(&sb, "----------------- | ----\n")
+	for _, runs := range r.FreeRuns {
+		blocks := strconv.FormatUint(runs.MinBlocks, 10)
+		if runs.MaxBlocks != runs.MinBlocks {
+			blocks += "-" + strconv.FormatUint(runs.MaxBlocks, 10)
+		}
+		fmt.Fprintf(&sb, "%8s %8d |\n", blocks, runs.Count)
+	}
+	fmt.Fprintf(&sb, "\nheap: %d bytes, %d in use, %d free, largest free run %d bytes\n", r.HeapBytes, r.UsedBytes, r.FreeBytes, r.LargestFree)
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
@@ -0,0 +1,118 @@
+package builder
+
+import (
+	"debug/dwarf"
+	"encoding/binary"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Heap dump of a 32-bit little endian system with the conservative GC.
+const testHeapDump = `some output of the program
+tinygo-heapdump 1 4 16 0
+heap 0x00002000 0x000020c0
+globals 0x00001000 0x00001008
+mem 0x00001000 0020000020200000
+object 0x00002000 1 0x0
+mem 0x00002000 1020000001000000ffffffffffffffff
+object 0x00002010 1 0x0
+mem 0x00002010 0000000002000000ffffffffffffffff
+object 0x00002020 2 0x0
+mem 0x00002020 0000000000000000000000000000000040200000000000000000000000000000
+object 0x00002040 1 0x0
+mem 0x00002040 00000000000000000000000000000000
+object 0x00002050 1 0x0
+mem 0x00002050 00000000000000000000000000000000
+free 0x00002060 2
+object 0x00002080 1 0x0
+mem 0x00002080 00000000000000000000000000000000
+free 0x00002090 3
+end
+more output of the program
+`
+
+func TestHeapDump(t *testing.T) {
+	dump, err := ParseHeapDump(strings.NewReader(testHeapDump))
+	if err != nil {
+		t.Fatal("could not parse heap dump:", err)
+	}
+	if len(dump.Objects) != 6 || len(dump.Free) != 2 || len(dump.Globals) != 1 {
+		t.Fatalf("unexpected heap dump: %+v", dump)
+	}
+
+	// The type of main.list is *main.node, where main.node is a struct with a
+	// pointer to the next node. The second global (main.untyped) doesn't have
+	// debug information.
+	uint32Type := &dwarf.UintType{BasicType: dwarf.BasicType{CommonType: dwarf.CommonType{ByteSize: 4, Name: "uint32"}}}
+	nodeStruct := &dwarf.StructType{CommonType: dwarf.CommonType{ByteSize: 8}, Kind: "struct"}
+	nodeType := &dwarf.TypedefType{CommonType: dwarf.CommonType{ByteSize: 8, Name: "main.node"}, Type: nodeStruct}
+	nodePtr := &dwarf.PtrType{CommonType: dwarf.CommonType{ByteSize: 4}, Type: nodeType}
+	nodeStruct.Field = []*dwarf.StructField{
+		{Name: "next", Type: nodePtr, ByteOffset: 0},
+		{Name: "value", Type: uint32Type, ByteOffset: 4},
+	}
+	globals := []heapGlobal{{name: "main.list", addr: 0x1000, typ: nodePtr}}
+	symbolAt := func(addr uint64) string {
+		if addr == 0x1004 {
+			return "main.untyped"
+		}
+		return ""
+	}
+	report := dump.analyze(globals, binary.LittleEndian, func(addr, size uint64) []byte { return nil }, symbolAt)
+
+	expected := &HeapReport{
+		HeapBytes:   192,
+		UsedBytes:   112,
+		FreeBytes:   80,
+		LargestFree: 48,
+		Types: []HeapUsage{
+			{Name: "(unknown type)", Objects: 4, Bytes: 80},
+			{Name: "main.node", Objects: 2, Bytes: 32},
+		},
+		Globals: []HeapUsage{
+			{Name: "main.untyped", Objects: 2, Bytes: 48},
+			{Name: "(not reachable from globals)", Objects: 2, Bytes: 32},
+			{Name: "main.list", Objects: 2, Bytes: 32},
+		},
+		FreeRuns: []HeapFreeRuns{
+			{MinBlocks: 1, MaxBlocks: 1, Count: 0},
+			{MinBlocks: 2, MaxBlocks: 3, Count: 2},
+		},
+	}
+	if !reflect.DeepEqual(report, expected) {
+		t.Errorf("unexpected heap report:\n got: %+v\nwant: %+v", report, expected)
+	}
+
+	var out strings.Builder
+	if err := report.WriteText(&out); err != nil {
+		t.Fatal(err)
+	}
+	expectedText := ` objects    bytes | type
+----------------- | ----
+       4       80 | (unknown type)
+       2       32 | main.node
+
+ objects    bytes | global
+----------------- | ----
+       2       48 | main.untyped
+       2       32 | (not reachable from globals)
+       2       32 | main.list
+
+  blocks    count | free runs
+----------------- | ----
+       1        0 |
+     2-3        2 |
+
+heap: 192 bytes, 112 in use, 80 free, largest free run 48 bytes
+`
+	if out.String() != expectedText {
+		t.Errorf("unexpected text output:\n%s\nwant:\n%s", out.String(), expectedText)
+	}
+
+	// Incomplete heap dumps must be rejected.
+	_, err = ParseHeapDump(strings.NewReader(testHeapDump[:strings.Index(testHeapDump, "end")]))
+	if err == nil || err.Error() != "heap dump is incomplete" {
+		t.Errorf("expected an error for an incomplete heap dump, got: %v", err)
+	}
+}
@@ -0,0 +1,132 @@
+package builder
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/tinygo-org/tinygo/compileopts"
+	"github.com/tinygo-org/tinygo/goenv"
+	"github.com/tinygo-org/tinygo/loader"
+	"tinygo.org/x/go-llvm"
+)
+
+// Manifest is a machine-readable description of a build, as written by
+// tinygo build -manifest. It is intended for tools like release pipelines that
+// need to know what was built and how large it is, without parsing the output
+// of -size.
+type Manifest struct {
+	TinyGoVersion  string
+	LLVMVersion    string
+	GoMinorVersion int
+	Artifacts      []ManifestArtifact
+	Target         *compileopts.TargetSpec // target with all inherited targets merged in
+	Options        *compileopts.Options
+	Packages       []ManifestPackage // all packages in the program, in initialization order
+	Size           *ManifestSize     `json:",omitempty"` // not set for object files
+}
+
+// ManifestArtifact is a single file produced by the build.
+type ManifestArtifact struct {
+	Path   string
+	Format string // elf, hex, bin, uf2, wasm, etc
+	Size   int64
+	SHA256 string
+}
+
+// ManifestPackage is a single package that is part of the program.
+type ManifestPackage struct {
+	ImportPath string
+	ActionID   string // cache key of the compiled package
+}
+
+// ManifestSize is the size of the program, as printed by -size=short.
+type ManifestSize struct {
+	Code   uint64
+	ROData uint64
+	Data   uint64
+	BSS    uint64
+	Flash  uint64
+	RAM    uint64
+}
+
+// newManifest creates a manifest for the given program. The artifacts must be
+// added separately, once they're stored in their final location.
+func newManifest(config *compileopts.Config, lprogram *loader.Program, actionIDs map[string]*compileJob) *Manifest {
+	manifest := &Manifest{
+		TinyGoVersion:  goenv.Version(),
+		LLVMVersion:    llvm.Version,
+		GoMinorVersion: config.GoMinorVersion,
+		Target:         config.Target,
+		Options:        config.Options,
+	}
+	for _, pkg := range lprogram.Sorted() {
+		manifest.Packages = append(manifest.Packages, ManifestPackage{
+			ImportPath: pkg.ImportPath,
+			ActionID:   actionIDs[pkg.ImportPath].result,
+		})
+	}
+	return manifest
+}
+
+// setSize stores the size of the program in the manifest.
+func (m *Manifest) setSize(sizes *programSize) {
+	m.Size = &ManifestSize{
+		Code:   sizes.Code,
+		ROData: sizes.ROData,
+		Data:   sizes.Data,
+		BSS:    sizes.BSS,
+		Flash:  sizes.Flash(),
+		RAM:    sizes.RAM(),
+	}
+}
+
+// AddArtifact adds the file at the given path to the list of artifacts,
+// together with its size and hash.
+func (m *Manifest) AddArtifact(path, format string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	hash := sha256.New()
+	size, err := io.Copy(hash, f)
+	if err != nil {
+		return err
+	}
+	m.Artifacts = append(m.Artifacts, ManifestArtifact{
+		Path:   path,
+		Format: format,
+		Size:   size,
+		SHA256: hex.EncodeToString(hash.Sum(nil)),
+	})
+	return nil
+}
+
+// Write writes the manifest as JSON to the given path.
+func (m *Manifest) Write(path string) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	return os.WriteFile(path, data, 0666)
+}
+
+// executableFormat returns the file format of the executable produced by the
+// linker.
+func executableFormat(config *compileopts.Config) string {
+	switch {
+	case strings.HasPrefix(config.Triple(), "wasm"):
+		return "wasm"
+	case config.GOOS() == "darwin":
+		return "macho"
+	case config.GOOS() == "windows":
+		return "pe"
+	default:
+		return "elf"
+	}
+}
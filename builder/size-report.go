@@ -48,6 +48,7 @@ func writeSizeReport(sizes *programSize, filename, pkgName string) error {
 		"pkgName":   pkgName,
 		"sizes":     programData,
 		"sizeTotal": sizeTotal,
+		"symbols":   sizes.Symbols,
 	})
 	if err != nil {
 		return fmt.Errorf("could not create report file: %w", err)
@@ -0,0 +1,307 @@
+package builder
+
+// This file attributes code and data size to individual symbols (functions and
+// global variables), for -size=full and -size=html. It also determines why a
+// given symbol is linked into the program at all, by following references from
+// the entry points of the program in the optimized LLVM module.
+
+import (
+	"debug/elf"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"tinygo.org/x/go-llvm"
+)
+
+// programSymbol is a single function or global variable in the program, as
+// found in the symbol table of the executable.
+type programSymbol struct {
+	Name    string
+	Package string
+	Kind    string // function, global, type data, method set, interface, map hasher
+	Code    uint64
+	ROData  uint64
+	Data    uint64
+	BSS     uint64
+
+	// Chain of symbols that explains why this symbol is linked in: the first
+	// symbol is an entry point (like main or an interrupt handler), and each
+	// following symbol is referenced by the previous one. It is empty if the
+	// symbol isn't part of the LLVM module (for example, C library functions).
+	Chain []string
+
+	address  uint64
+	function bool
+}
+
+// Flash usage in regular microcontrollers.
+func (s *programSymbol) Flash() uint64 {
+	return s.Code + s.ROData + s.Data
+}
+
+// Static RAM usage in regular microcontrollers.
+func (s *programSymbol) RAM() uint64 {
+	return s.Data + s.BSS
+}
+
+// readELFSymbols reads all functions and global variables with a size from the
+// symbol table of an ELF file.
+func readELFSymbols(file *elf.File) ([]*programSymbol, error) {
+	allSymbols, err := file.Symbols()
+	if err != nil {
+		return nil, err
+	}
+	var symbols []*programSymbol
+	for _, symbol := range allSymbols {
+		symType := elf.ST_TYPE(symbol.Info)
+		if symbol.Size == 0 || (symType != elf.STT_FUNC && symType != elf.STT_OBJECT) {
+			continue
+		}
+		if symbol.Section >= elf.SHN_LORESERVE {
+			continue
+		}
+		if file.Sections[symbol.Section].Flags&elf.SHF_ALLOC == 0 {
+			continue
+		}
+		symbols = append(symbols, &programSymbol{
+			Name:     symbol.Name,
+			Code:     symbol.Size, // fixed up in addSymbols
+			address:  symbol.Value,
+			function: symType == elf.STT_FUNC,
+		})
+	}
+	return symbols, nil
+}
+
+// addSymbols attributes the given symbols to packages and sections, and adds
+// them to the program. The addresses slice must be sorted.
+func (ps *programSize) addSymbols(symbols []*programSymbol, addresses []addressLine, sections []memorySection, packagePathMap map[string]string) {
+	for _, symbol := range symbols {
+		// Find the section of this symbol, to know whether it is code or
+		// data.
+		size := symbol.Code
+		symbol.Code = 0
+		found := false
+		for _, section := range sections {
+			if symbol.address < section.Address || symbol.address >= section.Address+section.Size {
+				continue
+			}
+			found = true
+			switch section.Type {
+			case memoryCode:
+				if symbol.function {
+					symbol.Code = size
+				} else {
+					symbol.ROData = size
+				}
+			case memoryROData:
+				symbol.ROData = size
+			case memoryData:
+				symbol.Data = size
+			case memoryBSS:
+				symbol.BSS = size
+			default:
+				found = false // stack
+			}
+			break
+		}
+		if !found {
+			continue
+		}
+
+		// Find the package of this symbol, in the same way as the package
+		// sizes are calculated: using the source location of the first byte.
+		symbol.Package = "(unknown)"
+		index := sort.Search(len(addresses), func(i int) bool {
+			return addresses[i].Address > symbol.address
+		}) - 1
+		if index >= 0 && addresses[index].Address+addresses[index].Length > symbol.address {
+			symbol.Package, _ = findPackagePath(addresses[index].File, packagePathMap)
+		}
+		symbol.Kind = symbolKind(symbol.Name, symbol.function)
+
+		ps.Symbols = append(ps.Symbols, symbol)
+		if pkg, ok := ps.Packages[symbol.Package]; ok {
+			pkg.Symbols = append(pkg.Symbols, symbol)
+		}
+	}
+
+	// Sort the largest symbols first.
+	sortSymbols(ps.Symbols)
+	for _, pkg := range ps.Packages {
+		sortSymbols(pkg.Symbols)
+	}
+}
+
+func sortSymbols(symbols []*programSymbol) {
+	sort.Slice(symbols, func(i, j int) bool {
+		a, b := symbols[i], symbols[j]
+		if a.Flash() != b.Flash() {
+			return a.Flash() > b.Flash()
+		}
+		if a.RAM() != b.RAM() {
+			return a.RAM() > b.RAM()
+		}
+		return a.Name < b.Name
+	})
+}
+
+// Functions used by the runtime to hash and compare map keys. See
+// hashmapKeyHashAlg and hashmapKeyEqualAlg in the runtime.
+var mapHasherNames = map[string]bool{
+	"runtime.hash32":                  true,
+	"runtime.hash64":                  true,
+	"runtime.memequal":                true,
+	"runtime.hashmapStringHash":       true,
+	"runtime.hashmapStringPtrHash":    true,
+	"runtime.hashmapStringEqual":      true,
+	"runtime.hashmapFloat32Hash":      true,
+	"runtime.hashmapFloat64Hash":      true,
+	"runtime.hashmapInterfaceHash":    true,
+	"runtime.hashmapInterfacePtrHash": true,
+	"runtime.hashmapInterfaceEqual":   true,
+}
+
+// symbolKind returns what kind of symbol this is, based on the naming
+// conventions of the compiler for compiler-generated symbols.
+func symbolKind(name string, function bool) string {
+	switch {
+	case strings.HasPrefix(name, "reflect/types."):
+		// Type codes, type IDs, method names, etc. See compiler/interface.go.
+		return "type data"
+	case strings.Contains(name, "$methodset") || strings.HasSuffix(name, "$reflectmethods") || strings.Contains(name, ".$methods."):
+		// Method sets of types, and the method signatures they contain.
+		return "method set"
+	case strings.HasSuffix(name, "$invoke") || strings.HasSuffix(name, ".$typeassert"):
+		// Interface method calls and type asserts, which are implemented by
+		// the interface lowering pass.
+		return "interface"
+	case mapHasherNames[name]:
+		return "map hasher"
+	case function:
+		return "function"
+	default:
+		return "global"
+	}
+}
+
+// symbolReferences returns, for each function and global in the module, the
+// list of other functions and globals it references.
+func symbolReferences(mod llvm.Module) map[string][]string {
+	references := make(map[string][]string)
+	addReferences := func(from llvm.Value, values []llvm.Value) {
+		seen := make(map[llvm.Value]bool)
+		var visit func(value llvm.Value)
+		visit = func(value llvm.Value) {
+			if seen[value] {
+				return
+			}
+			seen[value] = true
+			if !value.IsAGlobalValue().IsNil() {
+				if value != from {
+					references[from.Name()] = append(references[from.Name()], value.Name())
+				}
+				return
+			}
+			if value.IsAConstant().IsNil() {
+				return
+			}
+			// Look inside constant expressions, structs, arrays, etc.
+			for i := 0; i < value.OperandsCount(); i++ {
+				visit(value.Operand(i))
+			}
+		}
+		for _, value := range values {
+			visit(value)
+		}
+	}
+	for fn := mod.FirstFunction(); !fn.IsNil(); fn = llvm.NextFunction(fn) {
+		var operands []llvm.Value
+		for bb := fn.FirstBasicBlock(); !bb.IsNil(); bb = llvm.NextBasicBlock(bb) {
+			for inst := bb.FirstInstruction(); !inst.IsNil(); inst = llvm.NextInstruction(inst) {
+				for i := 0; i < inst.OperandsCount(); i++ {
+					operands = append(operands, inst.Operand(i))
+				}
+			}
+		}
+		addReferences(fn, operands)
+	}
+	for global := mod.FirstGlobal(); !global.IsNil(); global = llvm.NextGlobal(global) {
+		if initializer := global.Initializer(); !initializer.IsNil() {
+			addReferences(global, []llvm.Value{initializer})
+		}
+	}
+	return references
+}
+
+// symbolChains determines for each symbol in the module why it is linked in,
+// as the shortest chain of references from an entry point of the program. The
+// entry points are all externally visible functions and globals, such as the
+// main function, interrupt handlers, and exported functions.
+func symbolChains(mod llvm.Module) map[string][]string {
+	references := symbolReferences(mod)
+
+	// Do a breadth-first search starting at the roots, so that the shortest
+	// chain is found for each symbol.
+	parents := make(map[string]string)
+	var queue []string
+	addRoot := func(value llvm.Value) {
+		if value.IsDeclaration() {
+			return
+		}
+		switch value.Linkage() {
+		case llvm.InternalLinkage, llvm.PrivateLinkage:
+			return
+		}
+		parents[value.Name()] = ""
+		queue = append(queue, value.Name())
+	}
+	for fn := mod.FirstFunction(); !fn.IsNil(); fn = llvm.NextFunction(fn) {
+		addRoot(fn)
+	}
+	for global := mod.FirstGlobal(); !global.IsNil(); global = llvm.NextGlobal(global) {
+		addRoot(global)
+	}
+	for len(queue) != 0 {
+		name := queue[0]
+		queue = queue[1:]
+		for _, ref := range references[name] {
+			if _, ok := parents[ref]; !ok {
+				parents[ref] = name
+				queue = append(queue, ref)
+			}
+		}
+	}
+
+	chains := make(map[string][]string, len(parents))
+	for name := range parents {
+		var chain []string
+		for n := name; n != ""; n = parents[n] {
+			chain = append(chain, n)
+		}
+		// Reverse, to start at the root.
+		for i, j := 0, len(chain)-1; i < j; i, j = i+1, j-1 {
+			chain[i], chain[j] = chain[j], chain[i]
+		}
+		chains[name] = chain
+	}
+	return chains
+}
+
+// addSymbolChains stores for each symbol in the program why it is linked in.
+func (ps *programSize) addSymbolChains(chains map[string][]string) {
+	for _, symbol := range ps.Symbols {
+		symbol.Chain = chains[symbol.Name]
+	}
+}
+
+// printSymbolSizes prints the size of each symbol as a table, for -size=full.
+func printSymbolSizes(w io.Writer, symbols []*programSymbol) {
+	fmt.Fprintf(w, "   code  rodata    data     bss |   flash     ram | kind        | symbol\n")
+	fmt.Fprintf(w, "------------------------------- | --------------- | ----------- | ------\n")
+	for _, symbol := range symbols {
+		fmt.Fprintf(w, "%7d %7d %7d %7d | %7d %7d | %-11s | %s\n", symbol.Code, symbol.ROData, symbol.Data, symbol.BSS, symbol.Flash(), symbol.RAM(), symbol.Kind, symbol.Name)
+	}
+}
@@ -0,0 +1,92 @@
+package builder
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSymbolKind(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		function bool
+		kind     string
+	}{
+		{"main.main", true, "function"},
+		{"main.buffer", false, "global"},
+		{"reflect/types.type:named:main.Foo", false, "type data"},
+		{"reflect/types.typeid:basic:int", false, "type data"},
+		{"main.Foo$methodset", false, "method set"},
+		{"main.Foo$methodset$reflect", false, "method set"},
+		{"fmt.$methods.String() string", false, "method set"},
+		{"fmt.Stringer.String$invoke", true, "interface"},
+		{"error.$typeassert", true, "interface"},
+		{"runtime.hashmapStringHash", true, "map hasher"},
+		{"runtime.hashmapSet", true, "function"},
+	} {
+		if kind := symbolKind(tc.name, tc.function); kind != tc.kind {
+			t.Errorf("symbolKind(%q) = %q, expected %q", tc.name, kind, tc.kind)
+		}
+	}
+}
+
+func TestAddSymbols(t *testing.T) {
+	program := &programSize{Packages: map[string]*packageSize{}}
+	program.getPackage("main")
+	program.getPackage("runtime")
+	sections := []memorySection{
+		{Type: memoryCode, Address: 0x1000, Size: 0x1000},
+		{Type: memoryData, Address: 0x2000, Size: 0x100},
+		{Type: memoryBSS, Address: 0x3000, Size: 0x100},
+	}
+	addresses := []addressLine{
+		{Address: 0x1000, Length: 0x20, File: "/src/main.go"},
+		{Address: 0x1020, Length: 0x40, File: "/src/runtime/gc.go"},
+		{Address: 0x2000, Length: 0x10, File: "/src/main.go", IsVariable: true},
+	}
+	packagePathMap := map[string]string{
+		"/src":         "main",
+		"/src/runtime": "runtime",
+	}
+	symbols := []*programSymbol{
+		{Name: "main.main", Code: 0x20, address: 0x1000, function: true},
+		{Name: "runtime.alloc", Code: 0x40, address: 0x1020, function: true},
+		{Name: "main.table", Code: 0x10, address: 0x1060},
+		{Name: "main.counter", Code: 0x10, address: 0x2000},
+		{Name: "main.buffer", Code: 0x80, address: 0x3000},
+	}
+	program.addSymbols(symbols, addresses, sections, packagePathMap)
+
+	var names []string
+	for _, symbol := range program.Symbols {
+		names = append(names, symbol.Name)
+	}
+	expectedNames := []string{"runtime.alloc", "main.main", "main.counter", "main.table", "main.buffer"}
+	if !reflect.DeepEqual(names, expectedNames) {
+		t.Errorf("unexpected symbol order:\n got: %q\nwant: %q", names, expectedNames)
+	}
+
+	expected := map[string]programSymbol{
+		"main.main":     {Package: "main", Kind: "function", Code: 0x20},
+		"runtime.alloc": {Package: "runtime", Kind: "function", Code: 0x40},
+		"main.table":    {Package: "(unknown)", Kind: "global", ROData: 0x10},
+		"main.counter":  {Package: "main", Kind: "global", Data: 0x10},
+		"main.buffer":   {Package: "(unknown)", Kind: "global", BSS: 0x80},
+	}
+	for _, symbol := range program.Symbols {
+		want := expected[symbol.Name]
+		got := programSymbol{Package: symbol.Package, Kind: symbol.Kind, Code: symbol.Code, ROData: symbol.ROData, Data: symbol.Data, BSS: symbol.BSS}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("unexpected attribution for %s:\n got: %+v\nwant: %+v", symbol.Name, got, want)
+		}
+	}
+	if n := len(program.Packages["main"].Symbols); n != 2 {
+		t.Errorf("expected 2 symbols in package main, got %d", n)
+	}
+
+	// Make sure the HTML template is valid.
+	program.Symbols[0].Chain = []string{"main.main", "runtime.alloc"}
+	if err := writeSizeReport(program, filepath.Join(t.TempDir(), "size-report.html"), "main"); err != nil {
+		t.Error("could not write size report:", err)
+	}
+}
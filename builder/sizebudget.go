@@ -0,0 +1,191 @@
+package builder
+
+// This file implements size budgets (the -size-budget flag), which make a
+// build fail when the program grows beyond a configured flash or RAM size.
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// sizeBudget is the contents of a size budget file. An example:
+//
+//	{
+//	    "flash": "128K",
+//	    "ram": 16384,
+//	    "packages": {
+//	        "github.com/example/firmware/display": {"flash": "8K"}
+//	    }
+//	}
+//
+// Package names are the same as printed by -size=full. A zero or missing value
+// means there is no limit.
+type sizeBudget struct {
+	Flash    byteSize                `json:"flash"`
+	RAM      byteSize                `json:"ram"`
+	Packages map[string]packageLimit `json:"packages"`
+}
+
+// packageLimit is the size budget of a single package.
+type packageLimit struct {
+	Flash byteSize `json:"flash"`
+	RAM   byteSize `json:"ram"`
+}
+
+// byteSize is a size in bytes. In JSON, it can be written as a number or as a
+// string with a K or M suffix (for example "64K"), where 1K is 1024 bytes.
+type byteSize uint64
+
+func (s *byteSize) UnmarshalJSON(data []byte) error {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	switch value := value.(type) {
+	case float64:
+		if value < 0 || value != float64(uint64(value)) {
+			return fmt.Errorf("invalid size: %s", data)
+		}
+		*s = byteSize(value)
+		return nil
+	case string:
+		n, err := parseByteSize(value)
+		if err != nil {
+			return err
+		}
+		*s = n
+		return nil
+	default:
+		return fmt.Errorf("invalid size: %s", data)
+	}
+}
+
+// parseByteSize parses a size like "1024", "64K", "64KB", "64KiB" or "1M".
+func parseByteSize(value string) (byteSize, error) {
+	s := strings.ToUpper(strings.TrimSpace(value))
+	multiplier := uint64(1)
+	for _, unit := range []struct {
+		suffix     string
+		multiplier uint64
+	}{
+		{"KIB", 1024}, {"KB", 1024}, {"K", 1024},
+		{"MIB", 1024 * 1024}, {"MB", 1024 * 1024}, {"M", 1024 * 1024},
+		{"B", 1},
+	} {
+		if strings.HasSuffix(s, unit.suffix) {
+			s = strings.TrimSpace(s[:len(s)-len(unit.suffix)])
+			multiplier = unit.multiplier
+			break
+		}
+	}
+	n, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size: %q", value)
+	}
+	return byteSize(n * multiplier), nil
+}
+
+// loadSizeBudget reads a size budget file.
+func loadSizeBudget(path string) (*sizeBudget, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	budget := &sizeBudget{}
+	decoder := json.NewDecoder(f)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(budget); err != nil {
+		return nil, fmt.Errorf("could not read size budget %s: %w", path, err)
+	}
+	return budget, nil
+}
+
+// sizeBudgetError is returned when a program doesn't fit in its size budget.
+type sizeBudgetError struct {
+	Exceeded     []string // description of each budget that was exceeded
+	Contributors []string // largest contributors to the exceeded sizes
+}
+
+func (e *sizeBudgetError) Error() string {
+	var sb strings.Builder
+	sb.WriteString("size budget exceeded:")
+	for _, line := range e.Exceeded {
+		sb.WriteString("\n\t")
+		sb.WriteString(line)
+	}
+	for _, line := range e.Contributors {
+		sb.WriteString("\n")
+		sb.WriteString(line)
+	}
+	return sb.String()
+}
+
+// Number of packages to list as largest contributors when a budget is exceeded.
+const sizeBudgetContributors = 10
+
+// check returns a *sizeBudgetError if the program sizes exceed the budget, or
+// nil if the program fits.
+func (b *sizeBudget) check(sizes *programSize) error {
+	exceeded := make(map[string]bool) // "flash" or "ram"
+	var lines []string
+	checkLimit := func(what, kind string, used uint64, limit byteSize) {
+		if limit == 0 || used <= uint64(limit) {
+			return
+		}
+		exceeded[kind] = true
+		lines = append(lines, fmt.Sprintf("%s: %d bytes of %s used, budget is %d bytes (%d bytes over)", what, used, kind, limit, used-uint64(limit)))
+	}
+	checkLimit("program", "flash", sizes.Flash(), b.Flash)
+	checkLimit("program", "ram", sizes.RAM(), b.RAM)
+
+	names := make([]string, 0, len(b.Packages))
+	for name := range b.Packages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		limit := b.Packages[name]
+		var flash, ram uint64
+		if pkgSize, ok := sizes.Packages[name]; ok {
+			flash, ram = pkgSize.Flash(), pkgSize.RAM()
+		}
+		checkLimit("package "+name, "flash", flash, limit.Flash)
+		checkLimit("package "+name, "ram", ram, limit.RAM)
+	}
+
+	if len(lines) == 0 {
+		return nil
+	}
+	err := &sizeBudgetError{Exceeded: lines}
+	for _, kind := range []string{"flash", "ram"} {
+		if !exceeded[kind] {
+			continue
+		}
+		size := func(name string) uint64 {
+			if kind == "flash" {
+				return sizes.Packages[name].Flash()
+			}
+			return sizes.Packages[name].RAM()
+		}
+		pkgNames := sizes.sortedPackageNames()
+		sort.SliceStable(pkgNames, func(i, j int) bool {
+			return size(pkgNames[i]) > size(pkgNames[j])
+		})
+		if len(pkgNames) > sizeBudgetContributors {
+			pkgNames = pkgNames[:sizeBudgetContributors]
+		}
+		err.Contributors = append(err.Contributors, "largest contributors to "+kind+":")
+		for _, name := range pkgNames {
+			if size(name) == 0 {
+				break
+			}
+			err.Contributors = append(err.Contributors, fmt.Sprintf("\t%7d  %s", size(name), name))
+		}
+	}
+	return err
+}
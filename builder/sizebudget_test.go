@@ -0,0 +1,92 @@
+package builder
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseByteSize(t *testing.T) {
+	for _, tc := range []struct {
+		in  string
+		out byteSize
+	}{
+		{"1000", 1000},
+		{"64K", 64 * 1024},
+		{"64kb", 64 * 1024},
+		{"64 KiB", 64 * 1024},
+		{"2M", 2 * 1024 * 1024},
+		{"100B", 100},
+	} {
+		out, err := parseByteSize(tc.in)
+		if err != nil {
+			t.Errorf("could not parse %q: %v", tc.in, err)
+			continue
+		}
+		if out != tc.out {
+			t.Errorf("parseByteSize(%q) = %d, expected %d", tc.in, out, tc.out)
+		}
+	}
+	for _, in := range []string{"", "K", "-1", "1G", "1.5K"} {
+		if _, err := parseByteSize(in); err == nil {
+			t.Errorf("expected an error for %q", in)
+		}
+	}
+}
+
+func TestSizeBudget(t *testing.T) {
+	var budget sizeBudget
+	err := json.Unmarshal([]byte(`{
+		"flash": "1K",
+		"ram": 512,
+		"packages": {
+			"runtime": {"flash": 600},
+			"machine": {"ram": "1K"}
+		}
+	}`), &budget)
+	if err != nil {
+		t.Fatal("could not parse budget:", err)
+	}
+
+	sizes := &programSize{Packages: map[string]*packageSize{}}
+	addPackage := func(name string, code, data, bss uint64) {
+		sizes.Packages[name] = &packageSize{Program: sizes, Code: code, Data: data, BSS: bss}
+		sizes.Code += code
+		sizes.Data += data
+		sizes.BSS += bss
+	}
+	addPackage("runtime", 500, 0, 100)
+	addPackage("machine", 300, 0, 200)
+	addPackage("main", 100, 8, 0)
+
+	// The program fits.
+	if err := budget.check(sizes); err != nil {
+		t.Error("unexpected error:", err)
+	}
+
+	// The program and the runtime package are too big.
+	sizes.Packages["runtime"].Code += 200
+	sizes.Code += 200
+	err = budget.check(sizes)
+	var budgetErr *sizeBudgetError
+	if !errors.As(err, &budgetErr) {
+		t.Fatal("expected a size budget error, got:", err)
+	}
+	expectedExceeded := []string{
+		"program: 1108 bytes of flash used, budget is 1024 bytes (84 bytes over)",
+		"package runtime: 700 bytes of flash used, budget is 600 bytes (100 bytes over)",
+	}
+	if !reflect.DeepEqual(budgetErr.Exceeded, expectedExceeded) {
+		t.Errorf("unexpected exceeded budgets:\n got: %q\nwant: %q", budgetErr.Exceeded, expectedExceeded)
+	}
+	expectedContributors := []string{
+		"largest contributors to flash:",
+		"\t    700  runtime",
+		"\t    300  machine",
+		"\t    108  main",
+	}
+	if !reflect.DeepEqual(budgetErr.Contributors, expectedContributors) {
+		t.Errorf("unexpected contributors:\n got: %q\nwant: %q", budgetErr.Contributors, expectedContributors)
+	}
+}
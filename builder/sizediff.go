@@ -0,0 +1,236 @@
+package builder
+
+import (
+	"debug/elf"
+	_ "embed"
+	"fmt"
+	"html/template"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/tinygo-org/tinygo/goenv"
+)
+
+// SizeDiff is the difference in code and data size between two builds of a
+// program, as printed by tinygo size-diff.
+type SizeDiff struct {
+	Old      string      // path of the old binary
+	New      string      // path of the new binary
+	Total    SizeDelta   // difference for the whole program
+	Packages []SizeDelta // packages that changed in size
+	Symbols  []SizeDelta // symbols that changed in size (only for ELF files)
+}
+
+// SizeDelta is the size of a package or symbol in both builds.
+type SizeDelta struct {
+	Name      string
+	OldFlash  uint64
+	NewFlash  uint64
+	FlashDiff int64
+	OldRAM    uint64
+	NewRAM    uint64
+	RAMDiff   int64
+}
+
+// newSizeDelta returns a SizeDelta with the differences filled in.
+func newSizeDelta(name string, oldFlash, newFlash, oldRAM, newRAM uint64) SizeDelta {
+	return SizeDelta{
+		Name:      name,
+		OldFlash:  oldFlash,
+		NewFlash:  newFlash,
+		FlashDiff: int64(newFlash) - int64(oldFlash),
+		OldRAM:    oldRAM,
+		NewRAM:    newRAM,
+		RAMDiff:   int64(newRAM) - int64(oldRAM),
+	}
+}
+
+// DiffSizes compares the size of two binaries, usually two builds of the same
+// program. The binaries must include debug information to be able to
+// attribute sizes to packages.
+func DiffSizes(oldPath, newPath string) (*SizeDiff, error) {
+	oldSizes, err := loadProgramSize(oldPath, nil)
+	if err != nil {
+		return nil, fmt.Errorf("could not read %s: %w", oldPath, err)
+	}
+	newSizes, err := loadProgramSize(newPath, nil)
+	if err != nil {
+		return nil, fmt.Errorf("could not read %s: %w", newPath, err)
+	}
+	diff := &SizeDiff{
+		Old:   oldPath,
+		New:   newPath,
+		Total: newSizeDelta("total", oldSizes.Flash(), newSizes.Flash(), oldSizes.RAM(), newSizes.RAM()),
+	}
+
+	// Compare packages. Without the list of packages from the build, Go
+	// packages are identified by their directory so strip the GOROOT prefix
+	// to get the import path for standard library packages.
+	oldPackages := make(map[string]symbolSize)
+	for name, pkgSize := range oldSizes.Packages {
+		size := oldPackages[sizeDiffPackageName(name)]
+		size.flash += pkgSize.Flash()
+		size.ram += pkgSize.RAM()
+		oldPackages[sizeDiffPackageName(name)] = size
+	}
+	newPackages := make(map[string]symbolSize)
+	for name, pkgSize := range newSizes.Packages {
+		size := newPackages[sizeDiffPackageName(name)]
+		size.flash += pkgSize.Flash()
+		size.ram += pkgSize.RAM()
+		newPackages[sizeDiffPackageName(name)] = size
+	}
+	diff.Packages = compareSizes(oldPackages, newPackages)
+
+	// Compare symbols, if both files have a symbol table.
+	oldSymbols, err := loadSymbolSizes(oldPath)
+	if err != nil {
+		return nil, fmt.Errorf("could not read %s: %w", oldPath, err)
+	}
+	newSymbols, err := loadSymbolSizes(newPath)
+	if err != nil {
+		return nil, fmt.Errorf("could not read %s: %w", newPath, err)
+	}
+	if oldSymbols != nil && newSymbols != nil {
+		diff.Symbols = compareSizes(oldSymbols, newSymbols)
+	}
+
+	return diff, nil
+}
+
+// sizeDiffPackageName returns the package name to use in a size diff.
+func sizeDiffPackageName(name string) string {
+	for _, root := range []string{goenv.Get("TINYGOROOT"), goenv.Get("GOROOT")} {
+		if root == "" {
+			continue
+		}
+		prefix := filepath.Join(root, "src") + string(filepath.Separator)
+		if strings.HasPrefix(name, prefix) {
+			return filepath.ToSlash(name[len(prefix):])
+		}
+	}
+	return name
+}
+
+// compareSizes returns all entries that changed in size, with the largest
+// changes first.
+func compareSizes(oldSizes, newSizes map[string]symbolSize) []SizeDelta {
+	changed := []SizeDelta{}
+	for name, oldSize := range oldSizes {
+		newSize := newSizes[name]
+		if oldSize != newSize {
+			changed = append(changed, newSizeDelta(name, oldSize.flash, newSize.flash, oldSize.ram, newSize.ram))
+		}
+	}
+	for name, newSize := range newSizes {
+		if _, ok := oldSizes[name]; !ok && newSize != (symbolSize{}) {
+			changed = append(changed, newSizeDelta(name, 0, newSize.flash, 0, newSize.ram))
+		}
+	}
+	abs := func(n int64) int64 {
+		if n < 0 {
+			return -n
+		}
+		return n
+	}
+	sort.Slice(changed, func(i, j int) bool {
+		a, b := changed[i], changed[j]
+		if abs(a.FlashDiff) != abs(b.FlashDiff) {
+			return abs(a.FlashDiff) > abs(b.FlashDiff)
+		}
+		if abs(a.RAMDiff) != abs(b.RAMDiff) {
+			return abs(a.RAMDiff) > abs(b.RAMDiff)
+		}
+		return a.Name < b.Name
+	})
+	return changed
+}
+
+// symbolSize is the flash and RAM usage of a single package or symbol.
+type symbolSize struct {
+	flash uint64
+	ram   uint64
+}
+
+// loadSymbolSizes reads the flash and RAM usage of each function and global in
+// the symbol table of an ELF file. It returns nil if the file is not an ELF
+// file. Symbols with the same name (such as static functions in C) are added
+// together.
+func loadSymbolSizes(path string) (map[string]symbolSize, error) {
+	file, err := elf.Open(path)
+	if err != nil {
+		if _, ok := err.(*elf.FormatError); ok {
+			// Not an ELF file (WebAssembly, MachO, etc).
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+	symbols, err := file.Symbols()
+	if err != nil {
+		return nil, err
+	}
+	sizes := make(map[string]symbolSize)
+	for _, symbol := range symbols {
+		symType := elf.ST_TYPE(symbol.Info)
+		if symbol.Size == 0 || (symType != elf.STT_FUNC && symType != elf.STT_OBJECT) {
+			continue
+		}
+		if symbol.Section >= elf.SHN_LORESERVE {
+			continue
+		}
+		section := file.Sections[symbol.Section]
+		if section.Flags&elf.SHF_ALLOC == 0 {
+			continue
+		}
+		size := sizes[symbol.Name]
+		if section.Type != elf.SHT_NOBITS {
+			// Code, read-only data, and initial values of data.
+			size.flash += symbol.Size
+		}
+		if section.Type == elf.SHT_NOBITS || section.Flags&elf.SHF_WRITE != 0 {
+			// Data and BSS.
+			size.ram += symbol.Size
+		}
+		sizes[symbol.Name] = size
+	}
+	return sizes, nil
+}
+
+// WriteText writes the size difference as a table, in a format similar to
+// -size=full.
+func (d *SizeDiff) WriteText(w io.Writer) error {
+	var sb strings.Builder
+	writeTable := func(kind string, deltas []SizeDelta) {
+		fmt.Fprintf(&sb, "   flash      ram | %s\n", kind)
+		fmt.Fprintf(&sb, "----------------- | -------\n")
+		for _, delta := range deltas {
+			fmt.Fprintf(&sb, "%+8d %+8d | %s\n", delta.FlashDiff, delta.RAMDiff, delta.Name)
+		}
+	}
+	writeTable("package", d.Packages)
+	fmt.Fprintf(&sb, "----------------- | -------\n")
+	fmt.Fprintf(&sb, "%+8d %+8d | total\n", d.Total.FlashDiff, d.Total.RAMDiff)
+	if d.Symbols != nil {
+		sb.WriteString("\n")
+		writeTable("symbol", d.Symbols)
+	}
+	fmt.Fprintf(&sb, "\nflash: %d -> %d bytes, ram: %d -> %d bytes\n", d.Total.OldFlash, d.Total.NewFlash, d.Total.OldRAM, d.Total.NewRAM)
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+//go:embed size-diff.html
+var sizeDiffBase string
+
+// WriteHTML writes the size difference as a HTML page, similar to the report
+// of -size=html.
+func (d *SizeDiff) WriteHTML(w io.Writer) error {
+	tmpl, err := template.New("report").Parse(sizeDiffBase)
+	if err != nil {
+		return err
+	}
+	return tmpl.Execute(w, d)
+}
@@ -0,0 +1,68 @@
+package builder
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSizeDiff(t *testing.T) {
+	oldSizes := map[string]symbolSize{
+		"runtime.alloc": {flash: 400},
+		"main.main":     {flash: 100},
+		"main.buffer":   {ram: 256},
+		"main.removed":  {flash: 50},
+		"main.same":     {flash: 10},
+	}
+	newSizes := map[string]symbolSize{
+		"runtime.alloc": {flash: 400},
+		"main.main":     {flash: 180},
+		"main.buffer":   {ram: 512},
+		"main.added":    {flash: 50, ram: 8},
+		"main.same":     {flash: 10},
+	}
+	deltas := compareSizes(oldSizes, newSizes)
+	expected := []SizeDelta{
+		{Name: "main.main", OldFlash: 100, NewFlash: 180, FlashDiff: 80},
+		{Name: "main.added", NewFlash: 50, FlashDiff: 50, NewRAM: 8, RAMDiff: 8},
+		{Name: "main.removed", OldFlash: 50, FlashDiff: -50},
+		{Name: "main.buffer", OldRAM: 256, NewRAM: 512, RAMDiff: 256},
+	}
+	if !reflect.DeepEqual(deltas, expected) {
+		t.Errorf("unexpected size diff:\n got: %+v\nwant: %+v", deltas, expected)
+	}
+
+	diff := &SizeDiff{
+		Total:    newSizeDelta("total", 560, 640, 256, 520),
+		Packages: []SizeDelta{newSizeDelta("main", 160, 240, 256, 520)},
+		Symbols:  deltas,
+	}
+	var out strings.Builder
+	if err := diff.WriteText(&out); err != nil {
+		t.Fatal(err)
+	}
+	expectedText := `   flash      ram | package
+----------------- | -------
+     +80     +264 | main
+----------------- | -------
+     +80     +264 | total
+
+   flash      ram | symbol
+----------------- | -------
+     +80       +0 | main.main
+     +50       +8 | main.added
+     -50       +0 | main.removed
+      +0     +256 | main.buffer
+
+flash: 560 -> 640 bytes, ram: 256 -> 520 bytes
+`
+	if out.String() != expectedText {
+		t.Errorf("unexpected text output:\n%s\nwant:\n%s", out.String(), expectedText)
+	}
+
+	// Make sure the HTML template is valid.
+	out.Reset()
+	if err := diff.WriteHTML(&out); err != nil {
+		t.Error("could not write HTML:", err)
+	}
+}
@@ -30,6 +30,7 @@ type programSize struct {
 	ROData   uint64
 	Data     uint64
 	BSS      uint64
+	Symbols  []*programSymbol // largest first, only for ELF files
 }
 
 // sortedPackageNames returns the list of package names (ProgramSize.Packages)
@@ -76,6 +77,7 @@ type packageSize struct {
 	Data    uint64
 	BSS     uint64
 	Sub     map[string]*packageSize
+	Symbols []*programSymbol // largest first, only for ELF files
 }
 
 // Flash usage in regular microcontrollers.
@@ -189,6 +191,12 @@ func readProgramSizeFromDWARF(data *dwarf.Data, codeOffset, codeAlignment uint64
 			if err != nil {
 				return nil, err
 			}
+			if lr == nil {
+				// This compile unit has no line table, so there is nothing
+				// to attribute to a source file.
+				r.SkipChildren()
+				continue
+			}
 			lines = lr.Files()
 			var lineEntry = dwarf.LineEntry{
 				EndSequence: true,
@@ -431,6 +439,7 @@ func loadProgramSize(path string, packagePathMap map[string]string) (*programSiz
 
 	// Load the binary file, which could be in a number of file formats.
 	var sections []memorySection
+	var symbols []*programSymbol
 	if file, err := elf.NewFile(f); err == nil {
 		var codeAlignment uint64
 		switch file.Machine {
@@ -484,6 +493,13 @@ func loadProgramSize(path string, packagePathMap map[string]string) (*programSiz
 			}
 		}
 
+		// Read the functions and globals, to be able to show the size of
+		// individual symbols.
+		symbols, err = readELFSymbols(file)
+		if err != nil {
+			return nil, err
+		}
+
 		// Load allocated sections.
 		for _, section := range file.Sections {
 			if section.Flags&elf.SHF_ALLOC == 0 {
@@ -866,6 +882,9 @@ func loadProgramSize(path string, packagePathMap map[string]string) (*programSiz
 		program.Data += pkg.Data
 		program.BSS += pkg.BSS
 	}
+
+	// Attribute individual symbols to packages, if the file has them.
+	program.addSymbols(symbols, addresses, sections, packagePathMap)
 	return program, nil
 }
 
@@ -0,0 +1,385 @@
+package builder
+
+// This file implements the -symtab flag: it embeds a table in the binary that
+// maps program counters to function names, files and lines. The runtime uses it
+// to implement runtime.Caller, runtime.CallersFrames and similar functions.
+// See src/runtime/symtab_table.go for the table layout.
+//
+// The table is derived from the DWARF information of the linked program, which
+// means the program needs to be linked before the table can be created. This is
+// done by first linking with an empty table, then creating the real table from
+// the result and linking again with the real table. The table only contains
+// offsets that do not depend on the location of the table itself (apart from
+// function addresses, which are stored in fixed-size fields), so if the table
+// changes function addresses it can simply be patched with the new addresses.
+
+import (
+	"bytes"
+	"debug/dwarf"
+	"debug/elf"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/tinygo-org/tinygo/compileopts"
+	"github.com/tinygo-org/tinygo/compiler"
+	"tinygo.org/x/go-llvm"
+)
+
+// Name of the global that contains the symbol table. It is referenced by the
+// runtime package.
+const symtabSymbol = "tinygo_symtab"
+
+// checkSymtabSupport returns an error if -symtab is not supported for the
+// given configuration.
+func checkSymtabSupport(config *compileopts.Config) error {
+	if config.Options.SkipDWARF {
+		return errors.New("-symtab requires DWARF debug information")
+	}
+	if config.GOOS() == "darwin" || config.GOOS() == "windows" || config.Target.Linker != "ld.lld" {
+		return fmt.Errorf("-symtab is only supported for ELF targets, not for %s", config.GOOS())
+	}
+	arch := strings.Split(config.Triple(), "-")[0]
+	switch {
+	case arch == "x86_64", arch == "i386", arch == "i686", arch == "aarch64":
+	case strings.HasPrefix(arch, "arm"), strings.HasPrefix(arch, "thumb"):
+	case arch == "riscv32", arch == "riscv64":
+	default:
+		return fmt.Errorf("-symtab is not supported on %s", arch)
+	}
+	return nil
+}
+
+// linkWithSymbolTable links the program with an embedded symbol table. The
+// ldflags must include all object files to link except for the symbol table
+// object. Debug information is kept while creating the symbol table, and
+// stripped afterwards if stripFlags is not empty.
+func linkWithSymbolTable(config *compileopts.Config, compilerConfig *compiler.Config, ldflags, stripFlags []string, executable, tmpdir string) error {
+	objfile := filepath.Join(tmpdir, "symtab.o")
+	linkTable := func(table []byte, extraFlags []string) error {
+		err := createSymbolTableObject(table, objfile, compilerConfig)
+		if err != nil {
+			return err
+		}
+		flags := append(append(ldflags[:len(ldflags):len(ldflags)], objfile), extraFlags...)
+		if config.Options.PrintCommands != nil {
+			config.Options.PrintCommands(config.Target.Linker, flags...)
+		}
+		return link(config.Target.Linker, flags...)
+	}
+
+	// Start with an empty table: a header with zero functions and files.
+	table := make([]byte, 8)
+	for i := 0; ; i++ {
+		if i >= 4 {
+			return errors.New("could not create symbol table: the layout did not converge")
+		}
+		err := linkTable(table, nil)
+		if err != nil {
+			return err
+		}
+		newTable, err := makeSymbolTable(executable)
+		if err != nil {
+			return fmt.Errorf("could not create symbol table: %w", err)
+		}
+		if newTable == nil {
+			// The table isn't referenced from the program, so there is no
+			// need to create it.
+			break
+		}
+		if bytes.Equal(newTable, table) {
+			break
+		}
+		if len(newTable) == len(table) {
+			// Same layout, only some function addresses changed (because the
+			// table itself moved code around). Patch the new table in place.
+			table = newTable
+			err := replaceElfSymbolData(executable, symtabSymbol, table)
+			if err != nil {
+				return err
+			}
+			break
+		}
+		table = newTable
+	}
+
+	if len(stripFlags) != 0 {
+		// Link once more without debug information. This doesn't affect the
+		// layout of the program, so the table is still valid.
+		return linkTable(table, stripFlags)
+	}
+	return nil
+}
+
+// createSymbolTableObject writes an object file to path that contains the
+// symbol table global.
+func createSymbolTableObject(table []byte, path string, compilerConfig *compiler.Config) error {
+	ctx := llvm.NewContext()
+	defer ctx.Dispose()
+	mod := ctx.NewModule("symtab")
+	defer mod.Dispose()
+
+	value := ctx.ConstString(string(table), false)
+	global := llvm.AddGlobal(mod, value.Type(), symtabSymbol)
+	global.SetInitializer(value)
+	global.SetGlobalConstant(true)
+	global.SetAlignment(8)
+
+	machine, err := compiler.NewTargetMachine(compilerConfig)
+	if err != nil {
+		return err
+	}
+	defer machine.Dispose()
+	buf, err := machine.EmitToMemoryBuffer(mod, llvm.ObjectFile)
+	if err != nil {
+		return err
+	}
+	defer buf.Dispose()
+	return os.WriteFile(path, buf.Bytes(), 0666)
+}
+
+type symtabLineRow struct {
+	address uint64
+	file    string
+	line    int
+}
+
+type symtabFunction struct {
+	name  string
+	entry uint64
+	size  uint64
+}
+
+// makeSymbolTable creates the symbol table from the function symbols and DWARF
+// line tables in the given executable. It returns nil (without an error) if the
+// executable doesn't reference the symbol table.
+func makeSymbolTable(executable string) ([]byte, error) {
+	file, err := elf.Open(executable)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	symbols, err := file.Symbols()
+	if err != nil {
+		return nil, err
+	}
+
+	// Collect all functions in the program.
+	var functions []symtabFunction
+	hasTable := false
+	for _, symbol := range symbols {
+		if symbol.Name == symtabSymbol {
+			hasTable = true
+		}
+		if elf.ST_TYPE(symbol.Info) != elf.STT_FUNC || symbol.Size == 0 || symbol.Section == elf.SHN_UNDEF {
+			continue
+		}
+		entry := symbol.Value
+		if file.Machine == elf.EM_ARM {
+			// Remove the Thumb bit.
+			entry &^= 1
+		}
+		functions = append(functions, symtabFunction{
+			name:  symtabFunctionName(symbol.Name),
+			entry: entry,
+			size:  symbol.Size,
+		})
+	}
+	if !hasTable {
+		return nil, nil
+	}
+	sort.SliceStable(functions, func(i, j int) bool {
+		return functions[i].entry < functions[j].entry
+	})
+
+	// Remove aliases (multiple symbols for the same function) and overlapping
+	// functions, so that a binary search in the runtime finds the right
+	// function.
+	var uniqueFunctions []symtabFunction
+	for _, fn := range functions {
+		if len(uniqueFunctions) != 0 {
+			last := uniqueFunctions[len(uniqueFunctions)-1]
+			if fn.entry < last.entry+last.size {
+				continue
+			}
+		}
+		uniqueFunctions = append(uniqueFunctions, fn)
+	}
+	functions = uniqueFunctions
+
+	// Read all line table rows.
+	rows, err := readLineRows(file)
+	if err != nil {
+		return nil, err
+	}
+
+	// Now encode the table.
+	ptrSize := 4
+	if file.Class == elf.ELFCLASS64 {
+		ptrSize = 8
+	}
+	order := file.ByteOrder
+	funcSize := (ptrSize + 12 + ptrSize - 1) &^ (ptrSize - 1) // entry, size, name, lines (padded)
+
+	var blob []byte
+	stringOffsets := map[string]uint32{}
+	addString := func(s string) uint32 {
+		if offset, ok := stringOffsets[s]; ok {
+			return offset
+		}
+		offset := uint32(len(blob))
+		blob = binary.AppendUvarint(blob, uint64(len(s)))
+		blob = append(blob, s...)
+		stringOffsets[s] = offset
+		return offset
+	}
+	var files []uint32
+	fileIndices := map[string]uint64{}
+
+	funcs := make([]byte, len(functions)*funcSize)
+	for i, fn := range functions {
+		// Find the line table rows for this function.
+		start := sort.Search(len(rows), func(i int) bool {
+			return rows[i].address >= fn.entry
+		})
+		var lines []byte
+		count := 0
+		address := fn.entry
+		line := 0
+		for j := start; j < len(rows) && rows[j].address < fn.entry+fn.size; j++ {
+			row := rows[j]
+			if count != 0 && rows[j-1].file == row.file && rows[j-1].line == row.line {
+				continue // no change
+			}
+			fileIndex, ok := fileIndices[row.file]
+			if !ok {
+				fileIndex = uint64(len(files))
+				fileIndices[row.file] = fileIndex
+				files = append(files, addString(row.file))
+			}
+			lines = binary.AppendUvarint(lines, row.address-address)
+			lines = binary.AppendUvarint(lines, fileIndex)
+			lines = binary.AppendVarint(lines, int64(row.line-line))
+			address = row.address
+			line = row.line
+			count++
+		}
+		linesOffset := uint32(len(blob))
+		blob = binary.AppendUvarint(blob, uint64(count))
+		blob = append(blob, lines...)
+
+		entry := funcs[i*funcSize:]
+		if ptrSize == 8 {
+			order.PutUint64(entry, fn.entry)
+		} else {
+			order.PutUint32(entry, uint32(fn.entry))
+		}
+		order.PutUint32(entry[ptrSize:], uint32(fn.size))
+		order.PutUint32(entry[ptrSize+4:], addString(fn.name))
+		order.PutUint32(entry[ptrSize+8:], linesOffset)
+	}
+
+	table := make([]byte, 8+len(funcs)+len(files)*4, 8+len(funcs)+len(files)*4+len(blob))
+	order.PutUint32(table[0:], uint32(len(functions)))
+	order.PutUint32(table[4:], uint32(len(files)))
+	copy(table[8:], funcs)
+	for i, offset := range files {
+		order.PutUint32(table[8+len(funcs)+i*4:], offset)
+	}
+	table = append(table, blob...)
+	return table, nil
+}
+
+// Read all rows from all DWARF line tables in the file, sorted by address.
+func readLineRows(file *elf.File) ([]symtabLineRow, error) {
+	data, err := file.DWARF()
+	if err != nil {
+		return nil, err
+	}
+	var rows []symtabLineRow
+	r := data.Reader()
+	for {
+		e, err := r.Next()
+		if err != nil {
+			return nil, err
+		}
+		if e == nil {
+			break
+		}
+		if e.Tag != dwarf.TagCompileUnit {
+			r.SkipChildren()
+			continue
+		}
+		r.SkipChildren()
+		lr, err := data.LineReader(e)
+		if err != nil {
+			return nil, err
+		}
+		if lr == nil {
+			continue
+		}
+		compDir, _ := e.Val(dwarf.AttrCompDir).(string)
+		var entry dwarf.LineEntry
+		for {
+			err := lr.Next(&entry)
+			if err != nil {
+				break // io.EOF or a malformed line table
+			}
+			if entry.EndSequence || entry.File == nil || entry.Line == 0 {
+				continue
+			}
+			file := entry.File.Name
+			if !filepath.IsAbs(file) && compDir != "" {
+				file = filepath.Join(compDir, file)
+			}
+			rows = append(rows, symtabLineRow{
+				address: entry.Address,
+				file:    file,
+				line:    entry.Line,
+			})
+		}
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		return rows[i].address < rows[j].address
+	})
+	return rows, nil
+}
+
+// symtabFunctionName converts a TinyGo symbol name to the function name format
+// used by the gc toolchain. For example, "(*bytes.Buffer).Write" becomes
+// "bytes.(*Buffer).Write" and "main.main$1" becomes "main.main.func1".
+func symtabFunctionName(name string) string {
+	if strings.HasPrefix(name, "(") {
+		end := strings.Index(name, ")")
+		if end > 0 {
+			receiver := name[1:end]
+			pointer := strings.HasPrefix(receiver, "*")
+			receiver = strings.TrimPrefix(receiver, "*")
+			// Type parameters may contain dots too, so only look at the part
+			// before them.
+			typeEnd := strings.IndexByte(receiver, '[')
+			if typeEnd < 0 {
+				typeEnd = len(receiver)
+			}
+			if dot := strings.LastIndexByte(receiver[:typeEnd], '.'); dot >= 0 {
+				pkg, typ := receiver[:dot], receiver[dot+1:]
+				if pointer {
+					typ = "(*" + typ + ")"
+				}
+				name = pkg + "." + typ + name[end+1:]
+			}
+		}
+	}
+	if index := strings.LastIndexByte(name, '$'); index >= 0 {
+		if _, err := strconv.Atoi(name[index+1:]); err == nil {
+			name = name[:index] + ".func" + name[index+1:]
+		}
+	}
+	return name
+}
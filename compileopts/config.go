@@ -9,6 +9,7 @@ import (
 	"path/filepath"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/google/shlex"
 	"github.com/tinygo-org/tinygo/goenv"
@@ -99,6 +100,18 @@ func (c *Config) BuildTags() []string {
 		"math_big_pure_go",                           // to get math/big to work
 		"gc." + c.GC(), "scheduler." + c.Scheduler(), // used inside the runtime package
 		"serial." + c.Serial()}...) // used inside the machine package
+	if c.Symtab() {
+		tags = append(tags, "tinygo.symtab") // used inside the runtime package
+	}
+	if c.Fuzz() {
+		tags = append(tags, "tinygo.fuzz") // used inside the runtime package
+	}
+	if c.Sanitize("address") {
+		tags = append(tags, "tinygo.asan") // used inside the runtime package
+	}
+	if c.Sanitize("undefined") {
+		tags = append(tags, "tinygo.ubsan") // used inside the runtime package
+	}
 	for i := 1; i <= c.GoMinorVersion; i++ {
 		tags = append(tags, fmt.Sprintf("go1.%d", i))
 	}
@@ -107,7 +120,8 @@ func (c *Config) BuildTags() []string {
 }
 
 // GC returns the garbage collection strategy in use on this platform. Valid
-// values are "none", "leaking", "conservative" and "precise".
+// values are "none", "leaking", "conservative", "custom", "precise",
+// "incremental" and "compacting".
 func (c *Config) GC() string {
 	if c.Options.GC != "" {
 		return c.Options.GC
@@ -122,7 +136,7 @@ func (c *Config) GC() string {
 // that can be traced by the garbage collector.
 func (c *Config) NeedsStackObjects() bool {
 	switch c.GC() {
-	case "conservative", "custom", "precise":
+	case "conservative", "custom", "precise", "incremental", "compacting":
 		for _, tag := range c.BuildTags() {
 			if tag == "tinygo.wasm" {
 				return true
@@ -135,8 +149,17 @@ func (c *Config) NeedsStackObjects() bool {
 	}
 }
 
+// GCPauseBudget returns the maximum time a single step of the incremental
+// garbage collector may take. It is only used with -gc=incremental.
+func (c *Config) GCPauseBudget() time.Duration {
+	if c.Options.GCPauseBudget != 0 {
+		return c.Options.GCPauseBudget
+	}
+	return time.Millisecond
+}
+
 // Scheduler returns the scheduler implementation. Valid values are "none",
-// "asyncify" and "tasks".
+// "asyncify", "tasks" and "threads".
 func (c *Config) Scheduler() string {
 	if c.Options.Scheduler != "" {
 		return c.Options.Scheduler
@@ -372,6 +395,10 @@ func (c *Config) CFlags(libclang bool) []string {
 	cflags = append(cflags, "-gdwarf-4")
 	// Use the same optimization level as TinyGo.
 	cflags = append(cflags, "-O"+c.Options.Opt)
+	if c.Symtab() {
+		// Keep the frame pointer chain intact when C code calls back into Go.
+		cflags = append(cflags, "-fno-omit-frame-pointer")
+	}
 	// Set the LLVM target triple.
 	cflags = append(cflags, "--target="+c.Triple())
 	// Set the -mcpu (or similar) flag.
@@ -408,6 +435,14 @@ func (c *Config) LDFlags() []string {
 	if c.Target.LinkerScript != "" {
 		ldflags = append(ldflags, "-T", c.Target.LinkerScript)
 	}
+	if c.Sanitize("address") {
+		// Redirect calls to the C allocator to the AddressSanitizer
+		// allocator in the runtime, which adds redzones around every
+		// allocation.
+		for _, fn := range []string{"malloc", "calloc", "realloc", "free"} {
+			ldflags = append(ldflags, "--wrap="+fn)
+		}
+	}
 	ldflags = append(ldflags, c.Options.ExtLDFlags...)
 
 	return ldflags
@@ -438,6 +473,71 @@ func (c *Config) Debug() bool {
 	return c.Options.Debug
 }
 
+// Symtab returns whether a table that maps program counters to functions,
+// files and lines should be embedded in the binary. This is needed for
+// runtime.Caller, runtime.Callers and similar functions, and is enabled with
+// the -symtab flag.
+func (c *Config) Symtab() bool {
+	// Sanitizer reports include a stack trace, which needs the symbol table.
+	return c.Options.Symtab || c.Options.Sanitize != ""
+}
+
+// Sanitize returns whether the given sanitizer ("address" or "undefined") is
+// enabled with the -sanitize flag.
+func (c *Config) Sanitize(sanitizer string) bool {
+	if c.Options.Sanitize == "" {
+		return false
+	}
+	for _, s := range strings.Split(c.Options.Sanitize, ",") {
+		if s == sanitizer {
+			return true
+		}
+	}
+	return false
+}
+
+// SanitizerCFlags returns the flags to compile C code (outside of the standard
+// library) with the sanitizers enabled with -sanitize. The sanitizer runtime
+// is implemented in the runtime package, so only features supported there
+// are enabled.
+func (c *Config) SanitizerCFlags() []string {
+	var cflags []string
+	if c.Sanitize("address") {
+		cflags = append(cflags,
+			"-fsanitize=address",
+			"-fsanitize-address-use-after-return=never",
+			"-fno-sanitize-address-globals-dead-stripping")
+	}
+	if c.Sanitize("undefined") {
+		cflags = append(cflags, "-fsanitize=undefined", "-fsanitize-minimal-runtime")
+	}
+	return cflags
+}
+
+// Fuzz returns whether a fuzz test is going to be run (using tinygo test
+// -fuzz). In that case, packages outside the standard library are instrumented
+// with coverage counters that guide the fuzzer.
+func (c *Config) Fuzz() bool {
+	return c.Options.TestConfig.CompileTestBinary && c.Options.TestConfig.FuzzRegexp != ""
+}
+
+// CoverMode returns the coverage mode ("set", "count", or "atomic") when the
+// package under test is instrumented for statement coverage, or the empty
+// string if coverage is disabled.
+func (c *Config) CoverMode() string {
+	testConfig := c.Options.TestConfig
+	if !testConfig.CompileTestBinary {
+		return ""
+	}
+	if testConfig.CoverMode != "" {
+		return testConfig.CoverMode
+	}
+	if testConfig.Cover || testConfig.CoverProfile != "" {
+		return "set"
+	}
+	return ""
+}
+
 // BinaryFormat returns an appropriate binary format, based on the file
 // extension and the configured binary format in the target JSON file.
 func (c *Config) BinaryFormat(ext string) string {
@@ -606,4 +706,10 @@ type TestConfig struct {
 	BenchTime         string
 	BenchMem          bool
 	Shuffle           string
+	FuzzRegexp        string
+	FuzzTime          string
+	Cover             bool
+	CoverMode         string
+	CoverProfile      string
+	JSON              bool
 }
@@ -9,12 +9,15 @@ import (
 
 var (
 	validBuildModeOptions     = []string{"default", "c-shared", "wasi-legacy"}
-	validGCOptions            = []string{"none", "leaking", "conservative", "custom", "precise"}
-	validSchedulerOptions     = []string{"none", "tasks", "asyncify"}
+	validGCOptions            = []string{"none", "leaking", "conservative", "custom", "precise", "incremental", "compacting"}
+	validSchedulerOptions     = []string{"none", "tasks", "asyncify", "threads"}
 	validSerialOptions        = []string{"none", "uart", "usb", "rtt"}
 	validPrintSizeOptions     = []string{"none", "short", "full", "html"}
+	validAllocReportOptions   = []string{"none", "text", "json"}
 	validPanicStrategyOptions = []string{"print", "trap"}
 	validOptOptions           = []string{"none", "0", "1", "2", "s", "z"}
+	validCoverModeOptions     = []string{"set", "count", "atomic"}
+	validSanitizeOptions      = []string{"address", "undefined"}
 )
 
 // Options contains extra options to give to the compiler. These options are
@@ -30,6 +33,7 @@ type Options struct {
 	BuildMode       string // -buildmode flag
 	Opt             string
 	GC              string
+	GCPauseBudget   time.Duration // -gc-pause flag: maximum pause of a single incremental GC step
 	PanicStrategy   string
 	Scheduler       string
 	StackSize       uint64 // goroutine stack size (if none could be automatically determined)
@@ -43,9 +47,14 @@ type Options struct {
 	PrintCommands   func(cmd string, args ...string) `json:"-"`
 	Semaphore       chan struct{}                    `json:"-"` // -p flag controls cap
 	Debug           bool
+	Symtab          bool   // -symtab flag to embed a PC to file/line table
+	Sanitize        string // -sanitize flag, comma separated list of sanitizers
 	PrintSizes      string
+	SizeBudget      string         // -size-budget flag: JSON file with flash and RAM limits
 	PrintAllocs     *regexp.Regexp // regexp string
+	AllocReport     string         // -alloc-report flag: list remaining heap allocations
 	PrintStacks     bool
+	Manifest        string // -manifest flag: path to write a JSON build manifest to
 	Tags            []string
 	GlobalValues    map[string]map[string]string // map[pkgpath]map[varname]value
 	TestConfig      TestConfig
@@ -79,6 +88,14 @@ func (o *Options) Verify() error {
 				strings.Join(validGCOptions, ", "))
 		}
 	}
+	if o.GCPauseBudget != 0 {
+		if o.GCPauseBudget < 0 {
+			return fmt.Errorf("invalid -gc-pause=%s: must not be negative", o.GCPauseBudget)
+		}
+		if o.GC != "incremental" {
+			return fmt.Errorf("-gc-pause can only be used with -gc=incremental")
+		}
+	}
 
 	if o.Scheduler != "" {
 		valid := isInArray(validSchedulerOptions, o.Scheduler)
@@ -88,6 +105,11 @@ func (o *Options) Verify() error {
 				strings.Join(validSchedulerOptions, ", "))
 		}
 	}
+	if o.Scheduler == "threads" && (o.GC == "incremental" || o.GC == "compacting") {
+		// Other threads would keep running while the heap is being marked
+		// or compacted.
+		return fmt.Errorf("-gc=%s can't be used with -scheduler=threads", o.GC)
+	}
 
 	if o.Serial != "" {
 		valid := isInArray(validSerialOptions, o.Serial)
@@ -107,6 +129,15 @@ func (o *Options) Verify() error {
 		}
 	}
 
+	if o.AllocReport != "" {
+		valid := isInArray(validAllocReportOptions, o.AllocReport)
+		if !valid {
+			return fmt.Errorf(`invalid alloc-report option '%s': valid values are %s`,
+				o.AllocReport,
+				strings.Join(validAllocReportOptions, ", "))
+		}
+	}
+
 	if o.PanicStrategy != "" {
 		valid := isInArray(validPanicStrategyOptions, o.PanicStrategy)
 		if !valid {
@@ -122,6 +153,20 @@ func (o *Options) Verify() error {
 		}
 	}
 
+	if o.Sanitize != "" {
+		for _, sanitizer := range strings.Split(o.Sanitize, ",") {
+			if !isInArray(validSanitizeOptions, sanitizer) {
+				return fmt.Errorf("invalid -sanitize=%s: valid values are %s", o.Sanitize, strings.Join(validSanitizeOptions, ", "))
+			}
+		}
+	}
+
+	if o.TestConfig.CoverMode != "" {
+		if !isInArray(validCoverModeOptions, o.TestConfig.CoverMode) {
+			return fmt.Errorf("invalid -covermode=%s: valid values are %s", o.TestConfig.CoverMode, strings.Join(validCoverModeOptions, ", "))
+		}
+	}
+
 	return nil
 }
 
@@ -3,16 +3,24 @@ package compileopts_test
 import (
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/tinygo-org/tinygo/compileopts"
 )
 
 func TestVerifyOptions(t *testing.T) {
 
-	expectedGCError := errors.New(`invalid gc option 'incorrect': valid values are none, leaking, conservative, custom, precise`)
-	expectedSchedulerError := errors.New(`invalid scheduler option 'incorrect': valid values are none, tasks, asyncify`)
+	expectedGCError := errors.New(`invalid gc option 'incorrect': valid values are none, leaking, conservative, custom, precise, incremental, compacting`)
+	expectedGCPauseError := errors.New(`-gc-pause can only be used with -gc=incremental`)
+	expectedNegativeGCPauseError := errors.New(`invalid -gc-pause=-1ms: must not be negative`)
+	expectedSchedulerError := errors.New(`invalid scheduler option 'incorrect': valid values are none, tasks, asyncify, threads`)
+	expectedThreadsIncrementalError := errors.New(`-gc=incremental can't be used with -scheduler=threads`)
+	expectedThreadsCompactingError := errors.New(`-gc=compacting can't be used with -scheduler=threads`)
 	expectedPrintSizeError := errors.New(`invalid size option 'incorrect': valid values are none, short, full, html`)
+	expectedAllocReportError := errors.New(`invalid alloc-report option 'incorrect': valid values are none, text, json`)
 	expectedPanicStrategyError := errors.New(`invalid panic option 'incorrect': valid values are print, trap`)
+	expectedCoverModeError := errors.New(`invalid -covermode=incorrect: valid values are set, count, atomic`)
+	expectedSanitizeError := errors.New(`invalid -sanitize=address,thread: valid values are address, undefined`)
 
 	testCases := []struct {
 		name          string
@@ -54,6 +62,35 @@ func TestVerifyOptions(t *testing.T) {
 				GC: "custom",
 			},
 		},
+		{
+			name: "GCOptionIncremental",
+			opts: compileopts.Options{
+				GC:            "incremental",
+				GCPauseBudget: 500 * time.Microsecond,
+			},
+		},
+		{
+			name: "GCOptionCompacting",
+			opts: compileopts.Options{
+				GC: "compacting",
+			},
+		},
+		{
+			name: "GCPauseWithoutIncremental",
+			opts: compileopts.Options{
+				GC:            "conservative",
+				GCPauseBudget: time.Millisecond,
+			},
+			expectedError: expectedGCPauseError,
+		},
+		{
+			name: "GCPauseNegative",
+			opts: compileopts.Options{
+				GC:            "incremental",
+				GCPauseBudget: -time.Millisecond,
+			},
+			expectedError: expectedNegativeGCPauseError,
+		},
 		{
 			name: "InvalidSchedulerOption",
 			opts: compileopts.Options{
@@ -73,6 +110,28 @@ func TestVerifyOptions(t *testing.T) {
 				Scheduler: "tasks",
 			},
 		},
+		{
+			name: "SchedulerOptionThreads",
+			opts: compileopts.Options{
+				Scheduler: "threads",
+			},
+		},
+		{
+			name: "SchedulerOptionThreadsIncremental",
+			opts: compileopts.Options{
+				Scheduler: "threads",
+				GC:        "incremental",
+			},
+			expectedError: expectedThreadsIncrementalError,
+		},
+		{
+			name: "SchedulerOptionThreadsCompacting",
+			opts: compileopts.Options{
+				Scheduler: "threads",
+				GC:        "compacting",
+			},
+			expectedError: expectedThreadsCompactingError,
+		},
 		{
 			name: "InvalidPrintSizeOption",
 			opts: compileopts.Options{
@@ -98,6 +157,19 @@ func TestVerifyOptions(t *testing.T) {
 				PrintSizes: "full",
 			},
 		},
+		{
+			name: "InvalidAllocReportOption",
+			opts: compileopts.Options{
+				AllocReport: "incorrect",
+			},
+			expectedError: expectedAllocReportError,
+		},
+		{
+			name: "AllocReportOptionJSON",
+			opts: compileopts.Options{
+				AllocReport: "json",
+			},
+		},
 		{
 			name: "InvalidPanicOption",
 			opts: compileopts.Options{
@@ -117,6 +189,32 @@ func TestVerifyOptions(t *testing.T) {
 				PanicStrategy: "trap",
 			},
 		},
+		{
+			name: "InvalidCoverModeOption",
+			opts: compileopts.Options{
+				TestConfig: compileopts.TestConfig{
+					CoverMode: "incorrect",
+				},
+			},
+			expectedError: expectedCoverModeError,
+		},
+		{
+			name: "CoverModeOptionAtomic",
+			opts: compileopts.Options{
+				TestConfig: compileopts.TestConfig{
+					CoverMode: "atomic",
+				},
+			},
+		},
+		{
+			name:          "InvalidSanitizeOption",
+			opts:          compileopts.Options{Sanitize: "address,thread"},
+			expectedError: expectedSanitizeError,
+		},
+		{
+			name: "SanitizeOptionAddressUndefined",
+			opts: compileopts.Options{Sanitize: "address,undefined"},
+		},
 	}
 
 	for _, tc := range testCases {
@@ -410,6 +410,9 @@ func defaultTarget(options *Options) (*TargetSpec, error) {
 			"src/internal/futex/futex_linux.c",
 			"src/runtime/runtime_unix.c",
 			"src/runtime/signal.c")
+		if options.Scheduler == "threads" {
+			spec.ExtraFiles = append(spec.ExtraFiles, "src/internal/task/task_threads.c")
+		}
 	case "windows":
 		spec.Linker = "ld.lld"
 		spec.Libc = "mingw-w64"
@@ -472,7 +475,10 @@ func defaultTarget(options *Options) (*TargetSpec, error) {
 			asmGoarch = "mipsx"
 		}
 		spec.ExtraFiles = append(spec.ExtraFiles, "src/runtime/asm_"+asmGoarch+suffix+".S")
-		spec.ExtraFiles = append(spec.ExtraFiles, "src/internal/task/task_stack_"+asmGoarch+suffix+".S")
+		if options.Scheduler != "threads" {
+			// Goroutines are OS threads, so no stack switching is needed.
+			spec.ExtraFiles = append(spec.ExtraFiles, "src/internal/task/task_stack_"+asmGoarch+suffix+".S")
+		}
 	}
 
 	// Configure the emulator.
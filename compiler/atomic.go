@@ -29,6 +29,9 @@ func (b *builder) createAtomicOp(name string) llvm.Value {
 		ptr := b.getValue(b.fn.Params[0], getPos(b.fn))
 		val := b.getValue(b.fn.Params[1], getPos(b.fn))
 		oldVal := b.CreateAtomicRMW(llvm.AtomicRMWBinOpXchg, ptr, val, llvm.AtomicOrderingSequentiallyConsistent, true)
+		if b.WriteBarriers && typeHasPointers(val.Type()) {
+			b.createWriteBarrier(ptr)
+		}
 		return oldVal
 	case "CompareAndSwapInt32", "CompareAndSwapInt64", "CompareAndSwapUint32", "CompareAndSwapUint64", "CompareAndSwapUintptr", "CompareAndSwapPointer":
 		ptr := b.getValue(b.fn.Params[0], getPos(b.fn))
@@ -36,6 +39,9 @@ func (b *builder) createAtomicOp(name string) llvm.Value {
 		newVal := b.getValue(b.fn.Params[2], getPos(b.fn))
 		tuple := b.CreateAtomicCmpXchg(ptr, old, newVal, llvm.AtomicOrderingSequentiallyConsistent, llvm.AtomicOrderingSequentiallyConsistent, true)
 		swapped := b.CreateExtractValue(tuple, 1, "")
+		if b.WriteBarriers && typeHasPointers(newVal.Type()) {
+			b.createWriteBarrier(ptr)
+		}
 		return swapped
 	case "LoadInt32", "LoadInt64", "LoadUint32", "LoadUint64", "LoadUintptr", "LoadPointer":
 		ptr := b.getValue(b.fn.Params[0], getPos(b.fn))
@@ -49,6 +55,9 @@ func (b *builder) createAtomicOp(name string) llvm.Value {
 		store := b.CreateStore(val, ptr)
 		store.SetOrdering(llvm.AtomicOrderingSequentiallyConsistent)
 		store.SetAlignment(b.targetData.PrefTypeAlignment(val.Type())) // required
+		if b.WriteBarriers && typeHasPointers(val.Type()) {
+			b.createWriteBarrier(ptr)
+		}
 		return llvm.Value{}
 	default:
 		b.addError(b.fn.Pos(), "unknown atomic operation: "+b.fn.Name())
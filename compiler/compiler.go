@@ -58,7 +58,12 @@ type Config struct {
 	MaxStackAlloc      uint64
 	NeedsStackObjects  bool
 	Debug              bool // Whether to emit debug information in the LLVM module.
+	FramePointers      bool // Whether to keep frame pointers in all functions (for -symtab).
 	PanicStrategy      string
+	FuzzCoverage       bool  // Whether to insert coverage counters for fuzzing in packages outside the standard library.
+	WriteBarriers      bool  // Whether to call runtime.gcWriteBarrier after storing a pointer (for -gc=incremental).
+	GCPauseBudget      int64 // Maximum pause of an incremental GC step in nanoseconds (for -gc=incremental).
+	MovingGC           bool  // Whether the GC may move heap objects (for -gc=compacting).
 }
 
 // compilerContext contains function-independent data that should still be
@@ -91,6 +96,7 @@ type compilerContext struct {
 	pkg              *types.Package
 	packageDir       string // directory for this package
 	runtimePkg       *types.Package
+	fuzzCounters     llvm.Value // runtime.fuzzCounters, if this package is instrumented for fuzzing
 }
 
 // newCompilerContext returns a new compiler context ready for use, most
@@ -300,6 +306,11 @@ func CompilePackage(moduleName string, pkg *loader.Package, ssaPkg *ssa.Package,
 	// Load comments such as //go:extern on globals.
 	c.loadASTComments(pkg)
 
+	if c.FuzzCoverage && !pkg.Standard {
+		// Record which basic blocks are reached, to guide the fuzzer.
+		c.fuzzCounters = c.getGlobal(c.program.ImportedPackage("runtime").Members["fuzzCounters"].(*ssa.Global))
+	}
+
 	// Predeclare the runtime.alloc function, which is used by the wordpack
 	// functionality.
 	c.getFunction(c.program.ImportedPackage("runtime").Members["alloc"].(*ssa.Function))
@@ -1304,7 +1315,13 @@ func (b *builder) createFunction() {
 		}
 		b.SetInsertPointAtEnd(b.blockEntries[block])
 		b.currentBlock = block
+		needsFuzzCounter := !b.fuzzCounters.IsNil()
 		for _, instr := range block.Instrs {
+			if _, ok := instr.(*ssa.Phi); !ok && needsFuzzCounter {
+				// The counter must be inserted after all phi nodes.
+				b.createFuzzCounter(block)
+				needsFuzzCounter = false
+			}
 			if instr, ok := instr.(*ssa.DebugRef); ok {
 				if !b.Debug {
 					continue
@@ -1530,6 +1547,9 @@ func (b *builder) createInstruction(instr ssa.Instruction) {
 			return
 		}
 		b.CreateStore(llvmVal, llvmAddr)
+		if b.WriteBarriers && typeHasPointers(llvmVal.Type()) && needsWriteBarrier(instr.Addr) {
+			b.createWriteBarrier(llvmAddr)
+		}
 	default:
 		b.addError(instr.Pos(), "unknown instruction: "+instr.String())
 	}
@@ -1877,6 +1897,8 @@ func (b *builder) createFunctionCall(instr *ssa.CallCommon) (llvm.Value, error)
 				"trap":  tinygo.PanicStrategyTrap,
 			}[b.Config.PanicStrategy]
 			return llvm.ConstInt(b.ctx.Int8Type(), panicStrategy, false), nil
+		case name == "runtime.gcPauseBudget":
+			return llvm.ConstInt(b.ctx.Int64Type(), uint64(b.Config.GCPauseBudget), false), nil
 		case name == "runtime/interrupt.New":
 			return b.createInterruptGlobal(instr)
 		case name == "internal/abi.FuncPCABI0":
@@ -0,0 +1,35 @@
+package compiler
+
+// This file implements the coverage instrumentation used by tinygo test -fuzz.
+// At the start of every basic block, a counter in runtime.fuzzCounters is
+// incremented. The fuzzer (in the testing package) uses these counters to find
+// out whether an input reached code that wasn't reached before.
+
+import (
+	"hash/fnv"
+	"strconv"
+
+	"golang.org/x/tools/go/ssa"
+	"tinygo.org/x/go-llvm"
+)
+
+// createFuzzCounter increments the coverage counter for the given basic block.
+// The counter index is a hash of the function name and the block index, so
+// that it is stable across builds and independent of compilation order.
+func (b *builder) createFuzzCounter(block *ssa.BasicBlock) {
+	arrayType := b.fuzzCounters.GlobalValueType()
+	numCounters := uint32(arrayType.ArrayLength())
+
+	h := fnv.New32a()
+	h.Write([]byte(b.info.linkName + ":" + strconv.Itoa(block.Index)))
+	index := h.Sum32() % numCounters
+
+	zero := llvm.ConstInt(b.ctx.Int32Type(), 0, false)
+	ptr := b.CreateInBoundsGEP(arrayType, b.fuzzCounters, []llvm.Value{
+		zero,
+		llvm.ConstInt(b.ctx.Int32Type(), uint64(index), false),
+	}, "fuzz.counter")
+	counter := b.CreateLoad(b.ctx.Int8Type(), ptr, "")
+	counter = b.CreateAdd(counter, llvm.ConstInt(b.ctx.Int8Type(), 1, false), "")
+	b.CreateStore(counter, ptr)
+}
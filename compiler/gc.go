@@ -84,6 +84,26 @@ func (b *builder) trackPointer(value llvm.Value) {
 	b.createRuntimeCall("trackPointer", []llvm.Value{value, b.stackChainAlloca}, "")
 }
 
+// createWriteBarrier creates a call to runtime.gcWriteBarrier, which informs
+// the incremental GC that a pointer was stored in the object at addr.
+func (b *builder) createWriteBarrier(addr llvm.Value) {
+	b.createRuntimeCall("gcWriteBarrier", []llvm.Value{addr}, "")
+}
+
+// needsWriteBarrier returns whether a pointer store to the given address may
+// need a write barrier. Globals and stack allocated objects are scanned again
+// at the end of the mark phase, so stores to them don't need a write barrier.
+func needsWriteBarrier(addr ssa.Value) bool {
+	switch addr := addr.(type) {
+	case *ssa.Global:
+		return false
+	case *ssa.Alloc:
+		return addr.Heap
+	default:
+		return true
+	}
+}
+
 // typeHasPointers returns whether this type is a pointer or contains pointers.
 // If the type is an aggregate type, it will check whether there is a pointer
 // inside.
@@ -107,3 +127,24 @@ func typeHasPointers(t llvm.Type) bool {
 		return false
 	}
 }
+
+// typeHasInterface returns whether the given LLVM type contains an interface
+// value.
+func typeHasInterface(t llvm.Type) bool {
+	switch t.TypeKind() {
+	case llvm.StructTypeKind:
+		if t.StructName() == "runtime._interface" {
+			return true
+		}
+		for _, subType := range t.StructElementTypes() {
+			if typeHasInterface(subType) {
+				return true
+			}
+		}
+		return false
+	case llvm.ArrayTypeKind:
+		return typeHasInterface(t.ElementType())
+	default:
+		return false
+	}
+}
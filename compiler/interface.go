@@ -241,14 +241,21 @@ func (c *compilerContext) getTypeCode(typ types.Type) llvm.Value {
 			)
 		case *types.Interface:
 			typeFieldTypes = append(typeFieldTypes,
+				types.NewVar(token.NoPos, nil, "numMethods", types.Typ[types.Uint16]),
 				types.NewVar(token.NoPos, nil, "ptrTo", types.Typ[types.UnsafePointer]),
+				types.NewVar(token.NoPos, nil, "implements", types.Typ[types.UnsafePointer]),
+				types.NewVar(token.NoPos, nil, "methods", types.NewArray(types.Typ[types.UnsafePointer], int64(numMethods*3))),
 			)
-			// TODO: methods
 		case *types.Signature:
 			typeFieldTypes = append(typeFieldTypes,
+				types.NewVar(token.NoPos, nil, "numIn", types.Typ[types.Uint16]),
 				types.NewVar(token.NoPos, nil, "ptrTo", types.Typ[types.UnsafePointer]),
+				types.NewVar(token.NoPos, nil, "call", types.Typ[types.UnsafePointer]),
+				types.NewVar(token.NoPos, nil, "makeFunc", types.Typ[types.UnsafePointer]),
+				types.NewVar(token.NoPos, nil, "numOut", types.Typ[types.Uint16]),
+				types.NewVar(token.NoPos, nil, "variadic", types.Typ[types.Uint8]),
+				types.NewVar(token.NoPos, nil, "params", types.NewArray(types.Typ[types.UnsafePointer], int64(typ.Params().Len()+typ.Results().Len()))),
 			)
-			// TODO: signature params and return values
 		}
 		if hasMethodSet {
 			// This method set is appended at the start of the struct. It is
@@ -405,11 +412,55 @@ func (c *compilerContext) getTypeCode(typ types.Type) llvm.Value {
 			}
 			typeFields = append(typeFields, llvm.ConstArray(structFieldType, fields))
 		case *types.Interface:
-			typeFields = []llvm.Value{c.getTypeCode(types.NewPointer(typ))}
-			// TODO: methods
+			implements := llvm.ConstPointerNull(c.dataPtrType)
+			if numMethods != 0 {
+				implements = c.getInterfaceImplementsTrampoline(typ, globalName+"$implements", isLocal)
+				if c.funcPtrAddrSpace != 0 {
+					// The type struct stores this as a regular data pointer.
+					implements = llvm.ConstIntToPtr(llvm.ConstPtrToInt(implements, c.uintptrType), c.dataPtrType)
+				}
+			}
+			var methods []llvm.Value
+			for i := 0; i < ms.Len(); i++ {
+				method := ms.At(i).Obj().(*types.Func)
+				sig := method.Type().(*types.Signature)
+				methodType := types.NewSignatureType(nil, nil, nil, sig.Params(), sig.Results(), sig.Variadic())
+				methods = append(methods, c.methodNamePtr(method.Name()), c.getMethodSignature(method), c.getTypeCode(methodType))
+			}
+			typeFields = []llvm.Value{
+				llvm.ConstInt(c.ctx.Int16Type(), uint64(numMethods), false), // numMethods
+				c.getTypeCode(types.NewPointer(typ)),                        // ptrTo
+				implements,                                                  // implements
+				llvm.ConstArray(c.dataPtrType, methods),                     // methods
+			}
 		case *types.Signature:
-			typeFields = []llvm.Value{c.getTypeCode(types.NewPointer(typ))}
-			// TODO: params, return values, etc
+			var variadic uint64
+			if typ.Variadic() {
+				variadic = 1
+			}
+			var params []llvm.Value
+			for i := 0; i < typ.Params().Len(); i++ {
+				params = append(params, c.getTypeCode(typ.Params().At(i).Type()))
+			}
+			for i := 0; i < typ.Results().Len(); i++ {
+				params = append(params, c.getTypeCode(typ.Results().At(i).Type()))
+			}
+			call := c.getFuncCallTrampoline(typ, globalName+"$call", isLocal)
+			makeFunc := c.getMakeFuncStub(typ, globalName+"$makefunc", isLocal)
+			if c.funcPtrAddrSpace != 0 {
+				// The type struct stores these as regular data pointers.
+				call = llvm.ConstIntToPtr(llvm.ConstPtrToInt(call, c.uintptrType), c.dataPtrType)
+				makeFunc = llvm.ConstIntToPtr(llvm.ConstPtrToInt(makeFunc, c.uintptrType), c.dataPtrType)
+			}
+			typeFields = []llvm.Value{
+				llvm.ConstInt(c.ctx.Int16Type(), uint64(typ.Params().Len()), false), // numIn
+				c.getTypeCode(types.NewPointer(typ)),                                // ptrTo
+				call,                                                                // call
+				makeFunc,                                                            // makeFunc
+				llvm.ConstInt(c.ctx.Int16Type(), uint64(typ.Results().Len()), false), // numOut
+				llvm.ConstInt(c.ctx.Int8Type(), variadic, false),                     // variadic
+				llvm.ConstArray(c.dataPtrType, params),                               // params
+			}
 		}
 		// Prepend metadata byte.
 		typeFields = append([]llvm.Value{
@@ -576,7 +627,11 @@ func getTypeCodeName(t types.Type) (string, bool) {
 			}
 			results[i] = s
 		}
-		return "func:" + "{" + strings.Join(params, ",") + "}{" + strings.Join(results, ",") + "}", isLocal
+		variadic := ""
+		if t.Variadic() {
+			variadic = "..."
+		}
+		return "func:" + "{" + strings.Join(params, ",") + variadic + "}{" + strings.Join(results, ",") + "}", isLocal
 	case *types.Slice:
 		s, isLocal := getTypeCodeName(t.Elem())
 		return "slice:" + s, isLocal
@@ -632,6 +687,7 @@ func (c *compilerContext) getTypeMethodSet(typ types.Type) llvm.Value {
 			llvm.ConstInt(c.uintptrType, uint64(ms.Len()), false),
 			llvm.ConstArray(c.dataPtrType, signatures),
 			c.ctx.ConstStruct(wrappers, false),
+			c.getTypeMethodTable(typ, ms),
 		}, false)
 		global = llvm.AddGlobal(c.mod, globalValue.Type(), globalName)
 		global.SetInitializer(globalValue)
@@ -642,6 +698,421 @@ func (c *compilerContext) getTypeMethodSet(typ types.Type) llvm.Value {
 	return global
 }
 
+// getTypeMethodTable returns the table of exported methods used by the reflect
+// package to implement Type.Method and Value.Method, or a null pointer if the
+// type has no exported methods. The table is referenced from the method set,
+// and is kept in the type code by the interface lowering pass only when the
+// program might look up methods using reflection. The layout must match
+// methodTable in src/reflect/type.go.
+func (c *compilerContext) getTypeMethodTable(typ types.Type, ms *types.MethodSet) llvm.Value {
+	globalName := typ.String() + "$reflectmethods"
+	global := c.mod.NamedGlobal(globalName)
+	if !global.IsNil() {
+		return global
+	}
+
+	// Collect all exported methods. They are already sorted by name, as
+	// required by the reflect package.
+	var methods []llvm.Value
+	for i := 0; i < ms.Len(); i++ {
+		method := ms.At(i)
+		if !method.Obj().Exported() {
+			continue
+		}
+		fn := c.program.MethodValue(method)
+		llvmFnType, llvmFn := c.getFunction(fn)
+		sig := method.Type().(*types.Signature)
+		params := []*types.Var{fn.Signature.Recv()}
+		for j := 0; j < sig.Params().Len(); j++ {
+			params = append(params, sig.Params().At(j))
+		}
+		methodType := types.NewSignatureType(nil, nil, nil, sig.Params(), sig.Results(), sig.Variadic())
+		funcType := types.NewSignatureType(nil, nil, nil, types.NewTuple(params...), sig.Results(), sig.Variadic())
+		methods = append(methods, c.ctx.ConstStruct([]llvm.Value{
+			c.methodNamePtr(method.Obj().Name()),
+			c.getTypeCode(methodType),
+			c.getTypeCode(funcType),
+			llvmFn,
+			c.getBoundMethodStub(fn, llvmFnType, llvmFn),
+		}, false))
+	}
+	if len(methods) == 0 {
+		return llvm.ConstNull(c.dataPtrType)
+	}
+
+	globalValue := c.ctx.ConstStruct([]llvm.Value{
+		llvm.ConstInt(c.uintptrType, uint64(len(methods)), false),
+		llvm.ConstArray(methods[0].Type(), methods),
+	}, false)
+	global = llvm.AddGlobal(c.mod, globalValue.Type(), globalName)
+	global.SetInitializer(globalValue)
+	global.SetGlobalConstant(true)
+	global.SetUnnamedAddr(true)
+	global.SetLinkage(llvm.LinkOnceODRLinkage)
+	return global
+}
+
+// methodNamePtr returns a pointer to a null-terminated method name, for use in
+// the reflect method table.
+func (c *compilerContext) methodNamePtr(name string) llvm.Value {
+	globalName := "reflect/types.methodname:" + name
+	global := c.mod.NamedGlobal(globalName)
+	if global.IsNil() {
+		initializer := c.ctx.ConstString(name+"\x00", false)
+		global = llvm.AddGlobal(c.mod, initializer.Type(), globalName)
+		global.SetInitializer(initializer)
+		global.SetAlignment(1)
+		global.SetUnnamedAddr(true)
+		global.SetLinkage(llvm.LinkOnceODRLinkage)
+		global.SetGlobalConstant(true)
+	}
+	return global
+}
+
+// getBoundMethodStub returns a function that calls the given method with the
+// receiver stored in the function context, packed the same way as the value of
+// an interface. The reflect package uses it as the function pointer when it
+// converts a method value (see Value.Method) to a regular func value.
+func (c *compilerContext) getBoundMethodStub(fn *ssa.Function, llvmFnType llvm.Type, llvmFn llvm.Value) llvm.Value {
+	stubName := llvmFn.Name() + "$bound"
+	stub := c.mod.NamedFunction(stubName)
+	if !stub.IsNil() {
+		return stub
+	}
+
+	// The stub has the same parameters as the method, except for the
+	// receiver.
+	receiverType := c.getLLVMType(fn.Signature.Recv().Type())
+	numReceiverParams := len(c.expandFormalParamType(receiverType, "", nil))
+	stubType := llvm.FunctionType(llvmFnType.ReturnType(), llvmFnType.ParamTypes()[numReceiverParams:], false)
+	stub = llvm.AddFunction(c.mod, stubName, stubType)
+	c.addStandardAttributes(stub)
+	stub.SetLinkage(llvm.LinkOnceODRLinkage)
+	stub.SetUnnamedAddr(true)
+
+	// Create a new builder just to create this stub.
+	b := builder{
+		compilerContext: c,
+		Builder:         c.ctx.NewBuilder(),
+	}
+	defer b.Builder.Dispose()
+
+	if c.Debug {
+		pos := c.program.Fset.Position(fn.Pos())
+		difunc := c.attachDebugInfoRaw(fn, stub, "$bound", pos.Filename, pos.Line)
+		b.SetCurrentDebugLocation(uint(pos.Line), uint(pos.Column), difunc, llvm.Metadata{})
+	}
+
+	block := b.ctx.AddBasicBlock(stub, "entry")
+	b.SetInsertPointAtEnd(block)
+
+	// Unpack the receiver from the context parameter and call the method.
+	stubParams := stub.Params()
+	context := stubParams[len(stubParams)-1]
+	receiverValue := b.emitPointerUnpack(context, []llvm.Type{receiverType})[0]
+	params := append(b.expandFormalParam(receiverValue), stubParams[:len(stubParams)-1]...)
+	params = append(params, llvm.Undef(c.dataPtrType))
+	if llvmFnType.ReturnType().TypeKind() == llvm.VoidTypeKind {
+		b.CreateCall(llvmFnType, llvmFn, params, "")
+		b.CreateRetVoid()
+	} else {
+		ret := b.CreateCall(llvmFnType, llvmFn, params, "ret")
+		b.CreateRet(ret)
+	}
+
+	return stub
+}
+
+// getFuncCallTrampoline returns a function that can be used by the reflect
+// package to call a function of the given signature with the parameters stored
+// in memory. It has the following signature:
+//
+//	func(fn, fnContext, params, results unsafe.Pointer)
+//
+// The params and results point to a buffer laid out like a struct with all
+// parameters or results as fields. The trampoline is removed in the interface
+// lowering pass if the program never calls reflect.Value.Call.
+func (c *compilerContext) getFuncCallTrampoline(sig *types.Signature, name string, isLocal bool) llvm.Value {
+	llvmFn := c.mod.NamedFunction(name)
+	if !llvmFn.IsNil() && !isLocal {
+		return llvmFn
+	}
+
+	ptrType := c.dataPtrType
+	llvmFnType := llvm.FunctionType(c.ctx.VoidType(), []llvm.Type{ptrType, ptrType, ptrType, ptrType, ptrType}, false)
+	llvmFn = llvm.AddFunction(c.mod, name, llvmFnType)
+	c.addStandardAttributes(llvmFn)
+	if isLocal {
+		llvmFn.SetLinkage(llvm.InternalLinkage)
+	} else {
+		llvmFn.SetLinkage(llvm.LinkOnceODRLinkage)
+	}
+	llvmFn.SetUnnamedAddr(true)
+
+	// Create a new builder just to create this trampoline.
+	b := builder{
+		compilerContext: c,
+		Builder:         c.ctx.NewBuilder(),
+	}
+	defer b.Builder.Dispose()
+
+	if c.Debug {
+		difile := c.getDIFile("<Go reflect call>")
+		difunc := c.dibuilder.CreateFunction(difile, llvm.DIFunction{
+			Name:         "(Go reflect call)",
+			File:         difile,
+			Type:         c.dibuilder.CreateSubroutineType(llvm.DISubroutineType{File: difile}),
+			LocalToUnit:  true,
+			IsDefinition: true,
+			Flags:        llvm.FlagPrototyped,
+			Optimized:    true,
+		})
+		llvmFn.SetSubprogram(difunc)
+		b.SetCurrentDebugLocation(0, 0, difunc, llvm.Metadata{})
+	}
+
+	block := b.ctx.AddBasicBlock(llvmFn, "entry")
+	b.SetInsertPointAtEnd(block)
+
+	// Load all parameters from the params buffer.
+	var paramTypes []llvm.Type
+	for i := 0; i < sig.Params().Len(); i++ {
+		paramTypes = append(paramTypes, c.getLLVMType(sig.Params().At(i).Type()))
+	}
+	paramsType := c.ctx.StructType(paramTypes, false)
+	var params []llvm.Value
+	for i, paramType := range paramTypes {
+		param := llvm.ConstNull(paramType)
+		if c.targetData.TypeAllocSize(paramType) != 0 {
+			gep := b.CreateInBoundsGEP(paramsType, llvmFn.Param(2), []llvm.Value{
+				llvm.ConstInt(c.ctx.Int32Type(), 0, false),
+				llvm.ConstInt(c.ctx.Int32Type(), uint64(i), false),
+			}, "")
+			param = b.CreateLoad(paramType, gep, "")
+		}
+		params = append(params, b.expandFormalParam(param)...)
+	}
+	params = append(params, llvmFn.Param(1))
+
+	// Call the function and store the results in the results buffer.
+	fnPtr := llvmFn.Param(0)
+	if c.funcPtrAddrSpace != 0 {
+		fnPtr = b.CreateIntToPtr(b.CreatePtrToInt(fnPtr, c.uintptrType, ""), c.funcPtrType, "")
+	}
+	fnType := c.getLLVMFunctionType(types.NewSignatureType(nil, nil, nil, sig.Params(), sig.Results(), sig.Variadic()))
+	result := b.CreateCall(fnType, fnPtr, params, "")
+	var results []llvm.Value
+	switch sig.Results().Len() {
+	case 0:
+	case 1:
+		results = []llvm.Value{result}
+	default:
+		for i := 0; i < sig.Results().Len(); i++ {
+			results = append(results, b.CreateExtractValue(result, i, ""))
+		}
+	}
+	var resultTypes []llvm.Type
+	for _, result := range results {
+		resultTypes = append(resultTypes, result.Type())
+	}
+	resultsType := c.ctx.StructType(resultTypes, false)
+	for i, result := range results {
+		if c.targetData.TypeAllocSize(result.Type()) == 0 {
+			continue
+		}
+		gep := b.CreateInBoundsGEP(resultsType, llvmFn.Param(3), []llvm.Value{
+			llvm.ConstInt(c.ctx.Int32Type(), 0, false),
+			llvm.ConstInt(c.ctx.Int32Type(), uint64(i), false),
+		}, "")
+		b.CreateStore(result, gep)
+	}
+	b.CreateRetVoid()
+
+	return llvmFn
+}
+
+// getInterfaceImplementsTrampoline returns a function that can be used by the
+// reflect package to check whether a type implements the given interface. It
+// has the following signature:
+//
+//	func(typecode unsafe.Pointer) bool
+//
+// It calls the type assert function of the interface, which is defined by the
+// interface lowering pass. The trampoline is removed in the interface lowering
+// pass if the program never calls reflect.Type.Implements or AssignableTo.
+func (c *compilerContext) getInterfaceImplementsTrampoline(typ *types.Interface, name string, isLocal bool) llvm.Value {
+	llvmFn := c.mod.NamedFunction(name)
+	if !llvmFn.IsNil() && !isLocal {
+		return llvmFn
+	}
+
+	ptrType := c.dataPtrType
+	llvmFnType := llvm.FunctionType(c.ctx.Int1Type(), []llvm.Type{ptrType, ptrType}, false)
+	llvmFn = llvm.AddFunction(c.mod, name, llvmFnType)
+	c.addStandardAttributes(llvmFn)
+	if isLocal {
+		llvmFn.SetLinkage(llvm.InternalLinkage)
+	} else {
+		llvmFn.SetLinkage(llvm.LinkOnceODRLinkage)
+	}
+	llvmFn.SetUnnamedAddr(true)
+
+	// Create a new builder just to create this trampoline.
+	b := builder{
+		compilerContext: c,
+		Builder:         c.ctx.NewBuilder(),
+	}
+	defer b.Builder.Dispose()
+
+	if c.Debug {
+		difile := c.getDIFile("<Go reflect implements>")
+		difunc := c.dibuilder.CreateFunction(difile, llvm.DIFunction{
+			Name:         "(Go reflect implements)",
+			File:         difile,
+			Type:         c.dibuilder.CreateSubroutineType(llvm.DISubroutineType{File: difile}),
+			LocalToUnit:  true,
+			IsDefinition: true,
+			Flags:        llvm.FlagPrototyped,
+			Optimized:    true,
+		})
+		llvmFn.SetSubprogram(difunc)
+		b.SetCurrentDebugLocation(0, 0, difunc, llvm.Metadata{})
+	}
+
+	block := b.ctx.AddBasicBlock(llvmFn, "entry")
+	b.SetInsertPointAtEnd(block)
+	fn := c.getInterfaceImplementsFunc(typ)
+	implements := b.CreateCall(fn.GlobalValueType(), fn, []llvm.Value{llvmFn.Param(0)}, "")
+	b.CreateRet(implements)
+
+	return llvmFn
+}
+
+// getMakeFuncStub returns a function with the given signature that is used as
+// the function pointer of functions created by reflect.MakeFunc. It stores all
+// parameters in a buffer and calls reflect.callMakeFunc with the function
+// context, which points to the function created by reflect.MakeFunc:
+//
+//	func callMakeFunc(impl, params, results unsafe.Pointer)
+//
+// The params and results buffers are laid out the same way as for
+// getFuncCallTrampoline. The stub is removed in the interface lowering pass if
+// the program never calls reflect.MakeFunc.
+func (c *compilerContext) getMakeFuncStub(sig *types.Signature, name string, isLocal bool) llvm.Value {
+	llvmFn := c.mod.NamedFunction(name)
+	if !llvmFn.IsNil() && !isLocal {
+		return llvmFn
+	}
+
+	llvmFnType := c.getLLVMFunctionType(types.NewSignatureType(nil, nil, nil, sig.Params(), sig.Results(), sig.Variadic()))
+	llvmFn = llvm.AddFunction(c.mod, name, llvmFnType)
+	c.addStandardAttributes(llvmFn)
+	if isLocal {
+		llvmFn.SetLinkage(llvm.InternalLinkage)
+	} else {
+		llvmFn.SetLinkage(llvm.LinkOnceODRLinkage)
+	}
+	llvmFn.SetUnnamedAddr(true)
+
+	// Create a new builder just to create this stub.
+	b := builder{
+		compilerContext: c,
+		Builder:         c.ctx.NewBuilder(),
+	}
+	defer b.Builder.Dispose()
+
+	if c.Debug {
+		difile := c.getDIFile("<Go reflect makefunc>")
+		difunc := c.dibuilder.CreateFunction(difile, llvm.DIFunction{
+			Name:         "(Go reflect makefunc)",
+			File:         difile,
+			Type:         c.dibuilder.CreateSubroutineType(llvm.DISubroutineType{File: difile}),
+			LocalToUnit:  true,
+			IsDefinition: true,
+			Flags:        llvm.FlagPrototyped,
+			Optimized:    true,
+		})
+		llvmFn.SetSubprogram(difunc)
+		b.SetCurrentDebugLocation(0, 0, difunc, llvm.Metadata{})
+	}
+
+	block := b.ctx.AddBasicBlock(llvmFn, "entry")
+	b.SetInsertPointAtEnd(block)
+
+	// Store all parameters in the params buffer.
+	var params []llvm.Value
+	llvmParamIndex := 0
+	for i := 0; i < sig.Params().Len(); i++ {
+		llvmType := c.getLLVMType(sig.Params().At(i).Type())
+		var fields []llvm.Value
+		for range c.expandFormalParamType(llvmType, "", nil) {
+			fields = append(fields, llvmFn.Param(llvmParamIndex))
+			llvmParamIndex++
+		}
+		params = append(params, b.collapseFormalParam(llvmType, fields))
+	}
+	var paramTypes []llvm.Type
+	for _, param := range params {
+		paramTypes = append(paramTypes, param.Type())
+	}
+	paramsType := c.ctx.StructType(paramTypes, false)
+	paramsBuf := b.CreateAlloca(paramsType, "params")
+	for i, param := range params {
+		if c.targetData.TypeAllocSize(param.Type()) == 0 {
+			continue
+		}
+		gep := b.CreateInBoundsGEP(paramsType, paramsBuf, []llvm.Value{
+			llvm.ConstInt(c.ctx.Int32Type(), 0, false),
+			llvm.ConstInt(c.ctx.Int32Type(), uint64(i), false),
+		}, "")
+		b.CreateStore(param, gep)
+	}
+	context := llvmFn.Param(llvmParamIndex)
+
+	// Call the function created by reflect.MakeFunc.
+	var resultTypes []llvm.Type
+	for i := 0; i < sig.Results().Len(); i++ {
+		resultTypes = append(resultTypes, c.getLLVMType(sig.Results().At(i).Type()))
+	}
+	resultsType := c.ctx.StructType(resultTypes, false)
+	resultsBuf := b.CreateAlloca(resultsType, "results")
+	callFn := c.mod.NamedFunction("reflect.callMakeFunc")
+	if callFn.IsNil() {
+		ptrType := c.dataPtrType
+		callFnType := llvm.FunctionType(c.ctx.VoidType(), []llvm.Type{ptrType, ptrType, ptrType, ptrType}, false)
+		callFn = llvm.AddFunction(c.mod, "reflect.callMakeFunc", callFnType)
+	}
+	b.CreateCall(callFn.GlobalValueType(), callFn, []llvm.Value{context, paramsBuf, resultsBuf, llvm.Undef(c.dataPtrType)}, "")
+
+	// Load the results from the results buffer and return them.
+	var results []llvm.Value
+	for i, resultType := range resultTypes {
+		result := llvm.ConstNull(resultType)
+		if c.targetData.TypeAllocSize(resultType) != 0 {
+			gep := b.CreateInBoundsGEP(resultsType, resultsBuf, []llvm.Value{
+				llvm.ConstInt(c.ctx.Int32Type(), 0, false),
+				llvm.ConstInt(c.ctx.Int32Type(), uint64(i), false),
+			}, "")
+			result = b.CreateLoad(resultType, gep, "")
+		}
+		results = append(results, result)
+	}
+	switch len(results) {
+	case 0:
+		b.CreateRetVoid()
+	case 1:
+		b.CreateRet(results[0])
+	default:
+		retval := llvm.ConstNull(llvmFnType.ReturnType())
+		for i, result := range results {
+			retval = b.CreateInsertValue(retval, result, i, "")
+		}
+		b.CreateRet(retval)
+	}
+
+	return llvmFn
+}
+
 // getMethodSignatureName returns a unique name (that can be used as the name of
 // a global) for the given method.
 func (c *compilerContext) getMethodSignatureName(method *types.Func) string {
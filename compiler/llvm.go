@@ -231,6 +231,14 @@ func (c *compilerContext) makeGlobalArray(buf []byte, name string, elementType l
 //
 // For details on what's in this value, see src/runtime/gc_precise.go.
 func (c *compilerContext) createObjectLayout(t llvm.Type, pos token.Pos) llvm.Value {
+	if c.MovingGC && typeHasInterface(t) {
+		// The value word of an interface may hold a pointer or an integer
+		// (like a uintptr), so the GC can't update it when the object it
+		// points to is moved. Leave the layout unknown: such objects are
+		// scanned conservatively, which pins everything they reference.
+		return llvm.ConstNull(c.dataPtrType)
+	}
+
 	// Use the element type for arrays. This works even for nested arrays.
 	for {
 		kind := t.TypeKind()
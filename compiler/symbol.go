@@ -195,6 +195,11 @@ func (c *compilerContext) getFunction(fn *ssa.Function) (llvm.Type, llvm.Value)
 		// that the only thing we'll do is read the pointer.
 		llvmFn.AddAttributeAtIndex(1, c.ctx.CreateEnumAttribute(llvm.AttributeKindID("nocapture"), 0))
 		llvmFn.AddAttributeAtIndex(1, c.ctx.CreateEnumAttribute(llvm.AttributeKindID("readonly"), 0))
+	case "runtime.gcWriteBarrier":
+		// The write barrier of the incremental GC only looks at the address
+		// of the pointer, it doesn't read or store it.
+		llvmFn.AddAttributeAtIndex(1, c.ctx.CreateEnumAttribute(llvm.AttributeKindID("nocapture"), 0))
+		llvmFn.AddAttributeAtIndex(1, c.ctx.CreateEnumAttribute(llvm.AttributeKindID("readnone"), 0))
 	case "__mulsi3", "__divmodsi4", "__udivmodsi4":
 		if strings.Split(c.Triple, "-")[0] == "avr" {
 			// These functions are compiler-rt/libgcc functions that are
@@ -591,6 +596,10 @@ func (c *compilerContext) addStandardDefinedAttributes(llvmFn llvm.Value) {
 		// For details, see: https://llvm.org/docs/LangRef.html#function-attributes
 		llvmFn.AddFunctionAttr(c.ctx.CreateEnumAttribute(llvm.AttributeKindID("uwtable"), 1))
 	}
+	if c.FramePointers {
+		// Needed to walk the stack at runtime (runtime.Callers etc).
+		llvmFn.AddFunctionAttr(c.ctx.CreateStringAttribute("frame-pointer", "all"))
+	}
 }
 
 // addStandardAttributes adds all attributes added to defined functions.
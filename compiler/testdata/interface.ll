@@ -11,10 +11,18 @@ target triple = "wasm32-unknown-wasi"
 @"reflect/types.type:pointer:named:error" = linkonce_odr constant { i8, i16, ptr } { i8 -43, i16 0, ptr @"reflect/types.type:named:error" }, align 4
 @"reflect/types.type:named:error" = linkonce_odr constant { i8, i16, ptr, ptr, ptr, [7 x i8] } { i8 116, i16 1, ptr @"reflect/types.type:pointer:named:error", ptr @"reflect/types.type:interface:{Error:func:{}{basic:string}}", ptr @"reflect/types.type.pkgpath.empty", [7 x i8] c".error\00" }, align 4
 @"reflect/types.type.pkgpath.empty" = linkonce_odr unnamed_addr constant [1 x i8] zeroinitializer, align 1
-@"reflect/types.type:interface:{Error:func:{}{basic:string}}" = linkonce_odr constant { i8, ptr } { i8 84, ptr @"reflect/types.type:pointer:interface:{Error:func:{}{basic:string}}" }, align 4
+@"reflect/types.type:interface:{Error:func:{}{basic:string}}" = linkonce_odr constant { i8, i16, ptr, ptr, [3 x ptr] } { i8 84, i16 1, ptr @"reflect/types.type:pointer:interface:{Error:func:{}{basic:string}}", ptr @"reflect/types.type:interface:{Error:func:{}{basic:string}}$implements", [3 x ptr] [ptr @"reflect/types.methodname:Error", ptr @"reflect/methods.Error() string", ptr @"reflect/types.type:func:{}{basic:string}"] }, align 4
+@"reflect/types.methodname:Error" = linkonce_odr unnamed_addr constant [6 x i8] c"Error\00", align 1
+@"reflect/methods.Error() string" = linkonce_odr constant i8 0, align 1
+@"reflect/types.type:func:{}{basic:string}" = linkonce_odr constant { i8, i16, ptr, ptr, ptr, i16, i8, [1 x ptr] } { i8 24, i16 0, ptr @"reflect/types.type:pointer:func:{}{basic:string}", ptr @"reflect/types.type:func:{}{basic:string}$call", ptr @"reflect/types.type:func:{}{basic:string}$makefunc", i16 1, i8 0, [1 x ptr] [ptr @"reflect/types.type:basic:string"] }, align 4
+@"reflect/types.type:basic:string" = linkonce_odr constant { i8, ptr } { i8 81, ptr @"reflect/types.type:pointer:basic:string" }, align 4
+@"reflect/types.type:pointer:basic:string" = linkonce_odr constant { i8, i16, ptr } { i8 -43, i16 0, ptr @"reflect/types.type:basic:string" }, align 4
+@"reflect/types.type:pointer:func:{}{basic:string}" = linkonce_odr constant { i8, i16, ptr } { i8 -43, i16 0, ptr @"reflect/types.type:func:{}{basic:string}" }, align 4
 @"reflect/types.type:pointer:interface:{Error:func:{}{basic:string}}" = linkonce_odr constant { i8, i16, ptr } { i8 -43, i16 0, ptr @"reflect/types.type:interface:{Error:func:{}{basic:string}}" }, align 4
 @"reflect/types.type:pointer:interface:{String:func:{}{basic:string}}" = linkonce_odr constant { i8, i16, ptr } { i8 -43, i16 0, ptr @"reflect/types.type:interface:{String:func:{}{basic:string}}" }, align 4
-@"reflect/types.type:interface:{String:func:{}{basic:string}}" = linkonce_odr constant { i8, ptr } { i8 84, ptr @"reflect/types.type:pointer:interface:{String:func:{}{basic:string}}" }, align 4
+@"reflect/types.type:interface:{String:func:{}{basic:string}}" = linkonce_odr constant { i8, i16, ptr, ptr, [3 x ptr] } { i8 84, i16 1, ptr @"reflect/types.type:pointer:interface:{String:func:{}{basic:string}}", ptr @"reflect/types.type:interface:{String:func:{}{basic:string}}$implements", [3 x ptr] [ptr @"reflect/types.methodname:String", ptr @"reflect/methods.String() string", ptr @"reflect/types.type:func:{}{basic:string}"] }, align 4
+@"reflect/types.methodname:String" = linkonce_odr unnamed_addr constant [7 x i8] c"String\00", align 1
+@"reflect/methods.String() string" = linkonce_odr constant i8 0, align 1
 @"reflect/types.typeid:basic:int" = external constant i8
 
 ; Function Attrs: allockind("alloc,zeroed") allocsize(0)
@@ -55,6 +63,43 @@ entry:
   ret %runtime._interface { ptr @"reflect/types.type:pointer:named:error", ptr null }
 }
 
+; Function Attrs: nounwind
+define linkonce_odr i1 @"reflect/types.type:interface:{Error:func:{}{basic:string}}$implements"(ptr %0, ptr %1) unnamed_addr #2 {
+entry:
+  %2 = call i1 @"interface:{Error:func:{}{basic:string}}.$typeassert"(ptr %0)
+  ret i1 %2
+}
+
+declare i1 @"interface:{Error:func:{}{basic:string}}.$typeassert"(ptr) #3
+
+; Function Attrs: nounwind
+define linkonce_odr void @"reflect/types.type:func:{}{basic:string}$call"(ptr %0, ptr %1, ptr %2, ptr %3, ptr %4) unnamed_addr #2 {
+entry:
+  %5 = call %runtime._string %0(ptr %1)
+  %.elt = extractvalue %runtime._string %5, 0
+  store ptr %.elt, ptr %3, align 4
+  %.repack1 = getelementptr inbounds i8, ptr %3, i32 4
+  %.elt2 = extractvalue %runtime._string %5, 1
+  store i32 %.elt2, ptr %.repack1, align 4
+  ret void
+}
+
+; Function Attrs: nounwind
+define linkonce_odr %runtime._string @"reflect/types.type:func:{}{basic:string}$makefunc"(ptr %0) unnamed_addr #2 {
+entry:
+  %params = alloca {}, align 8
+  %results = alloca { %runtime._string }, align 8
+  call void @reflect.callMakeFunc(ptr %0, ptr nonnull %params, ptr nonnull %results, ptr undef)
+  %.unpack = load ptr, ptr %results, align 4
+  %.elt1 = getelementptr inbounds i8, ptr %results, i32 4
+  %.unpack2 = load i32, ptr %.elt1, align 4
+  %1 = insertvalue %runtime._string poison, ptr %.unpack, 0
+  %2 = insertvalue %runtime._string %1, i32 %.unpack2, 1
+  ret %runtime._string %2
+}
+
+declare void @reflect.callMakeFunc(ptr, ptr, ptr, ptr)
+
 ; Function Attrs: nounwind
 define hidden %runtime._interface @main.anonymousInterfaceType(ptr %context) unnamed_addr #2 {
 entry:
@@ -64,6 +109,15 @@ entry:
   ret %runtime._interface { ptr @"reflect/types.type:pointer:interface:{String:func:{}{basic:string}}", ptr null }
 }
 
+; Function Attrs: nounwind
+define linkonce_odr i1 @"reflect/types.type:interface:{String:func:{}{basic:string}}$implements"(ptr %0, ptr %1) unnamed_addr #2 {
+entry:
+  %2 = call i1 @"interface:{String:func:{}{basic:string}}.$typeassert"(ptr %0)
+  ret i1 %2
+}
+
+declare i1 @"interface:{String:func:{}{basic:string}}.$typeassert"(ptr) #4
+
 ; Function Attrs: nounwind
 define hidden i1 @main.isInt(ptr %itf.typecode, ptr %itf.value, ptr %context) unnamed_addr #2 {
 entry:
@@ -92,8 +146,6 @@ typeassert.ok:                                    ; preds = %entry
   br label %typeassert.next
 }
 
-declare i1 @"interface:{Error:func:{}{basic:string}}.$typeassert"(ptr) #3
-
 ; Function Attrs: nounwind
 define hidden i1 @main.isStringer(ptr %itf.typecode, ptr %itf.value, ptr %context) unnamed_addr #2 {
 entry:
@@ -107,8 +159,6 @@ typeassert.ok:                                    ; preds = %entry
   br label %typeassert.next
 }
 
-declare i1 @"interface:{String:func:{}{basic:string}}.$typeassert"(ptr) #4
-
 ; Function Attrs: nounwind
 define hidden i8 @main.callFooMethod(ptr %itf.typecode, ptr %itf.value, ptr %context) unnamed_addr #2 {
 entry:
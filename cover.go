@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Lines written by the testing package around the coverage profile, when it
+// is written to stdout (using -test.coverprofile=-).
+const (
+	coverProfileBegin = "tinygo: coverage profile begin"
+	coverProfileEnd   = "tinygo: coverage profile end"
+)
+
+// coverageWriter sits between the test binary and the test output. It removes
+// the coverage profile from the output and stores it separately, and
+// remembers the coverage summary line.
+type coverageWriter struct {
+	out       io.Writer
+	line      []byte // incomplete line
+	inProfile bool
+	profile   []byte // profile lines, excluding the mode line
+	summary   string // "coverage: 50.0% of statements"
+}
+
+func (w *coverageWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) != 0 {
+		i := bytes.IndexByte(p, '\n')
+		if i < 0 {
+			w.line = append(w.line, p...)
+			break
+		}
+		w.line = append(w.line, p[:i+1]...)
+		p = p[i+1:]
+		if err := w.writeLine(); err != nil {
+			return 0, err
+		}
+	}
+	return n, nil
+}
+
+// Flush writes the last line of output if it didn't end in a newline.
+func (w *coverageWriter) Flush() error {
+	if len(w.line) == 0 {
+		return nil
+	}
+	return w.writeLine()
+}
+
+func (w *coverageWriter) writeLine() error {
+	line := w.line
+	w.line = nil
+	// Output from emulators may use CRLF line endings.
+	text := strings.TrimRight(string(line), "\r\n")
+	switch {
+	case text == coverProfileBegin:
+		w.inProfile = true
+		return nil
+	case text == coverProfileEnd:
+		w.inProfile = false
+		return nil
+	case w.inProfile:
+		if !strings.HasPrefix(text, "mode: ") {
+			w.profile = append(w.profile, text...)
+			w.profile = append(w.profile, '\n')
+		}
+		return nil
+	case strings.HasPrefix(text, "coverage: "):
+		w.summary = text
+	}
+	_, err := w.out.Write(line)
+	return err
+}
+
+// Lock for appending to the coverage profile, because multiple packages may
+// be tested in parallel.
+var coverProfileLock sync.Mutex
+
+// appendCoverProfile appends the coverage profile lines of a single package to
+// the given file. The mode line is written first if the file is empty.
+func appendCoverProfile(path, mode string, profile []byte) error {
+	coverProfileLock.Lock()
+	defer coverProfileLock.Unlock()
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
+	if err != nil {
+		return err
+	}
+	st, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	if st.Size() == 0 {
+		fmt.Fprintf(f, "mode: %s\n", mode)
+	}
+	if _, err := f.Write(profile); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,8 @@
+// This is a separate module, because golang.org/x/exp/trace needs a newer Go
+// version and newer golang.org/x/tools than the top-level go.mod specifies.
+
+module github.com/tinygo-org/tinygo/internal/tracecheck
+
+go 1.20
+
+require golang.org/x/exp v0.0.0-20240823005443-9b4947da3948
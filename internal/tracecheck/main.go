@@ -0,0 +1,87 @@
+// Program tracecheck parses an execution trace using the trace parser from
+// golang.org/x/exp/trace, which is the same parser that is used by
+// `go tool trace`. It is used by TestTraceParse in the main package.
+//
+// It prints a sorted list of the distinct events in the trace (without
+// timestamps or IDs), or an error if the trace can't be parsed.
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"golang.org/x/exp/trace"
+)
+
+func main() {
+	if len(os.Args) != 2 {
+		fmt.Fprintln(os.Stderr, "usage: tracecheck <trace file>")
+		os.Exit(2)
+	}
+	f, err := os.Open(os.Args[1])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	defer f.Close()
+
+	r, err := trace.NewReader(f)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "could not read trace:", err)
+		os.Exit(1)
+	}
+	events := map[string]struct{}{}
+	for {
+		ev, err := r.ReadEvent()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "could not parse trace:", err)
+			os.Exit(1)
+		}
+		if s := describe(ev); s != "" {
+			events[s] = struct{}{}
+		}
+	}
+
+	var lines []string
+	for s := range events {
+		lines = append(lines, s)
+	}
+	sort.Strings(lines)
+	for _, s := range lines {
+		fmt.Println(s)
+	}
+}
+
+// Describe an event, leaving out everything that differs between runs.
+func describe(ev trace.Event) string {
+	switch ev.Kind() {
+	case trace.EventStateTransition:
+		st := ev.StateTransition()
+		if st.Resource.Kind != trace.ResourceGoroutine {
+			return ""
+		}
+		from, to := st.Goroutine()
+		s := fmt.Sprintf("goroutine %s->%s", from, to)
+		if st.Reason != "" {
+			s += " (" + st.Reason + ")"
+		}
+		return s
+	case trace.EventRangeBegin:
+		return "range " + ev.Range().Name
+	case trace.EventMetric:
+		return "metric " + ev.Metric().Name
+	case trace.EventTaskBegin:
+		return "task " + ev.Task().Type
+	case trace.EventRegionBegin:
+		return "region " + ev.Region().Type
+	case trace.EventLog:
+		log := ev.Log()
+		return "log " + log.Category + ": " + log.Message
+	}
+	return ""
+}
@@ -0,0 +1,479 @@
+package loader
+
+// This file implements statement coverage instrumentation for tinygo test
+// -cover. It works like cmd/cover in upstream Go: the source code of the
+// package under test is rewritten so that a counter is updated at the start of
+// every basic block, and the rewritten source is compiled instead of the
+// original file. All insertions are done on the same line as the original
+// code, so that line numbers stay the same.
+//
+// Every instrumented file registers its counters with the testing package in
+// an init function, which then reports the coverage percentage and writes the
+// coverage profile at the end of the test.
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"sort"
+)
+
+// Name of the sync/atomic import used in -covermode=atomic.
+const coverAtomicPackage = "_cover_atomic_"
+
+// coverBlock is a sequence of statements that are always executed together.
+type coverBlock struct {
+	start   token.Pos
+	end     token.Pos
+	numStmt int
+}
+
+// coverInsert is a piece of text to be inserted at a given byte offset.
+type coverInsert struct {
+	offset int
+	text   string
+}
+
+// coverFile holds the state while instrumenting a single file.
+type coverFile struct {
+	fset    *token.FileSet
+	content []byte
+	mode    string // "set", "count", or "atomic"
+	varName string // name of the variable holding the counters
+	blocks  []coverBlock
+	inserts []coverInsert
+}
+
+// instrumentCoverage returns the source code in data instrumented with
+// coverage counters. The name is the file name as it appears in the coverage
+// profile, and index must be unique for each file in the package. If the file
+// cannot be parsed, it is returned unmodified so that the parse error is
+// reported in the usual way.
+func instrumentCoverage(data []byte, name string, index int, mode string) []byte {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, name, data, 0)
+	if err != nil {
+		return data
+	}
+	f := &coverFile{
+		fset:    fset,
+		content: data,
+		mode:    mode,
+		varName: fmt.Sprintf("GoCover_%d", index),
+	}
+	ast.Walk(f, file)
+	if len(f.blocks) == 0 {
+		// Nothing to cover (for example, a file with only declarations).
+		return data
+	}
+
+	// Add imports right after the package clause, on the same line.
+	imports := `; import _ "unsafe"`
+	if mode == "atomic" {
+		imports += fmt.Sprintf(`; import %s "sync/atomic"`, coverAtomicPackage)
+	}
+	f.insert(file.Name.End(), imports)
+
+	// Apply all insertions, in order. Insertions at the same offset are kept in
+	// the order in which they were added.
+	sort.SliceStable(f.inserts, func(i, j int) bool {
+		return f.inserts[i].offset < f.inserts[j].offset
+	})
+	buf := &bytes.Buffer{}
+	last := 0
+	for _, ins := range f.inserts {
+		buf.Write(data[last:ins.offset])
+		buf.WriteString(ins.text)
+		last = ins.offset
+	}
+	buf.Write(data[last:])
+
+	f.writeVariables(buf, name, index)
+	return buf.Bytes()
+}
+
+// writeVariables appends the counters and the code to register them with the
+// testing package.
+func (f *coverFile) writeVariables(buf *bytes.Buffer, name string, index int) {
+	n := len(f.blocks)
+	fmt.Fprintf(buf, "\n\nvar %s = struct {\n", f.varName)
+	fmt.Fprintf(buf, "\tCount   [%d]uint32\n", n)
+	fmt.Fprintf(buf, "\tPos     [3 * %d]uint32\n", n)
+	fmt.Fprintf(buf, "\tNumStmt [%d]uint16\n", n)
+	fmt.Fprintf(buf, "}{\n")
+	fmt.Fprintf(buf, "\tPos: [3 * %d]uint32{\n", n)
+	for i, block := range f.blocks {
+		start := f.fset.Position(block.start)
+		end := f.fset.Position(block.end)
+		packed := (end.Column&0xFFFF)<<16 | (start.Column & 0xFFFF)
+		fmt.Fprintf(buf, "\t\t%d, %d, %#x, // [%d]\n", start.Line, end.Line, packed, i)
+	}
+	fmt.Fprintf(buf, "\t},\n")
+	fmt.Fprintf(buf, "\tNumStmt: [%d]uint16{\n", n)
+	for i, block := range f.blocks {
+		fmt.Fprintf(buf, "\t\t%d, // %d\n", block.numStmt, i)
+	}
+	fmt.Fprintf(buf, "\t},\n")
+	fmt.Fprintf(buf, "}\n")
+
+	// The package under test doesn't necessarily import the testing package,
+	// so call it through a linkname.
+	fmt.Fprintf(buf, "\n//go:linkname _coverRegister_%d testing.coverRegister\n", index)
+	fmt.Fprintf(buf, "func _coverRegister_%d(mode, file string, counters, pos []uint32, numStmt []uint16)\n", index)
+	fmt.Fprintf(buf, "\nfunc init() {\n")
+	fmt.Fprintf(buf, "\t_coverRegister_%d(%q, %q, %s.Count[:], %s.Pos[:], %s.NumStmt[:])\n", index, f.mode, name, f.varName, f.varName, f.varName)
+	fmt.Fprintf(buf, "}\n")
+
+	if f.mode == "atomic" {
+		// Make sure the import is used.
+		fmt.Fprintf(buf, "\nvar _ = %s.AddUint32\n", coverAtomicPackage)
+	}
+}
+
+// insert adds text to insert at the given position.
+func (f *coverFile) insert(pos token.Pos, text string) {
+	f.inserts = append(f.inserts, coverInsert{f.offset(pos), text})
+}
+
+// offset returns the byte offset of the given position in the file.
+func (f *coverFile) offset(pos token.Pos) int {
+	return f.fset.Position(pos).Offset
+}
+
+// Visit implements ast.Visitor. It inserts counters for all statement lists
+// in the file.
+func (f *coverFile) Visit(node ast.Node) ast.Visitor {
+	switch n := node.(type) {
+	case *ast.BlockStmt:
+		// If it's a switch or select, the body is a list of case clauses;
+		// don't tag the block itself.
+		if len(n.List) > 0 {
+			switch n.List[0].(type) {
+			case *ast.CaseClause: // switch
+				for _, n := range n.List {
+					clause := n.(*ast.CaseClause)
+					f.addCounters(clause.Colon+1, clause.Colon+1, clause.End(), clause.Body, false)
+				}
+				return f
+			case *ast.CommClause: // select
+				for _, n := range n.List {
+					clause := n.(*ast.CommClause)
+					f.addCounters(clause.Colon+1, clause.Colon+1, clause.End(), clause.Body, false)
+				}
+				return f
+			}
+		}
+		f.addCounters(n.Lbrace, n.Lbrace+1, n.Rbrace+1, n.List, true) // +1 to step past closing brace
+	case *ast.IfStmt:
+		if n.Init != nil {
+			ast.Walk(f, n.Init)
+		}
+		ast.Walk(f, n.Cond)
+		ast.Walk(f, n.Body)
+		if n.Else == nil {
+			return nil
+		}
+		// The else branch is special, because in
+		//	if x {
+		//	} else if y {
+		//	}
+		// we want to cover the "if y". To do this, we need a place to put the
+		// counter, so we add a hidden block:
+		//	if x {
+		//	} else {
+		//		if y {
+		//		}
+		//	}
+		elseOffset := f.findText(n.Body.End(), "else")
+		if elseOffset < 0 {
+			panic("lost else")
+		}
+		f.inserts = append(f.inserts, coverInsert{elseOffset + 4, "{"})
+		f.insert(n.Else.End(), "}")
+
+		// Adjust the position of the new block to start after the "else", so
+		// that the counter is placed after the "{" inserted above.
+		pos := f.fset.File(n.Body.End()).Pos(elseOffset + 4)
+		switch stmt := n.Else.(type) {
+		case *ast.IfStmt:
+			n.Else = &ast.BlockStmt{
+				Lbrace: pos,
+				List:   []ast.Stmt{stmt},
+				Rbrace: stmt.End(),
+			}
+		case *ast.BlockStmt:
+			stmt.Lbrace = pos
+		default:
+			panic("unexpected node type in if")
+		}
+		ast.Walk(f, n.Else)
+		return nil
+	case *ast.SelectStmt:
+		// Don't annotate an empty select - creates a syntax error.
+		if n.Body == nil || len(n.Body.List) == 0 {
+			return nil
+		}
+	case *ast.SwitchStmt:
+		// Don't annotate an empty switch - creates a syntax error.
+		if n.Body == nil || len(n.Body.List) == 0 {
+			if n.Init != nil {
+				ast.Walk(f, n.Init)
+			}
+			if n.Tag != nil {
+				ast.Walk(f, n.Tag)
+			}
+			return nil
+		}
+	case *ast.TypeSwitchStmt:
+		// Don't annotate an empty type switch - creates a syntax error.
+		if n.Body == nil || len(n.Body.List) == 0 {
+			if n.Init != nil {
+				ast.Walk(f, n.Init)
+			}
+			ast.Walk(f, n.Assign)
+			return nil
+		}
+	case *ast.FuncDecl:
+		// Don't annotate functions with blank names - they cannot be
+		// executed.
+		if n.Name.Name == "_" {
+			return nil
+		}
+	}
+	return f
+}
+
+// addCounters inserts counters for the basic blocks in the statement list.
+// The pos argument is the start of the first block, insertPos is where the
+// first counter is inserted, and blockEnd is the end of the statement list
+// (including the closing brace if extendToClosingBrace is set).
+func (f *coverFile) addCounters(pos, insertPos, blockEnd token.Pos, list []ast.Stmt, extendToClosingBrace bool) {
+	// Special case: make sure we add a counter to an empty block. Can't do
+	// this below or we will add a counter to an empty statement list after,
+	// say, a return statement.
+	if len(list) == 0 {
+		f.insert(insertPos, f.newCounter(insertPos, blockEnd, 0)+";")
+		return
+	}
+	// Make a copy of the list, as we may modify it.
+	list = append([]ast.Stmt(nil), list...)
+	// The statement list may contain several basic blocks, due to statements
+	// that affect the control flow.
+	for {
+		// Find the first statement that affects control flow (break,
+		// continue, if, etc). It will be the last statement of this basic
+		// block.
+		var last int
+		end := blockEnd
+		for last = 0; last < len(list); last++ {
+			stmt := list[last]
+			end = f.statementBoundary(stmt)
+			if f.endsBasicSourceBlock(stmt) {
+				// If it is a labeled statement, we need to place a counter
+				// between the label and its statement because it may be the
+				// target of a goto and thus start a basic block. That is,
+				// given
+				//	foo: stmt
+				// we need to create
+				//	foo: ; stmt
+				// and mark the label as a block-terminating statement.
+				// We can't do this if the labeled statement is already a
+				// control statement, such as a labeled for.
+				if label, isLabel := stmt.(*ast.LabeledStmt); isLabel && !isControl(label.Stmt) {
+					newLabel := *label
+					newLabel.Stmt = &ast.EmptyStmt{
+						Semicolon: label.Stmt.Pos(),
+						Implicit:  true,
+					}
+					end = label.Pos() // previous block ends before the label
+					list[last] = &newLabel
+					// Open a gap and drop in the old statement, now without
+					// a label.
+					list = append(list, nil)
+					copy(list[last+1:], list[last:])
+					list[last+1] = label.Stmt
+				}
+				last++
+				extendToClosingBrace = false // block is broken up now
+				break
+			}
+		}
+		if extendToClosingBrace {
+			end = blockEnd
+		}
+		if pos != end { // can have no source to cover if e.g. blocks abut
+			f.insert(insertPos, f.newCounter(pos, end, last)+";")
+		}
+		list = list[last:]
+		if len(list) == 0 {
+			break
+		}
+		pos = list[0].Pos()
+		insertPos = pos
+	}
+}
+
+// newCounter creates a new block and returns the statement that updates its
+// counter.
+func (f *coverFile) newCounter(start, end token.Pos, numStmt int) string {
+	counter := fmt.Sprintf("%s.Count[%d]", f.varName, len(f.blocks))
+	f.blocks = append(f.blocks, coverBlock{start, end, numStmt})
+	switch f.mode {
+	case "count":
+		return counter + "++"
+	case "atomic":
+		return fmt.Sprintf("%s.AddUint32(&%s, 1)", coverAtomicPackage, counter)
+	default: // "set"
+		return counter + " = 1"
+	}
+}
+
+// statementBoundary finds the location in s that terminates the current basic
+// block in the source.
+func (f *coverFile) statementBoundary(s ast.Stmt) token.Pos {
+	// Control flow statements are easy.
+	switch s := s.(type) {
+	case *ast.BlockStmt:
+		// Treat blocks like basic blocks to avoid overlapping counters.
+		return s.Lbrace
+	case *ast.IfStmt:
+		if pos, found := findFuncLit(s.Init); found {
+			return pos
+		}
+		if pos, found := findFuncLit(s.Cond); found {
+			return pos
+		}
+		return s.Body.Lbrace
+	case *ast.ForStmt:
+		if pos, found := findFuncLit(s.Init); found {
+			return pos
+		}
+		if pos, found := findFuncLit(s.Cond); found {
+			return pos
+		}
+		if pos, found := findFuncLit(s.Post); found {
+			return pos
+		}
+		return s.Body.Lbrace
+	case *ast.LabeledStmt:
+		return f.statementBoundary(s.Stmt)
+	case *ast.RangeStmt:
+		if pos, found := findFuncLit(s.X); found {
+			return pos
+		}
+		return s.Body.Lbrace
+	case *ast.SwitchStmt:
+		if pos, found := findFuncLit(s.Init); found {
+			return pos
+		}
+		if pos, found := findFuncLit(s.Tag); found {
+			return pos
+		}
+		return s.Body.Lbrace
+	case *ast.SelectStmt:
+		return s.Body.Lbrace
+	case *ast.TypeSwitchStmt:
+		if pos, found := findFuncLit(s.Init); found {
+			return pos
+		}
+		return s.Body.Lbrace
+	}
+	// If not a control flow statement, it is a declaration, expression, call,
+	// etc. and it may have a function literal. If it does, we want to exclude
+	// the body of the function from this block, so draw a line at the start
+	// of the body of the first function literal.
+	if pos, found := findFuncLit(s); found {
+		return pos
+	}
+	return s.End()
+}
+
+// endsBasicSourceBlock reports whether s changes the flow of control, so that
+// the statements after it are in a different block.
+func (f *coverFile) endsBasicSourceBlock(s ast.Stmt) bool {
+	switch s := s.(type) {
+	case *ast.BlockStmt:
+		// Treat blocks like basic blocks to avoid overlapping counters.
+		return true
+	case *ast.BranchStmt, *ast.ForStmt, *ast.IfStmt, *ast.RangeStmt, *ast.SwitchStmt, *ast.SelectStmt, *ast.TypeSwitchStmt:
+		return true
+	case *ast.LabeledStmt:
+		return true // a goto may branch here, starting a new basic block
+	case *ast.ExprStmt:
+		// Calls to panic change the flow. We can't verify that "panic" is the
+		// builtin without type information, but that is very unlikely to be a
+		// problem in practice.
+		if call, ok := s.X.(*ast.CallExpr); ok {
+			if ident, ok := call.Fun.(*ast.Ident); ok && ident.Name == "panic" && len(call.Args) == 1 {
+				return true
+			}
+		}
+	}
+	_, found := findFuncLit(s)
+	return found
+}
+
+// isControl reports whether s is a control statement that, if labeled, cannot
+// be separated from its label.
+func isControl(s ast.Stmt) bool {
+	switch s.(type) {
+	case *ast.ForStmt, *ast.RangeStmt, *ast.SwitchStmt, *ast.SelectStmt, *ast.TypeSwitchStmt:
+		return true
+	}
+	return false
+}
+
+// findText finds the first occurrence of text in the source, starting at pos.
+// It skips over comments. It returns the byte offset, or -1 if not found.
+func (f *coverFile) findText(pos token.Pos, text string) int {
+	b := []byte(text)
+	s := f.content
+	i := f.offset(pos)
+	for i < len(s) {
+		if bytes.HasPrefix(s[i:], b) {
+			return i
+		}
+		if i+2 <= len(s) && s[i] == '/' && s[i+1] == '/' {
+			for i < len(s) && s[i] != '\n' {
+				i++
+			}
+			continue
+		}
+		if i+2 <= len(s) && s[i] == '/' && s[i+1] == '*' {
+			for i += 2; ; i++ {
+				if i+2 > len(s) {
+					return -1
+				}
+				if s[i] == '*' && s[i+1] == '/' {
+					i += 2
+					break
+				}
+			}
+			continue
+		}
+		i++
+	}
+	return -1
+}
+
+// findFuncLit returns the position of the body of the first function literal
+// in n, if there is one.
+func findFuncLit(n ast.Node) (token.Pos, bool) {
+	if n == nil {
+		return token.NoPos, false
+	}
+	var pos token.Pos
+	ast.Inspect(n, func(n ast.Node) bool {
+		if pos.IsValid() {
+			return false
+		}
+		if lit, ok := n.(*ast.FuncLit); ok {
+			pos = lit.Body.Lbrace
+			return false
+		}
+		return true
+	})
+	return pos, pos.IsValid()
+}
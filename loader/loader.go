@@ -51,6 +51,7 @@ type PackageJSON struct {
 	Name       string
 	ForTest    string
 	Root       string
+	Standard   bool
 	Module     struct {
 		Path      string
 		Main      bool
@@ -349,11 +350,24 @@ func (p *Package) parseFile(path string, mode parser.Mode) (*ast.File, error) {
 	if err != nil {
 		return nil, err
 	}
+	if mode := p.program.config.CoverMode(); mode != "" && p.isTestedPackage() && !strings.HasSuffix(path, "_test.go") {
+		// Instrument the package under test for tinygo test -cover. Every
+		// parsed file adds an entry to FileHashes, so its length can be used
+		// as a unique index for this file.
+		name := p.ImportPath + "/" + filepath.Base(path)
+		data = instrumentCoverage(data, name, len(p.FileHashes), mode)
+	}
 	sum := sha512.Sum512_224(data)
 	p.FileHashes[originalPath] = sum[:]
 	return parser.ParseFile(p.program.fset, originalPath, data, mode)
 }
 
+// isTestedPackage returns whether this is the package under test (when
+// compiling a test binary).
+func (p *Package) isTestedPackage() bool {
+	return p.program.config.TestConfig.CompileTestBinary && p.ImportPath+".test" == p.program.MainPkg().ImportPath
+}
+
 // Parse parses and typechecks this package.
 //
 // Idempotent.
@@ -487,6 +501,12 @@ func (p *Package) parseFiles() ([]*ast.File, error) {
 		initialCFlags = append(initialCFlags, "-I"+p.Dir)
 		generated, headerCode, cflags, ldflags, accessedFiles, errs := cgo.Process(files, p.program.workingDir, p.ImportPath, p.program.fset, initialCFlags, p.program.config.GOOS())
 		p.CFlags = append(initialCFlags, cflags...)
+		if !p.Standard {
+			// Only instrument C code outside the standard library: the
+			// sanitizer runtime is part of the runtime package and can't
+			// itself be instrumented.
+			p.CFlags = append(p.CFlags, p.program.config.SanitizerCFlags()...)
+		}
 		p.CGoHeaders = headerCode
 		for path, hash := range accessedFiles {
 			p.FileHashes[path] = hash
@@ -204,11 +204,30 @@ func Build(pkgName, outpath string, options *compileopts.Options) error {
 			}
 
 			// Check whether file writing was successful.
-			return outf.Close()
+			if err := outf.Close(); err != nil {
+				return err
+			}
+		}
+	}
+
+	if result.Manifest != nil {
+		// Describe the output file in the manifest, now that it is in its
+		// final location.
+		err := result.Manifest.AddArtifact(outpath, result.BinaryFormat)
+		if err != nil {
+			return err
+		}
+		if options.Work && result.ConvertedFrom != "" {
+			// The output was converted from the linked ELF file, which is
+			// only kept (in the temporary directory) with -work.
+			err := result.Manifest.AddArtifact(result.ConvertedFrom, "elf")
+			if err != nil {
+				return err
+			}
 		}
+		return result.Manifest.Write(options.Manifest)
 	}
 
-	// Move was successful.
 	return nil
 }
 
@@ -225,7 +244,8 @@ func Test(pkgName string, stdout, stderr io.Writer, options *compileopts.Options
 
 	// Pass test flags to the test binary.
 	var flags []string
-	if testConfig.Verbose {
+	if testConfig.Verbose || testConfig.JSON {
+		// The JSON output is created from the verbose output.
 		flags = append(flags, "-test.v")
 	}
 	if testConfig.Short {
@@ -252,15 +272,38 @@ func Test(pkgName string, stdout, stderr io.Writer, options *compileopts.Options
 	if testConfig.Shuffle != "" {
 		flags = append(flags, "-test.shuffle="+testConfig.Shuffle)
 	}
+	if testConfig.FuzzRegexp != "" {
+		flags = append(flags, "-test.fuzz="+testConfig.FuzzRegexp)
+	}
+	if testConfig.FuzzTime != "" {
+		flags = append(flags, "-test.fuzztime="+testConfig.FuzzTime)
+	}
+	if config.CoverMode() != "" && testConfig.CoverProfile != "" {
+		// Let the test binary write the profile to stdout, so that this also
+		// works on systems without a filesystem (like microcontrollers).
+		flags = append(flags, "-test.coverprofile=-")
+	}
 
-	logToStdout := testConfig.Verbose || testConfig.BenchRegexp != ""
+	logToStdout := testConfig.Verbose || testConfig.BenchRegexp != "" || testConfig.FuzzRegexp != ""
 
 	var buf bytes.Buffer
 	var output io.Writer = &buf
-	// Send the test output to stdout if -v or -bench
-	if logToStdout {
+	var jsonOutput *testJSONWriter
+	if testConfig.JSON {
+		// Convert the test output to JSON events. These are written to stdout
+		// like the normal output of a test, so that the output of different
+		// packages is not mixed together.
+		jsonOutput = &testJSONWriter{out: stdout}
+		output = jsonOutput
+	} else if logToStdout {
+		// Send the test output to stdout if -v or -bench
 		output = os.Stdout
 	}
+	var coverOutput *coverageWriter
+	if config.CoverMode() != "" {
+		coverOutput = &coverageWriter{out: output}
+		output = coverOutput
+	}
 
 	passed := false
 	var duration time.Duration
@@ -283,15 +326,28 @@ func Test(pkgName string, stdout, stderr io.Writer, options *compileopts.Options
 		// Tests are always run in the package directory.
 		cmd.Dir = result.MainDir
 
+		if jsonOutput != nil {
+			jsonOutput.pkg = strings.TrimSuffix(result.ImportPath, ".test")
+		}
+
 		// Run the test.
 		start := time.Now()
 		err = cmd.Run()
 		duration = time.Since(start)
 		passed = err == nil
 
+		if coverOutput != nil {
+			coverOutput.Flush()
+			if testConfig.CoverProfile != "" {
+				if err := appendCoverProfile(testConfig.CoverProfile, config.CoverMode(), coverOutput.profile); err != nil {
+					return err
+				}
+			}
+		}
+
 		// if verbose or benchmarks, then output is already going to stdout
 		// However, if we failed and weren't printing to stdout, print the output we accumulated.
-		if !passed && !logToStdout {
+		if !passed && !logToStdout && jsonOutput == nil {
 			buf.WriteTo(stdout)
 		}
 
@@ -311,18 +367,44 @@ func Test(pkgName string, stdout, stderr io.Writer, options *compileopts.Options
 	importPath := strings.TrimSuffix(result.ImportPath, ".test")
 
 	var w io.Writer = stdout
-	if logToStdout {
+	if jsonOutput != nil {
+		w = jsonOutput
+		if jsonOutput.pkg == "" {
+			// The package failed to build, or has no test files.
+			jsonOutput.pkg = importPath
+			if jsonOutput.pkg == "" {
+				jsonOutput.pkg = pkgName
+			}
+		}
+	} else if logToStdout {
 		w = os.Stdout
 	}
 	if err, ok := err.(loader.NoTestFilesError); ok {
+		if jsonOutput != nil {
+			jsonOutput.pkg = err.ImportPath
+		}
 		fmt.Fprintf(w, "?   \t%s\t[no test files]\n", err.ImportPath)
+		if jsonOutput != nil {
+			jsonOutput.Finish("skip", 0)
+		}
 		// Pretend the test passed - it at least didn't fail.
 		return true, nil
 	} else if passed {
-		fmt.Fprintf(w, "ok  \t%s\t%.3fs\n", importPath, duration.Seconds())
+		if coverOutput != nil && coverOutput.summary != "" {
+			fmt.Fprintf(w, "ok  \t%s\t%.3fs\t%s\n", importPath, duration.Seconds(), coverOutput.summary)
+		} else {
+			fmt.Fprintf(w, "ok  \t%s\t%.3fs\n", importPath, duration.Seconds())
+		}
 	} else {
 		fmt.Fprintf(w, "FAIL\t%s\t%.3fs\n", importPath, duration.Seconds())
 	}
+	if jsonOutput != nil {
+		action := "fail"
+		if passed {
+			action = "pass"
+		}
+		jsonOutput.Finish(action, duration)
+	}
 	return passed, err
 }
 
@@ -912,6 +994,10 @@ func buildAndRun(pkgName string, config *compileopts.Config, stdout io.Writer, c
 	// stdout.
 	cmd.Stdout = newOutputWriter(stdout, result.Executable)
 	cmd.Stderr = os.Stderr
+	if config.TestConfig.JSON {
+		// Like go test -json, include the stderr of the test in the output.
+		cmd.Stderr = cmd.Stdout
+	}
 	if config.EmulatorName() == "simavr" {
 		cmd.Stdout = nil // don't print initial load commands
 		cmd.Stderr = stdout
@@ -1339,6 +1425,27 @@ integrated debugger.`
 	usageClean = `Clean the cache directory, normally stored in $HOME/.cache/tinygo. This is not
 normally needed.`
 
+	usageSizeDiff = `Compare the flash and RAM usage of two binaries, usually built from
+different versions of the same program:
+
+	tinygo size-diff old.elf new.elf
+
+Prints the change in size of each package and (for ELF files) each function or
+global variable that changed in size. Use -json to print the result as JSON,
+and -o to write a HTML report to the given file.`
+
+	usageHeapDump = `Decode a heap dump printed by debug.PrintHeapDump, usually captured from the
+serial console or RTT of a microcontroller:
+
+	tinygo heapdump program.elf dump.txt
+
+The program must be the same binary (with debug information) that printed the
+heap dump. Other output before and after the heap dump is ignored. Use - to
+read the heap dump from stdin.
+
+Prints how much memory is used by each type and kept alive by each global, and
+how fragmented the free memory is. Use -json to print the result as JSON.`
+
 	usageHelp    = `Print a short summary of the available commands, plus a list of command flags.`
 	usageVersion = `Print the version of the command and the version of the used $GOROOT.`
 	usageEnv     = `Print a list of environment variables that affect TinyGo (as a shell script).
@@ -1360,6 +1467,8 @@ commands:
 		env:		list environment variables used during build
 		list:		run go list using the TinyGo root
 		clean:		empty cache directory (%s)
+		size-diff:	compare code and data size of two binaries
+		heapdump:	decode a heap dump of a running program
 		targets:	list targets
 		info:		show info for specified target
 		version:	show version
@@ -1368,15 +1477,17 @@ commands:
 
 var (
 	commandHelp = map[string]string{
-		"build":   usageBuild,
-		"run":     usageRun,
-		"flash":   usageFlash,
-		"monitor": usageMonitor,
-		"gdb":     usageGdb,
-		"clean":   usageClean,
-		"help":    usageHelp,
-		"version": usageVersion,
-		"env":     usageEnv,
+		"build":     usageBuild,
+		"run":       usageRun,
+		"flash":     usageFlash,
+		"monitor":   usageMonitor,
+		"gdb":       usageGdb,
+		"clean":     usageClean,
+		"size-diff": usageSizeDiff,
+		"heapdump":  usageHeapDump,
+		"help":      usageHelp,
+		"version":   usageVersion,
+		"env":       usageEnv,
 	}
 )
 
@@ -1492,9 +1603,10 @@ func main() {
 	command := os.Args[1]
 
 	opt := flag.String("opt", "z", "optimization level: 0, 1, 2, s, z")
-	gc := flag.String("gc", "", "garbage collector to use (none, leaking, conservative)")
+	gc := flag.String("gc", "", "garbage collector to use (none, leaking, conservative, precise, incremental, compacting)")
+	gcPause := flag.Duration("gc-pause", 0, "maximum pause of a single GC step with -gc=incremental (default 1ms)")
 	panicStrategy := flag.String("panic", "print", "panic strategy (print, trap)")
-	scheduler := flag.String("scheduler", "", "which scheduler to use (none, tasks, asyncify)")
+	scheduler := flag.String("scheduler", "", "which scheduler to use (none, tasks, asyncify, threads)")
 	serial := flag.String("serial", "", "which serial output to use (none, uart, usb, rtt)")
 	work := flag.Bool("work", false, "print the name of the temporary build directory and do not delete this directory on exit")
 	interpTimeout := flag.Duration("interp-timeout", 180*time.Second, "interp optimization pass timeout")
@@ -1509,11 +1621,15 @@ func main() {
 		return err
 	})
 	printSize := flag.String("size", "", "print sizes (none, short, full, html)")
+	sizeBudget := flag.String("size-budget", "", "fail the build if the program exceeds the flash and RAM limits in the given JSON `file`")
 	printStacks := flag.Bool("print-stacks", false, "print stack sizes of goroutines")
 	printAllocsString := flag.String("print-allocs", "", "regular expression of functions for which heap allocations should be printed")
+	allocReport := flag.String("alloc-report", "", "print all heap allocations remaining after optimization (none, text, json)")
 	printCommands := flag.Bool("x", false, "Print commands")
 	parallelism := flag.Int("p", runtime.GOMAXPROCS(0), "the number of build jobs that can run in parallel")
 	nodebug := flag.Bool("no-debug", false, "strip debug information")
+	symtab := flag.Bool("symtab", false, "embed a symbol table for runtime.Caller and runtime.CallersFrames")
+	sanitize := flag.String("sanitize", "", "comma separated list of sanitizers for C code (address, undefined)")
 	ocdCommandsString := flag.String("ocd-commands", "", "OpenOCD commands, overriding target spec (can specify multiple separated by commas)")
 	ocdOutput := flag.Bool("ocd-output", false, "print OCD daemon output during debug")
 	port := flag.String("port", "", "flash port (can specify multiple candidates separated by commas)")
@@ -1537,7 +1653,7 @@ func main() {
 	skipDwarf := flag.Bool("internal-nodwarf", false, "internal flag, use -no-debug instead")
 
 	var flagJSON, flagDeps, flagTest bool
-	if command == "help" || command == "list" || command == "info" || command == "build" {
+	if command == "help" || command == "list" || command == "info" || command == "build" || command == "test" || command == "size-diff" || command == "heapdump" {
 		flag.BoolVar(&flagJSON, "json", false, "print data in JSON format")
 	}
 	if command == "help" || command == "list" {
@@ -1545,9 +1661,13 @@ func main() {
 		flag.BoolVar(&flagTest, "test", false, "supply -test flag to go list")
 	}
 	var outpath string
-	if command == "help" || command == "build" || command == "test" {
+	if command == "help" || command == "build" || command == "test" || command == "size-diff" {
 		flag.StringVar(&outpath, "o", "", "output filename")
 	}
+	var manifest string
+	if command == "help" || command == "build" {
+		flag.StringVar(&manifest, "manifest", "", "write a JSON description of the build output to `file`")
+	}
 
 	var witPackage, witWorld string
 	if command == "help" || command == "build" || command == "test" || command == "run" {
@@ -1567,6 +1687,11 @@ func main() {
 		flag.StringVar(&testConfig.BenchTime, "benchtime", "", "run each benchmark for duration `d`")
 		flag.BoolVar(&testConfig.BenchMem, "benchmem", false, "show memory stats for benchmarks")
 		flag.StringVar(&testConfig.Shuffle, "shuffle", "", "shuffle the order the tests and benchmarks run")
+		flag.StringVar(&testConfig.FuzzRegexp, "fuzz", "", "fuzz: regexp of the fuzz test to run")
+		flag.StringVar(&testConfig.FuzzTime, "fuzztime", "", "run fuzzing for duration `d` (or Nx for N iterations)")
+		flag.BoolVar(&testConfig.Cover, "cover", false, "enable coverage analysis")
+		flag.StringVar(&testConfig.CoverMode, "covermode", "", "coverage mode: set, count, atomic (implies -cover)")
+		flag.StringVar(&testConfig.CoverProfile, "coverprofile", "", "write a coverage profile to `file` (implies -cover)")
 	}
 
 	// Early command processing, before commands are interpreted by the Go flag
@@ -1584,6 +1709,11 @@ func main() {
 	}
 
 	flag.CommandLine.Parse(os.Args[2:])
+	if command == "test" {
+		// With tinygo test, -json changes the test output instead.
+		testConfig.JSON = flagJSON
+		flagJSON = false
+	}
 	globalVarValues, extLDFlags, err := parseGoLinkFlag(*ldflags)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
@@ -1614,6 +1744,7 @@ func main() {
 		StackSize:       stackSize,
 		Opt:             *opt,
 		GC:              *gc,
+		GCPauseBudget:   *gcPause,
 		PanicStrategy:   *panicStrategy,
 		Scheduler:       *scheduler,
 		Serial:          *serial,
@@ -1625,9 +1756,14 @@ func main() {
 		SkipDWARF:       *skipDwarf,
 		Semaphore:       make(chan struct{}, *parallelism),
 		Debug:           !*nodebug,
+		Symtab:          *symtab,
+		Sanitize:        *sanitize,
 		PrintSizes:      *printSize,
+		SizeBudget:      *sizeBudget,
 		PrintStacks:     *printStacks,
+		Manifest:        manifest,
 		PrintAllocs:     printAllocs,
+		AllocReport:     *allocReport,
 		Tags:            []string(tags),
 		TestConfig:      testConfig,
 		GlobalValues:    globalVarValues,
@@ -1749,6 +1885,20 @@ func main() {
 			os.Exit(1)
 		}
 
+		if options.TestConfig.FuzzRegexp != "" && len(explicitPkgNames) > 1 {
+			fmt.Println("cannot use -fuzz flag with multiple packages")
+			os.Exit(1)
+		}
+
+		if options.TestConfig.CoverProfile != "" && !options.TestConfig.CompileOnly {
+			// The coverage profile of every package is appended to this
+			// file, so start with an empty file.
+			if err := os.WriteFile(options.TestConfig.CoverProfile, nil, 0666); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+		}
+
 		fail := make(chan struct{}, 1)
 		var wg sync.WaitGroup
 		bufs := make([]testOutputBuf, len(explicitPkgNames))
@@ -1931,6 +2081,56 @@ func main() {
 			fmt.Fprintln(os.Stderr, "failed to run `go list`:", err)
 			os.Exit(1)
 		}
+	case "size-diff":
+		if flag.NArg() != 2 {
+			fmt.Fprintln(os.Stderr, "expected two binaries to compare")
+			usage(command)
+			os.Exit(1)
+		}
+		diff, err := builder.DiffSizes(flag.Arg(0), flag.Arg(1))
+		handleCompilerError(err)
+		if outpath != "" {
+			f, err := os.Create(outpath)
+			handleCompilerError(err)
+			err = diff.WriteHTML(f)
+			if err == nil {
+				err = f.Close()
+			}
+			handleCompilerError(err)
+		}
+		if flagJSON {
+			data, err := json.MarshalIndent(diff, "", "  ")
+			handleCompilerError(err)
+			fmt.Println(string(data))
+		} else {
+			err := diff.WriteText(os.Stdout)
+			handleCompilerError(err)
+		}
+	case "heapdump":
+		if flag.NArg() != 2 {
+			fmt.Fprintln(os.Stderr, "expected a binary and a heap dump")
+			usage(command)
+			os.Exit(1)
+		}
+		in := os.Stdin
+		if flag.Arg(1) != "-" {
+			f, err := os.Open(flag.Arg(1))
+			handleCompilerError(err)
+			defer f.Close()
+			in = f
+		}
+		dump, err := builder.ParseHeapDump(in)
+		handleCompilerError(err)
+		report, err := builder.AnalyzeHeapDump(dump, flag.Arg(0))
+		handleCompilerError(err)
+		if flagJSON {
+			data, err := json.MarshalIndent(report, "", "  ")
+			handleCompilerError(err)
+			fmt.Println(string(data))
+		} else {
+			err := report.WriteText(os.Stdout)
+			handleCompilerError(err)
+		}
 	case "clean":
 		// remove cache directory
 		err := os.RemoveAll(goenv.Get("GOCACHE"))
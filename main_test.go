@@ -7,14 +7,17 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"flag"
 	"io"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"reflect"
 	"regexp"
 	"runtime"
+	"strconv"
 	"strings"
 	"sync"
 	"testing"
@@ -150,6 +153,23 @@ func TestBuild(t *testing.T) {
 			}
 			runTestWithConfig("ldflags.go", t, opts, nil, nil)
 		})
+
+		if runtime.GOOS == "linux" {
+			// The symbol table is only supported for ELF binaries.
+			t.Run("symtab", func(t *testing.T) {
+				t.Parallel()
+				opts := optionsFromTarget("", sema)
+				opts.Symtab = true
+				runTestWithConfig("symtab.go", t, opts, nil, nil)
+			})
+			t.Run("pprof-symtab", func(t *testing.T) {
+				// CPU profiles contain call stacks with -symtab.
+				t.Parallel()
+				opts := optionsFromTarget("", sema)
+				opts.Symtab = true
+				runTestWithConfig("pprof.go", t, opts, nil, nil)
+			})
+		}
 	})
 
 	if testing.Short() {
@@ -311,12 +331,70 @@ func runPlatTests(options compileopts.Options, tests []string, t *testing.T) {
 			runTest("alias.go", options, t, nil, nil)
 		})
 	}
+	if !strings.HasPrefix(spec.Emulator, "simavr ") {
+		t.Run("gc.go-gc-incremental", func(t *testing.T) {
+			t.Parallel()
+			options := compileopts.Options(options)
+			options.GC = "incremental"
+			runTest("gc.go", options, t, nil, nil)
+		})
+		t.Run("gc.go-gc-compacting", func(t *testing.T) {
+			t.Parallel()
+			options := compileopts.Options(options)
+			options.GC = "compacting"
+			runTest("gc.go", options, t, nil, nil)
+		})
+		t.Run("gcbarrier.go-gc-incremental", func(t *testing.T) {
+			t.Parallel()
+			options := compileopts.Options(options)
+			options.GC = "incremental"
+			// Do as little work as possible in each GC step, so that the
+			// program runs while most objects are being marked.
+			options.GCPauseBudget = time.Nanosecond
+			runTest("gcbarrier.go", options, t, nil, nil)
+		})
+	}
+	if options.Target == "" && !isWebAssembly {
+		// The heap can only grow in small steps on these systems, which this
+		// test relies on.
+		t.Run("gclimits.go", func(t *testing.T) {
+			t.Parallel()
+			runTest("gclimits.go", options, t, nil, nil)
+		})
+	}
+	if options.Target == "" && options.GOOS == "linux" {
+		t.Run("threads.go", func(t *testing.T) {
+			t.Parallel()
+			options := compileopts.Options(options)
+			options.Scheduler = "threads"
+			runTest("threads.go", options, t, nil, nil)
+		})
+	}
 	if options.Target == "" || isWASI {
 		t.Run("filesystem.go", func(t *testing.T) {
 			t.Parallel()
 			runTest("filesystem.go", options, t, nil, nil)
 		})
 	}
+	if options.Target == "" || isWASI {
+		t.Run("metrics.go", func(t *testing.T) {
+			t.Parallel()
+			runTest("metrics.go", options, t, nil, nil)
+		})
+	}
+	if options.Target == "" || isWASI {
+		t.Run("trace.go", func(t *testing.T) {
+			t.Parallel()
+			runTest("trace.go", options, t, nil, nil)
+		})
+	}
+	if options.Target == "" && !isWebAssembly && options.GOOS != "windows" {
+		// CPU profiling uses SIGPROF, so only works on POSIX-like systems.
+		t.Run("pprof.go", func(t *testing.T) {
+			t.Parallel()
+			runTest("pprof.go", options, t, nil, nil)
+		})
+	}
 	if options.Target == "" || options.Target == "wasm" || isWASI {
 		t.Run("rand.go", func(t *testing.T) {
 			t.Parallel()
@@ -842,6 +920,283 @@ func TestWasmExit(t *testing.T) {
 	}
 }
 
+// Test the build manifest that is written by tinygo build -manifest.
+func TestBuildManifest(t *testing.T) {
+	t.Parallel()
+	options := optionsFromTarget("cortex-m-qemu", sema)
+	tmpdir := t.TempDir()
+	options.Manifest = filepath.Join(tmpdir, "manifest.json")
+	options.Work = true // keep the ELF file the hex file is converted from
+	outpath := filepath.Join(tmpdir, "alias.hex")
+	err := Build("testdata/alias.go", outpath, &options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(options.Manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var manifest builder.Manifest
+	err = json.Unmarshal(data, &manifest)
+	if err != nil {
+		t.Fatal("could not parse manifest:", err)
+	}
+
+	// The hex file and the ELF file it was converted from (in the temporary
+	// directory) should both be listed, with the right size.
+	if len(manifest.Artifacts) != 2 {
+		t.Fatalf("expected 2 artifacts, got %d", len(manifest.Artifacts))
+	}
+	hex, elf := manifest.Artifacts[0], manifest.Artifacts[1]
+	if hex.Path != outpath || hex.Format != "hex" {
+		t.Errorf("unexpected first artifact %s with format %s", hex.Path, hex.Format)
+	}
+	if elf.Format != "elf" {
+		t.Errorf("unexpected second artifact %s with format %s", elf.Path, elf.Format)
+	}
+	defer os.RemoveAll(filepath.Dir(elf.Path))
+	for _, artifact := range manifest.Artifacts {
+		st, err := os.Stat(artifact.Path)
+		if err != nil {
+			t.Error(err)
+		} else if st.Size() != artifact.Size {
+			t.Errorf("artifact %s has size %d, expected %d", artifact.Path, artifact.Size, st.Size())
+		}
+	}
+
+	if manifest.Target == nil || manifest.Target.CPU != "cortex-m3" {
+		// The CPU is set in an inherited target.
+		t.Error("target is missing or inherited targets weren't resolved:", manifest.Target)
+	}
+	if manifest.Size == nil || manifest.Size.Flash == 0 {
+		t.Error("program size is missing:", manifest.Size)
+	}
+	foundRuntime := false
+	for _, pkg := range manifest.Packages {
+		if pkg.ImportPath == "runtime" {
+			foundRuntime = pkg.ActionID != ""
+		}
+	}
+	if !foundRuntime {
+		t.Error("runtime package or its cache key is missing")
+	}
+}
+
+// Test that the trace written by testdata/trace.go can be read by the parser
+// used by `go tool trace`, and that it contains the expected events.
+func TestTraceParse(t *testing.T) {
+	t.Parallel()
+	options := optionsFromTarget("", sema)
+	config, err := builder.NewConfig(&options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tracefile := filepath.Join(t.TempDir(), "trace.out")
+	_, err = buildAndRun("testdata/trace.go", config, io.Discard, []string{tracefile}, nil, time.Minute, func(cmd *exec.Cmd, result builder.BuildResult) error {
+		return cmd.Run()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The parser is in a separate module, see internal/tracecheck/go.mod.
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	cmd := exec.Command("go", "run", ".", tracefile)
+	cmd.Dir = "internal/tracecheck"
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("could not parse trace: %v\n%s", err, stderr.String())
+	}
+	events := strings.Split(stdout.String(), "\n")
+	for _, expected := range []string{
+		"goroutine NotExist->Runnable",
+		"goroutine Running->Waiting (chan receive)",
+		"goroutine Running->NotExist",
+		"range GC concurrent mark phase",
+		"task mytask",
+		"region myregion",
+		"region sleep",
+		"log worker: received 2",
+	} {
+		found := false
+		for _, event := range events {
+			if event == expected {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("event not found in trace: %s", expected)
+		}
+	}
+}
+
+// Test that the heap dump printed by debug.PrintHeapDump can be parsed, and
+// that it matches what the program found with debug.WalkHeap and
+// debug.HeapFreeRuns.
+func TestHeapDump(t *testing.T) {
+	t.Parallel()
+	options := optionsFromTarget("", sema)
+	config, err := builder.NewConfig(&options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := &bytes.Buffer{}
+	_, err = buildAndRun("testdata/heapdump.go", config, stdout, nil, nil, time.Minute, func(cmd *exec.Cmd, result builder.BuildResult) error {
+		return cmd.Run()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Read the values printed before the heap dump.
+	var target uint64
+	var freeRuns [32]int
+	for _, line := range strings.Split(stdout.String(), "\n") {
+		fields := strings.Fields(line)
+		switch {
+		case strings.HasSuffix(line, ": false"):
+			t.Error("unexpected output:", line)
+		case len(fields) == 2 && fields[0] == "target:":
+			target, err = strconv.ParseUint(fields[1], 10, 64)
+		case len(fields) == 4 && fields[0] == "free" && fields[1] == "runs:":
+			var bucket, count int
+			bucket, err = strconv.Atoi(fields[2])
+			if err == nil {
+				count, err = strconv.Atoi(fields[3])
+			}
+			if err == nil && bucket >= 0 && bucket < len(freeRuns) {
+				freeRuns[bucket] = count
+			}
+		}
+		if err != nil {
+			t.Fatalf("could not parse line %q: %v", line, err)
+		}
+	}
+
+	dump, err := builder.ParseHeapDump(stdout)
+	if err != nil {
+		t.Fatal("could not parse heap dump:", err)
+	}
+
+	// The object allocated by the program must be in the heap dump.
+	found := false
+	for _, obj := range dump.Objects {
+		if obj.Addr+dump.HeaderSize == target {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("object at 0x%x not found in heap dump", target)
+	}
+
+	// All blocks of the heap are either part of an object or free, and the
+	// free runs are the same as the ones found with debug.HeapFreeRuns.
+	blocks := uint64(0)
+	for _, obj := range dump.Objects {
+		blocks += obj.Blocks
+	}
+	var dumpFreeRuns [32]int
+	for _, free := range dump.Free {
+		length := (free.End - free.Start) / dump.BlockSize
+		blocks += length
+		bucket := 0
+		for length > 1 && bucket < len(dumpFreeRuns)-1 {
+			length >>= 1
+			bucket++
+		}
+		dumpFreeRuns[bucket]++
+	}
+	if heapBlocks := (dump.HeapEnd - dump.HeapStart) / dump.BlockSize; blocks != heapBlocks {
+		t.Errorf("heap dump contains %d blocks, expected %d", blocks, heapBlocks)
+	}
+	if dumpFreeRuns != freeRuns {
+		t.Errorf("free runs in heap dump are %v, expected %v", dumpFreeRuns, freeRuns)
+	}
+}
+
+// Test that -sanitize=address,undefined reports bugs in C code, in the same
+// format as the sanitizer runtimes in compiler-rt.
+func TestSanitizers(t *testing.T) {
+	t.Parallel()
+	if runtime.GOOS != "linux" || runtime.GOARCH != "amd64" {
+		t.Skip("sanitizers are only tested on linux/amd64")
+	}
+
+	type testCase struct {
+		name   string
+		output []string // regular expressions that must match a line
+	}
+
+	tests := []testCase{
+		{name: "heap-overflow", output: []string{
+			`==\d+==ERROR: AddressSanitizer: heap-buffer-overflow on address 0x[0-9a-f]+ at pc 0x[0-9a-f]+`,
+			`WRITE of size 1 at 0x[0-9a-f]+`,
+			`    #0 0x[0-9a-f]+ in heapOverflow .*bugs\.c:8`,
+			`SUMMARY: AddressSanitizer: heap-buffer-overflow .*bugs\.c:8 in heapOverflow`,
+			`==\d+==ABORTING`,
+		}},
+		{name: "go-heap-overflow", output: []string{
+			`==\d+==ERROR: AddressSanitizer: heap-buffer-overflow on address 0x[0-9a-f]+ at pc 0x[0-9a-f]+`,
+			`WRITE of size 1 at 0x[0-9a-f]+`,
+			`    #0 0x[0-9a-f]+ in goHeapOverflow .*bugs\.c:25`,
+			`SUMMARY: AddressSanitizer: heap-buffer-overflow .*bugs\.c:25 in goHeapOverflow`,
+			`==\d+==ABORTING`,
+		}},
+		{name: "use-after-free", output: []string{
+			`==\d+==ERROR: AddressSanitizer: heap-use-after-free on address 0x[0-9a-f]+ at pc 0x[0-9a-f]+`,
+			`READ of size 4 at 0x[0-9a-f]+`,
+			`    #0 0x[0-9a-f]+ in useAfterFree .*bugs\.c:15`,
+			`SUMMARY: AddressSanitizer: heap-use-after-free .*bugs\.c:15 in useAfterFree`,
+			`==\d+==ABORTING`,
+		}},
+		{name: "unreachable", output: []string{
+			`ubsan: builtin-unreachable by 0x[0-9a-f]+ in unreachable .*bugs\.c:20`,
+			`    #0 0x[0-9a-f]+ in unreachable .*bugs\.c:20`,
+		}},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			options := optionsFromTarget("", sema)
+			options.Directory = TESTDATA + "/sanitize"
+			options.Sanitize = "address,undefined"
+			config, err := builder.NewConfig(&options)
+			if err != nil {
+				t.Fatal(err)
+			}
+			output := &bytes.Buffer{}
+			var exitCode int
+			_, err = buildAndRun(".", config, output, []string{tc.name}, nil, time.Minute, func(cmd *exec.Cmd, result builder.BuildResult) error {
+				err := cmd.Run()
+				var exitErr *exec.ExitError
+				if errors.As(err, &exitErr) {
+					exitCode = exitErr.ExitCode()
+					return nil
+				}
+				return err
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if exitCode != 1 {
+				t.Errorf("expected exit code 1, got %d", exitCode)
+			}
+			for _, pattern := range tc.output {
+				if !regexp.MustCompile(`(?m)^` + pattern + `$`).Match(output.Bytes()) {
+					t.Errorf("output does not contain a line matching %q", pattern)
+				}
+			}
+			if t.Failed() {
+				t.Logf("output:\n%s", output.String())
+			}
+		})
+	}
+}
+
 // Check whether the output of a test equals the expected output.
 func checkOutput(t *testing.T, filename string, actual []byte) {
 	expectedOutput, err := os.ReadFile(filename)
@@ -941,6 +1296,87 @@ func TestTest(t *testing.T) {
 				}
 			})
 
+			t.Run("Cover", func(t *testing.T) {
+				t.Parallel()
+
+				// Test a package with coverage enabled. The profile is sent
+				// over stdout, so this also works on emulated targets.
+
+				var wg sync.WaitGroup
+				defer wg.Wait()
+
+				out := ioLogger(t, &wg)
+				defer out.Close()
+
+				var output bytes.Buffer
+				opts := targ.opts
+				opts.TestConfig.CoverMode = "count"
+				opts.TestConfig.CoverProfile = filepath.Join(t.TempDir(), "cover.out")
+				passed, err := Test("github.com/tinygo-org/tinygo/tests/testing/cover", io.MultiWriter(&output, out), out, &opts, "")
+				if err != nil {
+					t.Errorf("test error: %v", err)
+				}
+				if !passed {
+					t.Error("test failed")
+				}
+				if !strings.Contains(output.String(), "coverage: 44.4% of statements") {
+					t.Error("missing coverage percentage in output")
+				}
+				profile, err := os.ReadFile(opts.TestConfig.CoverProfile)
+				if err != nil {
+					t.Fatal("could not read coverage profile:", err)
+				}
+				if !bytes.HasPrefix(profile, []byte("mode: count\n")) {
+					t.Errorf("unexpected coverage profile header:\n%s", profile)
+				}
+				// The first block of Sign is run twice.
+				if !bytes.Contains(profile, []byte("\ngithub.com/tinygo-org/tinygo/tests/testing/cover/cover.go:4.25,5.11 1 2\n")) {
+					t.Errorf("unexpected coverage profile:\n%s", profile)
+				}
+			})
+
+			t.Run("JSON", func(t *testing.T) {
+				t.Parallel()
+
+				// Test the -json output, which should work the same when
+				// running in an emulator.
+
+				var wg sync.WaitGroup
+				defer wg.Wait()
+
+				out := ioLogger(t, &wg)
+				defer out.Close()
+
+				var output bytes.Buffer
+				opts := targ.opts
+				opts.TestConfig.JSON = true
+				passed, err := Test("github.com/tinygo-org/tinygo/tests/testing/fail", io.MultiWriter(&output, out), out, &opts, "")
+				if err != nil {
+					t.Errorf("test error: %v", err)
+				}
+				if passed {
+					t.Error("test passed")
+				}
+				var actions []string
+				decoder := json.NewDecoder(&output)
+				for decoder.More() {
+					var e testEvent
+					if err := decoder.Decode(&e); err != nil {
+						t.Fatal("could not decode test event:", err)
+					}
+					if e.Package != "github.com/tinygo-org/tinygo/tests/testing/fail" {
+						t.Errorf("unexpected package in test event: %q", e.Package)
+					}
+					if e.Action != "output" {
+						actions = append(actions, e.Action+" "+e.Test)
+					}
+				}
+				expected := []string{"start ", "run TestFail", "fail TestFail", "fail "}
+				if !reflect.DeepEqual(actions, expected) {
+					t.Errorf("unexpected test events: %q", actions)
+				}
+			})
+
 			if targ.name != "Host" {
 				// Emulated tests are somewhat slow, and these do not need to be run across every platform.
 				return
@@ -971,6 +1407,32 @@ func TestTest(t *testing.T) {
 				}
 			})
 
+			if runtime.GOOS == "linux" {
+				t.Run("Fuzz", func(t *testing.T) {
+					t.Parallel()
+
+					// Test a package with a fuzz test, and fuzz it for a
+					// limited number of iterations.
+
+					var wg sync.WaitGroup
+					defer wg.Wait()
+
+					out := ioLogger(t, &wg)
+					defer out.Close()
+
+					opts := targ.opts
+					opts.TestConfig.FuzzRegexp = "FuzzParseList"
+					opts.TestConfig.FuzzTime = "1000x"
+					passed, err := Test("github.com/tinygo-org/tinygo/tests/testing/fuzz", out, out, &opts, "")
+					if err != nil {
+						t.Errorf("test error: %v", err)
+					}
+					if !passed {
+						t.Error("test failed")
+					}
+				})
+			}
+
 			t.Run("BuildErr", func(t *testing.T) {
 				t.Parallel()
 
@@ -410,7 +410,7 @@ func (w *outputWriter) Write(p []byte) (n int, err error) {
 			if address != 0 {
 				loc, err := addressToLine(w.executable, address)
 				if err == nil && loc.Filename != "" {
-					fmt.Printf("[tinygo: panic at %s]\n", loc.String())
+					fmt.Fprintf(w.out, "[tinygo: panic at %s]\n", loc.String())
 				}
 			}
 			w.line = w.line[:0]
@@ -0,0 +1,9 @@
+//go:build scheduler.threads
+
+package task
+
+import "internal/futex"
+
+// Futex is a futex that blocks the OS thread, using the futex implementation
+// of the operating system.
+type Futex = futex.Futex
@@ -0,0 +1,56 @@
+//go:build scheduler.threads
+
+package task
+
+// Futex-based mutex.
+// This is largely based on the paper "Futexes are Tricky" by Ulrich Drepper.
+// It describes a few ways to implement mutexes using a futex, and how some
+// seemingly-obvious implementations don't exactly work as intended.
+//
+// The futex can have 3 different values, depending on the state:
+//
+//   - 0: the mutex is currently unlocked.
+//   - 1: the mutex is locked, and is uncontended.
+//   - 2: the mutex is locked, and at least one thread may be waiting for it
+//     to be unlocked.
+//
+// A contended mutex is set back to 0 when unlocked, and a waiter is woken up.
+// It is possible for another thread to lock the mutex before the woken waiter
+// gets to run, but the waiter will then simply set the futex to 2 again and go
+// back to sleep.
+
+type Mutex struct {
+	futex Futex
+}
+
+func (m *Mutex) Lock() {
+	// Fast path: try to take an uncontended lock.
+	if m.futex.CompareAndSwap(0, 1) {
+		return
+	}
+
+	// The mutex is contended. If we manage to change the futex from 0 to 2, we
+	// obtained the lock. Otherwise wait until Unlock wakes us up, which it
+	// does when it sees the futex value 2.
+	for m.futex.Swap(2) != 0 {
+		m.futex.Wait(2)
+	}
+}
+
+func (m *Mutex) Unlock() {
+	if old := m.futex.Swap(0); old == 0 {
+		panic("sync: unlock of unlocked Mutex")
+	} else if old == 2 {
+		// There may be waiters, so wake one of them up.
+		m.futex.Wake()
+	}
+}
+
+// TryLock tries to lock m and reports whether it succeeded.
+//
+// Note that while correct uses of TryLock do exist, they are rare,
+// and use of TryLock is often a sign of a deeper problem
+// in a particular use of mutexes.
+func (m *Mutex) TryLock() bool {
+	return m.futex.CompareAndSwap(0, 1)
+}
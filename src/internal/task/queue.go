@@ -45,6 +45,13 @@ func (q *Queue) Pop() *Task {
 	return t
 }
 
+// Peek returns the first task in the queue without removing it, or nil if the
+// queue is empty. The other tasks in the queue can be reached through the Next
+// field. Interrupts must be disabled while walking the queue.
+func (q *Queue) Peek() *Task {
+	return q.head
+}
+
 // Append pops the contents of another queue and pushes them onto the end of this queue.
 func (q *Queue) Append(other *Queue) {
 	i := interrupt.Disable()
@@ -0,0 +1,34 @@
+//go:build scheduler.threads
+
+package task
+
+// Semaphore is a minimal counting semaphore.
+//
+// The main limitation is that when there are multiple waiters, a single call
+// to Post doesn't wake any of them: the waiters only proceed once Post has
+// been called for all of them. This is not a problem when there is only a
+// single waiter, which is the case for the semaphores used to pause and
+// resume a task.
+type Semaphore struct {
+	futex Futex
+}
+
+// Post (unlock) the semaphore, incrementing the value in the semaphore.
+func (s *Semaphore) Post() {
+	newValue := s.futex.Add(1)
+	if newValue == 0 {
+		// There was a waiter, wake it up.
+		s.futex.WakeAll()
+	}
+}
+
+// Wait (lock) the semaphore, decrementing the value in the semaphore. It
+// returns immediately when Post was called before.
+func (s *Semaphore) Wait() {
+	delta := int32(-1)
+	value := s.futex.Add(uint32(delta))
+	for int32(value) < 0 {
+		s.futex.Wait(value)
+		value = s.futex.Load()
+	}
+}
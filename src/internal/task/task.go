@@ -21,6 +21,9 @@ type Task struct {
 	// state is the underlying running state of the task.
 	state state
 
+	// allNext and allPrev link all tasks that currently exist, see All.
+	allNext, allPrev *Task
+
 	// This is needed for some crypto packages.
 	FipsIndicator uint8
 
@@ -46,6 +49,17 @@ func (t *Task) DataAtomicUint32() *Uint32 {
 	return (*Uint32)(unsafe.Pointer(&t.Data))
 }
 
+// numTasks is the number of tasks that have been started and haven't exited
+// yet.
+var numTasks uintptr
+
+// Count returns the number of tasks that have been started and haven't exited
+// yet. This includes the task running the main function, if the scheduler runs
+// it in a separate task.
+func Count() int {
+	return int(numTasks)
+}
+
 // getGoroutineStackSize is a compiler intrinsic that returns the stack size for
 // the given function and falls back to the default stack size. It is replaced
 // with a load from a special section just before codegen.
@@ -54,5 +68,14 @@ func getGoroutineStackSize(fn uintptr) uintptr
 //go:linkname runtime_alloc runtime.alloc
 func runtime_alloc(size uintptr, layout unsafe.Pointer) unsafe.Pointer
 
+//go:linkname gcWriteBarrier runtime.gcWriteBarrier
+func gcWriteBarrier(ptr unsafe.Pointer)
+
 //go:linkname scheduleTask runtime.scheduleTask
 func scheduleTask(*Task)
+
+//go:linkname traceGoCreate runtime.traceGoCreate
+func traceGoCreate(*Task)
+
+//go:linkname traceGoExit runtime.traceGoExit
+func traceGoExit()
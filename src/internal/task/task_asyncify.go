@@ -28,6 +28,10 @@ type state struct {
 	stackState
 
 	launched bool
+
+	// paused is set when the task is unwound by Pause, to tell the difference
+	// with a task that returned (and therefore exited).
+	paused bool
 }
 
 // stackState is the saved state of a stack while unwound.
@@ -52,6 +56,9 @@ type stackState struct {
 func start(fn uintptr, args unsafe.Pointer, stackSize uintptr) {
 	t := &Task{}
 	t.state.initialize(fn, args, stackSize)
+	numTasks++
+	addTask(t)
+	traceGoCreate(t)
 	scheduleTask(t)
 }
 
@@ -97,6 +104,7 @@ func Pause() {
 		runtimePanic("stack overflow")
 	}
 
+	currentTask.state.paused = true
 	currentTask.state.unwind()
 }
 
@@ -110,17 +118,28 @@ func (t *Task) Resume() {
 	prevTask := currentTask
 	t.gcData.swap()
 	currentTask = t
+	t.state.paused = false
 	if !t.state.launched {
 		t.state.launch()
 		t.state.launched = true
 	} else {
 		t.state.rewind()
 	}
+	if !t.state.paused {
+		// The goroutine function returned.
+		numTasks--
+		removeTask(t)
+		traceGoExit()
+	}
 	currentTask = prevTask
 	t.gcData.swap()
 	if uintptr(t.state.asyncifysp) > uintptr(t.state.csp) {
 		runtimePanic("stack overflow")
 	}
+
+	// Stores to the goroutine stack don't have a write barrier, so tell the
+	// incremental GC (if used) that the stack needs to be scanned again.
+	gcWriteBarrier(unsafe.Pointer(t.state.canaryPtr))
 }
 
 //export tinygo_rewind
@@ -131,3 +150,10 @@ func OnSystemStack() bool {
 	// If there is not an active goroutine, then this must be running on the system stack.
 	return Current() == nil
 }
+
+// PausedFrame returns the return address and frame pointer at which a paused
+// task will resume running. This is not possible with asyncify, because the
+// stack of a paused task has been unwound.
+func (t *Task) PausedFrame() (pc, fp uintptr) {
+	return 0, 0
+}
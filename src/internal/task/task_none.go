@@ -36,3 +36,9 @@ func OnSystemStack() bool {
 	// This scheduler does not do any stack switching.
 	return true
 }
+
+// PausedFrame returns the return address and frame pointer at which a paused
+// task will resume running. Tasks are never paused without a scheduler.
+func (t *Task) PausedFrame() (pc, fp uintptr) {
+	return 0, 0
+}
@@ -54,8 +54,14 @@ func Pause() {
 	currentTask.state.pause()
 }
 
+// pause is called from tinygo_startTask when the goroutine function returns,
+// to exit the goroutine.
+//
 //export tinygo_pause
 func pause() {
+	numTasks--
+	removeTask(currentTask)
+	traceGoExit()
 	Pause()
 }
 
@@ -67,6 +73,10 @@ func (t *Task) Resume() {
 	t.state.resume()
 	t.gcData.swap()
 	currentTask = nil
+
+	// Stores to the goroutine stack don't have a write barrier, so tell the
+	// incremental GC (if used) that the stack needs to be scanned again.
+	gcWriteBarrier(unsafe.Pointer(t.state.canaryPtr))
 }
 
 // initialize the state and prepare to call the specified function with the specified argument bundle.
@@ -106,6 +116,9 @@ var startTask [0]uint8
 func start(fn uintptr, args unsafe.Pointer, stackSize uintptr) {
 	t := &Task{}
 	t.state.initialize(fn, args, stackSize)
+	numTasks++
+	addTask(t)
+	traceGoCreate(t)
 	scheduleTask(t)
 }
 
@@ -114,3 +127,17 @@ func OnSystemStack() bool {
 	// If there is not an active goroutine, then this must be running on the system stack.
 	return Current() == nil
 }
+
+// PausedFrame returns the return address and frame pointer at which a paused
+// task will resume running. It is used to print a traceback of goroutines other
+// than the current one. Both values are zero if the task has not started yet.
+// It must not be called on the currently running task.
+func (t *Task) PausedFrame() (pc, fp uintptr) {
+	r := (*calleeSavedRegs)(unsafe.Pointer(t.state.sp))
+	pc, fp = r.frame()
+	if pc == uintptr(unsafe.Pointer(&startTask)) {
+		// The task hasn't started yet.
+		return 0, 0
+	}
+	return pc, fp
+}
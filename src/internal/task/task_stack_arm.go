@@ -22,6 +22,13 @@ type calleeSavedRegs struct {
 	pc uintptr
 }
 
+// frame returns the program counter and frame pointer at which a paused task
+// will resume.
+func (r *calleeSavedRegs) frame() (pc, fp uintptr) {
+	// The frame pointer is r11 in ARM mode.
+	return r.pc, r.r11
+}
+
 // archInit runs architecture-specific setup for the goroutine startup.
 func (s *state) archInit(r *calleeSavedRegs, fn uintptr, args unsafe.Pointer) {
 	// Store the initial sp for the startTask function (implemented in assembly).
@@ -33,6 +33,12 @@ type calleeSavedRegs struct {
 	d15 uintptr
 }
 
+// frame returns the program counter and frame pointer at which a paused task
+// will resume.
+func (r *calleeSavedRegs) frame() (pc, fp uintptr) {
+	return r.pc, r.x29
+}
+
 // archInit runs architecture-specific setup for the goroutine startup.
 func (s *state) archInit(r *calleeSavedRegs, fn uintptr, args unsafe.Pointer) {
 	// Store the initial sp for the startTask function (implemented in assembly).
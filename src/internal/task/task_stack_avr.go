@@ -26,6 +26,13 @@ type calleeSavedRegs struct {
 	pc uintptr
 }
 
+// frame returns the program counter and frame pointer at which a paused task
+// will resume.
+func (r *calleeSavedRegs) frame() (pc, fp uintptr) {
+	// Frame pointers are not supported on AVR.
+	return 0, 0
+}
+
 // archInit runs architecture-specific setup for the goroutine startup.
 // Note: adding //go:noinline to work around an AVR backend bug.
 //
@@ -32,6 +32,13 @@ type calleeSavedRegs struct {
 	locals [4]uintptr
 }
 
+// frame returns the program counter and frame pointer at which a paused task
+// will resume.
+func (r *calleeSavedRegs) frame() (pc, fp uintptr) {
+	// The windowed ABI does not use frame pointers that can be walked.
+	return 0, 0
+}
+
 // archInit runs architecture-specific setup for the goroutine startup.
 func (s *state) archInit(r *calleeSavedRegs, fn uintptr, args unsafe.Pointer) {
 	// Store the stack pointer for the tinygo_swapTask function (implemented in
@@ -30,6 +30,13 @@ type calleeSavedRegs struct {
 	pc uintptr // also link register or r0
 }
 
+// frame returns the program counter and frame pointer at which a paused task
+// will resume.
+func (r *calleeSavedRegs) frame() (pc, fp uintptr) {
+	// Frame pointers are not supported on Xtensa.
+	return r.pc, 0
+}
+
 // archInit runs architecture-specific setup for the goroutine startup.
 func (s *state) archInit(r *calleeSavedRegs, fn uintptr, args unsafe.Pointer) {
 	// Store the initial sp for the startTask function (implemented in assembly).
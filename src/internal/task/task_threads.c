@@ -0,0 +1,187 @@
+//go:build none
+
+// This file is manually included when using -scheduler=threads on Linux.
+
+#define _GNU_SOURCE
+#include <pthread.h>
+#include <semaphore.h>
+#include <signal.h>
+#include <stdint.h>
+#include <unistd.h>
+
+// Signal used to pause a thread during the mark phase of the GC. BDWGC uses the
+// same signal on Linux.
+#define taskPauseSignal (SIGRTMIN + 6)
+
+// Pointer to the internal/task.Task structure of the current thread.
+static __thread void *current_task;
+
+// State passed from tinygo_task_start to the thread that runs the goroutine.
+struct state_pass {
+    void      (*start)(void*);
+    void      *args;
+    void      *task;
+    pthread_t *thread;
+    uintptr_t *stackTop;
+    sem_t     startlock;
+};
+
+// A thread that has finished running a goroutine and is waiting for the next
+// one. It is stored on the stack of the idle thread.
+struct idle_thread {
+    struct idle_thread *next;
+    struct state_pass  *state; // goroutine to run next
+    sem_t              wake;   // posted when state is set
+};
+
+// Threads are kept around after their goroutine exits, so that new goroutines
+// don't need to create a new thread every time. At most max_idle_threads
+// threads are kept waiting: other threads exit when their goroutine exits.
+static pthread_mutex_t idle_lock = PTHREAD_MUTEX_INITIALIZER;
+static struct idle_thread *idle_threads;
+static int num_idle_threads;
+static int max_idle_threads;
+
+// Implemented in Go.
+void tinygo_task_gc_pause(int sig);
+void tinygo_task_started(void *task);
+void tinygo_task_exited(void *task);
+
+// Initialize the main thread.
+void tinygo_task_init(void *mainTask, pthread_t *thread, int32_t *numCPU) {
+    current_task = mainTask;
+    *thread = pthread_self();
+
+    // Register the GC pause signal for the entire process. The signal is sent
+    // to individual threads using pthread_kill.
+    struct sigaction act = { 0 };
+    act.sa_handler = &tinygo_task_gc_pause;
+    act.sa_flags = SA_RESTART;
+    sigaction(taskPauseSignal, &act, NULL);
+
+    // Obtain the number of CPUs available on program start (for NumCPU).
+    long num = sysconf(_SC_NPROCESSORS_ONLN);
+    if (num <= 0) {
+        num = 1;
+    }
+    *numCPU = num;
+    max_idle_threads = num;
+}
+
+// Run the goroutine passed in state on the current thread.
+static void run_goroutine(struct state_pass *state, uintptr_t stackTop) {
+    void (*start)(void*) = state->start;
+    void *args = state->args;
+    current_task = state->task;
+
+    // Store the thread ID here instead of in pthread_create, so that it is
+    // known before the GC can see this thread.
+    *(state->thread) = pthread_self();
+
+    // Note that the goroutine arguments are only kept alive by this stack (and
+    // the stack of the parent thread) from now on.
+    *(state->stackTop) = stackTop;
+
+    // Make the goroutine known to the GC before the parent continues.
+    tinygo_task_started(current_task);
+    sem_post(&state->startlock);
+
+    // Run the goroutine function.
+    start(args);
+
+    // Notify the Go side that the goroutine has exited.
+    tinygo_task_exited(current_task);
+    current_task = NULL;
+}
+
+// Entry point of a new goroutine thread.
+static void* start_wrapper(void *arg) {
+    // Save the current stack pointer as the top of the stack for the GC. It is
+    // the same for every goroutine that runs on this thread.
+    int stackAddr;
+    uintptr_t stackTop = (uintptr_t)(&stackAddr);
+
+    struct idle_thread idle;
+    sem_init(&idle.wake, 0, 0);
+
+    struct state_pass *state = arg;
+    for (;;) {
+        run_goroutine(state, stackTop);
+
+        // Wait for a new goroutine to run, unless there are enough idle
+        // threads already.
+        pthread_mutex_lock(&idle_lock);
+        if (num_idle_threads >= max_idle_threads) {
+            pthread_mutex_unlock(&idle_lock);
+            break;
+        }
+        idle.next = idle_threads;
+        idle_threads = &idle;
+        num_idle_threads++;
+        pthread_mutex_unlock(&idle_lock);
+        while (sem_wait(&idle.wake) != 0) {
+        }
+        state = idle.state;
+    }
+
+    sem_destroy(&idle.wake);
+    return NULL;
+}
+
+// Start a new goroutine, in an idle thread if there is one or else in a new OS
+// thread.
+int tinygo_task_start(uintptr_t fn, void *args, void *task, pthread_t *thread, uintptr_t *stackTop) {
+    // The Go side stores the thread as an uintptr.
+    if (sizeof(pthread_t) != sizeof(uintptr_t)) {
+        __builtin_trap();
+    }
+
+    struct state_pass state = {
+        .start    = (void*)fn,
+        .args     = args,
+        .task     = task,
+        .thread   = thread,
+        .stackTop = stackTop,
+    };
+    sem_init(&state.startlock, 0, 0);
+
+    pthread_mutex_lock(&idle_lock);
+    struct idle_thread *idle = idle_threads;
+    if (idle != NULL) {
+        idle_threads = idle->next;
+        num_idle_threads--;
+    }
+    pthread_mutex_unlock(&idle_lock);
+
+    int result = 0;
+    if (idle != NULL) {
+        idle->state = &state;
+        sem_post(&idle->wake);
+    } else {
+        pthread_attr_t attr;
+        pthread_attr_init(&attr);
+        pthread_attr_setdetachstate(&attr, PTHREAD_CREATE_DETACHED);
+        pthread_t newThread;
+        result = pthread_create(&newThread, &attr, &start_wrapper, &state);
+        pthread_attr_destroy(&attr);
+    }
+
+    // Wait until the thread has started and has read all of the state.
+    // The wait may be interrupted by the GC pause signal, so retry.
+    if (result == 0) {
+        while (sem_wait(&state.startlock) != 0) {
+        }
+    }
+    sem_destroy(&state.startlock);
+    return result;
+}
+
+// Return the current task (for task.Current()).
+void* tinygo_task_current(void) {
+    return current_task;
+}
+
+// Pause the given thread for the GC mark phase.
+void tinygo_task_send_gc_signal(pthread_t thread) {
+    pthread_kill(thread, taskPauseSignal);
+}
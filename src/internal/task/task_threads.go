@@ -0,0 +1,268 @@
+//go:build scheduler.threads
+
+package task
+
+// This file implements goroutines as OS threads (pthreads). Blocking is done
+// using futexes, and the garbage collector stops the world by sending a signal
+// to all other threads so that their stacks can be scanned.
+//
+// Every goroutine that exists has its own thread: goroutines are not
+// multiplexed onto a fixed number of threads like with the gc toolchain, as
+// that would need stack switching on top of the threads. To avoid creating a
+// new thread for every goroutine, threads are reused for new goroutines after
+// their goroutine exits. Up to NumCPU idle threads are kept for this purpose.
+
+import (
+	"unsafe"
+)
+
+// state is the per-goroutine state when each goroutine is an OS thread.
+type state struct {
+	// thread is the pthread_t of the thread that runs this goroutine.
+	thread uintptr
+
+	// stackTop is the highest address of the thread stack, used when scanning
+	// the stack in the GC.
+	stackTop uintptr
+
+	// QueueNext is the next task in the activeTasks list.
+	QueueNext *Task
+
+	// pauseSem is used to pause and resume the goroutine.
+	pauseSem Semaphore
+
+	// gcSem is used to pause the thread during a GC cycle.
+	gcSem Semaphore
+}
+
+// mainTask is the task of the main goroutine, which runs on the main thread.
+var mainTask Task
+
+// numCPU is the number of CPUs available at startup, see NumCPU.
+var numCPU int32
+
+// List of tasks (linked via QueueNext) that currently exist in the program.
+// It is used to stop all threads during a GC cycle.
+var (
+	activeTasks    = &mainTask
+	activeTaskLock PMutex
+)
+
+//go:linkname runtimePanic runtime.runtimePanic
+func runtimePanic(str string)
+
+// Init initializes the main goroutine. It must be called by the runtime on
+// startup, before starting other goroutines. The sp parameter is the top of
+// the stack of the main thread.
+func Init(sp uintptr) {
+	mainTask.state.stackTop = sp
+	numTasks = 1
+	tinygo_task_init(unsafe.Pointer(&mainTask), &mainTask.state.thread, &numCPU)
+}
+
+// Current returns the task of the current thread.
+func Current() *Task {
+	t := (*Task)(tinygo_task_current())
+	if t == nil {
+		runtimePanic("unknown current task")
+	}
+	return t
+}
+
+// Pause suspends the current goroutine until it is resumed by Resume.
+// It is possible that Resume is called before Pause, in which case Pause
+// returns immediately. Channel operations rely on this, as a goroutine might
+// be resumed between releasing the channel lock and the call to Pause.
+func Pause() {
+	Current().state.pauseSem.Wait()
+}
+
+// Resume the given task. If the task is not paused yet, the next call to
+// Pause won't block.
+func (t *Task) Resume() {
+	t.state.pauseSem.Post()
+}
+
+// start creates and starts a new goroutine with the given function and
+// arguments, in an idle thread or a new OS thread. The stack size is determined
+// by the C library and the stackSize parameter is ignored.
+func start(fn uintptr, args unsafe.Pointer, stackSize uintptr) {
+	t := &Task{}
+	if tinygo_task_start(fn, args, unsafe.Pointer(t), &t.state.thread, &t.state.stackTop) != 0 {
+		runtimePanic("could not start thread")
+	}
+}
+
+// taskStarted is called by a new thread before it runs the goroutine function.
+// The task can't be added to activeTasks in start, as it may already have
+// exited by then.
+//
+//export tinygo_task_started
+func taskStarted(t *Task) {
+	activeTaskLock.Lock()
+	t.state.QueueNext = activeTasks
+	activeTasks = t
+	numTasks++
+	activeTaskLock.Unlock()
+}
+
+// taskExited is called by a thread after the goroutine function returned,
+// right before the thread exits.
+//
+//export tinygo_task_exited
+func taskExited(t *Task) {
+	activeTaskLock.Lock()
+	found := false
+	for q := &activeTasks; *q != nil; q = &(*q).state.QueueNext {
+		if *q == t {
+			*q = t.state.QueueNext
+			found = true
+			break
+		}
+	}
+	numTasks--
+	activeTaskLock.Unlock()
+
+	if !found {
+		runtimePanic("exited task not found")
+	}
+}
+
+// All calls fn for every task that has been started and hasn't exited yet.
+// Tasks can't be started or exit while All is running, so fn must not start a
+// goroutine.
+func All(fn func(t *Task)) {
+	activeTaskLock.Lock()
+	for t := activeTasks; t != nil; t = t.state.QueueNext {
+		fn(t)
+	}
+	activeTaskLock.Unlock()
+}
+
+// Futex to wait on until all other threads have scanned their stack. The value
+// is the number of threads that still need to scan their stack.
+var scanDoneFutex Futex
+
+// Lock to make sure only a single thread marks its stack at a time, as the
+// mark phase of the GC doesn't support parallelism.
+var stackScanLock PMutex
+
+// GCScan is the mark phase of the GC when each goroutine runs in its own
+// thread. It stops all other threads, lets them scan their own stack, scans
+// the globals and then lets the other threads continue.
+func GCScan() {
+	current := Current()
+
+	// Don't allow goroutines to start or exit while the world is stopped.
+	activeTaskLock.Lock()
+
+	// Pause all other threads.
+	numOtherThreads := uint32(0)
+	for t := activeTasks; t != nil; t = t.state.QueueNext {
+		if t != current {
+			numOtherThreads++
+			tinygo_task_send_gc_signal(t.state.thread)
+		}
+	}
+	scanDoneFutex.Store(numOtherThreads)
+
+	// Scan the current stack, and all current registers.
+	scanCurrentStack()
+
+	// Let the paused threads scan their stack.
+	for t := activeTasks; t != nil; t = t.state.QueueNext {
+		if t != current {
+			t.state.gcSem.Post()
+		}
+	}
+
+	// Wait until all threads have finished scanning their stack.
+	for {
+		val := scanDoneFutex.Load()
+		if val == 0 {
+			break
+		}
+		scanDoneFutex.Wait(val)
+	}
+
+	// Scan all globals (implemented in the runtime).
+	gcScanGlobals()
+
+	// Let the paused threads continue running.
+	for t := activeTasks; t != nil; t = t.state.QueueNext {
+		if t != current {
+			t.state.gcSem.Post()
+		}
+	}
+
+	activeTaskLock.Unlock()
+}
+
+// gcPause is the handler of the signal sent by GCScan. It runs on the thread
+// that is being paused.
+//
+//export tinygo_task_gc_pause
+func gcPause(sig int32) {
+	t := Current()
+
+	// Wait until GCScan lets us scan the stack.
+	t.state.gcSem.Wait()
+
+	// Scan the stack of this thread. The registers of the interrupted code
+	// have been saved on the stack by the signal handler, so they will be
+	// scanned as well.
+	stackScanLock.Lock()
+	scanCurrentStack()
+	stackScanLock.Unlock()
+
+	// Notify GCScan that this thread has finished scanning its stack.
+	if scanDoneFutex.Add(^uint32(0)) == 0 {
+		scanDoneFutex.Wake()
+	}
+
+	// Wait until the mark phase has finished.
+	t.state.gcSem.Wait()
+}
+
+// StackTop returns the highest address of the stack of the current thread.
+func StackTop() uintptr {
+	return Current().state.stackTop
+}
+
+// NumCPU returns the number of CPUs that were available at startup.
+func NumCPU() int {
+	return int(numCPU)
+}
+
+// OnSystemStack returns whether the caller is running on the system stack,
+// which is only the case for the main goroutine. Unlike Current, it can also be
+// called from a signal handler on a thread that isn't running a goroutine.
+func OnSystemStack() bool {
+	return tinygo_task_current() == unsafe.Pointer(&mainTask)
+}
+
+// PausedFrame returns the return address and frame pointer at which a paused
+// task will resume running. This is not supported when goroutines are threads.
+func (t *Task) PausedFrame() (pc, fp uintptr) {
+	return 0, 0
+}
+
+// gcScanGlobals scans all globals, it is implemented in the runtime.
+//
+//go:linkname gcScanGlobals runtime.gcScanGlobals
+func gcScanGlobals()
+
+//export tinygo_scanCurrentStack
+func scanCurrentStack()
+
+//export tinygo_task_init
+func tinygo_task_init(mainTask unsafe.Pointer, thread *uintptr, numCPU *int32)
+
+//export tinygo_task_start
+func tinygo_task_start(fn uintptr, args unsafe.Pointer, t unsafe.Pointer, thread *uintptr, stackTop *uintptr) int32
+
+//export tinygo_task_current
+func tinygo_task_current() unsafe.Pointer
+
+//export tinygo_task_send_gc_signal
+func tinygo_task_send_gc_signal(thread uintptr)
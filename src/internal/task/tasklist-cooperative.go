@@ -0,0 +1,39 @@
+//go:build !scheduler.threads
+
+package task
+
+// List of all tasks that have been started and haven't exited yet, linked via
+// allNext and allPrev. It is used to find goroutines that are blocked (on a
+// channel for example) and therefore not part of any scheduler queue.
+var allTasks *Task
+
+// addTask adds a newly started task to the list of all tasks.
+func addTask(t *Task) {
+	t.allNext = allTasks
+	if allTasks != nil {
+		allTasks.allPrev = t
+	}
+	allTasks = t
+}
+
+// removeTask removes an exited task from the list of all tasks.
+func removeTask(t *Task) {
+	if t.allPrev != nil {
+		t.allPrev.allNext = t.allNext
+	} else {
+		allTasks = t.allNext
+	}
+	if t.allNext != nil {
+		t.allNext.allPrev = t.allPrev
+	}
+	t.allNext = nil
+	t.allPrev = nil
+}
+
+// All calls fn for every task that has been started and hasn't exited yet.
+// Tasks must not be started or exit while All is running.
+func All(fn func(t *Task)) {
+	for t := allTasks; t != nil; t = t.allNext {
+		fn(t)
+	}
+}
@@ -1702,8 +1702,6 @@ func TestNilMap(t *testing.T) {
 	mv.SetMapIndex(ValueOf("hi"), Value{})
 }
 
-/* // TODO(tinygo): missing chan reflect support
-
 func TestChan(t *testing.T) {
 	for loop := 0; loop < 2; loop++ {
 		var c chan int
@@ -1943,16 +1941,20 @@ func TestSelect(t *testing.T) {
 		}
 
 		// closed Chan send.
-		if x.Maybe() {
-			ch := make(chan int)
-			close(ch)
-			cases = append(cases, SelectCase{
-				Dir:  SelectSend,
-				Chan: ValueOf(ch),
-				Send: ValueOf(101),
-			})
-			info = append(info, caseInfo{desc: "closed Chan send", canSelect: true, panic: true})
-		}
+		// TODO(tinygo): sending on a closed channel is a runtime error, which
+		// aborts the program instead of panicking.
+		/*
+			if x.Maybe() {
+				ch := make(chan int)
+				close(ch)
+				cases = append(cases, SelectCase{
+					Dir:  SelectSend,
+					Chan: ValueOf(ch),
+					Send: ValueOf(101),
+				})
+				info = append(info, caseInfo{desc: "closed Chan send", canSelect: true, panic: true})
+			}
+		*/
 
 		// closed Chan recv.
 		if x.Maybe() {
@@ -2072,6 +2074,9 @@ func TestSelect(t *testing.T) {
 }
 
 func TestSelectMaxCases(t *testing.T) {
+	if runtime.GOARCH == "wasm" {
+		t.Skip("TODO(tinygo): recover is not supported on WebAssembly")
+	}
 	var sCases []SelectCase
 	channel := make(chan int)
 	close(channel)
@@ -2172,8 +2177,6 @@ func fmtSelect(info []caseInfo) string {
 	return buf.String()
 }
 
-// TODO(tinygo): missing func/method/call support
-
 type two [2]uintptr
 
 // Difficult test for function call because of
@@ -2264,6 +2267,9 @@ func TestCallWithStruct(t *testing.T) {
 	}
 }
 
+/*
+// TODO(tinygo): missing finalizer support
+
 func TestCallReturnsEmpty(t *testing.T) {
 	// Issue 21717: past-the-end pointer write in Call with
 	// nonzero-sized frame and zero-sized return value.
@@ -2288,6 +2294,8 @@ func TestCallReturnsEmpty(t *testing.T) {
 	runtime.KeepAlive(v)
 }
 
+*/
+
 func TestMakeFunc(t *testing.T) {
 	f := dummy
 	fv := MakeFunc(TypeOf(f), func(in []Value) []Value { return in })
@@ -2447,8 +2455,6 @@ func TestMakeFuncInvalidReturnAssignments(t *testing.T) {
 	})
 }
 
-*/
-
 type Point struct {
 	x, y int
 }
@@ -4975,8 +4981,6 @@ func TestTypeOverflow(t *testing.T) {
 	}
 }
 
-/*
-
 func checkSameType(t *testing.T, x Type, y any) {
 	if x != TypeOf(y) || TypeOf(Zero(x).Interface()) != TypeOf(y) {
 		t.Errorf("did not find preexisting type for %s (vs %s)", TypeOf(x), TypeOf(y))
@@ -5110,6 +5114,9 @@ func TestArrayOf(t *testing.T) {
 	checkSameType(t, ArrayOf(5, TypeOf(T(1))), [5]T{})
 }
 
+/*
+// TODO(tinygo): these tests rely on unimplemented features
+
 func TestArrayOfGC(t *testing.T) {
 	type T *uintptr
 	tt := TypeOf(T(nil))
@@ -5222,6 +5229,8 @@ func TestArrayOfDirectIface(t *testing.T) {
 	}
 }
 
+*/
+
 // Ensure passing in negative lengths panics.
 // See https://golang.org/issue/43603
 func TestArrayOfPanicOnNegativeLength(t *testing.T) {
@@ -5254,6 +5263,9 @@ func TestSliceOf(t *testing.T) {
 	checkSameType(t, SliceOf(TypeOf(T1(1))), []T1{})
 }
 
+/* // TODO(tinygo): MakeSlice reports an overflowing size as a runtime error,
+// which aborts the program instead of panicking, so it can't be recovered.
+
 func TestSliceOverflow(t *testing.T) {
 	// check that MakeSlice panics when size of slice overflows uint
 	const S = 1e6
@@ -5273,6 +5285,8 @@ func TestSliceOverflow(t *testing.T) {
 	MakeSlice(st, int(l), int(l))
 }
 
+*/
+
 func TestSliceOfGC(t *testing.T) {
 	type T *uintptr
 	tt := TypeOf(T(nil))
@@ -5634,6 +5648,8 @@ func TestStructOfExportRules(t *testing.T) {
 	}
 }
 
+/*
+
 func TestStructOfGC(t *testing.T) {
 	type T *uintptr
 	tt := TypeOf(T(nil))
@@ -6184,6 +6200,8 @@ func TestStructOfTooLarge(t *testing.T) {
 	}
 }
 
+*/
+
 func TestChanOf(t *testing.T) {
 	// check construction and use of type not in binary
 	type T string
@@ -6240,6 +6258,8 @@ func TestChanOfDir(t *testing.T) {
 	}
 }
 
+/*
+
 func TestChanOfGC(t *testing.T) {
 	done := make(chan bool, 1)
 	go func() {
@@ -6288,6 +6308,8 @@ func TestChanOfGC(t *testing.T) {
 	}
 }
 
+*/
+
 func TestMapOf(t *testing.T) {
 	// check construction and use of type not in binary
 	type K string
@@ -6311,6 +6333,8 @@ func TestMapOf(t *testing.T) {
 	shouldPanic("invalid key type", func() { MapOf(TypeOf((func())(nil)), TypeOf(false)) })
 }
 
+/*
+
 func TestMapOfGCKeys(t *testing.T) {
 	type T *uintptr
 	tt := TypeOf(T(nil))
@@ -6393,6 +6417,8 @@ func TestTypelinksSorted(t *testing.T) {
 	}
 }
 
+*/
+
 func TestFuncOf(t *testing.T) {
 	// check construction and use of type not in binary
 	type K string
@@ -6451,8 +6477,6 @@ func TestFuncOf(t *testing.T) {
 	FuncOf(in, nil, false)
 }
 
-*/
-
 type R0 struct {
 	*R1
 	*R2
@@ -6553,6 +6577,8 @@ func TestAllocsInterfaceSmall(t *testing.T) {
 	}
 }
 
+*/
+
 // An exhaustive is a mechanism for writing exhaustive or stochastic tests.
 // The basic usage is:
 //
@@ -6643,6 +6669,8 @@ func (x *exhaustive) Maybe() bool {
 	return x.Choose(2) == 1
 }
 
+/*
+
 func GCFunc(args []Value) []Value {
 	runtime.GC()
 	return []Value{}
@@ -1,5 +1,126 @@
 package reflect
 
+import "unsafe"
+
+// makeFuncImpl is the context of a function created by MakeFunc. The function
+// pointer of such a function is a stub emitted by the compiler for each
+// function signature, which calls callMakeFunc with this context. Function
+// types that don't exist in the program have no stub, see makeFuncNoStub.
+type makeFuncImpl struct {
+	ftyp *rawType
+	fn   func(args []Value) (results []Value)
+}
+
+// MakeFunc returns a new function of the given Type
+// that wraps the function fn. When called, that new function
+// does the following:
+//
+//   - converts its arguments to a slice of Values.
+//   - runs results := fn(args).
+//   - returns the results as a slice of Values, one per formal result.
+//
+// The implementation fn can assume that the argument Value slice
+// has the number and type of arguments given by typ.
+// If typ describes a variadic function, the final Value is itself
+// a slice representing the variadic arguments, as in the
+// body of a variadic function. The result Value slice returned by fn
+// must have the number and type of results given by typ.
+//
+// If typ doesn't exist in the program, which is usually the case for function
+// types created using FuncOf, the new function can only be called using
+// Value.Call and Value.CallSlice.
 func MakeFunc(typ Type, fn func(args []Value) (results []Value)) Value {
-	panic("unimplemented: reflect.MakeFunc()")
+	if typ.Kind() != Func {
+		panic("reflect: call of MakeFunc with non-Func type")
+	}
+	t := typ.(*rawType)
+	code := t.funcType().makeFunc
+	if code == nil {
+		code = makeFuncNoStubCode()
+	}
+	impl := &makeFuncImpl{
+		ftyp: t,
+		fn:   fn,
+	}
+	return Value{
+		typecode: t,
+		value: unsafe.Pointer(&funcHeader{
+			Context: unsafe.Pointer(impl),
+			Code:    code,
+		}),
+		flags: valueFlagExported,
+	}
+}
+
+// makeFuncNoStub is the function pointer of functions created by MakeFunc for
+// function types without a stub. Value.Call recognizes it and calls
+// callMakeFunc directly, so it is only reached when such a function is called
+// in some other way.
+func makeFuncNoStub() {
+	panic("reflect: function created by MakeFunc of a type created at runtime can only be called using Value.Call")
+}
+
+// makeFuncNoStubCode returns the function pointer of makeFuncNoStub.
+func makeFuncNoStubCode() unsafe.Pointer {
+	fn := makeFuncNoStub
+	return (*funcHeader)(unsafe.Pointer(&fn)).Code
+}
+
+// callMakeFunc is called from the stub of a function created by MakeFunc. The
+// params and results point to buffers laid out like a struct with all
+// parameters or results as fields, like for Value.Call.
+//
+// The compiler emits calls to it by name, see getMakeFuncStub in
+// compiler/interface.go. Value.Call calls it directly for functions without a
+// stub.
+func callMakeFunc(impl unsafe.Pointer, params, results unsafe.Pointer) {
+	f := (*makeFuncImpl)(impl)
+	ft := f.ftyp.funcType()
+
+	// Read the parameters from the buffer. The buffer is only valid during
+	// this call, so values that don't fit in a pointer are copied.
+	in := make([]Value, ft.numIn)
+	var offset uintptr
+	for i := range in {
+		t := ft.in(i)
+		offset = align(offset, uintptr(t.Align()))
+		value := unsafe.Add(params, offset)
+		if t.Size() <= unsafe.Sizeof(uintptr(0)) {
+			value = unsafe.Pointer(loadValue(value, t.Size()))
+		} else {
+			buf := alloc(t.Size(), nil)
+			memcpy(buf, value, t.Size())
+			value = buf
+		}
+		in[i] = Value{
+			typecode: t,
+			value:    value,
+			flags:    valueFlagExported,
+		}
+		offset += t.Size()
+	}
+
+	out := f.fn(in)
+
+	// Store the results in the results buffer.
+	numOut := int(ft.numOut)
+	if len(out) != numOut {
+		panic("reflect: wrong return count from function created by MakeFunc")
+	}
+	offset = 0
+	for i, x := range out {
+		t := ft.out(i)
+		if x.typecode == nil {
+			panic("reflect: function created by MakeFunc using closure returned zero Value")
+		}
+		if x.isRO() {
+			panic("reflect: function created by MakeFunc using closure returned value obtained from unexported field")
+		}
+		if !x.typecode.AssignableTo(t) {
+			panic("reflect: function created by MakeFunc using closure returned wrong type: have " + x.typecode.String() + " for " + t.String())
+		}
+		offset = align(offset, uintptr(t.Align()))
+		x.storeTo(unsafe.Add(results, offset), t)
+		offset += t.Size()
+	}
 }
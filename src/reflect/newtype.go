@@ -0,0 +1,410 @@
+package reflect
+
+// This file implements the type constructors: SliceOf, ArrayOf, MapOf,
+// ChanOf, StructOf, FuncOf and PointerTo for pointer types that can't be
+// represented using a tagged pointer.
+//
+// Types are compared by pointer, so a constructed type must be the same type
+// struct as the one created by the compiler for an identical type, if there is
+// one. To make this possible, the interface lowering pass defines a list of all
+// unnamed composite types in the program if the list is referenced. On first
+// use, these types are added to a cache that is keyed by a string that
+// describes the type. Types that don't exist in the program are created on the
+// heap using the same layout as the type structs emitted by the compiler (see
+// the top of type.go) and are added to the same cache.
+
+import (
+	"internal/itoa"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+	"unsafe"
+)
+
+// List of all unnamed slice, array, map, channel, function and struct types in
+// the program. It is defined by the interface lowering pass.
+//
+//go:extern reflect.types
+var programTypes struct {
+	numTypes uintptr
+	types    [0]*rawType
+}
+
+var (
+	typeCacheLock sync.Mutex
+	typeCache     map[string]*rawType
+)
+
+// lookupType returns the type with the given key, or creates it using create
+// if it doesn't exist yet. The create function must not call lookupType.
+func lookupType(key []byte, create func() *rawType) *rawType {
+	typeCacheLock.Lock()
+	defer typeCacheLock.Unlock()
+
+	if typeCache == nil {
+		typeCache = make(map[string]*rawType)
+		types := unsafe.Slice((**rawType)(unsafe.Pointer(&programTypes.types)), programTypes.numTypes)
+		for _, t := range types {
+			if key := programTypeKey(t); key != nil {
+				typeCache[string(key)] = t
+			}
+		}
+	}
+
+	if t := typeCache[string(key)]; t != nil {
+		return t
+	}
+	t := create()
+	typeCache[string(key)] = t
+	return t
+}
+
+// programTypeKey returns the cache key for a type in the list of program types.
+func programTypeKey(t *rawType) []byte {
+	if t.isNamed() {
+		return nil
+	}
+	switch t.Kind() {
+	case Slice:
+		return sliceKey(t.elem())
+	case Array:
+		return arrayKey(t.Len(), t.elem())
+	case Map:
+		return mapKey(t.key(), t.elem())
+	case Chan:
+		return chanKey(t.ChanDir(), t.elem())
+	case Func:
+		ft := t.funcType()
+		in := make([]*rawType, ft.numIn)
+		for i := range in {
+			in[i] = ft.in(i)
+		}
+		out := make([]*rawType, ft.numOut)
+		for i := range out {
+			out[i] = ft.out(i)
+		}
+		return funcKey(in, out, ft.variadic)
+	case Struct:
+		fields := make([]rawStructField, t.NumField())
+		for i := range fields {
+			fields[i] = t.rawField(i)
+		}
+		return structKey(fields)
+	}
+	return nil
+}
+
+func appendTypeKey(key []byte, t *rawType) []byte {
+	p := uintptr(unsafe.Pointer(t))
+	for i := uintptr(0); i < unsafe.Sizeof(p); i++ {
+		key = append(key, byte(p>>(i*8)))
+	}
+	return key
+}
+
+func pointerKey(elem *rawType) []byte {
+	return appendTypeKey([]byte("*"), elem)
+}
+
+func sliceKey(elem *rawType) []byte {
+	return appendTypeKey([]byte("[]"), elem)
+}
+
+func arrayKey(length int, elem *rawType) []byte {
+	return appendTypeKey([]byte("["+itoa.Itoa(length)+"]"), elem)
+}
+
+func mapKey(key, elem *rawType) []byte {
+	return appendTypeKey(appendTypeKey([]byte("map"), key), elem)
+}
+
+func chanKey(dir ChanDir, elem *rawType) []byte {
+	return appendTypeKey([]byte("chan"+itoa.Itoa(int(dir))), elem)
+}
+
+func funcKey(in, out []*rawType, variadic bool) []byte {
+	key := []byte("func")
+	if variadic {
+		key = append(key, '.')
+	}
+	key = append(key, itoa.Itoa(len(in))+","+itoa.Itoa(len(out))+":"...)
+	for _, t := range in {
+		key = appendTypeKey(key, t)
+	}
+	for _, t := range out {
+		key = appendTypeKey(key, t)
+	}
+	return key
+}
+
+func structKey(fields []rawStructField) []byte {
+	key := []byte("struct{")
+	for _, field := range fields {
+		key = appendTypeKey(key, field.Type)
+		if field.Anonymous {
+			key = append(key, 'E')
+		} else {
+			key = append(key, 'F')
+		}
+		key = append(key, field.Name...)
+		key = append(key, 0)
+		key = append(key, field.PkgPath...)
+		key = append(key, 0, byte(len(field.Tag)))
+		key = append(key, field.Tag...)
+	}
+	return key
+}
+
+// newPointerType creates a new pointer type that points to elem.
+func newPointerType(elem *rawType) *rawType {
+	t := &ptrType{
+		rawType: rawType{meta: uint8(Pointer) | flagComparable | flagIsBinary},
+		elem:    elem,
+	}
+	return &t.rawType
+}
+
+// newElemType creates a new slice type. It can also be used for other types
+// with the elemType layout.
+func newElemType(meta uint8, elem *rawType) *rawType {
+	t := &elemType{
+		rawType: rawType{meta: meta},
+		elem:    elem,
+	}
+	t.ptrTo = newPointerType(&t.rawType)
+	return &t.rawType
+}
+
+func sliceOf(elem *rawType) *rawType {
+	return lookupType(sliceKey(elem), func() *rawType {
+		return newElemType(uint8(Slice), elem)
+	})
+}
+
+func arrayOf(length int, elem *rawType) *rawType {
+	if length < 0 {
+		panic("reflect: negative length passed to ArrayOf")
+	}
+	if size := elem.Size(); size != 0 && uintptr(length) > ^uintptr(0)/size {
+		panic("reflect.ArrayOf: array size would exceed virtual address space")
+	}
+	slice := sliceOf(elem)
+	return lookupType(arrayKey(length, elem), func() *rawType {
+		meta := uint8(Array)
+		if elem.Comparable() {
+			meta |= flagComparable
+		}
+		if elem.isBinary() {
+			meta |= flagIsBinary
+		}
+		t := &arrayType{
+			rawType:  rawType{meta: meta},
+			elem:     elem,
+			arrayLen: uintptr(length),
+			slicePtr: slice,
+		}
+		t.ptrTo = newPointerType(&t.rawType)
+		return &t.rawType
+	})
+}
+
+func mapOf(key, elem *rawType) *rawType {
+	if !key.Comparable() {
+		panic("reflect.MapOf: invalid key type " + key.String())
+	}
+	return lookupType(mapKey(key, elem), func() *rawType {
+		t := &mapType{
+			rawType: rawType{meta: uint8(Map)},
+			elem:    elem,
+			key:     key,
+		}
+		t.ptrTo = newPointerType(&t.rawType)
+		return &t.rawType
+	})
+}
+
+func chanOf(dir ChanDir, elem *rawType) *rawType {
+	switch dir {
+	case SendDir, RecvDir, BothDir:
+	default:
+		panic("reflect.ChanOf: invalid dir")
+	}
+	return lookupType(chanKey(dir, elem), func() *rawType {
+		t := &elemType{
+			rawType:   rawType{meta: uint8(Chan) | flagComparable},
+			numMethod: uint16(dir), // the channel direction, see ChanDir
+			elem:      elem,
+		}
+		t.ptrTo = newPointerType(&t.rawType)
+		return &t.rawType
+	})
+}
+
+func funcOf(in, out []*rawType, variadic bool) *rawType {
+	if variadic && (len(in) == 0 || in[len(in)-1].Kind() != Slice) {
+		panic("reflect.FuncOf: last arg of variadic func must be slice")
+	}
+	if len(in) > 0xffff || len(out) > 0xffff {
+		panic("reflect.FuncOf: too many arguments")
+	}
+	return lookupType(funcKey(in, out, variadic), func() *rawType {
+		// The call trampoline and MakeFunc stub are left nil: they can't be
+		// created at runtime.
+		params := append(in[:len(in):len(in)], out...)
+		size := unsafe.Offsetof(funcType{}.params) + uintptr(len(params))*unsafe.Sizeof(params[0])
+		t := (*funcType)(alloc(size, nil))
+		t.meta = uint8(Func)
+		t.numIn = uint16(len(in))
+		t.numOut = uint16(len(out))
+		t.variadic = variadic
+		copy(unsafe.Slice(&t.params[0], len(params)), params)
+		t.ptrTo = newPointerType(&t.rawType)
+		return &t.rawType
+	})
+}
+
+func structOf(fields []StructField) *rawType {
+	// Check the fields, and convert them to the form used for the cache key.
+	if len(fields) > 0xffff {
+		panic("reflect.StructOf: too many fields")
+	}
+	rawFields := make([]rawStructField, len(fields))
+	var pkgPath string
+	for i, field := range fields {
+		if field.Name == "" {
+			panic("reflect.StructOf: field " + itoa.Itoa(i) + " has no name")
+		}
+		if !isValidFieldName(field.Name) {
+			panic("reflect.StructOf: field \"" + field.Name + "\" has invalid name")
+		}
+		if field.Type == nil {
+			panic("reflect.StructOf: field \"" + field.Name + "\" has no type")
+		}
+		for _, other := range fields[:i] {
+			if other.Name == field.Name {
+				panic("reflect.StructOf: duplicate field " + field.Name)
+			}
+		}
+		typ := field.Type.(*rawType)
+		if field.Anonymous && field.PkgPath != "" {
+			panic("reflect.StructOf: field \"" + field.Name + "\" is anonymous but has PkgPath set")
+		}
+		if field.IsExported() {
+			// Best-effort check for misuse, like upstream Go.
+			if c := field.Name[0]; 'a' <= c && c <= 'z' || c == '_' {
+				panic("reflect.StructOf: field \"" + field.Name + "\" is unexported but missing PkgPath")
+			}
+		} else {
+			if pkgPath != "" && pkgPath != field.PkgPath {
+				// All unexported fields share the package path stored in the
+				// struct type.
+				panic("unimplemented: reflect.StructOf() with unexported fields from different packages")
+			}
+			pkgPath = field.PkgPath
+		}
+		if field.Anonymous && typ.NumMethod() != 0 {
+			panic("unimplemented: reflect.StructOf() with embedded field with methods")
+		}
+		if len(field.Tag) > 0xff {
+			panic("reflect.StructOf: struct tag of field \"" + field.Name + "\" is too long")
+		}
+		rawFields[i] = rawStructField{
+			Name:      field.Name,
+			PkgPath:   field.PkgPath,
+			Type:      typ,
+			Tag:       field.Tag,
+			Anonymous: field.Anonymous,
+		}
+	}
+
+	return lookupType(structKey(rawFields), func() *rawType {
+		size := unsafe.Offsetof(structType{}.fields) + uintptr(len(rawFields))*unsafe.Sizeof(structField{})
+		t := (*structType)(alloc(size, nil))
+		t.meta = uint8(Struct) | flagComparable | flagIsBinary
+		t.pkgpath = &append([]byte(pkgPath), 0)[0]
+		t.numField = uint16(len(rawFields))
+
+		// Lay out the fields like the compiler does.
+		structFields := unsafe.Slice(&t.fields[0], len(rawFields))
+		var offset uintptr
+		alignment := uintptr(1)
+		for i, field := range rawFields {
+			if !field.Type.Comparable() {
+				t.meta &^= flagComparable
+			}
+			if !field.Type.isBinary() {
+				t.meta &^= flagIsBinary
+			}
+			fieldAlign := uintptr(field.Type.Align())
+			if fieldAlign > alignment {
+				alignment = fieldAlign
+			}
+			offset = align(offset, fieldAlign)
+			structFields[i] = structField{
+				fieldType: field.Type,
+				data:      unsafe.Pointer(&newStructFieldData(field, offset)[0]),
+			}
+			offset += field.Type.Size()
+		}
+		size = align(offset, alignment)
+		if uint64(size) > 0xffffffff {
+			panic("reflect.StructOf: struct size would exceed virtual address space")
+		}
+		t.size = uint32(size)
+
+		t.ptrTo = newPointerType(&t.rawType)
+		return &t.rawType
+	})
+}
+
+// newStructFieldData returns the packed field information of a struct field,
+// in the same format as emitted by the compiler (see rawField).
+func newStructFieldData(field rawStructField, offset uintptr) []byte {
+	var flags byte
+	if field.Anonymous {
+		flags |= structFieldFlagAnonymous | structFieldFlagIsEmbedded
+	}
+	if field.Tag != "" {
+		flags |= structFieldFlagHasTag
+	}
+	if field.PkgPath == "" {
+		flags |= structFieldFlagIsExported
+	}
+	data := []byte{flags}
+	for offset >= 0x80 {
+		data = append(data, byte(offset)|0x80)
+		offset >>= 7
+	}
+	data = append(data, byte(offset))
+	data = append(data, field.Name...)
+	data = append(data, 0)
+	if field.Tag != "" {
+		data = append(data, byte(len(field.Tag)))
+		data = append(data, field.Tag...)
+	}
+	return data
+}
+
+// isValidFieldName checks if a string is a valid (struct) field name or not.
+//
+// According to the language spec, a field name should be an identifier.
+//
+// identifier = letter { letter | unicode_digit } .
+// letter = unicode_letter | "_" .
+func isValidFieldName(fieldName string) bool {
+	for i, c := range fieldName {
+		if i == 0 && !isLetter(c) {
+			return false
+		}
+
+		if !(isLetter(c) || unicode.IsDigit(c)) {
+			return false
+		}
+	}
+
+	return len(fieldName) > 0
+}
+
+func isLetter(ch rune) bool {
+	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || ch == '_' || ch >= utf8.RuneSelf && unicode.IsLetter(ch)
+}
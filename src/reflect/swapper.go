@@ -36,5 +36,6 @@ func Swapper(slice interface{}) func(i, j int) {
 		memcpy(tmp, val1, size)
 		memcpy(val1, val2, size)
 		memcpy(val2, tmp, size)
+		gcWriteBarrier(header.data)
 	}
 }
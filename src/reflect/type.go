@@ -44,12 +44,21 @@
 //     pkgpath      *byte       // package path; null terminated
 //     numField     uint16
 //     fields       [...]structField // the remaining fields are all of type structField
-// - interface types (this is missing the interface methods):
+// - interface types (see interfaceType):
 //     meta         uint8
+//     nmethods     uint16      // number of methods
 //     ptrTo        *typeStruct
-// - signature types (this is missing input and output parameters):
+//     implements   *byte       // trampoline used by Implements, or nil
+//     methods      [...]interfaceMethod // name, signature and type of each method
+// - signature types (see funcType):
 //     meta         uint8
+//     numIn        uint16      // number of input parameters
 //     ptrTo        *typeStruct
+//     call         *byte       // trampoline used by Value.Call, or nil
+//     makeFunc     *byte       // stub used by MakeFunc, or nil
+//     numOut       uint16      // number of output parameters
+//     variadic     bool
+//     params       [...]*typeStruct // input parameters followed by outputs
 // - named types
 //     meta         uint8
 //     nmethods     uint16      // number of methods
@@ -60,6 +69,14 @@
 //
 // The type struct is essentially a union of all the above types. Which it is,
 // can be determined by looking at the meta byte.
+//
+// Types with exported methods may also be preceded by a pointer to a method
+// set, if the program looks up methods using reflection. The method set starts
+// with the number of methods n, and word 1+2n is a pointer to the method table
+// (see methodTable).
+//
+// Types created at runtime using SliceOf and similar functions use the same
+// layout, see newtype.go.
 
 package reflect
 
@@ -190,6 +207,18 @@ const (
 	BothDir = RecvDir | SendDir             // chan
 )
 
+func (d ChanDir) String() string {
+	switch d {
+	case SendDir:
+		return "chan<-"
+	case RecvDir:
+		return "<-chan"
+	case BothDir:
+		return "chan"
+	}
+	return "ChanDir" + itoa.Itoa(int(d))
+}
+
 // Method represents a single method.
 type Method struct {
 	// Name is the method name.
@@ -439,10 +468,80 @@ type ptrType struct {
 	elem      *rawType
 }
 
+// Type for interface types. The implements trampoline checks whether a type
+// implements the interface, it is nil for the empty interface and in programs
+// that don't call Implements or AssignableTo.
+// The methods array isn't necessarily 1 interfaceMethod long, instead it is as
+// long as numMethod.
 type interfaceType struct {
 	rawType
-	ptrTo *rawType
-	// TODO: methods
+	numMethod  uint16
+	ptrTo      *rawType
+	implements unsafe.Pointer
+	methods    [1]interfaceMethod // the remaining fields are all of type interfaceMethod
+}
+
+// interfaceMethod describes a single method of an interface type. The
+// signature is a unique pointer for each method name and signature, the same
+// as used by the compiler to match methods to interfaces. The type is only
+// present in programs that look up methods using reflection, like the method
+// table of other types.
+type interfaceMethod struct {
+	name      *byte // null terminated
+	signature unsafe.Pointer
+	typ       *rawType // method type, or nil
+}
+
+// method returns the i'th method of the interface type.
+func (t *interfaceType) method(i int) *interfaceMethod {
+	return (*interfaceMethod)(unsafe.Add(unsafe.Pointer(&t.methods[0]), uintptr(i)*unsafe.Sizeof(t.methods[0])))
+}
+
+// hasMethod returns whether the interface type has a method with the given
+// signature.
+func (t *interfaceType) hasMethod(signature unsafe.Pointer) bool {
+	for i := 0; i < int(t.numMethod); i++ {
+		if t.method(i).signature == signature {
+			return true
+		}
+	}
+	return false
+}
+
+type funcType struct {
+	rawType
+	numIn    uint16
+	ptrTo    *rawType
+	call     unsafe.Pointer
+	makeFunc unsafe.Pointer
+	numOut   uint16
+	variadic bool
+	params   [1]*rawType // the remaining fields are all of type *rawType
+}
+
+// in returns the i'th input parameter, or the (i-numIn)'th output parameter if
+// i >= numIn.
+func (t *funcType) in(i int) *rawType {
+	return *(**rawType)(unsafe.Add(unsafe.Pointer(&t.params[0]), uintptr(i)*unsafe.Sizeof(t.params[0])))
+}
+
+func (t *funcType) out(i int) *rawType {
+	return t.in(int(t.numIn) + i)
+}
+
+// Method table of a type, referenced from the method set. It only contains
+// exported methods, sorted by name.
+type methodTable struct {
+	numMethods uintptr
+	methods    [1]rawMethod // the remaining fields are all of type rawMethod
+}
+
+type rawMethod struct {
+	name  *byte          // null terminated
+	mtyp  *rawType       // method type, without receiver
+	ftyp  *rawType       // function type, with the receiver as first parameter
+	fn    unsafe.Pointer // function that implements the method
+	bound unsafe.Pointer // fn with the receiver in the function context
 }
 
 type arrayType struct {
@@ -539,9 +638,12 @@ func pointerTo(t *rawType) *rawType {
 			return (*rawType)(unsafe.Add(unsafe.Pointer(t), 1))
 		}
 
-		// TODO(dgryski): This is blocking https://github.com/tinygo-org/tinygo/issues/3131
-		// We need to be able to create types that match existing types to prevent typecode equality.
-		panic("reflect: cannot make *****T type")
+		// This type can't be represented using a tagged pointer, and the
+		// compiler doesn't create such types either. Create a new pointer
+		// type instead.
+		return lookupType(pointerKey(t), func() *rawType {
+			return newPointerType(t)
+		})
 	case Struct:
 		return (*structType)(unsafe.Pointer(t)).ptrTo
 	default:
@@ -607,6 +709,35 @@ func (t *rawType) String() string {
 	case Interface:
 		// TODO(dgryski): Needs actual method set info
 		return "interface {}"
+	case Func:
+		ft := t.funcType()
+		s := "func("
+		for i := 0; i < int(ft.numIn); i++ {
+			if i > 0 {
+				s += ", "
+			}
+			if ft.variadic && i == int(ft.numIn)-1 {
+				s += "..." + ft.in(i).elem().String()
+			} else {
+				s += ft.in(i).String()
+			}
+		}
+		s += ")"
+		switch ft.numOut {
+		case 0:
+		case 1:
+			s += " " + ft.out(0).String()
+		default:
+			s += " ("
+			for i := 0; i < int(ft.numOut); i++ {
+				if i > 0 {
+					s += ", "
+				}
+				s += ft.out(i).String()
+			}
+			s += ")"
+		}
+		return s
 	default:
 		return t.Kind().String()
 	}
@@ -636,6 +767,11 @@ var (
 	errTypeChanDir      = &TypeError{"ChanDir"}
 	errTypeFieldByName  = &TypeError{"FieldByName"}
 	errTypeFieldByIndex = &TypeError{"FieldByIndex"}
+	errTypeIn           = &TypeError{"In"}
+	errTypeOut          = &TypeError{"Out"}
+	errTypeNumIn        = &TypeError{"NumIn"}
+	errTypeNumOut       = &TypeError{"NumOut"}
+	errTypeIsVariadic   = &TypeError{"IsVariadic"}
 )
 
 // Elem returns the element type for channel, slice and array types, the
@@ -1009,12 +1145,40 @@ func (t *rawType) AssignableTo(u Type) bool {
 		return true
 	}
 
+	if t.Kind() == Chan && u.Kind() == Chan && t.ChanDir() == BothDir && t.elem() == u.(*rawType).elem() && (!t.isNamed() || !u.(*rawType).isNamed()) {
+		// A bidirectional channel can be assigned to a directional channel
+		// with the same element type.
+		return true
+	}
+
 	if u.Kind() == Interface {
-		panic("reflect: unimplemented: AssignableTo with interface")
+		return t.implements((*interfaceType)(unsafe.Pointer(u.(*rawType).underlying())))
 	}
 	return false
 }
 
+// implements returns whether t implements the interface type u, which has at
+// least one method.
+func (t *rawType) implements(u *interfaceType) bool {
+	if t.Kind() == Interface {
+		// An interface implements u if it has all the methods of u.
+		v := (*interfaceType)(unsafe.Pointer(t.underlying()))
+		for i := 0; i < int(u.numMethod); i++ {
+			if !v.hasMethod(u.method(i).signature) {
+				return false
+			}
+		}
+		return true
+	}
+	if u.implements == nil {
+		// The compiler removes the trampoline if Implements and AssignableTo
+		// are never called, which means this can't be reached.
+		panic("reflect: missing implements trampoline for " + (*rawType)(unsafe.Pointer(u)).String())
+	}
+	implements := *(*func(*rawType) bool)(unsafe.Pointer(&funcHeader{Code: u.implements}))
+	return implements(t)
+}
+
 func (t *rawType) Implements(u Type) bool {
 	if u.Kind() != Interface {
 		panic("reflect: non-interface type passed to Type.Implements")
@@ -1024,11 +1188,17 @@ func (t *rawType) Implements(u Type) bool {
 
 // Comparable returns whether values of this type can be compared to each other.
 func (t *rawType) Comparable() bool {
+	if t.ptrtag() != 0 {
+		return true
+	}
 	return (t.meta & flagComparable) == flagComparable
 }
 
 // isBinary returns if the hashmapAlgorithmBinary functions can be used on this type
 func (t *rawType) isBinary() bool {
+	if t.ptrtag() != 0 {
+		return true
+	}
 	return (t.meta & flagIsBinary) == flagIsBinary
 }
 
@@ -1047,16 +1217,30 @@ func (t *rawType) ConvertibleTo(u Type) bool {
 	panic("unimplemented: (reflect.Type).ConvertibleTo()")
 }
 
+// funcType returns the function type struct of t, which must be of kind Func.
+func (t *rawType) funcType() *funcType {
+	return (*funcType)(unsafe.Pointer(t.underlying()))
+}
+
 func (t *rawType) IsVariadic() bool {
-	panic("unimplemented: (reflect.Type).IsVariadic()")
+	if t.Kind() != Func {
+		panic(errTypeIsVariadic)
+	}
+	return t.funcType().variadic
 }
 
 func (t *rawType) NumIn() int {
-	panic("unimplemented: (reflect.Type).NumIn()")
+	if t.Kind() != Func {
+		panic(errTypeNumIn)
+	}
+	return int(t.funcType().numIn)
 }
 
 func (t *rawType) NumOut() int {
-	panic("unimplemented: (reflect.Type).NumOut()")
+	if t.Kind() != Func {
+		panic(errTypeNumOut)
+	}
+	return int(t.funcType().numOut)
 }
 
 func (t *rawType) NumMethod() int {
@@ -1071,8 +1255,7 @@ func (t *rawType) NumMethod() int {
 	case Struct:
 		return int((*structType)(unsafe.Pointer(t)).numMethod)
 	case Interface:
-		//FIXME: Use len(methods)
-		return (*interfaceType)(unsafe.Pointer(t)).ptrTo.NumMethod()
+		return int((*interfaceType)(unsafe.Pointer(t)).numMethod)
 	}
 
 	// Other types have no methods attached.  Note we don't panic here.
@@ -1123,12 +1306,26 @@ func (t *rawType) Key() Type {
 	return t.key()
 }
 
-func (t rawType) In(i int) Type {
-	panic("unimplemented: (reflect.Type).In()")
+func (t *rawType) In(i int) Type {
+	if t.Kind() != Func {
+		panic(errTypeIn)
+	}
+	ft := t.funcType()
+	if uint(i) >= uint(ft.numIn) {
+		panic("reflect: Function index out of range")
+	}
+	return ft.in(i)
 }
 
-func (t rawType) Out(i int) Type {
-	panic("unimplemented: (reflect.Type).Out()")
+func (t *rawType) Out(i int) Type {
+	if t.Kind() != Func {
+		panic(errTypeOut)
+	}
+	ft := t.funcType()
+	if uint(i) >= uint(ft.numOut) {
+		panic("reflect: Function index out of range")
+	}
+	return ft.out(i)
 }
 
 // OverflowComplex reports whether the complex128 x cannot be represented by type t.
@@ -1183,12 +1380,90 @@ func (t rawType) OverflowUint(x uint64) bool {
 	panic("reflect: OverflowUint of non-uint type")
 }
 
-func (t rawType) Method(i int) Method {
-	panic("unimplemented: (reflect.Type).Method()")
+func (t *rawType) Method(i int) Method {
+	if t.Kind() == Interface {
+		it := (*interfaceType)(unsafe.Pointer(t.underlying()))
+		if uint(i) >= uint(it.numMethod) {
+			panic("reflect: Method index out of range")
+		}
+		m := it.method(i)
+		if m.typ == nil {
+			// The compiler removes the method types if Method and
+			// MethodByName are never called, which means this can't be
+			// reached.
+			panic("reflect: missing method type for " + t.String())
+		}
+		// Methods of interface types have no Func, like in Go.
+		return Method{
+			Name:  readStringZ(unsafe.Pointer(m.name)),
+			Type:  m.typ,
+			Index: i,
+		}
+	}
+	methods := t.methods()
+	if uint(i) >= uint(len(methods)) {
+		panic("reflect: Method index out of range")
+	}
+	m := &methods[i]
+	return Method{
+		Name: readStringZ(unsafe.Pointer(m.name)),
+		Type: m.ftyp,
+		Func: Value{
+			typecode: m.ftyp,
+			value:    unsafe.Pointer(&funcHeader{Code: m.fn}),
+			flags:    valueFlagExported,
+		},
+		Index: i,
+	}
+}
+
+func (t *rawType) MethodByName(name string) (Method, bool) {
+	if t.Kind() == Interface {
+		it := (*interfaceType)(unsafe.Pointer(t.underlying()))
+		for i := 0; i < int(it.numMethod); i++ {
+			if readStringZ(unsafe.Pointer(it.method(i).name)) == name {
+				return t.Method(i), true
+			}
+		}
+		return Method{}, false
+	}
+	if i := methodIndex(t.methods(), name); i >= 0 {
+		return t.Method(i), true
+	}
+	return Method{}, false
 }
 
-func (t rawType) MethodByName(name string) (Method, bool) {
-	panic("unimplemented: (reflect.Type).MethodByName()")
+// methods returns the exported methods of t, sorted by name. The method table
+// is only present in programs that look up methods using reflection (the
+// compiler removes it otherwise), see the comment at the top of this file.
+func (t *rawType) methods() []rawMethod {
+	if t.ptrtag() != 0 || t.Kind() == Interface || t.NumMethod() == 0 {
+		return nil
+	}
+	ptrSize := unsafe.Sizeof(uintptr(0))
+	methodSet := *(*unsafe.Pointer)(unsafe.Add(unsafe.Pointer(t), -int(ptrSize)))
+	numMethods := *(*uintptr)(methodSet)
+	table := *(**methodTable)(unsafe.Add(methodSet, (1+2*numMethods)*ptrSize))
+	return unsafe.Slice(&table.methods[0], table.numMethods)
+}
+
+// methodIndex returns the index of the method with the given name, or -1 if
+// there is no such method.
+func methodIndex(methods []rawMethod, name string) int {
+	// Binary search, the methods are sorted by name.
+	low, high := 0, len(methods)
+	for low < high {
+		mid := int(uint(low+high) >> 1)
+		if readStringZ(unsafe.Pointer(methods[mid].name)) < name {
+			low = mid + 1
+		} else {
+			high = mid
+		}
+	}
+	if low < len(methods) && readStringZ(unsafe.Pointer(methods[low].name)) == name {
+		return low
+	}
+	return -1
 }
 
 func (t *rawType) PkgPath() string {
@@ -1382,24 +1657,64 @@ func align(offset uintptr, alignment uintptr) uintptr {
 	return (offset + alignment - 1) &^ (alignment - 1)
 }
 
+// SliceOf returns the slice type with element type t.
+// For example, if t represents int, SliceOf(t) represents []int.
 func SliceOf(t Type) Type {
-	panic("unimplemented: reflect.SliceOf()")
+	return sliceOf(t.(*rawType))
 }
 
-func ArrayOf(n int, t Type) Type {
-	panic("unimplemented: reflect.ArrayOf()")
+// ArrayOf returns the array type with the given length and element type.
+// For example, if t represents int, ArrayOf(5, t) represents [5]int.
+func ArrayOf(length int, elem Type) Type {
+	return arrayOf(length, elem.(*rawType))
 }
 
-func StructOf([]StructField) Type {
-	panic("unimplemented: reflect.StructOf()")
+// ChanOf returns the channel type with the given direction and element type.
+// For example, if t represents int, ChanOf(RecvDir, t) represents <-chan int.
+func ChanOf(dir ChanDir, t Type) Type {
+	return chanOf(dir, t.(*rawType))
 }
 
-func MapOf(key, value Type) Type {
-	panic("unimplemented: reflect.MapOf()")
+// StructOf returns the struct type containing fields.
+// The Offset and Index fields are ignored and computed as they would be
+// by the compiler.
+//
+// StructOf does not support embedded fields with methods, and all unexported
+// fields must have the same PkgPath.
+func StructOf(fields []StructField) Type {
+	return structOf(fields)
+}
+
+// MapOf returns the map type with the given key and element types.
+// For example, if k represents int and e represents string,
+// MapOf(k, e) represents map[int]string.
+//
+// If the key type is not a valid map key type (that is, if it does
+// not implement Go's == operator), MapOf panics.
+func MapOf(key, elem Type) Type {
+	return mapOf(key.(*rawType), elem.(*rawType))
 }
 
+// FuncOf returns the function type with the given argument and result types.
+// For example if k represents int and e represents string,
+// FuncOf([]Type{k}, []Type{e}, false) represents func(int) string.
+//
+// The variadic argument controls whether the function is variadic. FuncOf
+// panics if the in[len(in)-1] does not represent a slice and variadic is
+// true.
+//
+// Functions of a type created by FuncOf can't be called using Value.Call,
+// unless the same function type also exists in the program.
 func FuncOf(in, out []Type, variadic bool) Type {
-	panic("unimplemented: reflect.FuncOf()")
+	rawIn := make([]*rawType, len(in))
+	for i, t := range in {
+		rawIn[i] = t.(*rawType)
+	}
+	rawOut := make([]*rawType, len(out))
+	for i, t := range out {
+		rawOut[i] = t.(*rawType)
+	}
+	return funcOf(rawIn, rawOut, variadic)
 }
 
 const maxVarintLen32 = 5
@@ -16,6 +16,7 @@ const (
 	valueFlagExported
 	valueFlagEmbedRO
 	valueFlagStickyRO
+	valueFlagMethod // value is a method value, see methodValue
 
 	valueFlagRO = valueFlagEmbedRO | valueFlagStickyRO
 )
@@ -83,6 +84,9 @@ func (v Value) Interface() interface{} {
 	if !v.isExported() {
 		panic("(reflect.Value).Interface: unexported")
 	}
+	if v.flags&valueFlagMethod != 0 {
+		v = v.funcValue()
+	}
 	return valueInterfaceUnsafe(v)
 }
 
@@ -230,7 +234,7 @@ func (v Value) IsValid() bool {
 }
 
 func (v Value) CanInterface() bool {
-	return v.isExported() && !v.isRO()
+	return v.isExported() && !v.isRO() && v.flags&valueFlagMethod == 0
 }
 
 func (v Value) CanAddr() bool {
@@ -1164,8 +1168,16 @@ func (v Value) Set(x Value) {
 	if !x.typecode.AssignableTo(v.typecode) {
 		panic("reflect.Value.Set: value of type " + x.typecode.String() + " cannot be assigned to type " + v.typecode.String())
 	}
+	x.storeTo(v.value, v.typecode)
+}
 
-	if v.typecode.Kind() == Interface && x.typecode.Kind() != Interface {
+// storeTo stores the value x to the memory at ptr, which has type t. The type
+// of x must be assignable to t.
+func (x Value) storeTo(ptr unsafe.Pointer, t *rawType) {
+	if x.flags&valueFlagMethod != 0 {
+		x = x.funcValue()
+	}
+	if t.Kind() == Interface && x.typecode.Kind() != Interface {
 		// move the value of x back into the interface, if possible
 		if x.isIndirect() && x.typecode.Size() <= unsafe.Sizeof(uintptr(0)) {
 			x.value = unsafe.Pointer(loadValue(x.value, x.typecode.Size()))
@@ -1173,17 +1185,18 @@ func (v Value) Set(x Value) {
 
 		intf := composeInterface(unsafe.Pointer(x.typecode), x.value)
 		x = Value{
-			typecode: v.typecode,
+			typecode: t,
 			value:    unsafe.Pointer(&intf),
 		}
 	}
 
-	size := v.typecode.Size()
+	size := t.Size()
 	if size <= unsafe.Sizeof(uintptr(0)) && !x.isIndirect() {
-		storeValue(v.value, size, uintptr(x.value))
+		storeValue(ptr, size, uintptr(x.value))
 	} else {
-		memcpy(v.value, x.value, size)
+		memcpy(ptr, x.value, size)
 	}
+	gcWriteBarrier(ptr)
 }
 
 func (v Value) SetZero() {
@@ -1737,6 +1750,9 @@ func (e *ValueError) Error() string {
 //go:linkname memcpy runtime.memcpy
 func memcpy(dst, src unsafe.Pointer, size uintptr)
 
+//go:linkname gcWriteBarrier runtime.gcWriteBarrier
+func gcWriteBarrier(ptr unsafe.Pointer)
+
 //go:linkname memzero runtime.memzero
 func memzero(ptr unsafe.Pointer, size uintptr)
 
@@ -2099,16 +2115,231 @@ type SelectCase struct {
 	Send Value     // value to send (for send)
 }
 
+// These types must match the ones in src/runtime/chan.go.
+type channelOp struct {
+	next  unsafe.Pointer
+	task  unsafe.Pointer
+	index uint32
+	value unsafe.Pointer
+}
+
+type chanSelectState struct {
+	ch    unsafe.Pointer
+	value unsafe.Pointer // nil for a receive operation
+}
+
+//go:linkname chanMake runtime.chanMake
+func chanMake(elementSize uintptr, bufSize uintptr) unsafe.Pointer
+
+//go:linkname chanSend runtime.chanSend
+func chanSend(ch unsafe.Pointer, value unsafe.Pointer, op *channelOp)
+
+//go:linkname chanRecv runtime.chanRecv
+func chanRecv(ch unsafe.Pointer, value unsafe.Pointer, op *channelOp) bool
+
+//go:linkname chanClose runtime.chanClose
+func chanClose(ch unsafe.Pointer)
+
+//go:linkname chanSelect runtime.chanSelect
+func chanSelect(recvbuf unsafe.Pointer, states []chanSelectState, ops []channelOp) (uint32, bool)
+
+// Select executes a select operation described by the list of cases.
+// Like the Go select statement, it blocks until at least one of the cases
+// can proceed, makes a choice (like the select statement in TinyGo, this is
+// the first case that can proceed), and then executes that case. It returns
+// the index of the chosen case and, if that case was a receive operation, the
+// value received and a boolean indicating whether the value corresponds to a
+// send on the channel (as opposed to a zero value received because the
+// channel is closed).
+// Select supports a maximum of 65536 cases.
 func Select(cases []SelectCase) (chosen int, recv Value, recvOK bool) {
-	panic("unimplemented: reflect.Select")
+	if len(cases) > 65536 {
+		panic("reflect.Select: too many cases (max 65536)")
+	}
+
+	// Convert the cases to the form used by the runtime. A default case (and
+	// a case with a zero Value channel) is treated as a nil channel, which
+	// never proceeds.
+	states := make([]chanSelectState, len(cases))
+	defaultCase := -1
+	var recvSize uintptr
+	for i, c := range cases {
+		switch c.Dir {
+		case SelectDefault:
+			if defaultCase >= 0 {
+				panic("reflect.Select: multiple default cases")
+			}
+			if c.Chan.IsValid() {
+				panic("reflect.Select: default case has Chan value")
+			}
+			if c.Send.IsValid() {
+				panic("reflect.Select: default case has Send value")
+			}
+			defaultCase = i
+		case SelectSend:
+			if !c.Chan.IsValid() {
+				break
+			}
+			c.Chan.checkChan("Select", SendDir)
+			if !c.Send.IsValid() {
+				panic("reflect.Select: SendDir case missing Send value")
+			}
+			states[i] = chanSelectState{
+				ch:    c.Chan.pointer(),
+				value: c.Chan.sendValue(c.Send, "Select"),
+			}
+		case SelectRecv:
+			if c.Send.IsValid() {
+				panic("reflect.Select: RecvDir case has Send value")
+			}
+			if !c.Chan.IsValid() {
+				break
+			}
+			c.Chan.checkChan("Select", RecvDir)
+			if size := c.Chan.typecode.elem().Size(); size > recvSize {
+				recvSize = size
+			}
+			states[i] = chanSelectState{
+				ch: c.Chan.pointer(),
+			}
+		default:
+			panic("reflect.Select: invalid Dir")
+		}
+	}
+
+	// Only a select without a default case blocks, and it needs space to
+	// queue every operation.
+	var ops []channelOp
+	if defaultCase < 0 {
+		ops = make([]channelOp, len(cases))
+	}
+	recvbuf := alloc(recvSize, nil)
+	index, ok := chanSelect(recvbuf, states, ops)
+	if index >= uint32(len(cases)) {
+		// No case could proceed, so the default case was chosen.
+		return defaultCase, Value{}, false
+	}
+	chosen = int(index)
+	if cases[chosen].Dir == SelectRecv {
+		recv = New(cases[chosen].Chan.typecode.elem()).Elem()
+		memcpy(recv.value, recvbuf, recv.typecode.Size())
+		recvOK = ok
+	}
+	return
 }
 
-func (v Value) Send(x Value) {
-	panic("unimplemented: reflect.Value.Send()")
+// checkChan panics if v is not a channel that can be used in the given
+// direction.
+func (v Value) checkChan(op string, dir ChanDir) {
+	if v.Kind() != Chan {
+		panic(&ValueError{Method: op, Kind: v.Kind()})
+	}
+	if v.isRO() {
+		panic("reflect: " + op + " using value obtained using unexported field")
+	}
+	if v.typecode.ChanDir()&dir == 0 {
+		if dir == SendDir {
+			panic("reflect: send on recv-only channel")
+		}
+		panic("reflect: recv on send-only channel")
+	}
+}
+
+// sendValue returns a pointer to a copy of x, converted to the element type of
+// channel v, for use in a send operation.
+func (v Value) sendValue(x Value, op string) unsafe.Pointer {
+	elem := v.typecode.elem()
+	if !x.typecode.AssignableTo(elem) {
+		panic("reflect." + op + ": value of type " + x.typecode.String() + " cannot be sent to channel of type " + v.typecode.String())
+	}
+	// The runtime treats a nil value as a receive operation, but allocating
+	// zero bytes still returns a valid pointer.
+	value := alloc(elem.Size(), nil)
+	x.storeTo(value, elem)
+	return value
 }
 
+// Send sends x on the channel v. It panics if v's kind is not Chan or if x's
+// type is not the same type as v's element type.
+func (v Value) Send(x Value) {
+	v.checkChan("Send", SendDir)
+	var op channelOp
+	chanSend(v.pointer(), v.sendValue(x, "Send"), &op)
+}
+
+// TrySend attempts to send x on the channel v but will not block. It panics if
+// v's Kind is not Chan. It reports whether the value was sent.
+func (v Value) TrySend(x Value) bool {
+	v.checkChan("TrySend", SendDir)
+	states := []chanSelectState{{
+		ch:    v.pointer(),
+		value: v.sendValue(x, "TrySend"),
+	}}
+	index, _ := chanSelect(nil, states, nil)
+	return index == 0
+}
+
+// Recv receives and returns a value from the channel v. It panics if v's Kind
+// is not Chan. The receive blocks until a value is ready. The boolean value ok
+// is true if the value x corresponds to a send on the channel, false if it is
+// a zero value received because the channel is closed.
+func (v Value) Recv() (x Value, ok bool) {
+	v.checkChan("Recv", RecvDir)
+	x = New(v.typecode.elem()).Elem()
+	var op channelOp
+	ok = chanRecv(v.pointer(), x.value, &op)
+	return x, ok
+}
+
+// TryRecv attempts to receive a value from the channel v but will not block.
+// It panics if v's Kind is not Chan. If the receive delivers a value, x is the
+// transferred value and ok is true. If the receive cannot finish without
+// blocking, x is the zero Value and ok is false. If the channel is closed, x
+// is the zero value for the channel's element type and ok is false.
+func (v Value) TryRecv() (x Value, ok bool) {
+	v.checkChan("TryRecv", RecvDir)
+	x = New(v.typecode.elem()).Elem()
+	states := []chanSelectState{{
+		ch: v.pointer(),
+	}}
+	index, ok := chanSelect(x.value, states, nil)
+	if index != 0 {
+		return Value{}, false
+	}
+	return x, ok
+}
+
+// Close closes the channel v. It panics if v's Kind is not Chan or v is a
+// receive-only channel.
 func (v Value) Close() {
-	panic("unimplemented: reflect.Value.Close()")
+	if v.Kind() != Chan {
+		panic(&ValueError{Method: "Close", Kind: v.Kind()})
+	}
+	if v.isRO() {
+		panic("reflect: Close using value obtained using unexported field")
+	}
+	if v.typecode.ChanDir()&SendDir == 0 {
+		panic("reflect: close of receive-only channel")
+	}
+	chanClose(v.pointer())
+}
+
+// MakeChan creates a new channel with the specified type and buffer size.
+func MakeChan(typ Type, buffer int) Value {
+	if typ.Kind() != Chan {
+		panic("reflect.MakeChan of non-chan type")
+	}
+	if buffer < 0 {
+		panic("reflect.MakeChan: negative buffer size")
+	}
+	if typ.ChanDir() != BothDir {
+		panic("reflect.MakeChan: unidirectional channel type")
+	}
+	return Value{
+		typecode: typ.(*rawType),
+		value:    chanMake(typ.Elem().Size(), uintptr(buffer)),
+		flags:    valueFlagExported,
+	}
 }
 
 // MakeMap creates a new map with the specified type.
@@ -2116,26 +2347,248 @@ func MakeMap(typ Type) Value {
 	return MakeMapWithSize(typ, 8)
 }
 
+// methodValue is what a Value returned by Value.Method points to. It starts
+// with a funcHeader so that it can be used like a regular func value in most
+// places, but the function needs to be called with the receiver as the first
+// parameter.
+type methodValue struct {
+	funcHeader
+	recv  Value
+	ftyp  *rawType       // function type including the receiver
+	bound unsafe.Pointer // see rawMethod
+}
+
+// funcValue converts the method value v to a regular func value, which calls
+// the method with a copy of the receiver stored in the function context.
+func (v Value) funcValue() Value {
+	method := (*methodValue)(v.value)
+	recv := method.recv
+
+	// The receiver is packed like the value of an interface, see
+	// getBoundMethodStub in compiler/interface.go.
+	context := recv.value
+	size := recv.typecode.Size()
+	if size > unsafe.Sizeof(uintptr(0)) {
+		context = alloc(size, nil)
+		memcpy(context, recv.value, size)
+	} else if recv.isIndirect() {
+		context = unsafe.Pointer(loadValue(recv.value, size))
+	}
+	return Value{
+		typecode: v.typecode,
+		value: unsafe.Pointer(&funcHeader{
+			Context: context,
+			Code:    method.bound,
+		}),
+		flags: v.flags &^ valueFlagMethod,
+	}
+}
+
+// Call calls the function v with the input arguments in. For example, if
+// len(in) == 3, v.Call(in) represents the Go call v(in[0], in[1], in[2]).
+// Call panics if v's Kind is not Func. It returns the output results as Values.
+// As in Go, each input argument must be assignable to the type of the
+// function's corresponding input parameter. If v is a variadic function, Call
+// creates the variadic slice parameter itself, copying in the corresponding
+// values.
 func (v Value) Call(in []Value) []Value {
-	panic("unimplemented: (reflect.Value).Call()")
+	if v.Kind() != Func {
+		panic(&ValueError{Method: "Call", Kind: v.Kind()})
+	}
+	return v.call("Call", in, false)
 }
 
+// CallSlice calls the variadic function v with the input arguments in,
+// assigning the slice in[len(in)-1] to v's final variadic argument. For
+// example, if len(in) == 3, v.CallSlice(in) represents the Go call v(in[0],
+// in[1], in[2]...). CallSlice panics if v's Kind is not Func or if v is not
+// variadic.
 func (v Value) CallSlice(in []Value) []Value {
-	panic("unimplemented: (reflect.Value).CallSlice()")
+	if v.Kind() != Func {
+		panic(&ValueError{Method: "CallSlice", Kind: v.Kind()})
+	}
+	return v.call("CallSlice", in, true)
 }
 
-func (v Value) Method(i int) Value {
-	panic("unimplemented: (reflect.Value).Method()")
+func (v Value) call(op string, in []Value, isSlice bool) []Value {
+	if v.isRO() {
+		panic("reflect: " + op + " using value obtained using unexported field")
+	}
+	if v.IsNil() {
+		panic("reflect: call of nil function")
+	}
+	fn := (*funcHeader)(v.value)
+	typ := v.typecode
+	if v.flags&valueFlagMethod != 0 {
+		// Method value: pass the receiver as the first parameter.
+		method := (*methodValue)(v.value)
+		in = append([]Value{method.recv}, in...)
+		typ = method.ftyp
+	}
+	ft := typ.funcType()
+	numIn := int(ft.numIn)
+
+	if isSlice {
+		if !ft.variadic {
+			panic("reflect: CallSlice of non-variadic function")
+		}
+		if len(in) != numIn {
+			panic("reflect: CallSlice with wrong number of input arguments")
+		}
+	} else if ft.variadic {
+		// Pack the variadic arguments in a slice.
+		if len(in) < numIn-1 {
+			panic("reflect: Call with too few input arguments")
+		}
+		sliceType := ft.in(numIn - 1)
+		elemType := sliceType.elem()
+		extra := in[numIn-1:]
+		slice := MakeSlice(sliceType, len(extra), len(extra))
+		for i, x := range extra {
+			if !x.typecode.AssignableTo(elemType) {
+				panic("reflect: cannot use " + x.typecode.String() + " as type " + elemType.String() + " in " + op)
+			}
+			slice.Index(i).Set(x)
+		}
+		in = append(in[:numIn-1:numIn-1], slice)
+	} else if len(in) != numIn {
+		if len(in) < numIn {
+			panic("reflect: Call with too few input arguments")
+		}
+		panic("reflect: Call with too many input arguments")
+	}
+
+	// Functions created by MakeFunc for types that don't exist in the program
+	// don't need a trampoline: callMakeFunc is called directly.
+	noStub := fn.Code == makeFuncNoStubCode()
+	if ft.call == nil && !noStub {
+		// The trampoline is removed by the compiler if Call and CallSlice
+		// are not used anywhere in the program. Function types created
+		// using FuncOf don't have one either.
+		panic("reflect: " + op + " of " + typ.String() + " is not supported")
+	}
+
+	// Store all input parameters in a buffer, laid out like a struct.
+	var size uintptr
+	for i := 0; i < numIn; i++ {
+		t := ft.in(i)
+		size = align(size, uintptr(t.Align())) + t.Size()
+	}
+	params := alloc(size, nil)
+	var offset uintptr
+	for i, x := range in {
+		t := ft.in(i)
+		if x.typecode == nil {
+			panic("reflect: " + op + " using zero Value argument")
+		}
+		if !x.typecode.AssignableTo(t) {
+			panic("reflect: " + op + " using " + x.typecode.String() + " as type " + t.String())
+		}
+		offset = align(offset, uintptr(t.Align()))
+		x.storeTo(unsafe.Add(params, offset), t)
+		offset += t.Size()
+	}
+
+	// Allocate a buffer for the results, also laid out like a struct.
+	numOut := int(ft.numOut)
+	size = 0
+	for i := 0; i < numOut; i++ {
+		t := ft.out(i)
+		size = align(size, uintptr(t.Align())) + t.Size()
+	}
+	results := alloc(size, nil)
+
+	if noStub {
+		callMakeFunc(fn.Context, params, results)
+	} else {
+		// Call the function through the trampoline emitted by the compiler.
+		call := *(*func(fn, context, params, results unsafe.Pointer))(unsafe.Pointer(&funcHeader{Code: ft.call}))
+		call(fn.Code, fn.Context, params, results)
+	}
+
+	// Read the results back from the buffer.
+	out := make([]Value, numOut)
+	offset = 0
+	for i := range out {
+		t := ft.out(i)
+		offset = align(offset, uintptr(t.Align()))
+		value := unsafe.Add(results, offset)
+		if t.Size() <= unsafe.Sizeof(uintptr(0)) {
+			value = unsafe.Pointer(loadValue(value, t.Size()))
+		}
+		out[i] = Value{
+			typecode: t,
+			value:    value,
+			flags:    valueFlagExported,
+		}
+		offset += t.Size()
+	}
+	return out
 }
 
-func (v Value) MethodByName(name string) Value {
-	panic("unimplemented: (reflect.Value).MethodByName()")
+// Method returns a function value corresponding to v's i'th method. The
+// arguments to a Call on the returned function should not include a receiver;
+// the returned function will always use v as the receiver. Method panics if i
+// is out of range or if v is a nil interface value.
+func (v Value) Method(i int) Value {
+	if v.typecode == nil {
+		panic(&ValueError{Method: "reflect.Value.Method", Kind: Invalid})
+	}
+	if v.flags&valueFlagMethod != 0 || uint(i) >= uint(v.typecode.NumMethod()) {
+		panic("reflect: Method index out of range")
+	}
+	if v.Kind() == Interface {
+		if v.IsNil() {
+			panic("reflect: Method on nil interface value")
+		}
+		// Look up the method of the dynamic type by name.
+		name := readStringZ(unsafe.Pointer((*interfaceType)(unsafe.Pointer(v.typecode.underlying())).method(i).name))
+		m := v.Elem().MethodByName(name)
+		if !m.IsValid() {
+			panic("reflect: Method on unexported method " + name)
+		}
+		return m
+	}
+	m := &v.typecode.methods()[i]
+	return Value{
+		typecode: m.mtyp,
+		value: unsafe.Pointer(&methodValue{
+			funcHeader: funcHeader{Code: m.fn},
+			recv:       v,
+			ftyp:       m.ftyp,
+			bound:      m.bound,
+		}),
+		flags: v.flags.ro() | valueFlagExported | valueFlagMethod,
+	}
 }
 
-func (v Value) Recv() (x Value, ok bool) {
-	panic("unimplemented: (reflect.Value).Recv()")
+// MethodByName returns a function value corresponding to the method of v with
+// the given name. It returns the zero Value if no method was found.
+func (v Value) MethodByName(name string) Value {
+	if v.typecode == nil {
+		panic(&ValueError{Method: "reflect.Value.MethodByName", Kind: Invalid})
+	}
+	if v.flags&valueFlagMethod != 0 {
+		panic("reflect: MethodByName of method value")
+	}
+	if v.Kind() == Interface {
+		if v.IsNil() {
+			panic("reflect: MethodByName on nil interface value")
+		}
+		return v.Elem().MethodByName(name)
+	}
+	if i := methodIndex(v.typecode.methods(), name); i >= 0 {
+		return v.Method(i)
+	}
+	return Value{}
 }
 
+// NewAt returns a Value representing a pointer to a value of the specified
+// type, using p as that pointer.
 func NewAt(typ Type, p unsafe.Pointer) Value {
-	panic("unimplemented: reflect.New()")
+	return Value{
+		typecode: pointerTo(typ.(*rawType)),
+		value:    p,
+		flags:    valueFlagExported,
+	}
 }
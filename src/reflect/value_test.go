@@ -594,6 +594,180 @@ func TestTinyNumMethods(t *testing.T) {
 	}
 }
 
+func TestTinyMethods(t *testing.T) {
+	m := &methodStruct{i: 5}
+
+	refptrt := TypeOf(m)
+	method := refptrt.Method(1)
+	if method.Name != "PointerMethod2" || method.Index != 1 {
+		t.Errorf("Method(1) = %s (index %d), want PointerMethod2 (index 1)", method.Name, method.Index)
+	}
+	if got, want := method.Type.String(), "func(*reflect_test.methodStruct) int"; got != want {
+		t.Errorf("Method(1).Type = %s, want %s", got, want)
+	}
+	if got := method.Func.Call([]Value{ValueOf(m)})[0].Int(); got != 5 {
+		t.Errorf("Method(1).Func.Call() = %d, want 5", got)
+	}
+	if _, ok := refptrt.MethodByName("pointerMethod3"); ok {
+		t.Errorf("MethodByName found unexported method")
+	}
+
+	v := ValueOf(m).MethodByName("ValueMethod1")
+	if got, want := v.Type().String(), "func() int"; got != want {
+		t.Errorf("MethodByName type = %s, want %s", got, want)
+	}
+	m.i = 7
+	if got := v.Call(nil)[0].Int(); got != 7 {
+		t.Errorf("MethodByName(ValueMethod1).Call() = %d, want 7", got)
+	}
+	if got := ValueOf(*m).Method(0).Call(nil)[0].Int(); got != 7 {
+		t.Errorf("Method(0).Call() = %d, want 7", got)
+	}
+	if v := ValueOf(*m).MethodByName("PointerMethod1"); v.IsValid() {
+		t.Errorf("MethodByName found pointer method on value")
+	}
+}
+
+func TestTinyFuncType(t *testing.T) {
+	fn := func(prefix string, n ...int) (string, error) {
+		return prefix + strings.Repeat("x", len(n)), nil
+	}
+	typ := TypeOf(fn)
+	if typ.NumIn() != 2 || typ.NumOut() != 2 || !typ.IsVariadic() {
+		t.Errorf("unexpected signature: NumIn=%d NumOut=%d IsVariadic=%v", typ.NumIn(), typ.NumOut(), typ.IsVariadic())
+	}
+	if got, want := typ.String(), "func(string, ...int) (string, error)"; got != want {
+		t.Errorf("String() = %s, want %s", got, want)
+	}
+	if typ.In(1) != TypeOf([]int(nil)) || typ.Out(0) != TypeOf("") {
+		t.Errorf("unexpected parameter types: %s, %s", typ.In(1), typ.Out(0))
+	}
+
+	out := ValueOf(fn).Call([]Value{ValueOf("a"), ValueOf(1), ValueOf(2)})
+	if len(out) != 2 || out[0].String() != "axx" || !out[1].IsNil() {
+		t.Errorf("Call returned %v", out)
+	}
+	out = ValueOf(fn).CallSlice([]Value{ValueOf("b"), ValueOf([]int{1, 2, 3})})
+	if out[0].String() != "bxxx" {
+		t.Errorf("CallSlice returned %v", out[0])
+	}
+}
+
+type valueMethoder interface {
+	ValueMethod1() int
+}
+
+type pointerMethoder interface {
+	PointerMethod1() int
+	PointerMethod2() int
+}
+
+func TestTinyInterfaceMethods(t *testing.T) {
+	m := &methodStruct{i: 3}
+	valueMethoderType := TypeOf((*valueMethoder)(nil)).Elem()
+	pointerMethoderType := TypeOf((*pointerMethoder)(nil)).Elem()
+	if got, want := pointerMethoderType.NumMethod(), 2; got != want {
+		t.Errorf("NumMethod() = %d, want %d", got, want)
+	}
+	if !TypeOf(m).Implements(pointerMethoderType) || TypeOf(*m).Implements(pointerMethoderType) {
+		t.Errorf("Implements returned the wrong result for pointerMethoder")
+	}
+	if !TypeOf(*m).AssignableTo(valueMethoderType) || TypeOf(5).AssignableTo(valueMethoderType) {
+		t.Errorf("AssignableTo returned the wrong result for valueMethoder")
+	}
+	if TypeOf((*any)(nil)).Elem().Implements(valueMethoderType) {
+		t.Errorf("empty interface implements valueMethoder")
+	}
+
+	// Methods of interface types have a type, but no Func.
+	method := pointerMethoderType.Method(1)
+	if method.Name != "PointerMethod2" || method.Index != 1 || method.Func.IsValid() {
+		t.Errorf("Method(1) = %s (index %d), want PointerMethod2 (index 1)", method.Name, method.Index)
+	}
+	if method.Type != TypeOf((func() int)(nil)) {
+		t.Errorf("Method(1).Type = %s, want func() int", method.Type)
+	}
+	if method, ok := pointerMethoderType.MethodByName("PointerMethod1"); !ok || method.Index != 0 {
+		t.Errorf("MethodByName(PointerMethod1) = %s (index %d), %v", method.Name, method.Index, ok)
+	}
+	if _, ok := pointerMethoderType.MethodByName("ValueMethod1"); ok {
+		t.Errorf("MethodByName found a method that isn't part of the interface")
+	}
+
+	// Call checks parameters of interface type.
+	fn := ValueOf(func(v valueMethoder) int {
+		return v.ValueMethod1()
+	})
+	if got := fn.Call([]Value{ValueOf(*m)})[0].Int(); got != 3 {
+		t.Errorf("Call() = %d, want 3", got)
+	}
+	shouldPanic("reflect: Call using int as type reflect_test.valueMethoder", func() {
+		fn.Call([]Value{ValueOf(5)})
+	})
+
+	// Methods of interface values call the method of the dynamic type.
+	var itf pointerMethoder = m
+	v := ValueOf(&itf).Elem()
+	m.i = 4
+	if got := v.Method(1).Call(nil)[0].Int(); got != 4 {
+		t.Errorf("Method(1).Call() = %d, want 4", got)
+	}
+}
+
+type largeReceiver struct {
+	a, b, c int
+}
+
+func (r largeReceiver) Sum() int {
+	return r.a + r.b + r.c
+}
+
+func TestTinyMethodValue(t *testing.T) {
+	m := &methodStruct{i: 5}
+	f := ValueOf(m).MethodByName("PointerMethod1").Interface().(func() int)
+	m.i = 6
+	if got := f(); got != 6 {
+		t.Errorf("PointerMethod1() = %d, want 6", got)
+	}
+
+	// Receivers that are stored directly in an interface value and receivers
+	// that aren't.
+	g := ValueOf(methodStruct{i: 1}).Method(0).Interface().(func() int)
+	if got := g(); got != 1 {
+		t.Errorf("ValueMethod1() = %d, want 1", got)
+	}
+	r := largeReceiver{1, 2, 3}
+	h := ValueOf(r).MethodByName("Sum").Interface().(func() int)
+	r.a = 10
+	if got := h(); got != 6 {
+		t.Errorf("Sum() = %d, want 6", got)
+	}
+
+	// Method values can be passed to Call and stored using Set.
+	apply := ValueOf(func(f func() int) int {
+		return f() * 2
+	})
+	if got := apply.Call([]Value{ValueOf(m).MethodByName("PointerMethod2")})[0].Int(); got != 12 {
+		t.Errorf("Call() = %d, want 12", got)
+	}
+	var fn func() int
+	ValueOf(&fn).Elem().Set(ValueOf(r).MethodByName("Sum"))
+	if got := fn(); got != 15 {
+		t.Errorf("Sum() = %d, want 15", got)
+	}
+}
+
+func TestTinyMakeFuncMethodValue(t *testing.T) {
+	m := &methodStruct{i: 8}
+	var get func() func() int
+	ValueOf(&get).Elem().Set(MakeFunc(TypeOf(get), func([]Value) []Value {
+		return []Value{ValueOf(m).MethodByName("PointerMethod1")}
+	}))
+	if got := get()(); got != 8 {
+		t.Errorf("get()() = %d, want 8", got)
+	}
+}
+
 func TestAssignableTo(t *testing.T) {
 	var a any
 	refa := ValueOf(&a).Elem()
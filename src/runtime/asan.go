@@ -0,0 +1,692 @@
+//go:build tinygo.asan
+
+package runtime
+
+// This file implements the runtime side of AddressSanitizer, which is enabled
+// with -sanitize=address. C code (outside the standard library) is compiled
+// with -fsanitize=address, so that every memory access in C code checks the
+// shadow memory before the access and calls one of the __asan_report_*
+// functions below if the memory is poisoned.
+//
+// This deliberately doesn't use the AddressSanitizer runtime from compiler-rt.
+// That runtime intercepts libc functions in a dynamically linked program, which
+// doesn't work with the statically linked binaries TinyGo produces. It also
+// only knows about memory from its own allocator, so it can't check accesses
+// from C code to objects on the Go heap, and it is a large C++ library that
+// would have to be built for every target. Instead, the subset of the runtime
+// that is needed by the instrumented code is implemented here:
+//
+//   - The shadow memory is mapped at startup, at the fixed offset the compiler
+//     assumes for the target.
+//   - The Go heap poisons everything except live objects, and adds a redzone
+//     after each object (see gc_blocks.go). Objects freed by the GC are
+//     poisoned until they are reused.
+//   - The C allocator (malloc, free, etc.) is wrapped using the linker (see
+//     compileopts.Config.LDFlags) to add redzones around allocations and to
+//     keep freed memory in a quarantine for a while.
+//   - Stack and global redzones are poisoned by the instrumented code itself,
+//     using the functions below.
+//
+// Go code is not instrumented, so only invalid accesses from C code are
+// detected. Reports include a stack trace with both C and Go frames.
+//
+// The interface between the instrumented code and this runtime (the shadow
+// offset, the __asan_* functions and the version check) is defined by LLVM and
+// not a stable API, so it must be checked when upgrading LLVM. Incompatible
+// changes usually come with a new __asan_version_mismatch_check_v* function,
+// which makes linking fail instead of silently missing bugs.
+
+import (
+	"internal/task"
+	"unsafe"
+)
+
+// Each shadow byte describes 8 bytes of memory.
+const asanGranularity = 8
+
+// Size of the redzone added after each Go heap allocation.
+const asanRedzoneSize = 16
+
+// Shadow values, the same as in compiler-rt so that reports look familiar.
+const (
+	asanHeapLeftRedzone    = 0xfa
+	asanHeapFreed          = 0xfd
+	asanStackLeftRedzone   = 0xf1
+	asanStackMidRedzone    = 0xf2
+	asanStackRightRedzone  = 0xf3
+	asanStackAfterReturn   = 0xf5
+	asanStackUseAfterScope = 0xf8
+	asanGlobalRedzone      = 0xf9
+	asanUserPoisoned       = 0xf7
+	asanAllocaLeftRedzone  = 0xca
+	asanAllocaRightRedzone = 0xcb
+)
+
+// Mmap flags that aren't used elsewhere in the runtime.
+const (
+	asan_MAP_NORESERVE       = 0x4000
+	asan_MAP_FIXED_NOREPLACE = 0x100000
+)
+
+var asanInitialized bool
+
+// Map the shadow memory. This is called both from the constructor inserted by
+// the compiler in instrumented C code (which may run before the Go runtime is
+// initialized) and at the start of the Go runtime, whichever comes first.
+func asanInit() {
+	if asanInitialized {
+		return
+	}
+	asanInitialized = true
+
+	// Try to map the shadow memory for the entire user address space. The
+	// memory is only allocated when it is written to, so this is cheap.
+	// The size of the address space may depend on the kernel configuration,
+	// so try smaller sizes if the mapping fails.
+	for _, bits := range asanAddressBits {
+		size := (uintptr(1) << bits) / asanGranularity
+		addr := mmap(unsafe.Pointer(uintptr(asanShadowOffset)), size, flag_PROT_READ|flag_PROT_WRITE, flag_MAP_PRIVATE|flag_MAP_ANONYMOUS|asan_MAP_NORESERVE|asan_MAP_FIXED_NOREPLACE, -1, 0)
+		if uintptr(addr) == asanShadowOffset {
+			return
+		}
+	}
+	print("==", libc_getpid(), "==ERROR: AddressSanitizer: failed to map the shadow memory\n")
+	exit(1)
+}
+
+// Return the shadow byte for the given address.
+//
+//go:inline
+func asanShadow(addr uintptr) *uint8 {
+	return (*uint8)(unsafe.Pointer(addr/asanGranularity + asanShadowOffset))
+}
+
+// Set the shadow bytes for [addr, addr+size) to the given value. The address
+// must be aligned to asanGranularity, and the size is rounded up.
+func asanPoison(addr, size uintptr, value uint8) {
+	shadowStart := addr/asanGranularity + asanShadowOffset
+	shadowEnd := (addr+size+asanGranularity-1)/asanGranularity + asanShadowOffset
+	for p := shadowStart; p < shadowEnd; p++ {
+		*(*uint8)(unsafe.Pointer(p)) = value
+	}
+}
+
+// Mark [addr, addr+size) as addressable. The address must be aligned to
+// asanGranularity. If size is not a multiple of asanGranularity, the last
+// granule is marked as partially addressable.
+func asanUnpoison(addr, size uintptr) {
+	asanPoison(addr, size&^(asanGranularity-1), 0)
+	if size%asanGranularity != 0 {
+		*asanShadow(addr + size&^(asanGranularity-1)) = uint8(size % asanGranularity)
+	}
+}
+
+// Return whether the byte at addr may not be accessed.
+//
+//go:inline
+func asanIsPoisoned(addr uintptr) bool {
+	shadow := int8(*asanShadow(addr))
+	return shadow != 0 && int8(addr%asanGranularity) >= shadow
+}
+
+// Return the first poisoned address in [addr, addr+size), or 0 if the entire
+// range may be accessed.
+func asanFindPoisoned(addr, size uintptr) uintptr {
+	end := addr + size
+	for addr < end {
+		if addr%asanGranularity == 0 && end-addr >= asanGranularity && *asanShadow(addr) == 0 {
+			// Fast path: the entire granule is addressable.
+			addr += asanGranularity
+			continue
+		}
+		if asanIsPoisoned(addr) {
+			return addr
+		}
+		addr++
+	}
+	return 0
+}
+
+// Called by the Go heap after allocating an object at ptr with the given size.
+// The object is preceded by the start of the allocation (in case there is a
+// header) and followed by a redzone up to end.
+func asanAllocated(start, ptr, size, end uintptr) {
+	asanPoison(start, ptr-start, asanHeapLeftRedzone)
+	asanUnpoison(ptr, size)
+	redzone := (ptr + size + asanGranularity - 1) &^ (asanGranularity - 1)
+	asanPoison(redzone, end-redzone, asanHeapLeftRedzone)
+}
+
+// Called by the Go heap when the memory [addr, addr+size) has been freed.
+func asanFreed(addr, size uintptr) {
+	asanPoison(addr, size, asanHeapFreed)
+}
+
+// Called by the Go heap for memory that is part of the heap but has never
+// been allocated.
+func asanHeapInit(addr, size uintptr) {
+	asanPoison(addr, size, asanHeapLeftRedzone)
+}
+
+// Describe the kind of invalid access, based on the shadow byte of the first
+// poisoned address.
+func asanBugType(addr uintptr) string {
+	shadow := *asanShadow(addr)
+	if shadow > 0 && shadow < asanGranularity {
+		// Partially addressable granule: the access is right after an
+		// object, so look at the next granule to find out what kind of
+		// object.
+		shadow = *asanShadow(addr + asanGranularity)
+	}
+	switch shadow {
+	case asanHeapLeftRedzone:
+		return "heap-buffer-overflow"
+	case asanHeapFreed:
+		return "heap-use-after-free"
+	case asanStackLeftRedzone:
+		return "stack-buffer-underflow"
+	case asanStackMidRedzone, asanStackRightRedzone:
+		return "stack-buffer-overflow"
+	case asanStackAfterReturn:
+		return "stack-use-after-return"
+	case asanStackUseAfterScope:
+		return "stack-use-after-scope"
+	case asanGlobalRedzone:
+		return "global-buffer-overflow"
+	case asanUserPoisoned:
+		return "use-after-poison"
+	case asanAllocaLeftRedzone, asanAllocaRightRedzone:
+		return "dynamic-stack-buffer-overflow"
+	}
+	return "unknown-crash"
+}
+
+// Print a report about an invalid memory access and exit the program.
+func asanReportAccess(addr, size uintptr, isWrite bool, pc uintptr) {
+	bad := asanFindPoisoned(addr, size)
+	if bad == 0 {
+		bad = addr
+	}
+	access := "READ"
+	if isWrite {
+		access = "WRITE"
+	}
+	asanReport(asanBugType(bad), addr, pc, func() {
+		println(access, "of size", size, "at", unsafe.Pointer(addr))
+	})
+}
+
+// Print a sanitizer report and exit the program.
+func asanReport(bug string, addr, pc uintptr, details func()) {
+	pid := libc_getpid()
+	printlock()
+	println("=================================================================")
+	print("==", pid, "==ERROR: AddressSanitizer: ", bug, " on address ", unsafe.Pointer(addr), " at pc ", unsafe.Pointer(pc), "\n")
+	if details != nil {
+		details()
+	}
+	sanitizerPrintStack(pc)
+	println()
+	print("SUMMARY: AddressSanitizer: ", bug)
+	if name, file, line := sanitizerLocation(pc); name != "" {
+		print(" ", file, ":", line, " in ", name)
+	}
+	println()
+	print("==", pid, "==ABORTING\n")
+	printunlock()
+	exit(1)
+}
+
+// Entry points called from instrumented code.
+
+//export __asan_init
+func asan_init() {
+	asanInit()
+}
+
+//export __asan_version_mismatch_check_v8
+func asan_version_mismatch_check_v8() {
+}
+
+//export __asan_handle_no_return
+func asan_handle_no_return() {
+	// The stack frames that are skipped by the noreturn call may have left
+	// poisoned redzones on the stack. Unpoison the rest of the system stack.
+	// Goroutine stacks are not unpoisoned, as their bounds aren't known here.
+	if task.OnSystemStack() {
+		sp := getCurrentStackPointer() &^ (asanGranularity - 1)
+		if sp < stackTop {
+			asanPoison(sp, stackTop-sp, 0)
+		}
+	}
+}
+
+//export __asan_report_load1
+func asan_report_load1(addr uintptr) {
+	asanReportAccess(addr, 1, false, uintptr(returnAddress(0)))
+}
+
+//export __asan_report_load2
+func asan_report_load2(addr uintptr) {
+	asanReportAccess(addr, 2, false, uintptr(returnAddress(0)))
+}
+
+//export __asan_report_load4
+func asan_report_load4(addr uintptr) {
+	asanReportAccess(addr, 4, false, uintptr(returnAddress(0)))
+}
+
+//export __asan_report_load8
+func asan_report_load8(addr uintptr) {
+	asanReportAccess(addr, 8, false, uintptr(returnAddress(0)))
+}
+
+//export __asan_report_load16
+func asan_report_load16(addr uintptr) {
+	asanReportAccess(addr, 16, false, uintptr(returnAddress(0)))
+}
+
+//export __asan_report_load_n
+func asan_report_load_n(addr, size uintptr) {
+	asanReportAccess(addr, size, false, uintptr(returnAddress(0)))
+}
+
+//export __asan_report_store1
+func asan_report_store1(addr uintptr) {
+	asanReportAccess(addr, 1, true, uintptr(returnAddress(0)))
+}
+
+//export __asan_report_store2
+func asan_report_store2(addr uintptr) {
+	asanReportAccess(addr, 2, true, uintptr(returnAddress(0)))
+}
+
+//export __asan_report_store4
+func asan_report_store4(addr uintptr) {
+	asanReportAccess(addr, 4, true, uintptr(returnAddress(0)))
+}
+
+//export __asan_report_store8
+func asan_report_store8(addr uintptr) {
+	asanReportAccess(addr, 8, true, uintptr(returnAddress(0)))
+}
+
+//export __asan_report_store16
+func asan_report_store16(addr uintptr) {
+	asanReportAccess(addr, 16, true, uintptr(returnAddress(0)))
+}
+
+//export __asan_report_store_n
+func asan_report_store_n(addr, size uintptr) {
+	asanReportAccess(addr, size, true, uintptr(returnAddress(0)))
+}
+
+// The following functions are used instead of inline checks in very large
+// functions.
+
+//export __asan_load1
+func asan_load1(addr uintptr) {
+	asanCheck(addr, 1, false, uintptr(returnAddress(0)))
+}
+
+//export __asan_load2
+func asan_load2(addr uintptr) {
+	asanCheck(addr, 2, false, uintptr(returnAddress(0)))
+}
+
+//export __asan_load4
+func asan_load4(addr uintptr) {
+	asanCheck(addr, 4, false, uintptr(returnAddress(0)))
+}
+
+//export __asan_load8
+func asan_load8(addr uintptr) {
+	asanCheck(addr, 8, false, uintptr(returnAddress(0)))
+}
+
+//export __asan_load16
+func asan_load16(addr uintptr) {
+	asanCheck(addr, 16, false, uintptr(returnAddress(0)))
+}
+
+//export __asan_loadN
+func asan_loadN(addr, size uintptr) {
+	asanCheck(addr, size, false, uintptr(returnAddress(0)))
+}
+
+//export __asan_store1
+func asan_store1(addr uintptr) {
+	asanCheck(addr, 1, true, uintptr(returnAddress(0)))
+}
+
+//export __asan_store2
+func asan_store2(addr uintptr) {
+	asanCheck(addr, 2, true, uintptr(returnAddress(0)))
+}
+
+//export __asan_store4
+func asan_store4(addr uintptr) {
+	asanCheck(addr, 4, true, uintptr(returnAddress(0)))
+}
+
+//export __asan_store8
+func asan_store8(addr uintptr) {
+	asanCheck(addr, 8, true, uintptr(returnAddress(0)))
+}
+
+//export __asan_store16
+func asan_store16(addr uintptr) {
+	asanCheck(addr, 16, true, uintptr(returnAddress(0)))
+}
+
+//export __asan_storeN
+func asan_storeN(addr, size uintptr) {
+	asanCheck(addr, size, true, uintptr(returnAddress(0)))
+}
+
+// Check whether [addr, addr+size) may be accessed, and report an error if not.
+func asanCheck(addr, size uintptr, isWrite bool, pc uintptr) {
+	if size != 0 && asanFindPoisoned(addr, size) != 0 {
+		asanReportAccess(addr, size, isWrite, pc)
+	}
+}
+
+// Calls to memcpy, memmove and memset in instrumented code are replaced with
+// calls to these functions, so that the whole range is checked.
+
+//export __asan_memcpy
+func asan_memcpy(dst, src unsafe.Pointer, size uintptr) unsafe.Pointer {
+	pc := uintptr(returnAddress(0))
+	asanCheck(uintptr(src), size, false, pc)
+	asanCheck(uintptr(dst), size, true, pc)
+	memcpy(dst, src, size)
+	return dst
+}
+
+//export __asan_memmove
+func asan_memmove(dst, src unsafe.Pointer, size uintptr) unsafe.Pointer {
+	pc := uintptr(returnAddress(0))
+	asanCheck(uintptr(src), size, false, pc)
+	asanCheck(uintptr(dst), size, true, pc)
+	memmove(dst, src, size)
+	return dst
+}
+
+//export __asan_memset
+func asan_memset(dst unsafe.Pointer, c int32, size uintptr) unsafe.Pointer {
+	asanCheck(uintptr(dst), size, true, uintptr(returnAddress(0)))
+	for i := uintptr(0); i < size; i++ {
+		*(*uint8)(unsafe.Add(dst, i)) = uint8(c)
+	}
+	return dst
+}
+
+// Global variables in instrumented code are followed by a redzone, which is
+// poisoned when the globals are registered from a constructor function.
+
+// Layout of a global as described by the compiler, see
+// llvm/lib/Transforms/Instrumentation/AddressSanitizer.cpp.
+type asanGlobal struct {
+	beg             uintptr
+	size            uintptr
+	sizeWithRedzone uintptr
+	name            *byte
+	moduleName      *byte
+	hasDynamicInit  uintptr
+	location        unsafe.Pointer
+	odrIndicator    uintptr
+}
+
+//export __asan_register_globals
+func asan_register_globals(globals *asanGlobal, n uintptr) {
+	asanInit()
+	for i := uintptr(0); i < n; i++ {
+		g := (*asanGlobal)(unsafe.Add(unsafe.Pointer(globals), i*unsafe.Sizeof(*globals)))
+		asanUnpoison(g.beg, g.size)
+		redzone := (g.beg + g.size + asanGranularity - 1) &^ (asanGranularity - 1)
+		asanPoison(redzone, g.beg+g.sizeWithRedzone-redzone, asanGlobalRedzone)
+	}
+}
+
+//export __asan_unregister_globals
+func asan_unregister_globals(globals *asanGlobal, n uintptr) {
+	for i := uintptr(0); i < n; i++ {
+		g := (*asanGlobal)(unsafe.Add(unsafe.Pointer(globals), i*unsafe.Sizeof(*globals)))
+		asanPoison(g.beg, g.sizeWithRedzone, 0)
+	}
+}
+
+// Stack redzones are poisoned by writing directly to the shadow memory, or
+// using the following functions for large stack frames. The addr parameter is
+// the shadow address, not the address of the stack object.
+
+//export __asan_set_shadow_00
+func asan_set_shadow_00(addr, size uintptr) {
+	asanSetShadow(addr, size, 0)
+}
+
+//export __asan_set_shadow_f1
+func asan_set_shadow_f1(addr, size uintptr) {
+	asanSetShadow(addr, size, asanStackLeftRedzone)
+}
+
+//export __asan_set_shadow_f2
+func asan_set_shadow_f2(addr, size uintptr) {
+	asanSetShadow(addr, size, asanStackMidRedzone)
+}
+
+//export __asan_set_shadow_f3
+func asan_set_shadow_f3(addr, size uintptr) {
+	asanSetShadow(addr, size, asanStackRightRedzone)
+}
+
+//export __asan_set_shadow_f5
+func asan_set_shadow_f5(addr, size uintptr) {
+	asanSetShadow(addr, size, asanStackAfterReturn)
+}
+
+//export __asan_set_shadow_f8
+func asan_set_shadow_f8(addr, size uintptr) {
+	asanSetShadow(addr, size, asanStackUseAfterScope)
+}
+
+func asanSetShadow(addr, size uintptr, value uint8) {
+	for i := uintptr(0); i < size; i++ {
+		*(*uint8)(unsafe.Pointer(addr + i)) = value
+	}
+}
+
+// Size of the redzones around variable sized stack allocations (alloca).
+const asanAllocaRedzoneSize = 32
+
+//export __asan_alloca_poison
+func asan_alloca_poison(addr, size uintptr) {
+	left := addr - asanAllocaRedzoneSize
+	partial := addr + size
+	right := (partial + asanAllocaRedzoneSize - 1) &^ (asanAllocaRedzoneSize - 1)
+	partialAligned := partial &^ (asanGranularity - 1)
+	asanPoison(left, asanAllocaRedzoneSize, asanAllocaLeftRedzone)
+	asanPoison(partialAligned, right-partialAligned, asanAllocaRightRedzone)
+	if partial%asanGranularity != 0 {
+		*asanShadow(partialAligned) = uint8(partial % asanGranularity)
+	}
+	asanPoison(right, asanAllocaRedzoneSize, asanAllocaRightRedzone)
+}
+
+//export __asan_allocas_unpoison
+func asan_allocas_unpoison(top, bottom uintptr) {
+	if top == 0 || top > bottom {
+		return
+	}
+	asanPoison(top, bottom-top, 0)
+}
+
+// Manual poisoning, see <sanitizer/asan_interface.h>.
+
+//export __asan_poison_memory_region
+func asan_poison_memory_region(addr unsafe.Pointer, size uintptr) {
+	start := uintptr(addr)
+	end := start + size
+	// Only whole granules can be poisoned, except when the poisoned range
+	// reaches the end of an addressable granule.
+	start = (start + asanGranularity - 1) &^ (asanGranularity - 1)
+	if end > start {
+		asanPoison(start, (end-start)&^(asanGranularity-1), asanUserPoisoned)
+	}
+}
+
+//export __asan_unpoison_memory_region
+func asan_unpoison_memory_region(addr unsafe.Pointer, size uintptr) {
+	start := uintptr(addr) &^ (asanGranularity - 1)
+	asanUnpoison(start, uintptr(addr)+size-start)
+}
+
+//export __asan_address_is_poisoned
+func asan_address_is_poisoned(addr unsafe.Pointer) int32 {
+	if asanIsPoisoned(uintptr(addr)) {
+		return 1
+	}
+	return 0
+}
+
+// The C allocator is wrapped, to add redzones around each allocation and to
+// detect use-after-free and double free. Each allocation has a header in the
+// left redzone that stores the requested size and the state of the chunk.
+
+// Size of the chunk header, which is also the left redzone.
+const asanChunkHeaderSize = 16
+
+// Minimum size of the right redzone.
+const asanChunkRedzoneSize = 16
+
+const (
+	asanChunkAllocated   = 0x7a6f6e6561736e61 // arbitrary magic values
+	asanChunkQuarantined = 0x7175617261746e65
+)
+
+type asanChunkHeader struct {
+	size  uintptr
+	state uintptr
+}
+
+// Number of freed chunks that are kept before they're really freed.
+const asanQuarantineSize = 1024
+
+var (
+	asanMallocLock      task.PMutex
+	asanQuarantine      [asanQuarantineSize]unsafe.Pointer
+	asanQuarantineIndex int
+)
+
+//export __real_malloc
+func asan_real_malloc(size uintptr) unsafe.Pointer
+
+//export __real_free
+func asan_real_free(ptr unsafe.Pointer)
+
+//export __wrap_malloc
+func asan_wrap_malloc(size uintptr) unsafe.Pointer {
+	return asanMalloc(size, uintptr(returnAddress(0)))
+}
+
+//export __wrap_calloc
+func asan_wrap_calloc(nmemb, size uintptr) unsafe.Pointer {
+	total := nmemb * size
+	if nmemb != 0 && total/nmemb != size {
+		// Overflow.
+		return nil
+	}
+	ptr := asanMalloc(total, uintptr(returnAddress(0)))
+	if ptr != nil {
+		memzero(ptr, total)
+	}
+	return ptr
+}
+
+//export __wrap_realloc
+func asan_wrap_realloc(ptr unsafe.Pointer, size uintptr) unsafe.Pointer {
+	pc := uintptr(returnAddress(0))
+	if ptr == nil {
+		return asanMalloc(size, pc)
+	}
+	header := asanChunk(ptr, pc)
+	newPtr := asanMalloc(size, pc)
+	if newPtr == nil {
+		return nil
+	}
+	copySize := header.size
+	if size < copySize {
+		copySize = size
+	}
+	memcpy(newPtr, ptr, copySize)
+	asanFree(ptr, pc)
+	return newPtr
+}
+
+//export __wrap_free
+func asan_wrap_free(ptr unsafe.Pointer) {
+	if ptr == nil {
+		return
+	}
+	asanFree(ptr, uintptr(returnAddress(0)))
+}
+
+func asanMalloc(size, pc uintptr) unsafe.Pointer {
+	userSize := (size + asanGranularity - 1) &^ (asanGranularity - 1)
+	total := asanChunkHeaderSize + userSize + asanChunkRedzoneSize
+	if total < size {
+		// Overflow.
+		return nil
+	}
+	chunk := asan_real_malloc(total)
+	if chunk == nil {
+		return nil
+	}
+	header := (*asanChunkHeader)(chunk)
+	header.size = size
+	header.state = asanChunkAllocated
+	ptr := unsafe.Add(chunk, asanChunkHeaderSize)
+	asanAllocated(uintptr(chunk), uintptr(ptr), size, uintptr(chunk)+total)
+	return ptr
+}
+
+// Return the header of the chunk, reporting an error if ptr was not returned
+// by malloc or has already been freed.
+func asanChunk(ptr unsafe.Pointer, pc uintptr) *asanChunkHeader {
+	addr := uintptr(ptr)
+	if addr%asanGranularity != 0 || *asanShadow(addr - asanChunkHeaderSize) != asanHeapLeftRedzone {
+		asanReport("attempting free on address which was not malloc()-ed", addr, pc, nil)
+	}
+	header := (*asanChunkHeader)(unsafe.Add(ptr, -asanChunkHeaderSize))
+	switch header.state {
+	case asanChunkAllocated:
+		return header
+	case asanChunkQuarantined:
+		asanReport("attempting double-free", addr, pc, nil)
+	default:
+		asanReport("attempting free on address which was not malloc()-ed", addr, pc, nil)
+	}
+	return nil
+}
+
+func asanFree(ptr unsafe.Pointer, pc uintptr) {
+	header := asanChunk(ptr, pc)
+	header.state = asanChunkQuarantined
+	userSize := (header.size + asanGranularity - 1) &^ (asanGranularity - 1)
+	asanPoison(uintptr(ptr), userSize, asanHeapFreed)
+
+	// Keep the chunk in the quarantine for a while, so that use-after-free
+	// can be detected. Really free the oldest chunk in the quarantine.
+	asanMallocLock.Lock()
+	old := asanQuarantine[asanQuarantineIndex]
+	asanQuarantine[asanQuarantineIndex] = unsafe.Add(ptr, -asanChunkHeaderSize)
+	asanQuarantineIndex = (asanQuarantineIndex + 1) % asanQuarantineSize
+	asanMallocLock.Unlock()
+	if old != nil {
+		oldHeader := (*asanChunkHeader)(old)
+		total := asanChunkHeaderSize + (oldHeader.size+asanGranularity-1)&^(asanGranularity-1) + asanChunkRedzoneSize
+		oldHeader.state = 0
+		asanPoison(uintptr(old), total, 0)
+		asan_real_free(old)
+	}
+}
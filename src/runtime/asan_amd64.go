@@ -0,0 +1,11 @@
+//go:build tinygo.asan
+
+package runtime
+
+// Offset of the shadow memory: the shadow byte of address addr is at
+// (addr >> 3) + asanShadowOffset. This must match the offset that LLVM uses
+// for linux/amd64.
+const asanShadowOffset = 0x7fff8000
+
+// Size of the user address space in bits.
+var asanAddressBits = [...]uintptr{47}
@@ -0,0 +1,12 @@
+//go:build tinygo.asan
+
+package runtime
+
+// Offset of the shadow memory: the shadow byte of address addr is at
+// (addr >> 3) + asanShadowOffset. This must match the offset that LLVM uses
+// for linux/arm64.
+const asanShadowOffset = 1 << 36
+
+// Possible sizes of the user address space in bits, which depend on the kernel
+// configuration. The largest size that can be mapped is used.
+var asanAddressBits = [...]uintptr{48, 47, 42, 39}
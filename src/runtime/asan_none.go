@@ -0,0 +1,24 @@
+//go:build !tinygo.asan
+
+package runtime
+
+// The program wasn't built with -sanitize=address, so the heap doesn't need to
+// maintain shadow memory.
+
+const asanRedzoneSize = 0
+
+//go:inline
+func asanInit() {
+}
+
+//go:inline
+func asanAllocated(start, ptr, size, end uintptr) {
+}
+
+//go:inline
+func asanFreed(addr, size uintptr) {
+}
+
+//go:inline
+func asanHeapInit(addr, size uintptr) {
+}
@@ -66,6 +66,9 @@ func syscall_Exit(code int) {
 
 const baremetal = true
 
+// Memory profiling is off by default, as it needs extra RAM.
+const defaultMemProfileRate = 0
+
 // timeOffset is how long the monotonic clock started after the Unix epoch. It
 // should be a positive integer under normal operation or zero when it has not
 // been set.
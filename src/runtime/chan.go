@@ -175,6 +175,7 @@ func (ch *channel) bufferPush(value unsafe.Pointer) {
 	}
 
 	memcpy(elemAddr, value, ch.elementSize)
+	gcWriteBarrier(elemAddr)
 }
 
 // Pop a value from the channel buffer and store it in the 'value' pointer, for
@@ -190,6 +191,7 @@ func (ch *channel) bufferPop(value unsafe.Pointer) {
 	}
 
 	memcpy(value, elemAddr, ch.elementSize)
+	gcWriteBarrier(value)
 
 	// Zero the value to allow the GC to collect it.
 	memzero(elemAddr, ch.elementSize)
@@ -215,6 +217,7 @@ func (ch *channel) trySend(value unsafe.Pointer) bool {
 	if ch.bufLen == 0 {
 		if receiver := ch.receivers.pop(chanOperationOk); receiver != nil {
 			memcpy(receiver.task.Ptr, value, ch.elementSize)
+			gcWriteBarrier(receiver.task.Ptr)
 			scheduleTask(receiver.task)
 			return true
 		}
@@ -258,6 +261,7 @@ func chanSend(ch *channel, value unsafe.Pointer, op *channelOp) {
 	// Wait until this goroutine is resumed.
 	// It might be resumed after Unlock() and before Pause(). In that case,
 	// because we use semaphores, the Pause() will continue immediately.
+	traceBlock("chan send")
 	task.Pause()
 
 	// Check whether the sent happened normally (not because the channel was
@@ -300,6 +304,7 @@ func (ch *channel) tryRecv(value unsafe.Pointer) (received, ok bool) {
 	// immediately.
 	if sender := ch.senders.pop(chanOperationOk); sender != nil {
 		memcpy(value, sender.value, ch.elementSize)
+		gcWriteBarrier(value)
 		scheduleTask(sender.task)
 		return true, true
 	}
@@ -334,6 +339,7 @@ func chanRecv(ch *channel, value unsafe.Pointer, op *channelOp) bool {
 	interrupt.Restore(mask)
 
 	// Wait until the goroutine is resumed.
+	traceBlock("chan receive")
 	task.Pause()
 
 	// Return whether the receive happened from a closed channel.
@@ -501,6 +507,7 @@ func chanSelect(recvbuf unsafe.Pointer, states []chanSelectState, ops []channelO
 	unlockAllStates(states)
 	chanSelectLock.Unlock()
 	interrupt.Restore(mask)
+	traceBlock("select")
 	task.Pause()
 
 	// Resumed, so one channel operation must have progressed.
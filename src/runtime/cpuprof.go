@@ -0,0 +1,176 @@
+//go:build darwin || (linux && !baremetal && !wasip1 && !wasm_unknown && !wasip2 && !nintendoswitch)
+
+package runtime
+
+// CPU profiling support. A profiling timer (ITIMER_PROF) periodically sends
+// SIGPROF to the process, and the signal handler records the interrupted call
+// stack in a fixed-size hash table. The runtime/pprof package reads the table
+// once profiling has been stopped.
+//
+// The call stack is found by walking the frame pointers of the interrupted
+// code, so only the interrupted program counter is recorded when the program
+// wasn't built with -symtab.
+
+import "sync/atomic"
+
+//export tinygo_cpuprofile_start
+func tinygo_cpuprofile_start(hz int32)
+
+//export tinygo_cpuprofile_stop
+func tinygo_cpuprofile_stop()
+
+// Number of distinct call stacks that can be recorded in a single profile.
+// Samples for more call stacks are counted as lost.
+const (
+	cpuProfileBucketBits = 10
+	cpuProfileBuckets    = 1 << cpuProfileBucketBits
+)
+
+// Maximum number of hash table entries that are checked before a sample is
+// dropped.
+const cpuProfileMaxProbes = 16
+
+// Maximum number of frames recorded per sample. Deeper call stacks are
+// truncated.
+const cpuProfileMaxStack = 32
+
+// States of a hash table bucket. A bucket is claimed by the first signal
+// handler that changes its state from empty to writing, so that signal
+// handlers running on different threads at the same time don't store their
+// call stack in the same bucket.
+const (
+	cpuProfileBucketEmpty = iota
+	cpuProfileBucketWriting
+	cpuProfileBucketReady
+)
+
+type cpuProfileBucket struct {
+	state atomic.Uint32
+	count atomic.Uintptr
+	depth uintptr
+	stack [cpuProfileMaxStack]uintptr
+}
+
+// Return whether the bucket contains the given call stack.
+func (b *cpuProfileBucket) matches(stack []uintptr) bool {
+	if b.depth != uintptr(len(stack)) {
+		return false
+	}
+	for i, pc := range stack {
+		if b.stack[i] != pc {
+			return false
+		}
+	}
+	return true
+}
+
+var (
+	// Hash table that is written to by the SIGPROF handler. It is allocated
+	// when profiling starts and stays around until it has been read.
+	cpuProfileTable atomic.Pointer[[cpuProfileBuckets]cpuProfileBucket]
+
+	// Number of samples that didn't fit in the hash table.
+	cpuProfileLost atomic.Uintptr
+
+	// Profiling rate in samples per second, or 0 when not profiling.
+	cpuProfileRate int
+)
+
+// SetCPUProfileRate sets the CPU profiling rate to hz samples per second.
+// If hz <= 0, SetCPUProfileRate turns off profiling.
+// If the profiler is on, the rate cannot be changed without first turning it
+// off.
+//
+// Most clients should use the runtime/pprof package or the testing package's
+// -test.cpuprofile flag instead of calling SetCPUProfileRate directly.
+func SetCPUProfileRate(hz int) {
+	if hz > 1000000 {
+		hz = 1000000
+	}
+	if hz <= 0 {
+		if cpuProfileRate != 0 {
+			tinygo_cpuprofile_stop()
+			cpuProfileRate = 0
+		}
+		return
+	}
+	if cpuProfileRate != 0 {
+		println("runtime: cannot set cpu profile rate until previous profile has finished.")
+		return
+	}
+	cpuProfileLost.Store(0)
+	cpuProfileTable.Store(new([cpuProfileBuckets]cpuProfileBucket))
+	cpuProfileRate = hz
+	tinygo_cpuprofile_start(int32(hz))
+}
+
+// Called from the SIGPROF signal handler with the interrupted program counter
+// and frame pointer. This function must not allocate or block.
+//
+//export tinygo_cpuprofile_signal
+func tinygo_cpuprofile_signal(pc, fp uintptr) {
+	table := cpuProfileTable.Load()
+	if table == nil {
+		return
+	}
+
+	// The interrupted instruction, followed by the return addresses of the
+	// functions that called it.
+	var stack [cpuProfileMaxStack]uintptr
+	stack[0] = pc
+	depth := 1 + signalCallers(fp, stack[1:])
+
+	// Simple open addressing hash table with linear probing.
+	hash := uint32(0)
+	for _, pc := range stack[:depth] {
+		hash ^= uint32(pc) ^ uint32(uint64(pc)>>32)
+		hash *= 0x9e3779b1 // Fibonacci hashing
+	}
+	index := uintptr(hash >> (32 - cpuProfileBucketBits))
+	for i := 0; i < cpuProfileMaxProbes; i++ {
+		bucket := &table[(index+uintptr(i))%cpuProfileBuckets]
+		switch bucket.state.Load() {
+		case cpuProfileBucketReady:
+			if bucket.matches(stack[:depth]) {
+				bucket.count.Add(1)
+				return
+			}
+		case cpuProfileBucketEmpty:
+			if bucket.state.CompareAndSwap(cpuProfileBucketEmpty, cpuProfileBucketWriting) {
+				bucket.depth = uintptr(depth)
+				copy(bucket.stack[:], stack[:depth])
+				bucket.count.Store(1)
+				bucket.state.Store(cpuProfileBucketReady)
+				return
+			}
+		}
+		// The bucket is used for a different call stack, or it is being
+		// written by a signal handler on a different thread.
+	}
+	cpuProfileLost.Add(1)
+}
+
+//go:linkname pprof_cpuProfileSupported runtime/pprof.cpuProfileSupported
+func pprof_cpuProfileSupported() bool {
+	return true
+}
+
+// Read the samples collected during the last profile. The profile must have
+// been stopped with SetCPUProfileRate(0). The first address of every stack is
+// the interrupted program counter, the others are return addresses. The return
+// value is the number of samples that could not be recorded.
+//
+//go:linkname pprof_readCPUProfile runtime/pprof.readCPUProfile
+func pprof_readCPUProfile(fn func(stack []uintptr, count uint64)) (lost uint64) {
+	table := cpuProfileTable.Swap(nil)
+	if table == nil {
+		return 0
+	}
+	for i := range table {
+		bucket := &table[i]
+		if bucket.state.Load() == cpuProfileBucketReady {
+			fn(bucket.stack[:bucket.depth], uint64(bucket.count.Load()))
+		}
+	}
+	return uint64(cpuProfileLost.Swap(0))
+}
@@ -0,0 +1,18 @@
+//go:build !(darwin || (linux && !baremetal && !wasip1 && !wasm_unknown && !wasip2 && !nintendoswitch))
+
+package runtime
+
+// SetCPUProfileRate sets the CPU profiling rate to hz samples per second.
+// CPU profiling is not supported on this platform, so this is a no-op.
+func SetCPUProfileRate(hz int) {
+}
+
+//go:linkname pprof_cpuProfileSupported runtime/pprof.cpuProfileSupported
+func pprof_cpuProfileSupported() bool {
+	return false
+}
+
+//go:linkname pprof_readCPUProfile runtime/pprof.readCPUProfile
+func pprof_readCPUProfile(fn func(pc uintptr, count uint64)) (lost uint64) {
+	return 0
+}
@@ -6,7 +6,7 @@ package runtime
 // at process startup. Changes to operating system CPU allocation after
 // process startup are not reflected.
 func NumCPU() int {
-	return 1
+	return numCPU()
 }
 
 // Stub for NumCgoCall, does not return the real value
@@ -14,11 +14,6 @@ func NumCgoCall() int {
 	return 0
 }
 
-// Stub for NumGoroutine, does not return the real value
-func NumGoroutine() int {
-	return 1
-}
-
 // Stub for Breakpoint, does not do anything.
 func Breakpoint() {
 	panic("Breakpoint not supported")
@@ -7,11 +7,17 @@ package debug
 
 import (
 	"fmt"
+	"os"
 	"runtime"
 	"strconv"
 	"strings"
 )
 
+// Implemented in the runtime.
+func setGCPercent(percent int32) int32
+func setMemoryLimit(limit int64) int64
+func setOutOfMemoryHook(hook func(size uintptr))
+
 // SetMaxStack sets the maximum amount of memory that can be used by a single
 // goroutine stack.
 //
@@ -22,14 +28,26 @@ func SetMaxStack(n int) int {
 
 // PrintStack prints to standard error the stack trace returned by runtime.Stack.
 //
-// Not implemented.
-func PrintStack() {}
+// Function names and lines are only included when the program was built with
+// the -symtab flag.
+func PrintStack() {
+	os.Stderr.Write(Stack())
+}
 
 // Stack returns a formatted stack trace of the goroutine that calls it.
+// It calls runtime.Stack with a large enough buffer to capture the entire trace.
 //
-// Not implemented.
+// Function names and lines are only included when the program was built with
+// the -symtab flag.
 func Stack() []byte {
-	return nil
+	buf := make([]byte, 1024)
+	for {
+		n := runtime.Stack(buf, false)
+		if n < len(buf) {
+			return buf[:n]
+		}
+		buf = make([]byte, 2*len(buf))
+	}
 }
 
 // ReadBuildInfo returns the build information embedded
@@ -66,9 +84,55 @@ type Module struct {
 	Replace *Module // replaced by this module
 }
 
-// Not implemented.
-func SetGCPercent(n int) int {
-	return n
+// SetGCPercent sets the garbage collection target percentage: after a
+// collection, the heap is grown until the amount of free memory is at least
+// this percentage of the memory that is still in use. A larger percentage means
+// the GC needs to run less often, at the cost of a larger heap. A negative
+// percentage disables garbage collection until the heap can't grow anymore,
+// for example because the memory limit has been reached.
+// SetGCPercent returns the previous setting. The initial setting is 100.
+//
+// The heap can't grow on most microcontrollers, so this setting has little
+// effect there. It is ignored by -gc=leaking, -gc=none and -gc=custom.
+func SetGCPercent(percent int) int {
+	return int(setGCPercent(int32(percent)))
+}
+
+// SetMemoryLimit provides the runtime with a soft memory limit in bytes: the
+// heap isn't grown beyond this limit, instead the GC runs more often to stay
+// below it. The limit can still be exceeded by a bit when the heap grows, and
+// by more if the live heap itself doesn't fit: in that case the heap is grown
+// anyway instead of running out of memory. Unlike the standard Go
+// implementation, only the size of the heap counts towards the limit.
+//
+// SetMemoryLimit returns the previously set memory limit. A negative input
+// does not adjust the limit, and allows for retrieval of the currently set
+// memory limit. The initial limit is math.MaxInt64.
+//
+// The heap can't grow on most microcontrollers, so this setting has little
+// effect there. It is ignored by -gc=leaking, -gc=none and -gc=custom.
+func SetMemoryLimit(limit int64) int64 {
+	return setMemoryLimit(limit)
+}
+
+// SetOutOfMemoryHook sets a function that is called when an allocation fails
+// because the heap is full and can't grow anymore, right before the program
+// panics with an out of memory error. The argument is the number of bytes the
+// allocator tried to reserve, which includes the object header of the precise
+// GC and the redzone added with -sanitize=address, so it can be a bit larger
+// than the requested object. A nil hook removes the previously set hook.
+//
+// The hook can for example reset the chip, or drop references to objects that
+// aren't essential: after the hook returns, the runtime collects garbage once
+// more and retries the allocation before panicking (except with -gc=leaking,
+// which never frees memory). The heap is not locked while the hook runs, so it
+// may allocate memory and call runtime.GC. The hook is not called again while
+// it is running: an allocation that fails inside the hook panics.
+//
+// This function is specific to TinyGo and doesn't exist in the standard Go
+// implementation.
+func SetOutOfMemoryHook(hook func(size uintptr)) {
+	setOutOfMemoryHook(hook)
 }
 
 // Start of stolen from big go. TODO: import/reuse without copy pasta.
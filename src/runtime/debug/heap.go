@@ -0,0 +1,102 @@
+package debug
+
+// Heap introspection. These functions are specific to TinyGo and only work with
+// the block based GCs (-gc=conservative, -gc=precise, -gc=incremental and
+// -gc=compacting). With other GCs, the heap appears to be empty.
+
+import "unsafe"
+
+// Implemented in the runtime.
+func heapBlockSize() uintptr
+func walkHeap(fn func(addr, blocks, layout uintptr) bool)
+func walkHeapFree(fn func(addr, blocks uintptr))
+func printHeapDump()
+
+// HeapObject is an object on the heap, as found by WalkHeap.
+type HeapObject struct {
+	Addr   uintptr // start of the object, as returned by new or make
+	Blocks uintptr // size of the object in heap blocks (see HeapBlockSize)
+	Layout uintptr // pointer layout with -gc=precise or -gc=compacting, 0 if not known
+}
+
+// MayContainPointer returns whether the word at the given byte offset from the
+// start of the object may contain a pointer according to the object layout.
+// It always returns true if the layout is not known.
+func (obj HeapObject) MayContainPointer(offset uintptr) bool {
+	if obj.Layout == 0 {
+		return true
+	}
+	// The GC looks at every pointer-aligned word, see gc_precise.go in the
+	// runtime for a description of the layout format.
+	index := offset / unsafe.Alignof(uintptr(0))
+	if obj.Layout&1 != 0 {
+		// The bitmap is stored in the layout value itself.
+		sizeFieldBits := uintptr(4)
+		switch unsafe.Sizeof(uintptr(0)) {
+		case 4:
+			sizeFieldBits = 5
+		case 8:
+			sizeFieldBits = 6
+		}
+		size := (obj.Layout >> 1) & (1<<sizeFieldBits - 1)
+		if size == 0 {
+			return false
+		}
+		bitmap := obj.Layout >> (1 + sizeFieldBits)
+		return (bitmap>>(index%size))&1 != 0
+	}
+	// The layout value points to a global with the size and the bitmap.
+	size := *(*uintptr)(unsafe.Pointer(obj.Layout))
+	if size == 0 {
+		return false
+	}
+	index %= size
+	bitmap := unsafe.Add(unsafe.Pointer(obj.Layout), unsafe.Sizeof(uintptr(0)))
+	return (*(*uint8)(unsafe.Add(bitmap, index/8))>>(index%8))&1 != 0
+}
+
+// HeapBlockSize returns the size of a heap block in bytes, which is the unit in
+// which heap memory is allocated. It returns 0 if the GC doesn't support heap
+// introspection.
+func HeapBlockSize() uintptr {
+	return heapBlockSize()
+}
+
+// WalkHeap calls fn for every object on the heap, until fn returns false.
+// Objects that are not reachable anymore but haven't been freed yet are also
+// included: call runtime.GC first to only see objects that are still in use.
+//
+// The heap is locked while walking it, so fn must not allocate memory.
+func WalkHeap(fn func(obj HeapObject) bool) {
+	walkHeap(func(addr, blocks, layout uintptr) bool {
+		return fn(HeapObject{Addr: addr, Blocks: blocks, Layout: layout})
+	})
+}
+
+// HeapFreeRuns returns a histogram of the free memory on the heap: element i
+// is the number of runs of consecutive free blocks with a length of at least
+// 1<<i and less than 1<<(i+1) blocks. A heap with many short runs of free
+// blocks is fragmented: large allocations may fail even though there is enough
+// free memory in total.
+func HeapFreeRuns() (runs [32]int) {
+	walkHeapFree(func(addr, blocks uintptr) {
+		i := 0
+		for blocks > 1 && i < len(runs)-1 {
+			blocks >>= 1
+			i++
+		}
+		runs[i]++
+	})
+	return
+}
+
+// PrintHeapDump prints the contents of the heap and of all globals, in the same
+// way as println. This means that on microcontrollers, the dump is sent over
+// the serial port or RTT (see the -serial flag). The dump can be decoded with
+// the "tinygo heapdump" command, using the same binary that printed it, to find
+// out which types use how much memory and which globals keep them alive.
+//
+// Call runtime.GC first to only include objects that are still in use.
+func PrintHeapDump() {
+	printHeapDump()
+}
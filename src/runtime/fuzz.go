@@ -0,0 +1,33 @@
+//go:build tinygo.fuzz
+
+package runtime
+
+// Coverage counters for tinygo test -fuzz. The compiler inserts an increment
+// of one of these counters at the start of every basic block in packages
+// outside the standard library.
+var fuzzCounters [65536]uint8
+
+// Function called right before the program aborts on an unrecovered panic,
+// set by the testing package while running a fuzz input.
+var fuzzCrashHandler func()
+
+//go:linkname fuzz_counters testing.fuzzCounters
+func fuzz_counters() []uint8 {
+	return fuzzCounters[:]
+}
+
+//go:linkname fuzz_setCrashHandler testing.fuzzSetCrashHandler
+func fuzz_setCrashHandler(handler func()) {
+	fuzzCrashHandler = handler
+}
+
+// fuzzCrash is called when the program is about to abort because of a panic.
+// It lets the fuzzer save the input that caused the panic.
+func fuzzCrash() {
+	handler := fuzzCrashHandler
+	if handler != nil {
+		// Only call the handler once, in case it panics itself.
+		fuzzCrashHandler = nil
+		handler()
+	}
+}
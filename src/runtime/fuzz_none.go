@@ -0,0 +1,18 @@
+//go:build !tinygo.fuzz
+
+package runtime
+
+// The program wasn't built for fuzzing, so there are no coverage counters.
+
+//go:linkname fuzz_counters testing.fuzzCounters
+func fuzz_counters() []uint8 {
+	return nil
+}
+
+//go:linkname fuzz_setCrashHandler testing.fuzzSetCrashHandler
+func fuzz_setCrashHandler(handler func()) {
+}
+
+//go:inline
+func fuzzCrash() {
+}
@@ -1,4 +1,4 @@
-//go:build gc.conservative || gc.precise
+//go:build gc.conservative || gc.precise || gc.incremental || gc.compacting
 
 package runtime
 
@@ -57,8 +57,15 @@ var (
 	gcMallocs     uint64         // total number of allocations
 	gcFrees       uint64         // total number of objects freed
 	gcFreedBlocks uint64         // total number of freed blocks
+	gcNumGC       uint32         // total number of completed GC cycles
+	gcNumForcedGC uint32         // total number of GC cycles started by runtime.GC
 )
 
+// Heap lock for parallel goroutines. No-op when single threaded.
+// The lock is not reentrant, so code that holds it must not allocate memory or
+// call functions that might (like the out of memory hook).
+var gcLock task.PMutex
+
 // zeroSizedAlloc is just a sentinel that gets returned when allocating 0 bytes.
 var zeroSizedAlloc uint8
 
@@ -237,6 +244,9 @@ func initHeap() {
 	// Set all block states to 'free'.
 	metadataSize := heapEnd - uintptr(metadataStart)
 	memzero(unsafe.Pointer(metadataStart), metadataSize)
+
+	// Nothing has been allocated yet, so nothing may be accessed.
+	asanHeapInit(heapStart, uintptr(metadataStart)-heapStart)
 }
 
 // setHeapEnd is called to expand the heap. The heap can only grow, not shrink.
@@ -260,6 +270,7 @@ func setHeapEnd(newHeapEnd uintptr) {
 	heapEnd = newHeapEnd
 	calculateHeapAddresses()
 	memcpy(metadataStart, oldMetadataStart, oldMetadataSize)
+	asanHeapInit(uintptr(oldMetadataStart), uintptr(metadataStart)-uintptr(oldMetadataStart))
 
 	// Note: the memcpy above assumes the heap grows enough so that the new
 	// metadata does not overlap the old metadata. If that isn't true, memmove
@@ -313,10 +324,30 @@ func alloc(size uintptr, layout unsafe.Pointer) unsafe.Pointer {
 		size += align(unsafe.Sizeof(layout))
 	}
 
+	// Add a redzone after the object when built with -sanitize=address, so
+	// that out-of-bounds accesses from C code can be detected.
+	size += asanRedzoneSize
+
 	if interrupt.In() {
 		runtimePanicAt(returnAddress(0), "heap alloc in interrupt")
 	}
 
+	gcLock.Lock()
+
+	if memProfileFull() {
+		// Make room for a memory profile sample, which can't be done while
+		// holding the GC lock as it needs to allocate memory.
+		gcLock.Unlock()
+		memProfileGrow()
+		gcLock.Lock()
+	}
+
+	if gcIncremental {
+		// Do a bit of GC work, if a GC cycle is in progress or should be
+		// started.
+		gcIncrementalStep()
+	}
+
 	gcTotalAlloc += uint64(size)
 	gcMallocs++
 
@@ -328,29 +359,47 @@ func alloc(size uintptr, layout unsafe.Pointer) unsafe.Pointer {
 	index := nextAlloc
 	numFreeBlocks := uintptr(0)
 	heapScanCount := uint8(0)
+	calledOutOfMemoryHook := false
 	for {
 		if index == nextAlloc {
 			if heapScanCount == 0 {
 				heapScanCount = 1
 			} else if heapScanCount == 1 {
-				// The entire heap has been searched for free memory, but none
-				// could be found. Run a garbage collection cycle to reclaim
-				// free memory and try again.
-				heapScanCount = 2
-				freeBytes := runGC()
-				heapSize := uintptr(metadataStart) - heapStart
-				if freeBytes < heapSize/3 {
-					// Ensure there is at least 33% headroom.
-					// This percentage was arbitrarily chosen, and may need to
-					// be tuned in the future.
-					growHeap()
+				if gcPercent < 0 && heapBelowLimit() && growHeap() {
+					// The GC has been disabled with debug.SetGCPercent(-1),
+					// so grow the heap instead of collecting garbage until
+					// the memory limit has been reached.
+				} else {
+					// The entire heap has been searched for free memory, but
+					// none could be found. Run a garbage collection cycle to
+					// reclaim free memory and try again.
+					heapScanCount = 2
+					freeBytes := runGC()
+					paceHeap(freeBytes)
 				}
+			} else if gcCompacting && heapScanCount == 2 {
+				// Even after garbage collection, no free memory could be
+				// found. There may be enough free memory, but too fragmented
+				// to fit this allocation. Move objects together and try again.
+				heapScanCount = 3
+				compactHeap()
+				index = nextAlloc
+				numFreeBlocks = 0
 			} else {
 				// Even after garbage collection, no free memory could be found.
 				// Try to increase heap size.
 				if growHeap() {
 					// Success, the heap was increased in size. Try again with a
 					// larger heap.
+				} else if !calledOutOfMemoryHook && callOutOfMemoryHook(size, &gcLock) {
+					// The program may have dropped references to free some
+					// memory. Run a GC cycle again and try once more.
+					// The heap may have changed while the hook was running
+					// without holding the GC lock, so start searching again.
+					calledOutOfMemoryHook = true
+					heapScanCount = 1
+					index = nextAlloc
+					numFreeBlocks = 0
 				} else {
 					// Unfortunately the heap could not be increased. This
 					// happens on baremetal systems for example (where all
@@ -394,7 +443,11 @@ func alloc(size uintptr, layout unsafe.Pointer) unsafe.Pointer {
 			}
 
 			// Set the following blocks as being allocated.
-			thisAlloc.setState(blockStateHead)
+			if gcIncremental {
+				thisAlloc.setState(gcAllocState(thisAlloc, nextAlloc))
+			} else {
+				thisAlloc.setState(blockStateHead)
+			}
 			for i := thisAlloc + 1; i != nextAlloc; i++ {
 				i.setState(blockStateTail)
 			}
@@ -410,7 +463,11 @@ func alloc(size uintptr, layout unsafe.Pointer) unsafe.Pointer {
 				pointer = unsafe.Add(pointer, add)
 				size -= add
 			}
+			size -= asanRedzoneSize
 			memzero(pointer, size)
+			asanAllocated(thisAlloc.address(), uintptr(pointer), size, nextAlloc.address())
+			memProfileAlloc(thisAlloc, size, uintptr(returnAddress(0)))
+			gcLock.Unlock()
 			return pointer
 		}
 	}
@@ -426,8 +483,9 @@ func realloc(ptr unsafe.Pointer, size uintptr) unsafe.Pointer {
 
 	// this might be a few bytes longer than the original size of
 	// ptr, because we align to full blocks of size bytesPerBlock
-	oldSize := endOfTailAddress - ptrAddress
+	oldSize := endOfTailAddress - ptrAddress - asanRedzoneSize
 	if size <= oldSize {
+		asanAllocated(ptrAddress, ptrAddress, size, endOfTailAddress)
 		return ptr
 	}
 
@@ -442,22 +500,84 @@ func free(ptr unsafe.Pointer) {
 	// TODO: free blocks on request, when the compiler knows they're unused.
 }
 
-// GC performs a garbage collection cycle.
+// GC performs a garbage collection cycle. With -gc=compacting, it also
+// compacts the heap afterwards, so it takes longer than with the other GCs.
 func GC() {
+	gcLock.Lock()
+	gcNumForcedGC++
 	runGC()
+	if gcCompacting {
+		compactHeap()
+	}
+	gcLock.Unlock()
+}
+
+// paceHeap grows the heap after a GC cycle so that the amount of free memory
+// is at least the percentage of the live heap set with debug.SetGCPercent
+// (100% by default), as long as the heap stays below the memory limit set with
+// debug.SetMemoryLimit. A larger heap means garbage collection is needed less
+// often.
+func paceHeap(freeBytes uintptr) {
+	if gcPercent < 0 {
+		return
+	}
+	heapSize := uintptr(metadataStart) - heapStart
+	live := uint64(heapSize - freeBytes)
+	wantFree := live * uint64(gcPercent) / 100
+	for uint64(freeBytes) < wantFree && heapBelowLimit() {
+		if !growHeap() {
+			break
+		}
+		newHeapSize := uintptr(metadataStart) - heapStart
+		freeBytes += newHeapSize - heapSize
+		heapSize = newHeapSize
+	}
+}
+
+// heapBelowLimit returns whether the heap is smaller than the memory limit set
+// with debug.SetMemoryLimit, and may therefore grow. Growing the heap may still
+// exceed the limit by a bit.
+func heapBelowLimit() bool {
+	return uint64(heapEnd-heapStart) < uint64(gcMemoryLimit)
 }
 
 // runGC performs a garbage collection cycle. It is the internal implementation
 // of the runtime.GC() function. The difference is that it returns the number of
 // free bytes in the heap after the GC is finished.
+// The heap lock must be held while calling runGC.
 func runGC() (freeBytes uintptr) {
+	if gcIncremental {
+		return gcIncrementalRunGC()
+	}
 	if gcDebug {
 		println("running collection cycle...")
 	}
+	traceGCStart()
 
 	// Mark phase: mark all reachable objects, recursively.
-	markStack()
-	findGlobals(markRoots)
+	markAll()
+
+	// Record which sampled objects in the memory profile are about to be freed.
+	memProfileSweep()
+
+	// Sweep phase: free all non-marked objects and unmark marked objects for
+	// the next collection cycle.
+	freeBytes = sweep()
+	gcNumGC++
+	traceGCDone((gcTotalBlocks - gcFreedBlocks) * uint64(bytesPerBlock))
+
+	// Show how much has been sweeped, for debugging.
+	if gcDebug {
+		dumpHeap()
+	}
+
+	return
+}
+
+// markAll marks all objects that are reachable from the roots (globals,
+// goroutine stacks, etc).
+func markAll() {
+	gcMarkReachable()
 
 	if baremetal && hasScheduler {
 		// Channel operations in interrupts may move task pointers around while we are marking.
@@ -490,17 +610,6 @@ func runGC() (freeBytes uintptr) {
 	} else {
 		finishMark()
 	}
-
-	// Sweep phase: free all non-marked objects and unmark marked objects for
-	// the next collection cycle.
-	freeBytes = sweep()
-
-	// Show how much has been sweeped, for debugging.
-	if gcDebug {
-		dumpHeap()
-	}
-
-	return
 }
 
 // markRoots reads all pointers from start to end (exclusive) and if they look
@@ -618,6 +727,11 @@ func startMark(root gcBlock) {
 
 // finishMark finishes the marking process by processing all stack overflows.
 func finishMark() {
+	if gcIncremental {
+		// Process all objects that are still waiting to be scanned.
+		gcMarkWork(0)
+		return
+	}
 	for stackOverflow {
 		// Re-mark all blocks.
 		stackOverflow = false
@@ -648,7 +762,16 @@ func markRoot(addr, root uintptr) {
 			if gcDebug {
 				println("found unmarked pointer", root, "at address", addr)
 			}
-			startMark(head)
+			if gcIncremental {
+				// Scan the object later, in a bounded GC step.
+				gcGrey(head)
+			} else if gcPinning {
+				// Looking for objects that must not be moved (see
+				// compactHeap), not for reachable objects.
+				head.setState(blockStateMark)
+			} else {
+				startMark(head)
+			}
 		}
 	}
 }
@@ -656,13 +779,23 @@ func markRoot(addr, root uintptr) {
 // Sweep goes through all memory and frees unmarked memory.
 // It returns how many bytes are free in the heap after the sweep.
 func sweep() (freeBytes uintptr) {
-	freeCurrentObject := false
+	freeBytes, _ = sweepBlocks(0, endBlock, false)
+	return
+}
+
+// sweepBlocks sweeps the blocks from start to end (exclusive). The
+// freeCurrentObject parameter indicates whether the first block, if it is a
+// tail, belongs to an object that is being freed. It returns the number of
+// bytes that are free in this range after the sweep, and whether the object at
+// the end of the range is being freed.
+func sweepBlocks(start, end gcBlock, freeCurrentObject bool) (freeBytes uintptr, freeingObject bool) {
 	var freed uint64
-	for block := gcBlock(0); block < endBlock; block++ {
+	for block := start; block < end; block++ {
 		switch block.state() {
 		case blockStateHead:
 			// Unmarked head. Free it, including all tail blocks following it.
 			block.markFree()
+			asanFreed(block.address(), bytesPerBlock)
 			freeCurrentObject = true
 			gcFrees++
 			freed++
@@ -671,6 +804,7 @@ func sweep() (freeBytes uintptr) {
 				// This is a tail object following an unmarked head.
 				// Free it now.
 				block.markFree()
+				asanFreed(block.address(), bytesPerBlock)
 				freed++
 			}
 		case blockStateMark:
@@ -681,11 +815,12 @@ func sweep() (freeBytes uintptr) {
 			freeCurrentObject = false
 		case blockStateFree:
 			freeBytes += bytesPerBlock
+			freeCurrentObject = false
 		}
 	}
 	gcFreedBlocks += freed
 	freeBytes += uintptr(freed) * bytesPerBlock
-	return
+	return freeBytes, freeCurrentObject
 }
 
 // dumpHeap can be used for debugging purposes. It dumps the state of each heap
@@ -714,6 +849,7 @@ func dumpHeap() {
 // The returned memory statistics are up to date as of the
 // call to ReadMemStats. This would not do GC implicitly for you.
 func ReadMemStats(m *MemStats) {
+	gcLock.Lock()
 	m.HeapIdle = 0
 	m.HeapInuse = 0
 	for block := gcBlock(0); block < endBlock; block++ {
@@ -733,6 +869,9 @@ func ReadMemStats(m *MemStats) {
 	m.Sys = uint64(heapEnd - heapStart)
 	m.HeapAlloc = (gcTotalBlocks - gcFreedBlocks) * uint64(bytesPerBlock)
 	m.Alloc = m.HeapAlloc
+	m.NumGC = gcNumGC
+	m.NumForcedGC = gcNumForcedGC
+	gcLock.Unlock()
 }
 
 func SetFinalizer(obj interface{}, finalizer interface{}) {
@@ -0,0 +1,256 @@
+//go:build gc.compacting
+
+package runtime
+
+// This file implements heap compaction for the precise block-based GC, selected
+// with -gc=compacting. Long running programs that allocate objects of varying
+// sizes can fragment the heap: there is enough free memory in total, but no
+// free range is large enough for a big allocation. Compaction fixes this by
+// sliding objects towards the start of the heap, so that all free memory ends
+// up in one range at the end.
+//
+// Only objects of which all references are known precisely can be moved,
+// because the references to a moved object must be updated. This is a
+// "mostly-copying" collector: everything that is referenced conservatively is
+// pinned, and stays where it is. Specifically, these objects are pinned:
+//
+//   - Objects referenced from stacks, registers and globals. These roots are
+//     scanned conservatively, and they also include pointers kept by C code in
+//     globals or on the stack (see below).
+//   - Objects allocated without a known layout (like goroutine stacks, map
+//     buckets, and memory allocated by C code through malloc), and all objects
+//     they reference. They may contain pointers to themselves, and they're
+//     scanned conservatively. The compiler also leaves the layout of objects
+//     that contain an interface unknown: the value word of an interface may
+//     hold either a pointer or an integer, which must not be changed.
+//   - Objects pinned using runtime.Pinner. Use this for memory that is used
+//     outside of the view of the GC, like a buffer passed to a DMA peripheral
+//     or a pointer that C code keeps after a call has returned.
+//   - Objects sampled by the memory profiler.
+//
+// Objects that are pinned act as barriers: objects are only moved into the free
+// space before the next pinned object.
+//
+// Interrupts are disabled while the heap is being compacted, so that interrupt
+// handlers never see objects that are half moved. Note that pointers converted
+// to uintptr are not updated when an object moves, as is also the case for
+// goroutine stacks in the standard Go implementation.
+//
+// Compaction happens when an allocation fails even after a GC cycle, and on
+// every call to runtime.GC. The latter makes it possible to defragment the heap
+// at a convenient time, for example before a phase of the program that needs
+// large allocations.
+//
+// Compaction is done in three passes over the heap, similar to the LISP 2
+// algorithm, after the objects to be pinned have been marked:
+//  1. Determine the new location of each object. Instead of storing the new
+//     location in the object (which would need an extra word in every object),
+//     only the new location of the first object in each chunk of the heap is
+//     stored in a small table. The new location of other objects is calculated
+//     from this table when needed.
+//  2. Update all pointers in precisely scanned objects.
+//  3. Move the objects to their new location.
+
+import (
+	"runtime/interrupt"
+	"unsafe"
+)
+
+const gcCompacting = true
+
+// Number of entries in the forwarding table. Every entry covers a chunk of the
+// heap, so a larger table makes finding the new location of an object faster
+// but costs more RAM.
+const gcCompactTableSize = 64
+
+var (
+	// Set while looking for objects that must be pinned: markRoot then only
+	// marks the objects it finds, without marking the objects they reference.
+	gcPinning bool
+
+	// The number of blocks covered by each entry in gcCompactTable.
+	gcCompactChunk gcBlock
+
+	// New location of the first object that starts in each chunk.
+	gcCompactTable [gcCompactTableSize]gcBlock
+)
+
+// compactHeap moves all objects that are not pinned towards the start of the
+// heap. It must be called after a GC cycle, with the heap lock held.
+func compactHeap() {
+	if gcDebug {
+		println("compacting heap...")
+	}
+	mask := interrupt.Disable()
+
+	// Mark all objects that can't be moved. After this, heads of movable
+	// objects are in the "head" state and pinned objects in the "mark" state.
+	pinObjects()
+
+	// Pass 1: determine the new location of every object.
+	gcCompactChunk = (endBlock + gcCompactTableSize - 1) / gcCompactTableSize
+	if gcCompactChunk == 0 {
+		gcCompactChunk = 1
+	}
+	dest := gcBlock(0)
+	nextChunk := gcBlock(0)
+	for block := gcBlock(0); block < endBlock; {
+		state := block.state()
+		if state != blockStateHead && state != blockStateMark {
+			block++
+			continue
+		}
+		if chunk := block / gcCompactChunk; chunk >= nextChunk {
+			gcCompactTable[chunk] = dest
+			nextChunk = chunk + 1
+		}
+		next := block.findNext()
+		if state == blockStateHead {
+			dest += next - block
+		} else {
+			// Pinned object. Movable objects after it will be moved to the
+			// free space right after this object.
+			dest = next
+		}
+		block = next
+	}
+
+	// Pass 2: update all pointers to objects that will move.
+	for block := gcBlock(0); block < endBlock; block++ {
+		state := block.state()
+		if state != blockStateHead && state != blockStateMark {
+			continue
+		}
+		if *(*uintptr)(block.pointer()) == 0 {
+			// Unknown layout. This object only references pinned objects.
+			continue
+		}
+		scanner := newGCObjectScanner(block)
+		if scanner.pointerFree() {
+			continue
+		}
+		// The first word of the object is the pointer layout value.
+		start := block.address() + align(unsafe.Sizeof(uintptr(0)))
+		end := block.findNext().address()
+		for addr := start; addr != end; addr += unsafe.Alignof(addr) {
+			word := *(*uintptr)(unsafe.Pointer(addr))
+			if !scanner.nextIsPointer(word, block.address(), addr) {
+				continue
+			}
+			referenced := blockFromAddr(word)
+			if referenced.state() == blockStateFree {
+				continue
+			}
+			head := referenced.findHead()
+			if head.state() != blockStateHead {
+				// Pinned object.
+				continue
+			}
+			if newHead := forwardBlock(head); newHead != head {
+				*(*uintptr)(unsafe.Pointer(addr)) = word - uintptr(head-newHead)*bytesPerBlock
+			}
+		}
+	}
+
+	// Pass 3: move all objects to their new location, and unmark the pinned
+	// objects.
+	dest = 0
+	for block := gcBlock(0); block < endBlock; {
+		switch block.state() {
+		case blockStateHead:
+			next := block.findNext()
+			size := next - block
+			if dest != block {
+				memmove(dest.pointer(), block.pointer(), uintptr(size)*bytesPerBlock)
+				for b := block; b != next; b++ {
+					b.clearState()
+				}
+				dest.setState(blockStateHead)
+				for b := dest + 1; b != dest+size; b++ {
+					b.setState(blockStateTail)
+				}
+				if gcDebug {
+					println("moved object:", block, "->", dest, int(size))
+				}
+			}
+			dest += size
+			block = next
+		case blockStateMark:
+			next := block.findNext()
+			block.unmark()
+			dest = next
+			block = next
+		default:
+			block++
+		}
+	}
+
+	// Start allocating from the large free range at the end of the heap.
+	nextAlloc = dest
+	if nextAlloc == endBlock {
+		nextAlloc = 0
+	}
+
+	interrupt.Restore(mask)
+
+	if gcDebug {
+		dumpHeap()
+	}
+}
+
+// pinObjects marks all objects that must not be moved by compactHeap.
+func pinObjects() {
+	// Pin all objects that are referenced from roots.
+	gcPinning = true
+	gcMarkReachable()
+
+	// Pin all objects without a known layout, and all objects they reference.
+	for block := gcBlock(0); block < endBlock; block++ {
+		state := block.state()
+		if state != blockStateHead && state != blockStateMark {
+			continue
+		}
+		if *(*uintptr)(block.pointer()) != 0 {
+			continue
+		}
+		if state == blockStateHead {
+			block.setState(blockStateMark)
+		}
+		markRoots(block.address(), block.findNext().address())
+	}
+	gcPinning = false
+
+	memProfilePinObjects()
+}
+
+// forwardBlock returns the new location of a movable object, as determined in
+// the first pass of compactHeap.
+func forwardBlock(head gcBlock) gcBlock {
+	chunk := head / gcCompactChunk
+	dest := gcCompactTable[chunk]
+	block := chunk * gcCompactChunk
+	for {
+		switch block.state() {
+		case blockStateHead:
+			if block == head {
+				return dest
+			}
+			next := block.findNext()
+			dest += next - block
+			block = next
+		case blockStateMark:
+			next := block.findNext()
+			dest = next
+			block = next
+		default:
+			block++
+		}
+	}
+}
+
+// clearState sets the block state to free. Unlike markFree, it doesn't clear
+// the contents of the block when gcAsserts is set.
+func (b gcBlock) clearState() {
+	stateBytePtr := (*uint8)(unsafe.Add(metadataStart, b/blocksPerStateByte))
+	*stateBytePtr &^= uint8(blockStateMask << ((b % blocksPerStateByte) * stateBits))
+}
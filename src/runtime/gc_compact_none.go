@@ -0,0 +1,12 @@
+//go:build gc.conservative || gc.precise || gc.incremental
+
+package runtime
+
+// Stubs for heap compaction, which is only done with -gc=compacting. See
+// gc_compact.go.
+
+const gcCompacting = false
+
+const gcPinning = false
+
+func compactHeap() {}
@@ -1,8 +1,8 @@
-//go:build gc.conservative
+//go:build gc.conservative || gc.incremental
 
 // This implements the block-based heap as a fully conservative GC. No tracking
 // of pointers is done, every word in an object is considered live if it looks
-// like a pointer.
+// like a pointer. The incremental GC (gc_incremental.go) is also conservative.
 
 package runtime
 
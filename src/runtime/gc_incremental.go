@@ -0,0 +1,343 @@
+//go:build gc.incremental
+
+package runtime
+
+// This file implements the incremental mode of the block-based GC in
+// gc_blocks.go, selected with -gc=incremental. Instead of doing a complete
+// mark/sweep cycle at once when the heap is full, the work is split in small
+// steps that are done on every allocation. A single step takes at most the
+// pause budget (set with -gc-pause), which avoids long pauses in programs that
+// must respond quickly, for example because they drive hardware.
+//
+// Marking uses the usual tri-color abstraction. White objects are unmarked.
+// Grey objects are marked but still need to be scanned: they're on the grey
+// stack or will be found again after a grey stack overflow. Black objects are
+// marked and have been scanned. Because the program continues to run while the
+// heap is being marked, the compiler inserts a call to gcWriteBarrier after
+// every pointer store that may go to the heap. When the object that was
+// modified is already marked, it is made grey again so that it will be scanned
+// again (a Steele-style write barrier). Stores to globals and stacks don't
+// have a write barrier, instead these roots are scanned again at the end of
+// the mark phase. This last step (mark termination) is not bounded by the
+// pause budget, but it normally only needs to scan the roots and the objects
+// that were modified since they were last scanned.
+//
+// Objects allocated during the mark phase are white: if they are reachable,
+// they will be found through the write barrier or by scanning the roots again.
+// Objects allocated during the sweep phase are marked if they are in the part
+// of the heap that hasn't been swept yet, so that they won't be freed.
+
+import (
+	"runtime/interrupt"
+	"unsafe"
+)
+
+const gcIncremental = true
+
+const (
+	gcGreyStackSize = 64                             // number of grey blocks to queue before forcing a rescan
+	gcScanChunk     = 32 * unsafe.Sizeof(uintptr(0)) // number of bytes to scan between deadline checks
+	gcSweepChunk    = 256                            // number of blocks to sweep between deadline checks
+)
+
+// Phases of an incremental GC cycle.
+const (
+	gcPhaseIdle uint8 = iota
+	gcPhaseMark
+	gcPhaseSweep
+)
+
+var (
+	gcPhase          uint8
+	gcTrigger        uintptr // start a new cycle when this many bytes are in use
+	gcGreyStack      [gcGreyStackSize]gcBlock
+	gcGreyLen        uintptr
+	gcRescanning     bool    // rescanning all marked blocks after a grey stack overflow
+	gcRescanBlock    gcBlock // next block to look at while rescanning
+	gcScanAddr       uintptr // next word to scan in the current object
+	gcScanEnd        uintptr // end of the current object
+	gcSweepBlock     gcBlock // next block to sweep
+	gcSweepFreeing   bool    // whether the block before gcSweepBlock was freed
+	gcSweepFreeBytes uintptr // free bytes found in this sweep phase so far
+	gcLastFreeBytes  uintptr // free bytes found by the last completed cycle
+)
+
+// Compiler intrinsic.
+// Returns the maximum time in nanoseconds a single GC step should take. It can
+// be changed using the -gc-pause compiler flag.
+func gcPauseBudget() int64
+
+// gcWriteBarrier is called by the compiler after a pointer has been stored at
+// the given address. If the object that contains this address has already been
+// marked in the current GC cycle, it needs to be scanned again.
+//
+// This function may be called from interrupts.
+func gcWriteBarrier(ptr unsafe.Pointer) {
+	addr := uintptr(ptr)
+	if gcPhase != gcPhaseMark || !isOnHeap(addr) {
+		return
+	}
+	block := blockFromAddr(addr)
+	if block.state() == blockStateFree {
+		// Not an allocated object.
+		return
+	}
+	head := block.findHead()
+	if head.state() == blockStateMark {
+		gcPush(head)
+	}
+}
+
+// gcGrey marks the given block and queues it to be scanned.
+func gcGrey(block gcBlock) {
+	if gcDebug {
+		println("greying block:", block)
+	}
+	block.setState(blockStateMark)
+	gcPush(block)
+}
+
+// gcPush queues a marked block to be scanned. If the grey stack is full, all
+// marked blocks will be scanned again once the stack is empty.
+func gcPush(block gcBlock) {
+	i := interrupt.Disable()
+	if gcGreyLen == uintptr(len(gcGreyStack)) {
+		stackOverflow = true
+		if gcDebug {
+			println("gc grey stack overflowed")
+		}
+	} else {
+		gcGreyStack[gcGreyLen] = block
+		gcGreyLen++
+	}
+	interrupt.Restore(i)
+}
+
+// gcNextGrey returns the next block that must be scanned, and false if there
+// are no more blocks to scan.
+func gcNextGrey() (gcBlock, bool) {
+	for {
+		i := interrupt.Disable()
+		if gcGreyLen != 0 {
+			gcGreyLen--
+			block := gcGreyStack[gcGreyLen]
+			interrupt.Restore(i)
+			return block, true
+		}
+		if !gcRescanning && stackOverflow {
+			// Some marked blocks could not be pushed on the grey stack.
+			// Look at all marked blocks again.
+			stackOverflow = false
+			gcRescanning = true
+			gcRescanBlock = 0
+		}
+		interrupt.Restore(i)
+
+		if !gcRescanning {
+			return 0, false
+		}
+		for gcRescanBlock < endBlock {
+			block := gcRescanBlock
+			gcRescanBlock++
+			if block.state() == blockStateMark {
+				return block, true
+			}
+		}
+		gcRescanning = false
+	}
+}
+
+// gcMarkDone returns whether there is no more marking work to do.
+func gcMarkDone() bool {
+	return gcGreyLen == 0 && !stackOverflow && !gcRescanning && gcScanAddr == gcScanEnd
+}
+
+// gcDeadlinePassed returns whether the deadline of the current GC step has
+// passed. A deadline of zero means there is no deadline.
+func gcDeadlinePassed(deadline timeUnit) bool {
+	return deadline != 0 && ticks() >= deadline
+}
+
+// gcIncrementalStep does a bounded amount of GC work. It starts a new GC cycle
+// when enough memory is in use. It is called on every allocation, with the
+// heap lock held.
+func gcIncrementalStep() {
+	if gcPhase == gcPhaseIdle {
+		if gcTrigger == 0 {
+			gcTrigger = (uintptr(metadataStart) - heapStart) / 2
+		}
+		if gcPercent < 0 || gcLiveBytes() < gcTrigger {
+			// Not yet time for a new cycle, or the GC has been disabled
+			// with debug.SetGCPercent(-1) and will only run when the heap
+			// is full.
+			return
+		}
+		gcStartCycle()
+	}
+
+	deadline := ticks() + nanosecondsToTicks(gcPauseBudget())
+	if gcPhase == gcPhaseMark {
+		if !gcMarkWork(deadline) {
+			return
+		}
+		gcTerminateMark()
+	}
+	if gcPhase == gcPhaseSweep {
+		gcSweepWork(deadline)
+	}
+}
+
+// gcIncrementalRunGC finishes the GC cycle in progress (if any) and then does
+// a complete GC cycle without pausing, for runtime.GC and for when the heap is
+// full. It returns the number of free bytes in the heap afterwards.
+func gcIncrementalRunGC() uintptr {
+	gcFinishCycle()
+	gcStartCycle()
+	gcFinishCycle()
+	return gcLastFreeBytes
+}
+
+// gcFinishCycle finishes the current GC cycle without pausing.
+func gcFinishCycle() {
+	if gcPhase == gcPhaseMark {
+		gcMarkWork(0)
+		gcTerminateMark()
+	}
+	if gcPhase == gcPhaseSweep {
+		gcSweepWork(0)
+	}
+}
+
+// gcLiveBytes returns the number of bytes that are currently allocated on the
+// heap, including garbage that hasn't been swept yet.
+func gcLiveBytes() uintptr {
+	return uintptr(gcTotalBlocks-gcFreedBlocks) * bytesPerBlock
+}
+
+// gcStartCycle starts the mark phase by marking all roots grey.
+func gcStartCycle() {
+	if gcDebug {
+		println("starting incremental collection cycle...")
+	}
+	traceGCStart()
+	gcPhase = gcPhaseMark
+	gcMarkReachable()
+}
+
+// gcMarkWork scans grey objects until there are none left, or until the
+// deadline has passed. A deadline of zero means there is no deadline. It
+// returns true if there is no more marking work to do.
+func gcMarkWork(deadline timeUnit) bool {
+	for {
+		if gcScanAddr == gcScanEnd {
+			// Start scanning the next grey object.
+			block, ok := gcNextGrey()
+			if !ok {
+				return true
+			}
+			gcScanAddr, gcScanEnd = block.address(), block.findNext().address()
+		}
+
+		if gcScanEnd-gcScanAddr > gcScanChunk {
+			// Scan part of a large object. Include the bytes of a pointer
+			// that starts in this chunk but ends in the next (on platforms
+			// where the pointer alignment is smaller than the pointer size).
+			end := gcScanAddr + gcScanChunk
+			markRoots(gcScanAddr, end+unsafe.Sizeof(end)-unsafe.Alignof(end))
+			gcScanAddr = end
+		} else {
+			markRoots(gcScanAddr, gcScanEnd)
+			gcScanAddr = gcScanEnd
+		}
+
+		if gcDeadlinePassed(deadline) {
+			return gcMarkDone()
+		}
+	}
+}
+
+// gcTerminateMark finishes the mark phase. The roots are scanned again, since
+// they don't have a write barrier, and all remaining grey objects are scanned.
+// After this, the sweep phase starts.
+func gcTerminateMark() {
+	markAll()
+
+	// Interrupts may still make objects grey through the write barrier, so
+	// switch to the sweep phase with interrupts disabled once everything has
+	// been marked.
+	for {
+		i := interrupt.Disable()
+		if gcMarkDone() {
+			gcPhase = gcPhaseSweep
+			interrupt.Restore(i)
+			break
+		}
+		interrupt.Restore(i)
+		gcMarkWork(0)
+	}
+
+	// Record which sampled objects in the memory profile are about to be freed.
+	memProfileSweep()
+
+	gcSweepBlock = 0
+	gcSweepFreeing = false
+	gcSweepFreeBytes = 0
+}
+
+// gcSweepWork sweeps the heap until the end of the heap has been reached, or
+// until the deadline has passed. A deadline of zero means there is no
+// deadline. The GC cycle is finished once the whole heap has been swept.
+func gcSweepWork(deadline timeUnit) {
+	for gcSweepBlock < endBlock {
+		end := gcSweepBlock + gcSweepChunk
+		if end > endBlock {
+			end = endBlock
+		}
+		freeBytes, freeing := sweepBlocks(gcSweepBlock, end, gcSweepFreeing)
+		gcSweepFreeBytes += freeBytes
+		gcSweepBlock = end
+		gcSweepFreeing = freeing
+		if gcDeadlinePassed(deadline) {
+			return
+		}
+	}
+
+	// The cycle is finished. Start the next cycle once half of the memory that
+	// is currently free has been allocated, or earlier when the heap has grown
+	// by the percentage set with debug.SetGCPercent.
+	gcPhase = gcPhaseIdle
+	gcNumGC++
+	gcLastFreeBytes = gcSweepFreeBytes
+	live := gcLiveBytes()
+	gcTrigger = live + (uintptr(metadataStart)-heapStart-live)/2
+	if gcPercent >= 0 {
+		if growth := uint64(live) * uint64(gcPercent) / 100; growth < uint64(gcTrigger-live) {
+			gcTrigger = live + uintptr(growth)
+		}
+	}
+	traceGCDone(uint64(live))
+
+	// Show how much has been sweeped, for debugging.
+	if gcDebug {
+		dumpHeap()
+	}
+}
+
+// gcAllocState returns the state of the head block of a new allocation from
+// start to end (exclusive).
+func gcAllocState(start, end gcBlock) blockState {
+	if gcPhase != gcPhaseSweep {
+		return blockStateHead
+	}
+	if start >= gcSweepBlock {
+		// This part of the heap hasn't been swept yet. Mark the object, so
+		// that it won't be freed.
+		return blockStateMark
+	}
+	if end >= gcSweepBlock {
+		// The object ends at or after the block that the sweeper will look at
+		// next, so its tail blocks must not be freed.
+		gcSweepFreeing = false
+	}
+	return blockStateHead
+}
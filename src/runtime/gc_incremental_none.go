@@ -0,0 +1,24 @@
+//go:build gc.conservative || gc.precise || gc.compacting
+
+package runtime
+
+// Stubs for the incremental GC, which is only used with -gc=incremental. See
+// gc_incremental.go.
+
+const gcIncremental = false
+
+func gcIncrementalStep() {}
+
+func gcIncrementalRunGC() uintptr {
+	return 0
+}
+
+func gcGrey(block gcBlock) {}
+
+func gcMarkWork(deadline timeUnit) bool {
+	return true
+}
+
+func gcAllocState(start, end gcBlock) blockState {
+	return blockStateHead
+}
@@ -46,6 +46,9 @@ func alloc(size uintptr, layout unsafe.Pointer) unsafe.Pointer {
 			continue
 		}
 		// Failed to make the heap bigger, so we must really be out of memory.
+		// Memory is never freed, so there is no point in trying again after
+		// the hook has been called.
+		callOutOfMemoryHook(size, &gcLock)
 		runtimePanic("out of memory")
 	}
 	gcLock.Unlock()
@@ -0,0 +1,66 @@
+package runtime
+
+// Garbage collector settings that can be changed using the runtime/debug
+// package. They're only used by the block-based GCs (gc_blocks.go), the other
+// GCs only store them.
+
+import "internal/task"
+
+const maxInt64 = 1<<63 - 1
+
+var (
+	// Amount of free memory to aim for after a GC cycle, as a percentage of
+	// the live heap. A negative value means the GC only runs when the heap
+	// can't grow anymore.
+	gcPercent int32 = 100
+
+	// Soft limit on the size of the heap in bytes. The heap isn't grown beyond
+	// this limit, unless an allocation would fail otherwise.
+	gcMemoryLimit int64 = maxInt64
+
+	// Function to call before panicking with an out of memory error.
+	gcOutOfMemoryHook func(size uintptr)
+)
+
+//go:linkname debug_setGCPercent runtime/debug.setGCPercent
+func debug_setGCPercent(percent int32) int32 {
+	if percent < 0 {
+		percent = -1
+	}
+	old := gcPercent
+	gcPercent = percent
+	return old
+}
+
+//go:linkname debug_setMemoryLimit runtime/debug.setMemoryLimit
+func debug_setMemoryLimit(limit int64) int64 {
+	old := gcMemoryLimit
+	if limit >= 0 {
+		gcMemoryLimit = limit
+	}
+	return old
+}
+
+//go:linkname debug_setOutOfMemoryHook runtime/debug.setOutOfMemoryHook
+func debug_setOutOfMemoryHook(hook func(size uintptr)) {
+	gcOutOfMemoryHook = hook
+}
+
+// callOutOfMemoryHook calls the hook set with debug.SetOutOfMemoryHook, if
+// there is one. It returns whether a hook was called, after which the
+// allocation can be tried again. The hook is not called recursively when it
+// runs out of memory itself.
+// It must be called with the GC lock held. The lock is released while the hook
+// runs, so that the hook can allocate memory.
+func callOutOfMemoryHook(size uintptr, lock *task.PMutex) bool {
+	hook := gcOutOfMemoryHook
+	if hook == nil {
+		return false
+	}
+	gcOutOfMemoryHook = nil
+	lock.Unlock()
+	hook(size)
+	lock.Lock()
+	gcOutOfMemoryHook = hook
+	return true
+}
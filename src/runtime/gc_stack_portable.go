@@ -1,4 +1,4 @@
-//go:build (gc.conservative || gc.custom || gc.precise) && tinygo.wasm
+//go:build (gc.conservative || gc.custom || gc.precise || gc.incremental || gc.compacting) && tinygo.wasm
 
 package runtime
 
@@ -8,6 +8,12 @@ import (
 	"unsafe"
 )
 
+// gcMarkReachable marks all objects reachable from the stack and from globals.
+func gcMarkReachable() {
+	markStack()
+	findGlobals(markRoots)
+}
+
 //go:extern runtime.stackChainStart
 var stackChainStart *stackChainObject
 
@@ -47,6 +53,11 @@ func markStack() {
 
 	if task.OnSystemStack() {
 		markRoots(getCurrentStackPointer(), stackTop)
+	} else {
+		// The goroutine stack is heap allocated, and stores to it don't have
+		// a write barrier. With the incremental GC, it may have been scanned
+		// earlier in this GC cycle so it must be scanned again.
+		gcWriteBarrier(unsafe.Pointer(getCurrentStackPointer()))
 	}
 }
 
@@ -1,8 +1,17 @@
-//go:build (gc.conservative || gc.precise) && !tinygo.wasm
+//go:build (gc.conservative || gc.precise || gc.incremental || gc.compacting) && !tinygo.wasm && !scheduler.threads
 
 package runtime
 
-import "internal/task"
+import (
+	"internal/task"
+	"unsafe"
+)
+
+// gcMarkReachable marks all objects reachable from the stack and from globals.
+func gcMarkReachable() {
+	markStack()
+	findGlobals(markRoots)
+}
 
 // markStack marks all root pointers found on the stack.
 //
@@ -35,5 +44,16 @@ func scanstack(sp uintptr) {
 		// This is a goroutine stack.
 		// It is an allocation, so scan it as if it were a value in a global.
 		markRoot(0, sp)
+		if gcPinning {
+			// The registers that were just pushed onto the stack won't be
+			// there anymore when the rest of the stack is looked at, so pin
+			// the objects they point to now.
+			markRoots(sp, blockFromAddr(sp).findHead().findNext().address())
+		}
+		if gcIncremental {
+			// The stack may have been scanned earlier in this GC cycle, and
+			// stores to the stack don't have a write barrier. Scan it again.
+			gcWriteBarrier(unsafe.Pointer(sp))
+		}
 	}
 }
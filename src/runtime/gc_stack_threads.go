@@ -0,0 +1,26 @@
+//go:build (gc.conservative || gc.precise) && scheduler.threads
+
+package runtime
+
+import "internal/task"
+
+// gcMarkReachable marks all objects reachable from the stack and from globals.
+// All other threads are stopped while their stacks are scanned.
+func gcMarkReachable() {
+	task.GCScan()
+}
+
+// gcScanGlobals scans all globals. It is called from task.GCScan while the
+// world is stopped.
+func gcScanGlobals() {
+	findGlobals(markRoots)
+}
+
+// scanstack is called by tinygo_scanCurrentStack (implemented in assembly)
+// after pushing all registers onto the stack. It scans the stack of the current
+// thread.
+//
+//go:export tinygo_scanstack
+func scanstack(sp uintptr) {
+	markRoots(sp, task.StackTop())
+}
@@ -0,0 +1,10 @@
+//go:build !gc.incremental
+
+package runtime
+
+import "unsafe"
+
+// gcWriteBarrier is only needed for the incremental GC. The compiler doesn't
+// emit calls to it for other GCs, but the runtime and some other packages call
+// it after copying pointers in memory.
+func gcWriteBarrier(ptr unsafe.Pointer) {}
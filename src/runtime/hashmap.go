@@ -4,6 +4,12 @@ package runtime
 // It is very roughly based on the implementation of the Go hashmap:
 //
 //     https://golang.org/src/runtime/map.go
+//
+// Like the Go hashmap, the map grows incrementally: when it gets too full, a
+// new bucket array of twice the size is allocated and entries are moved
+// (evacuated) from the old buckets to the new buckets a few buckets at a time,
+// on every insert and delete. This way, a single insert never needs to rehash
+// the entire map, which could take a long time on a microcontroller.
 
 import (
 	"reflect"
@@ -14,6 +20,8 @@ import (
 // The underlying hashmap structure for Go.
 type hashmap struct {
 	buckets    unsafe.Pointer // pointer to array of buckets
+	oldbuckets unsafe.Pointer // previous array of buckets while growing, nil otherwise
+	nevacuate  uintptr        // next bucket in oldbuckets to evacuate
 	seed       uintptr
 	count      uintptr
 	keySize    uintptr // maybe this can store the key type as well? E.g. keysize == 5 means string?
@@ -36,25 +44,41 @@ type hashmapBucket struct {
 
 type hashmapIterator struct {
 	buckets      unsafe.Pointer // pointer to array of hashapBuckets
+	oldbuckets   unsafe.Pointer // old buckets if the map was growing when the iterator was started
 	numBuckets   uintptr        // length of buckets array
 	bucketNumber uintptr        // current index into buckets array
 	startBucket  uintptr        // starting location for iterator
+	checkBucket  uintptr        // bucket number of the entries to return from an old bucket
 	bucket       *hashmapBucket // current bucket in chain
-	bucketIndex  uint8          // current index into bucket
-	startIndex   uint8          // starting bucket index for iterator
-	wrapped      bool           // true if the iterator has wrapped
+	bucketIndex  uint8          // number of slots visited in the current bucket
+	startIndex   uint8          // first slot to visit in every bucket
+	done         bool           // true if the iterator has visited all buckets
 }
 
 func hashmapNewIterator() unsafe.Pointer {
 	return unsafe.Pointer(new(hashmapIterator))
 }
 
+// Special tophash values. A slot in a bucket is either empty, contains an entry
+// (with a tophash of at least hashmapMinTopHash), or is a slot in the old
+// buckets of a growing map of which the entry has been moved to the new
+// buckets.
+const (
+	hashmapEmpty      = 0
+	hashmapEvacuated  = 1
+	hashmapMinTopHash = 2
+)
+
+// Value of hashmapIterator.checkBucket when the iterator isn't iterating over
+// an old bucket.
+const hashmapNoCheck = ^uintptr(0)
+
 // Get the topmost 8 bits of the hash, without using a special value (like 0).
 func hashmapTopHash(hash uint32) uint8 {
 	tophash := uint8(hash >> 24)
-	if tophash < 1 {
-		// 0 means empty slot, so make it bigger.
-		tophash += 1
+	if tophash < hashmapMinTopHash {
+		// 0 means empty slot and 1 means evacuated slot, so make it bigger.
+		tophash += hashmapMinTopHash
 	}
 	return tophash
 }
@@ -94,6 +118,8 @@ func hashmapClear(m *hashmap) {
 	}
 
 	m.count = 0
+	m.oldbuckets = nil
+	m.nevacuate = 0
 	numBuckets := uintptr(1) << m.bucketBits
 	bucketSize := hashmapBucketSize(m)
 	for i := uintptr(0); i < numBuckets; i++ {
@@ -207,10 +233,11 @@ func hashmapSlotValue(m *hashmap, bucket *hashmapBucket, slot uint8) unsafe.Poin
 //
 //go:nobounds
 func hashmapSet(m *hashmap, key unsafe.Pointer, value unsafe.Pointer, hash uint32) {
-	if hashmapHasSpaceToGrow(m.bucketBits) && hashmapOverLoadFactor(m.count, m.bucketBits) {
+	if m.oldbuckets == nil && hashmapHasSpaceToGrow(m.bucketBits) && hashmapOverLoadFactor(m.count, m.bucketBits) {
 		hashmapGrow(m)
-		// seed changed when we grew; rehash key with new seed
-		hash = m.keyHash(key, m.keySize, m.seed)
+	}
+	if m.oldbuckets != nil {
+		hashmapGrowWork(m, hash)
 	}
 
 	tophash := hashmapTopHash(hash)
@@ -225,7 +252,7 @@ func hashmapSet(m *hashmap, key unsafe.Pointer, value unsafe.Pointer, hash uint3
 		for i := uint8(0); i < 8; i++ {
 			slotKey := hashmapSlotKey(m, bucket, i)
 			slotValue := hashmapSlotValue(m, bucket, i)
-			if bucket.tophash[i] == 0 && emptySlotKey == nil {
+			if bucket.tophash[i] == hashmapEmpty && emptySlotKey == nil {
 				// Found an empty slot, store it for if we couldn't find an
 				// existing slot.
 				emptySlotKey = slotKey
@@ -237,6 +264,7 @@ func hashmapSet(m *hashmap, key unsafe.Pointer, value unsafe.Pointer, hash uint3
 				if m.keyEqual(key, slotKey, m.keySize) {
 					// found same key, replace it
 					memcpy(slotValue, value, m.valueSize)
+					gcWriteBarrier(slotValue)
 					return
 				}
 			}
@@ -247,12 +275,14 @@ func hashmapSet(m *hashmap, key unsafe.Pointer, value unsafe.Pointer, hash uint3
 	if emptySlotKey == nil {
 		// Add a new bucket to the bucket chain.
 		// TODO: rebalance if necessary to avoid O(n) insert and lookup time.
-		lastBucket.next = (*hashmapBucket)(hashmapInsertIntoNewBucket(m, key, value, tophash))
+		m.count++
+		lastBucket.next = hashmapInsertIntoNewBucket(m, key, value, tophash)
 		return
 	}
 	m.count++
 	memcpy(emptySlotKey, key, m.keySize)
 	memcpy(emptySlotValue, value, m.valueSize)
+	gcWriteBarrier(emptySlotKey) // the key and value are in the same bucket
 	*emptySlotTophash = tophash
 }
 
@@ -266,17 +296,102 @@ func hashmapInsertIntoNewBucket(m *hashmap, key, value unsafe.Pointer, tophash u
 	// Insert into the first slot, which is empty as it has just been allocated.
 	slotKey := hashmapSlotKey(m, bucket, 0)
 	slotValue := hashmapSlotValue(m, bucket, 0)
-	m.count++
 	memcpy(slotKey, key, m.keySize)
 	memcpy(slotValue, value, m.valueSize)
+	gcWriteBarrier(slotKey) // the key and value are in the same bucket
 	bucket.tophash[0] = tophash
 	return bucket
 }
 
+// hashmapGrow starts growing the map to twice the number of buckets. It only
+// allocates the new buckets: the entries are moved over by hashmapGrowWork.
+// The seed stays the same, so that the entries of old bucket n end up in new
+// bucket n or n+oldNumBuckets depending on the next bit of their hash.
 func hashmapGrow(m *hashmap) {
-	// allocate our new buckets twice as big
-	n := hashmapCopy(m, m.bucketBits+1)
-	*m = n
+	numBuckets := uintptr(1) << (m.bucketBits + 1)
+	m.oldbuckets = m.buckets
+	m.buckets = alloc(hashmapBucketSize(m)*numBuckets, nil)
+	m.bucketBits++
+	m.nevacuate = 0
+}
+
+// hashmapGrowWork moves a bounded number of entries from the old buckets to the
+// new buckets of a growing map: the old bucket of the given hash (so that the
+// caller can modify the entry in the new buckets), and the next bucket that
+// hasn't been evacuated yet. This is called on every insert and delete, so the
+// map has finished growing long before it needs to grow again.
+func hashmapGrowWork(m *hashmap, hash uint32) {
+	oldNumBuckets := uintptr(1) << (m.bucketBits - 1)
+	hashmapEvacuate(m, uintptr(hash)&(oldNumBuckets-1))
+	hashmapEvacuateNext(m)
+}
+
+// hashmapEvacuateNext evacuates the next old bucket and stops growing the map
+// once all old buckets have been evacuated.
+func hashmapEvacuateNext(m *hashmap) {
+	hashmapEvacuate(m, m.nevacuate)
+	m.nevacuate++
+	if m.nevacuate == uintptr(1)<<(m.bucketBits-1) {
+		m.oldbuckets = nil
+		m.nevacuate = 0
+	}
+}
+
+// hashmapEvacuate moves all entries in the given old bucket (and the rest of
+// its chain) to the new buckets. Evacuating a bucket twice is a no-op.
+//
+// The keys are left in the old bucket, because an iterator may still be
+// iterating over the old buckets (see hashmapNext).
+// The values are cleared so that the GC won't pin these allocations.
+//
+//go:nobounds
+func hashmapEvacuate(m *hashmap, n uintptr) {
+	if m.oldbuckets == nil {
+		return
+	}
+	bucket := hashmapBucketAddr(m, m.oldbuckets, n)
+	for bucket != nil {
+		for i := uint8(0); i < 8; i++ {
+			if bucket.tophash[i] < hashmapMinTopHash {
+				// Empty or already evacuated.
+				continue
+			}
+			slotKey := hashmapSlotKey(m, bucket, i)
+			slotValue := hashmapSlotValue(m, bucket, i)
+			hash := m.keyHash(slotKey, m.keySize, m.seed)
+			hashmapInsertEvacuated(m, slotKey, slotValue, hash)
+			bucket.tophash[i] = hashmapEvacuated
+			memzero(slotValue, m.valueSize)
+		}
+		bucket = bucket.next
+	}
+}
+
+// hashmapInsertEvacuated inserts an entry from the old buckets into the new
+// buckets. The key is known to not be present in the new buckets yet, and the
+// number of entries in the map doesn't change.
+//
+//go:nobounds
+func hashmapInsertEvacuated(m *hashmap, key, value unsafe.Pointer, hash uint32) {
+	tophash := hashmapTopHash(hash)
+	bucket := hashmapBucketAddrForHash(m, hash)
+	for {
+		for i := uint8(0); i < 8; i++ {
+			if bucket.tophash[i] == hashmapEmpty {
+				slotKey := hashmapSlotKey(m, bucket, i)
+				memcpy(slotKey, key, m.keySize)
+				memcpy(hashmapSlotValue(m, bucket, i), value, m.valueSize)
+				gcWriteBarrier(slotKey) // the key and value are in the same bucket
+				bucket.tophash[i] = tophash
+				return
+			}
+		}
+		if bucket.next == nil {
+			bucket.next = hashmapInsertIntoNewBucket(m, key, value, tophash)
+			return
+		}
+		bucket = bucket.next
+	}
 }
 
 //go:linkname hashmapClone maps.clone
@@ -291,6 +406,8 @@ func hashmapCopy(m *hashmap, sizeBits uint8) hashmap {
 	// clone map as empty
 	n := *m
 	n.count = 0
+	n.oldbuckets = nil
+	n.nevacuate = 0
 	n.seed = uintptr(fastrand())
 
 	n.bucketBits = sizeBits
@@ -325,28 +442,44 @@ func hashmapGet(m *hashmap, key, value unsafe.Pointer, valueSize uintptr, hash u
 	}
 
 	tophash := hashmapTopHash(hash)
-	bucket := hashmapBucketAddrForHash(m, hash)
+	bucket, i := hashmapFind(m, hashmapBucketAddrForHash(m, hash), key, tophash)
+	if bucket == nil && m.oldbuckets != nil {
+		// The map is growing, and the entry may not have been moved to the new
+		// buckets yet.
+		oldNumBuckets := uintptr(1) << (m.bucketBits - 1)
+		oldBucket := hashmapBucketAddr(m, m.oldbuckets, uintptr(hash)&(oldNumBuckets-1))
+		bucket, i = hashmapFind(m, oldBucket, key, tophash)
+	}
+	if bucket == nil {
+		// Did not find the key.
+		memzero(value, m.valueSize)
+		return false
+	}
+
+	// Found the key, copy it.
+	memcpy(value, hashmapSlotValue(m, bucket, i), m.valueSize)
+	gcWriteBarrier(value)
+	return true
+}
 
-	// Try to find the key.
+// hashmapFind looks for the key in the given bucket chain, and returns the
+// bucket and slot index where it is stored. It returns a nil bucket if the key
+// is not found.
+//
+//go:nobounds
+func hashmapFind(m *hashmap, bucket *hashmapBucket, key unsafe.Pointer, tophash uint8) (*hashmapBucket, uint8) {
 	for bucket != nil {
 		for i := uint8(0); i < 8; i++ {
-			slotKey := hashmapSlotKey(m, bucket, i)
-			slotValue := hashmapSlotValue(m, bucket, i)
 			if bucket.tophash[i] == tophash {
 				// This could be the key we're looking for.
-				if m.keyEqual(key, slotKey, m.keySize) {
-					// Found the key, copy it.
-					memcpy(value, slotValue, m.valueSize)
-					return true
+				if m.keyEqual(key, hashmapSlotKey(m, bucket, i), m.keySize) {
+					return bucket, i
 				}
 			}
 		}
 		bucket = bucket.next
 	}
-
-	// Did not find the key.
-	memzero(value, m.valueSize)
-	return false
+	return nil, 0
 }
 
 // Delete a given key from the map. No-op when the key does not exist in the
@@ -361,33 +494,34 @@ func hashmapDelete(m *hashmap, key unsafe.Pointer, hash uint32) {
 		return
 	}
 
-	tophash := hashmapTopHash(hash)
-	bucket := hashmapBucketAddrForHash(m, hash)
+	if m.oldbuckets != nil {
+		// Make sure the entry is in the new buckets.
+		hashmapGrowWork(m, hash)
+	}
 
-	// Try to find the key.
-	for bucket != nil {
-		for i := uint8(0); i < 8; i++ {
-			slotKey := hashmapSlotKey(m, bucket, i)
-			if bucket.tophash[i] == tophash {
-				// This could be the key we're looking for.
-				if m.keyEqual(key, slotKey, m.keySize) {
-					// Found the key, delete it.
-					bucket.tophash[i] = 0
-					// Zero out the key and value so garbage collector doesn't pin the allocations.
-					memzero(slotKey, m.keySize)
-					slotValue := hashmapSlotValue(m, bucket, i)
-					memzero(slotValue, m.valueSize)
-					m.count--
-					return
-				}
-			}
-		}
-		bucket = bucket.next
+	tophash := hashmapTopHash(hash)
+	bucket, i := hashmapFind(m, hashmapBucketAddrForHash(m, hash), key, tophash)
+	if bucket == nil {
+		// Key not found.
+		return
 	}
+
+	// Found the key, delete it.
+	bucket.tophash[i] = hashmapEmpty
+	// Zero out the key and value so garbage collector doesn't pin the allocations.
+	memzero(hashmapSlotKey(m, bucket, i), m.keySize)
+	memzero(hashmapSlotValue(m, bucket, i), m.valueSize)
+	m.count--
 }
 
 // Iterate over a hashmap.
 //
+// Iterating doesn't modify the map. If the map is growing, entries can be in
+// either the old or the new buckets. Like the Go hashmap, the iterator walks
+// the new buckets, and for every new bucket of which the old bucket hasn't been
+// evacuated yet it walks the old bucket instead, returning only the entries
+// that belong in the new bucket.
+//
 //go:nobounds
 func hashmapNext(m *hashmap, it *hashmapIterator, key, value unsafe.Pointer) bool {
 	if m == nil {
@@ -398,18 +532,17 @@ func hashmapNext(m *hashmap, it *hashmapIterator, key, value unsafe.Pointer) boo
 	if it.buckets == nil {
 		// initialize iterator
 		it.buckets = m.buckets
+		it.oldbuckets = m.oldbuckets
 		it.numBuckets = uintptr(1) << m.bucketBits
 		it.startBucket = uintptr(fastrand()) & (it.numBuckets - 1)
 		it.startIndex = uint8(fastrand() & 7)
 
 		it.bucketNumber = it.startBucket
-		it.bucket = hashmapBucketAddr(m, it.buckets, it.bucketNumber)
-		it.bucketIndex = it.startIndex
+		hashmapIteratorStartBucket(m, it)
 	}
 
 	for {
-		// If we've wrapped and we're back at our starting location, terminate the iteration.
-		if it.wrapped && it.bucketNumber == it.startBucket && it.bucketIndex == it.startIndex {
+		if it.done {
 			return false
 		}
 
@@ -424,33 +557,51 @@ func hashmapNext(m *hashmap, it *hashmapIterator, key, value unsafe.Pointer) boo
 			if it.bucketNumber >= it.numBuckets {
 				// went through all buckets -- wrap around
 				it.bucketNumber = 0
-				it.wrapped = true
 			}
-			it.bucket = hashmapBucketAddr(m, it.buckets, it.bucketNumber)
+			if it.bucketNumber == it.startBucket {
+				// Back at our starting location, terminate the iteration.
+				it.done = true
+				return false
+			}
+			hashmapIteratorStartBucket(m, it)
 			continue
 		}
 
-		if it.bucket.tophash[it.bucketIndex] == 0 {
+		slot := (it.bucketIndex + it.startIndex) & 7
+		it.bucketIndex++
+		tophash := it.bucket.tophash[slot]
+		if tophash == hashmapEmpty {
 			// slot is empty - move on
-			it.bucketIndex++
 			continue
 		}
 
+		slotKey := hashmapSlotKey(m, it.bucket, slot)
+		if it.checkBucket != hashmapNoCheck {
+			// This is an old bucket. Only return the entries that belong in
+			// the current new bucket, the others are returned when visiting
+			// the other new bucket.
+			hash := m.keyHash(slotKey, m.keySize, m.seed)
+			if uintptr(hash)&(it.numBuckets-1) != it.checkBucket {
+				continue
+			}
+		}
+
 		// Found a key.
-		slotKey := hashmapSlotKey(m, it.bucket, it.bucketIndex)
 		memcpy(key, slotKey, m.keySize)
+		gcWriteBarrier(key)
 
-		if it.buckets == m.buckets {
-			// Our view of the buckets is the same as the parent map.
+		if tophash >= hashmapMinTopHash && it.buckets == m.buckets && (it.checkBucket == hashmapNoCheck || it.oldbuckets == m.oldbuckets) {
+			// Our view of the buckets is the same as the parent map, and the
+			// entry hasn't been moved.
 			// Just copy the value we have
-			slotValue := hashmapSlotValue(m, it.bucket, it.bucketIndex)
+			slotValue := hashmapSlotValue(m, it.bucket, slot)
 			memcpy(value, slotValue, m.valueSize)
-			it.bucketIndex++
+			gcWriteBarrier(value)
 		} else {
-			it.bucketIndex++
-
-			// Our view of the buckets doesn't match the parent map.
-			// Look up the key in the new buckets and return that value if it exists
+			// Our view of the buckets doesn't match the parent map (and the
+			// entry may have been evacuated to the new buckets).
+			// Look up the key in the parent map and return that value if it
+			// exists
 			hash := m.keyHash(key, m.keySize, m.seed)
 			ok := hashmapGet(m, key, value, m.valueSize, hash)
 			if !ok {
@@ -465,6 +616,42 @@ func hashmapNext(m *hashmap, it *hashmapIterator, key, value unsafe.Pointer) boo
 	}
 }
 
+// hashmapIteratorStartBucket moves the iterator to the start of the bucket
+// chain of it.bucketNumber. If the map was growing when the iterator was
+// started and the old bucket of these entries still contains entries, the
+// iterator walks the old bucket instead.
+func hashmapIteratorStartBucket(m *hashmap, it *hashmapIterator) {
+	it.bucketIndex = 0
+	it.checkBucket = hashmapNoCheck
+	if it.oldbuckets != nil {
+		oldNumBuckets := it.numBuckets / 2
+		oldBucket := hashmapBucketAddr(m, it.oldbuckets, it.bucketNumber&(oldNumBuckets-1))
+		if !hashmapBucketEvacuated(oldBucket) {
+			it.bucket = oldBucket
+			it.checkBucket = it.bucketNumber
+			return
+		}
+	}
+	it.bucket = hashmapBucketAddr(m, it.buckets, it.bucketNumber)
+}
+
+// hashmapBucketEvacuated returns whether the given old bucket chain contains no
+// entries anymore. An old bucket is evacuated all at once, so the new buckets
+// only contain entries of this old bucket if it has been evacuated.
+//
+//go:nobounds
+func hashmapBucketEvacuated(bucket *hashmapBucket) bool {
+	for bucket != nil {
+		for i := uint8(0); i < 8; i++ {
+			if bucket.tophash[i] >= hashmapMinTopHash {
+				return false
+			}
+		}
+		bucket = bucket.next
+	}
+	return true
+}
+
 // Hashmap with plain binary data keys (not containing strings etc.).
 func hashmapBinarySet(m *hashmap, key, value unsafe.Pointer) {
 	if m == nil {
@@ -0,0 +1,194 @@
+//go:build gc.conservative || gc.precise || gc.incremental || gc.compacting
+
+package runtime
+
+// Heap introspection for the block based GC, used by the heap functions in
+// runtime/debug.
+//
+// The heap dump is a line based text format, so that it can be captured from a
+// serial console or RTT together with other output of the program. It can be
+// decoded with "tinygo heapdump", using the DWARF debug information of the
+// program to find the types of heap objects. The format looks like this:
+//
+//	tinygo-heapdump 1 <pointer size> <block size> <header size>
+//	heap <start> <end>
+//	globals <start> <end>
+//	object <address> <number of blocks> <layout>
+//	free <address> <number of blocks>
+//	mem <address> <hex bytes>
+//	end
+//
+// All addresses and layout values are hexadecimal numbers starting with 0x. The
+// "mem" lines contain the contents of the globals and heap objects, at most 32
+// bytes per line. Object addresses are the address of the first block of the
+// object, the data of the object starts after the header (which stores the
+// layout with -gc=precise, see gc_precise.go). The layout is 0 if not known.
+
+import "unsafe"
+
+// Number of bytes printed in a single "mem" line of a heap dump.
+const heapDumpLineSize = 32
+
+//go:linkname debug_heapBlockSize runtime/debug.heapBlockSize
+func debug_heapBlockSize() uintptr {
+	return bytesPerBlock
+}
+
+// Call fn for every object on the heap, including objects that are garbage
+// but haven't been freed yet. The address is the start of the data of the
+// object, after the header. Iteration stops when fn returns false.
+// The heap is locked while fn runs, so fn must not allocate memory.
+//
+//go:linkname debug_walkHeap runtime/debug.walkHeap
+func debug_walkHeap(fn func(addr, blocks, layout uintptr) bool) {
+	gcLock.Lock()
+	for block := gcBlock(0); block < endBlock; {
+		state := block.state()
+		if state != blockStateHead && state != blockStateMark {
+			block++
+			continue
+		}
+		next := block.findNext()
+		if !fn(block.address()+heapObjectHeaderSize(), uintptr(next-block), heapObjectLayout(block)) {
+			break
+		}
+		block = next
+	}
+	gcLock.Unlock()
+}
+
+// Call fn for every run of free blocks on the heap. The heap is locked while fn
+// runs, so fn must not allocate memory.
+//
+//go:linkname debug_walkHeapFree runtime/debug.walkHeapFree
+func debug_walkHeapFree(fn func(addr, blocks uintptr)) {
+	gcLock.Lock()
+	for block := gcBlock(0); block < endBlock; {
+		if block.state() != blockStateFree {
+			block++
+			continue
+		}
+		start := block
+		for block < endBlock && block.state() == blockStateFree {
+			block++
+		}
+		fn(start.address(), uintptr(block-start))
+	}
+	gcLock.Unlock()
+}
+
+// Print a heap dump in the format described at the top of this file.
+//
+//go:linkname debug_printHeapDump runtime/debug.printHeapDump
+func debug_printHeapDump() {
+	gcLock.Lock()
+	printlock()
+	printstring("tinygo-heapdump 1 ")
+	printuintptr(unsafe.Sizeof(uintptr(0)))
+	printspace()
+	printuintptr(bytesPerBlock)
+	printspace()
+	printuintptr(heapObjectHeaderSize())
+	printnl()
+	printstring("heap ")
+	printptr(heapStart)
+	printspace()
+	printptr(uintptr(metadataStart))
+	printnl()
+	findGlobals(func(start, end uintptr) {
+		printstring("globals ")
+		printptr(start)
+		printspace()
+		printptr(end)
+		printnl()
+		printHeapDumpMemory(start, end)
+	})
+	for block := gcBlock(0); block < endBlock; {
+		switch block.state() {
+		case blockStateHead, blockStateMark:
+			next := block.findNext()
+			printstring("object ")
+			printptr(block.address())
+			printspace()
+			printuintptr(uintptr(next - block))
+			printspace()
+			printHeapDumpHex(heapObjectLayout(block))
+			printnl()
+			printHeapDumpMemory(block.address(), next.address())
+			block = next
+		case blockStateFree:
+			start := block
+			for block < endBlock && block.state() == blockStateFree {
+				block++
+			}
+			printstring("free ")
+			printptr(start.address())
+			printspace()
+			printuintptr(uintptr(block - start))
+			printnl()
+		default:
+			// Tail blocks without a head. This shouldn't happen.
+			block++
+		}
+	}
+	printstring("end")
+	printnl()
+	printunlock()
+	gcLock.Unlock()
+}
+
+// heapObjectHeaderSize returns the number of bytes at the start of each heap
+// object that are used by the GC instead of the program.
+func heapObjectHeaderSize() uintptr {
+	if !preciseHeap {
+		return 0
+	}
+	return align(unsafe.Sizeof(uintptr(0)))
+}
+
+// heapObjectLayout returns the pointer layout of the object starting at the
+// given block, or 0 if it isn't known.
+func heapObjectLayout(block gcBlock) uintptr {
+	if !preciseHeap {
+		return 0
+	}
+	return *(*uintptr)(unsafe.Pointer(block.address()))
+}
+
+// printHeapDumpHex prints a hexadecimal number starting with 0x. Unlike
+// printptr, it prints 0x0 instead of nil for zero.
+func printHeapDumpHex(n uintptr) {
+	if n == 0 {
+		printstring("0x0")
+		return
+	}
+	printptr(n)
+}
+
+// printHeapDumpMemory prints the memory from start to end as "mem" lines of a
+// heap dump.
+func printHeapDumpMemory(start, end uintptr) {
+	for addr := start; addr < end; addr += heapDumpLineSize {
+		printstring("mem ")
+		printptr(addr)
+		putchar(' ')
+		lineEnd := addr + heapDumpLineSize
+		if lineEnd > end {
+			lineEnd = end
+		}
+		for p := addr; p < lineEnd; p++ {
+			b := *(*byte)(unsafe.Pointer(p))
+			putchar(hexDigit(b >> 4))
+			putchar(hexDigit(b & 0xf))
+		}
+		printnl()
+	}
+}
+
+// hexDigit returns the lowercase hexadecimal digit for the given nibble.
+func hexDigit(nibble byte) byte {
+	if nibble < 10 {
+		return nibble + '0'
+	}
+	return nibble - 10 + 'a'
+}
@@ -0,0 +1,25 @@
+//go:build !(gc.conservative || gc.precise || gc.incremental || gc.compacting)
+
+package runtime
+
+// Heap introspection is only supported by the block based GC. With other GCs,
+// the heap appears to be empty.
+
+//go:linkname debug_heapBlockSize runtime/debug.heapBlockSize
+func debug_heapBlockSize() uintptr {
+	return 0
+}
+
+//go:linkname debug_walkHeap runtime/debug.walkHeap
+func debug_walkHeap(fn func(addr, blocks, layout uintptr) bool) {
+}
+
+//go:linkname debug_walkHeapFree runtime/debug.walkHeapFree
+func debug_walkHeapFree(fn func(addr, blocks uintptr)) {
+}
+
+//go:linkname debug_printHeapDump runtime/debug.printHeapDump
+func debug_printHeapDump() {
+	printstring("heap dumps are only supported by the conservative, precise, incremental and compacting GCs")
+	printnl()
+}
@@ -3,3 +3,6 @@
 package runtime
 
 const baremetal = false
+
+// Sample one allocation per 512kB by default, like upstream Go.
+const defaultMemProfileRate = 512 * 1024
@@ -0,0 +1,13 @@
+// Portions copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package metrics provides a stable interface to access implementation-defined
+// metrics exported by the runtime. This package is similar to existing functions
+// like [runtime.ReadMemStats], but significantly more general.
+//
+// Metrics are designated by a string key. The full list of supported metrics is
+// available in the slice of Descriptions returned by [All]. TinyGo supports a
+// subset of the metrics of the gc toolchain, with the same keys and meaning, so
+// that existing code that exports these metrics works unmodified.
+package metrics
@@ -0,0 +1,114 @@
+// Portions copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package metrics
+
+import (
+	"runtime"
+)
+
+// Sample captures a single metric sample.
+type Sample struct {
+	// Name is the name of the metric sampled.
+	//
+	// It must correspond to a name in one of the metric descriptions
+	// returned by All.
+	Name string
+
+	// Value is the value of the metric sample.
+	Value Value
+}
+
+// Indices into allDesc.
+const (
+	metricCgoCalls = iota
+	metricGCCyclesAutomatic
+	metricGCCyclesForced
+	metricGCCyclesTotal
+	metricHeapAllocsBytes
+	metricHeapAllocsObjects
+	metricHeapFreesObjects
+	metricHeapGoal
+	metricHeapObjects
+	metricMemoryHeapFree
+	metricMemoryHeapObjects
+	metricMemoryHeapReleased
+	metricMemoryMetadata
+	metricMemoryTotal
+	metricGOMAXPROCS
+	metricGoroutines
+)
+
+// Read populates each [Value] field in the given slice of metric samples.
+//
+// Desired metrics should be present in the slice with the appropriate name.
+// The user of this API is encouraged to re-use the same slice between calls for
+// efficiency, but is not required to do so.
+//
+// Sample values with names not appearing in [All] will have their Value populated
+// as KindBad to indicate that the name is unknown.
+func Read(m []Sample) {
+	// Reading memory statistics requires walking the heap, so only do it once
+	// and only when needed.
+	var stats runtime.MemStats
+	readStats := false
+
+	for i := range m {
+		sample := &m[i]
+		index := -1
+		for j := range allDesc {
+			if allDesc[j].Name == sample.Name {
+				index = j
+				break
+			}
+		}
+		if index < 0 {
+			sample.Value = Value{}
+			continue
+		}
+		if index < metricGOMAXPROCS && index != metricCgoCalls && !readStats {
+			runtime.ReadMemStats(&stats)
+			readStats = true
+		}
+
+		var value uint64
+		switch index {
+		case metricCgoCalls:
+			value = uint64(runtime.NumCgoCall())
+		case metricGCCyclesAutomatic:
+			value = uint64(stats.NumGC - stats.NumForcedGC)
+		case metricGCCyclesForced:
+			value = uint64(stats.NumForcedGC)
+		case metricGCCyclesTotal:
+			value = uint64(stats.NumGC)
+		case metricHeapAllocsBytes:
+			value = stats.TotalAlloc
+		case metricHeapAllocsObjects:
+			value = stats.Mallocs
+		case metricHeapFreesObjects:
+			value = stats.Frees
+		case metricHeapGoal:
+			// The garbage collector runs when the heap is full (or can't be
+			// grown any further), so the goal is the entire heap.
+			value = stats.HeapSys
+		case metricHeapObjects:
+			value = stats.Mallocs - stats.Frees
+		case metricMemoryHeapFree:
+			value = stats.HeapIdle
+		case metricMemoryHeapObjects:
+			value = stats.HeapInuse
+		case metricMemoryHeapReleased:
+			value = stats.HeapReleased
+		case metricMemoryMetadata:
+			value = stats.GCSys
+		case metricMemoryTotal:
+			value = stats.HeapSys + stats.GCSys
+		case metricGOMAXPROCS:
+			value = uint64(runtime.GOMAXPROCS(0))
+		case metricGoroutines:
+			value = uint64(runtime.NumGoroutine())
+		}
+		sample.Value = Value{kind: KindUint64, scalar: value}
+	}
+}
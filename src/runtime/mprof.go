@@ -0,0 +1,42 @@
+package runtime
+
+// Memory profiling types. The actual sampling is implemented by the garbage
+// collector (see mprof_blocks.go).
+
+// MemProfileRate controls the fraction of memory allocations that are recorded
+// and reported in the memory profile. The profiler aims to sample an average
+// of one allocation per MemProfileRate bytes allocated.
+//
+// To include every allocated block in the profile, set MemProfileRate to 1.
+// To turn off profiling entirely, set MemProfileRate to 0.
+//
+// Unlike upstream Go, memory profiling is off by default on baremetal systems
+// to avoid the extra memory that is needed to store the profile.
+var MemProfileRate int = defaultMemProfileRate
+
+// A MemProfileRecord describes the live objects allocated by a particular call
+// sequence (stack trace).
+type MemProfileRecord struct {
+	AllocBytes, FreeBytes     int64       // number of bytes allocated, freed
+	AllocObjects, FreeObjects int64       // number of objects allocated, freed
+	Stack0                    [32]uintptr // stack trace for this record; ends at first 0 entry
+}
+
+// InUseBytes returns the number of bytes in use (AllocBytes - FreeBytes).
+func (r *MemProfileRecord) InUseBytes() int64 { return r.AllocBytes - r.FreeBytes }
+
+// InUseObjects returns the number of objects in use (AllocObjects - FreeObjects).
+func (r *MemProfileRecord) InUseObjects() int64 {
+	return r.AllocObjects - r.FreeObjects
+}
+
+// Stack returns the stack trace associated with the record, a prefix of
+// r.Stack0.
+func (r *MemProfileRecord) Stack() []uintptr {
+	for i, v := range r.Stack0 {
+		if v == 0 {
+			return r.Stack0[0:i]
+		}
+	}
+	return r.Stack0[0:]
+}
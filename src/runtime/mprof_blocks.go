@@ -0,0 +1,237 @@
+//go:build gc.conservative || gc.precise || gc.incremental || gc.compacting
+
+package runtime
+
+// Memory profiling for the block based GC.
+//
+// Every time roughly MemProfileRate bytes have been allocated, the allocation
+// that crosses this threshold is sampled: its allocation site is recorded in a
+// bucket and the object itself is remembered. Right before a sweep, all
+// remembered objects that were not marked are counted as freed in their bucket.
+//
+// Samples are recorded while the GC lock is held, so recording a sample must
+// not allocate memory. Instead, alloc calls memProfileGrow before taking the
+// lock to make sure there is room for at least one more sample.
+
+type memProfileBucket struct {
+	pc         uintptr
+	allocs     int64
+	allocBytes int64
+	frees      int64
+	freeBytes  int64
+}
+
+type memProfileObject struct {
+	// Bitwise inverse of the first block of the object. It is stored inverted
+	// so that the conservative GC doesn't see it as a pointer and keeps the
+	// object alive.
+	invBlock gcBlock
+	size     uintptr
+	bucket   int
+}
+
+var (
+	memProfileBuckets []memProfileBucket
+	memProfileObjects []memProfileObject
+
+	// Number of bytes left before the next allocation is sampled.
+	memProfileNext uintptr
+
+	// Set while the tables above are being grown, to avoid growing them
+	// again for the allocations done by memProfileGrow itself.
+	memProfileGrowing bool
+)
+
+// Initial capacity of the tables above.
+const (
+	memProfileMinBuckets = 16
+	memProfileMinObjects = 64
+)
+
+// memProfileFull returns whether the tables should be grown before the next
+// sample can be recorded. It must be called with the GC lock held.
+//
+//go:inline
+func memProfileFull() bool {
+	if MemProfileRate <= 0 || memProfileGrowing {
+		return false
+	}
+	return len(memProfileBuckets) == cap(memProfileBuckets) || len(memProfileObjects) == cap(memProfileObjects)
+}
+
+// memProfileGrow makes room in the tables for more samples. It must be called
+// without holding the GC lock, before the object to be sampled is allocated.
+//
+//go:noinline
+func memProfileGrow() {
+	gcLock.Lock()
+	if !memProfileFull() {
+		// Another thread already grew the tables.
+		gcLock.Unlock()
+		return
+	}
+	memProfileGrowing = true
+	bucketCap := cap(memProfileBuckets)
+	if len(memProfileBuckets) == bucketCap {
+		bucketCap = bucketCap*2 + memProfileMinBuckets
+	}
+	objectCap := cap(memProfileObjects)
+	if len(memProfileObjects) == objectCap {
+		objectCap = objectCap*2 + memProfileMinObjects
+	}
+	gcLock.Unlock()
+
+	// These allocations might run a GC cycle, which removes freed objects
+	// from memProfileObjects. So only copy the tables after allocating.
+	buckets := make([]memProfileBucket, 0, bucketCap)
+	objects := make([]memProfileObject, 0, objectCap)
+
+	gcLock.Lock()
+	if cap(memProfileBuckets) < bucketCap {
+		memProfileBuckets = append(buckets, memProfileBuckets...)
+	}
+	if cap(memProfileObjects) < objectCap {
+		memProfileObjects = append(objects, memProfileObjects...)
+	}
+	memProfileGrowing = false
+	gcLock.Unlock()
+}
+
+// Decide whether the allocation of the given size should be sampled, and if so,
+// record it. This is called for every allocation so should be fast in the
+// common case.
+//
+//go:inline
+func memProfileAlloc(block gcBlock, size uintptr, pc uintptr) {
+	if MemProfileRate <= 0 {
+		return
+	}
+	if size < memProfileNext {
+		memProfileNext -= size
+		return
+	}
+	memProfileRecord(block, size, pc)
+}
+
+// Record a sampled allocation. This is called with the GC lock held, so it
+// doesn't allocate: if the tables are full (because they are being grown at the
+// moment), the sample is only partially recorded or dropped.
+//
+//go:noinline
+func memProfileRecord(block gcBlock, size uintptr, pc uintptr) {
+	// Pick a new sampling threshold uniformly in the range
+	// [0, 2*MemProfileRate), so that on average one sample is taken every
+	// MemProfileRate bytes.
+	rate := uintptr(MemProfileRate)
+	if rate > 1 {
+		memProfileNext = uintptr(fastrand64() % uint64(rate*2))
+	} else {
+		memProfileNext = 0
+	}
+
+	// Find the bucket for this allocation site.
+	index := -1
+	for i := range memProfileBuckets {
+		if memProfileBuckets[i].pc == pc {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		if len(memProfileBuckets) == cap(memProfileBuckets) {
+			return
+		}
+		index = len(memProfileBuckets)
+		memProfileBuckets = memProfileBuckets[:index+1]
+		memProfileBuckets[index] = memProfileBucket{pc: pc}
+	}
+	bucket := &memProfileBuckets[index]
+	bucket.allocs++
+	bucket.allocBytes += int64(size)
+
+	if len(memProfileObjects) == cap(memProfileObjects) {
+		// The object can't be tracked, so it won't be counted as freed.
+		return
+	}
+	memProfileObjects = memProfileObjects[:len(memProfileObjects)+1]
+	memProfileObjects[len(memProfileObjects)-1] = memProfileObject{
+		invBlock: ^block,
+		size:     size,
+		bucket:   index,
+	}
+}
+
+// Update the memory profile after the mark phase: every sampled object that
+// hasn't been marked is about to be freed.
+func memProfileSweep() {
+	objects := memProfileObjects
+	for i := 0; i < len(objects); i++ {
+		obj := objects[i]
+		if (^obj.invBlock).state() == blockStateMark {
+			continue
+		}
+		bucket := &memProfileBuckets[obj.bucket]
+		bucket.frees++
+		bucket.freeBytes += int64(obj.size)
+
+		// Remove the object from the list by replacing it with the last one.
+		objects[i] = objects[len(objects)-1]
+		objects = objects[:len(objects)-1]
+		i--
+	}
+	memProfileObjects = objects
+}
+
+// memProfilePinObjects marks all sampled objects, so that they won't be moved
+// by compactHeap. The sampled objects are tracked by their block number, which
+// would be wrong after the object has been moved.
+func memProfilePinObjects() {
+	for _, obj := range memProfileObjects {
+		(^obj.invBlock).setState(blockStateMark)
+	}
+}
+
+// MemProfile returns a profile of memory allocated and freed per allocation
+// site.
+//
+// MemProfile returns n, the number of records in the current memory profile.
+// If len(p) >= n, MemProfile copies the profile into p and returns n, true.
+// If len(p) < n, MemProfile does not change p and returns n, false.
+//
+// If inuseZero is true, the profile includes allocation records
+// where r.AllocBytes > 0 but r.AllocBytes == r.FreeBytes.
+// These are sites where memory was allocated, but it has all
+// been released back to the runtime.
+//
+// Only a single frame (the allocation site) is recorded for each record.
+//
+// Most clients should use the runtime/pprof package or
+// the testing package's -test.memprofile flag instead
+// of calling MemProfile directly.
+func MemProfile(p []MemProfileRecord, inuseZero bool) (n int, ok bool) {
+	for i := range memProfileBuckets {
+		bucket := &memProfileBuckets[i]
+		if inuseZero || bucket.allocBytes != bucket.freeBytes {
+			n++
+		}
+	}
+	if n > len(p) {
+		return n, false
+	}
+	i := 0
+	for j := range memProfileBuckets {
+		bucket := &memProfileBuckets[j]
+		if !inuseZero && bucket.allocBytes == bucket.freeBytes {
+			continue
+		}
+		p[i] = MemProfileRecord{
+			AllocBytes:   bucket.allocBytes,
+			FreeBytes:    bucket.freeBytes,
+			AllocObjects: bucket.allocs,
+			FreeObjects:  bucket.frees,
+		}
+		p[i].Stack0[0] = bucket.pc
+		i++
+	}
+	return n, true
+}
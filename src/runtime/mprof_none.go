@@ -0,0 +1,11 @@
+//go:build !(gc.conservative || gc.precise || gc.incremental || gc.compacting)
+
+package runtime
+
+// MemProfile returns a profile of memory allocated and freed per allocation
+// site. Memory profiling is only supported by the block based garbage
+// collectors (-gc=conservative, -gc=precise, -gc=incremental and
+// -gc=compacting), so the profile is always empty.
+func MemProfile(p []MemProfileRecord, inuseZero bool) (n int, ok bool) {
+	return 0, true
+}
@@ -75,6 +75,7 @@ func panicOrGoexit(message interface{}, panicking panicState) {
 		// Exit the goroutine instead of printing a panic message.
 		deadlock()
 	}
+	fuzzCrash()
 	printstring("panic: ")
 	printitf(message)
 	printnl()
@@ -92,6 +93,7 @@ func runtimePanicAt(addr unsafe.Pointer, msg string) {
 	if panicStrategy() == tinygo.PanicStrategyTrap {
 		trap()
 	}
+	fuzzCrash()
 	if hasReturnAddr {
 		printstring("panic: runtime error at ")
 		printptr(uintptr(addr) - callInstSize)
@@ -0,0 +1,57 @@
+//go:build gc.compacting
+
+package runtime
+
+import (
+	"reflect"
+	"unsafe"
+)
+
+// A Pinner is a set of pinned Go objects. An object can be pinned with the Pin
+// method and all pinned objects of a Pinner can be unpinned with the Unpin
+// method.
+//
+// Pinned objects are kept alive and are never moved by the garbage collector
+// until they are unpinned.
+type Pinner struct {
+	// Buffer with the pinned pointers. It is allocated without a layout, so
+	// that it is scanned conservatively and everything it references is
+	// pinned by the compacting GC.
+	refs unsafe.Pointer
+	len  uintptr
+	cap  uintptr
+}
+
+// Pin pins a Go object, preventing it from being moved or freed by the garbage
+// collector until the Unpin method has been called.
+//
+// The argument must be a pointer of any type or an unsafe.Pointer.
+func (p *Pinner) Pin(pointer any) {
+	if pointer == nil {
+		runtimePanic("runtime.Pinner: argument is nil")
+	}
+	if kind := reflect.TypeOf(pointer).Kind(); kind != reflect.Pointer && kind != reflect.UnsafePointer {
+		runtimePanic("runtime.Pinner: argument is not a pointer")
+	}
+	value := (*_interface)(unsafe.Pointer(&pointer)).value
+	const ptrSize = unsafe.Sizeof(unsafe.Pointer(nil))
+	if p.len == p.cap {
+		newCap := p.cap * 2
+		if newCap == 0 {
+			newCap = 4
+		}
+		buf := alloc(newCap*ptrSize, nil)
+		memcpy(buf, p.refs, p.len*ptrSize)
+		p.refs = buf
+		p.cap = newCap
+	}
+	*(*unsafe.Pointer)(unsafe.Add(p.refs, p.len*ptrSize)) = value
+	p.len++
+}
+
+// Unpin unpins all pinned objects of the Pinner.
+func (p *Pinner) Unpin() {
+	p.refs = nil
+	p.len = 0
+	p.cap = 0
+}
@@ -0,0 +1,18 @@
+//go:build !gc.compacting
+
+package runtime
+
+// A Pinner is a set of pinned Go objects. An object can be pinned with the Pin
+// method and all pinned objects of a Pinner can be unpinned with the Unpin
+// method.
+//
+// Only -gc=compacting moves objects, so with this garbage collector pinning
+// doesn't need to do anything.
+type Pinner struct{}
+
+// Pin pins a Go object, preventing it from being moved or freed by the garbage
+// collector until the Unpin method has been called.
+func (p *Pinner) Pin(pointer any) {}
+
+// Unpin unpins all pinned objects of the Pinner.
+func (p *Pinner) Unpin() {}
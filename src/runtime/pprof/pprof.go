@@ -1,44 +1,396 @@
+// Package pprof writes runtime profiling data in the format expected by the
+// pprof visualization tool.
+//
+// TinyGo supports CPU profiles on Linux and MacOS, and heap profiles when using
+// the conservative or precise garbage collector. Other profiles (goroutine,
+// threadcreate, block and mutex) exist but don't contain stack information.
+//
+// CPU profile samples contain the call stack of the interrupted code when the
+// program is built with -symtab, and only the interrupted instruction
+// otherwise. Heap profile samples only contain the allocation site.
 package pprof
 
-// TinyGo does not implement pprof. However, a dummy shell is needed for the
-// testing package (and testing/internal/pprof).
-
 import (
 	"errors"
+	"fmt"
 	"io"
+	"os"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
 )
 
 var ErrUnimplemented = errors.New("runtime/pprof: unimplemented")
 
-type Profile struct{}
+// Implemented in the runtime.
+func cpuProfileSupported() bool
+func readCPUProfile(fn func(stack []uintptr, count uint64)) (lost uint64)
 
-func StartCPUProfile(w io.Writer) error {
-	return nil
+// A Profile is a collection of samples that can be written in the pprof
+// format.
+type Profile struct {
+	name  string
+	count func() int
+	write func(w io.Writer, debug int) error
 }
 
-func StopCPUProfile() {
-}
+var (
+	heapProfile = &Profile{
+		name:  "heap",
+		count: countHeap,
+		write: writeHeap,
+	}
+	allocsProfile = &Profile{
+		name:  "allocs",
+		count: countHeap,
+		write: writeAllocs,
+	}
+	goroutineProfile = &Profile{
+		name:  "goroutine",
+		count: runtime.NumGoroutine,
+		write: writeGoroutine,
+	}
+	threadcreateProfile = &Profile{
+		name:  "threadcreate",
+		count: countZero,
+		write: writeEmpty("threadcreate", valueType{"threadcreate", "count"}),
+	}
+	blockProfile = &Profile{
+		name:  "block",
+		count: countZero,
+		write: writeEmpty("block", valueType{"contentions", "count"}, valueType{"delay", "nanoseconds"}),
+	}
+	mutexProfile = &Profile{
+		name:  "mutex",
+		count: countZero,
+		write: writeEmpty("mutex", valueType{"contentions", "count"}, valueType{"delay", "nanoseconds"}),
+	}
+)
 
-func WriteHeapProfile(w io.Writer) error {
-	return nil
+var profiles = []*Profile{
+	allocsProfile,
+	blockProfile,
+	goroutineProfile,
+	heapProfile,
+	mutexProfile,
+	threadcreateProfile,
 }
 
+// Lookup returns the profile with the given name, or nil if no such profile
+// exists.
 func Lookup(name string) *Profile {
+	for _, p := range profiles {
+		if p.name == name {
+			return p
+		}
+	}
 	return nil
 }
 
+// Profiles returns a slice of all the known profiles, sorted by name.
+func Profiles() []*Profile {
+	all := make([]*Profile, len(profiles))
+	copy(all, profiles)
+	return all
+}
+
+// Name returns this profile's name, which can be passed to Lookup to reobtain
+// the profile.
 func (p *Profile) Name() string {
-	return ""
+	return p.name
 }
 
+// Count returns the number of execution stacks currently in the profile.
 func (p *Profile) Count() int {
-	return 0
+	return p.count()
 }
 
+// WriteTo writes a pprof-formatted snapshot of the profile to w.
+// If a write to w returns an error, WriteTo returns that error.
+// Otherwise, WriteTo returns nil.
+//
+// The debug parameter enables additional output. Passing debug=0 writes the
+// gzip-compressed protocol buffer described in
+// https://github.com/google/pprof/tree/main/proto#overview. Passing debug=1
+// writes the legacy text format.
 func (p *Profile) WriteTo(w io.Writer, debug int) error {
-	return ErrUnimplemented
+	return p.write(w, debug)
 }
 
-func Profiles() []*Profile {
+func countZero() int {
+	return 0
+}
+
+var cpu struct {
+	sync.Mutex
+	profiling bool
+	w         io.Writer
+	start     time.Time
+	hz        int
+}
+
+// StartCPUProfile enables CPU profiling for the current process.
+// While profiling, the profile will be buffered and written to w.
+// StartCPUProfile returns an error if profiling is already enabled or not
+// supported on this platform.
+func StartCPUProfile(w io.Writer) error {
+	// 100 Hz is frequent enough to produce useful data, rare enough not to
+	// slow down the program much, and makes it easy to convert sample counts
+	// to seconds. This is the same rate as upstream Go.
+	const hz = 100
+
+	if !cpuProfileSupported() {
+		return errors.New("cpu profiling not supported on " + runtime.GOOS + "/" + runtime.GOARCH)
+	}
+
+	cpu.Lock()
+	defer cpu.Unlock()
+	if cpu.profiling {
+		return fmt.Errorf("cpu profiling already in use")
+	}
+	cpu.profiling = true
+	cpu.w = w
+	cpu.start = time.Now()
+	cpu.hz = hz
+	runtime.SetCPUProfileRate(hz)
 	return nil
 }
+
+// StopCPUProfile stops the current CPU profile, if any, and writes it to the
+// writer passed to StartCPUProfile.
+func StopCPUProfile() {
+	cpu.Lock()
+	defer cpu.Unlock()
+
+	if !cpu.profiling {
+		return
+	}
+	cpu.profiling = false
+	runtime.SetCPUProfileRate(0)
+
+	period := int64(time.Second) / int64(cpu.hz)
+	p := &profile{
+		sampleTypes: []valueType{
+			{"samples", "count"},
+			{"cpu", "nanoseconds"},
+		},
+		periodType:    valueType{"cpu", "nanoseconds"},
+		period:        period,
+		timeNanos:     cpu.start.UnixNano(),
+		durationNanos: int64(time.Since(cpu.start)),
+	}
+	lost := readCPUProfile(func(stack []uintptr, count uint64) {
+		// The first address is the interrupted instruction, the others are
+		// return addresses.
+		p.samples = append(p.samples, sample{
+			stack:  append([]uintptr{stack[0]}, callSites(stack[1:])...),
+			values: []int64{int64(count), int64(count) * period},
+		})
+	})
+	if lost != 0 {
+		p.comments = append(p.comments, fmt.Sprintf("%d samples lost", lost))
+	}
+	sortSamples(p.samples)
+	if err := p.write(cpu.w); err != nil {
+		// StopCPUProfile can't return an error, so report it like upstream Go
+		// reports problems with profiles.
+		fmt.Fprintln(os.Stderr, "runtime/pprof: could not write CPU profile:", err)
+	}
+	cpu.w = nil
+}
+
+// WriteHeapProfile is shorthand for Lookup("heap").WriteTo(w, 0).
+// It is preserved for backwards compatibility.
+func WriteHeapProfile(w io.Writer) error {
+	return writeHeap(w, 0)
+}
+
+func countHeap() int {
+	n, _ := runtime.MemProfile(nil, true)
+	return n
+}
+
+// Read the current memory profile.
+func readMemProfile() []runtime.MemProfileRecord {
+	var p []runtime.MemProfileRecord
+	n, ok := runtime.MemProfile(nil, true)
+	for {
+		// Allocate room for a slightly bigger profile,
+		// in case a few more entries have been added
+		// since the call to MemProfile.
+		p = make([]runtime.MemProfileRecord, n+50)
+		n, ok = runtime.MemProfile(p, true)
+		if ok {
+			p = p[0:n]
+			break
+		}
+		// Profile grew; try again.
+	}
+	sort.Slice(p, func(i, j int) bool { return p[i].InUseBytes() > p[j].InUseBytes() })
+	return p
+}
+
+func writeHeap(w io.Writer, debug int) error {
+	return writeHeapInternal(w, debug, "inuse_space")
+}
+
+func writeAllocs(w io.Writer, debug int) error {
+	return writeHeapInternal(w, debug, "alloc_space")
+}
+
+func writeHeapInternal(w io.Writer, debug int, defaultSampleType string) error {
+	rate := int64(runtime.MemProfileRate)
+	records := readMemProfile()
+
+	if debug != 0 {
+		return writeHeapText(w, records, rate)
+	}
+
+	p := &profile{
+		sampleTypes: []valueType{
+			{"alloc_objects", "count"},
+			{"alloc_space", "bytes"},
+			{"inuse_objects", "count"},
+			{"inuse_space", "bytes"},
+		},
+		defaultSampleType: defaultSampleType,
+		periodType:        valueType{"space", "bytes"},
+		period:            rate,
+		timeNanos:         time.Now().UnixNano(),
+	}
+	for i := range records {
+		r := &records[i]
+		allocObjects, allocBytes := scaleHeapSample(r.AllocObjects, r.AllocBytes, rate)
+		inuseObjects, inuseBytes := scaleHeapSample(r.InUseObjects(), r.InUseBytes(), rate)
+		p.samples = append(p.samples, sample{
+			stack:  callSites(r.Stack()),
+			values: []int64{allocObjects, allocBytes, inuseObjects, inuseBytes},
+		})
+	}
+	return p.write(w)
+}
+
+// Write the heap profile in the legacy text format.
+func writeHeapText(w io.Writer, records []runtime.MemProfileRecord, rate int64) error {
+	var total runtime.MemProfileRecord
+	for i := range records {
+		r := &records[i]
+		total.AllocBytes += r.AllocBytes
+		total.AllocObjects += r.AllocObjects
+		total.FreeBytes += r.FreeBytes
+		total.FreeObjects += r.FreeObjects
+	}
+
+	// Technically the rate is MemProfileRate not 2*MemProfileRate, but early
+	// versions of the C++ heap profiler reported 2*MemProfileRate, so that's
+	// what pprof has come to expect.
+	fmt.Fprintf(w, "heap profile: %d: %d [%d: %d] @ heap/%d\n",
+		total.InUseObjects(), total.InUseBytes(),
+		total.AllocObjects, total.AllocBytes,
+		2*rate)
+	for i := range records {
+		r := &records[i]
+		fmt.Fprintf(w, "%d: %d [%d: %d] @",
+			r.InUseObjects(), r.InUseBytes(),
+			r.AllocObjects, r.AllocBytes)
+		for _, pc := range r.Stack() {
+			fmt.Fprintf(w, " %#x", pc)
+		}
+		fmt.Fprintf(w, "\n")
+	}
+
+	var s runtime.MemStats
+	runtime.ReadMemStats(&s)
+	fmt.Fprintf(w, "\n# runtime.MemStats\n")
+	fmt.Fprintf(w, "# Alloc = %d\n", s.Alloc)
+	fmt.Fprintf(w, "# TotalAlloc = %d\n", s.TotalAlloc)
+	fmt.Fprintf(w, "# Sys = %d\n", s.Sys)
+	fmt.Fprintf(w, "# Mallocs = %d\n", s.Mallocs)
+	fmt.Fprintf(w, "# Frees = %d\n", s.Frees)
+	fmt.Fprintf(w, "# HeapAlloc = %d\n", s.HeapAlloc)
+	fmt.Fprintf(w, "# HeapSys = %d\n", s.HeapSys)
+	fmt.Fprintf(w, "# HeapIdle = %d\n", s.HeapIdle)
+	fmt.Fprintf(w, "# HeapInuse = %d\n", s.HeapInuse)
+	_, err := fmt.Fprintf(w, "# HeapReleased = %d\n", s.HeapReleased)
+	return err
+}
+
+// Convert return addresses to addresses of the call instructions, which is what
+// the pprof tool expects.
+func callSites(stack []uintptr) []uintptr {
+	sites := make([]uintptr, len(stack))
+	for i, pc := range stack {
+		sites[i] = pc - 1
+	}
+	return sites
+}
+
+// scaleHeapSample adjusts the data from a heap sample to account for its
+// probability of appearing in the collected data. The runtime samples an
+// allocation of size bytes with a probability of about size/rate, so an
+// allocation smaller than rate represents rate/size allocations on average.
+func scaleHeapSample(count, size, rate int64) (int64, int64) {
+	if count == 0 || size == 0 {
+		return 0, 0
+	}
+	if rate <= 1 {
+		// if rate==1 all samples were collected so no adjustment is needed.
+		return count, size
+	}
+	avgSize := size / count
+	if avgSize >= rate {
+		// Allocations this big are always sampled.
+		return count, size
+	}
+	scale := float64(rate) / float64(avgSize)
+	return int64(float64(count) * scale), int64(float64(size) * scale)
+}
+
+func writeGoroutine(w io.Writer, debug int) error {
+	n := runtime.NumGoroutine()
+	if debug != 0 {
+		_, err := fmt.Fprintf(w, "goroutine profile: total %d\n", n)
+		return err
+	}
+	p := &profile{
+		sampleTypes: []valueType{{"goroutine", "count"}},
+		periodType:  valueType{"goroutine", "count"},
+		period:      1,
+		timeNanos:   time.Now().UnixNano(),
+		samples: []sample{
+			{values: []int64{int64(n)}},
+		},
+	}
+	return p.write(w)
+}
+
+// Return a write function for a profile that is never populated by TinyGo.
+func writeEmpty(name string, sampleTypes ...valueType) func(w io.Writer, debug int) error {
+	return func(w io.Writer, debug int) error {
+		if debug != 0 {
+			_, err := fmt.Fprintf(w, "%s profile: total 0\n", name)
+			return err
+		}
+		p := &profile{
+			sampleTypes: sampleTypes,
+			periodType:  sampleTypes[0],
+			period:      1,
+			timeNanos:   time.Now().UnixNano(),
+		}
+		return p.write(w)
+	}
+}
+
+// Sort samples by their stack addresses, so that profiles are deterministic.
+func sortSamples(samples []sample) {
+	sort.Slice(samples, func(i, j int) bool {
+		a, b := samples[i].stack, samples[j].stack
+		for k := 0; k < len(a) && k < len(b); k++ {
+			if a[k] != b[k] {
+				return a[k] < b[k]
+			}
+		}
+		return len(a) < len(b)
+	})
+}
@@ -0,0 +1,242 @@
+package pprof
+
+// This file writes profiles in the gzipped protocol buffer format described in
+// https://github.com/google/pprof/blob/main/proto/profile.proto.
+//
+// TinyGo doesn't have a symbol table at runtime, so profiles only contain
+// addresses. The pprof tool resolves those to functions and lines using the
+// DWARF debug information in the binary, for example:
+//
+//	go tool pprof ./binary cpu.pprof
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Field numbers from profile.proto.
+const (
+	// message Profile
+	tagProfile_SampleType        = 1  // repeated ValueType
+	tagProfile_Sample            = 2  // repeated Sample
+	tagProfile_Mapping           = 3  // repeated Mapping
+	tagProfile_Location          = 4  // repeated Location
+	tagProfile_StringTable       = 6  // repeated string
+	tagProfile_TimeNanos         = 9  // int64
+	tagProfile_DurationNanos     = 10 // int64
+	tagProfile_PeriodType        = 11 // ValueType
+	tagProfile_Period            = 12 // int64
+	tagProfile_Comment           = 13 // repeated int64
+	tagProfile_DefaultSampleType = 14 // int64
+
+	// message ValueType
+	tagValueType_Type = 1 // int64 (string table index)
+	tagValueType_Unit = 2 // int64 (string table index)
+
+	// message Sample
+	tagSample_Location = 1 // repeated uint64
+	tagSample_Value    = 2 // repeated int64
+
+	// message Mapping
+	tagMapping_ID       = 1 // uint64
+	tagMapping_Start    = 2 // uint64
+	tagMapping_Limit    = 3 // uint64
+	tagMapping_Offset   = 4 // uint64
+	tagMapping_Filename = 5 // int64 (string table index)
+
+	// message Location
+	tagLocation_ID        = 1 // uint64
+	tagLocation_MappingID = 2 // uint64
+	tagLocation_Address   = 3 // uint64
+)
+
+type valueType struct {
+	typ, unit string
+}
+
+type sample struct {
+	// Stack of program counters, innermost frame first.
+	stack  []uintptr
+	values []int64
+}
+
+// profile is an in-memory representation of a profile, that can be written in
+// the protobuf format using write.
+type profile struct {
+	sampleTypes       []valueType
+	defaultSampleType string
+	periodType        valueType
+	period            int64
+	timeNanos         int64
+	durationNanos     int64
+	comments          []string
+	samples           []sample
+}
+
+type memMap struct {
+	start, end, offset uint64
+	file               string
+}
+
+// profileWriter writes a single profile. It keeps track of the string table
+// and deduplicates locations.
+type profileWriter struct {
+	pb        protobuf
+	strings   []string
+	stringMap map[string]int
+	locs      map[uintptr]uint64
+	mappings  []memMap
+}
+
+func (b *profileWriter) stringIndex(s string) int64 {
+	id, ok := b.stringMap[s]
+	if !ok {
+		id = len(b.strings)
+		b.strings = append(b.strings, s)
+		b.stringMap[s] = id
+	}
+	return int64(id)
+}
+
+func (b *profileWriter) valueType(tag int, t valueType) {
+	start := b.pb.startMessage()
+	b.pb.int64(tagValueType_Type, b.stringIndex(t.typ))
+	b.pb.int64(tagValueType_Unit, b.stringIndex(t.unit))
+	b.pb.endMessage(tag, start)
+}
+
+// Return the location ID for the given address, adding a new location if
+// needed.
+func (b *profileWriter) location(addr uintptr) uint64 {
+	if id, ok := b.locs[addr]; ok {
+		return id
+	}
+	id := uint64(len(b.locs) + 1)
+	b.locs[addr] = id
+	return id
+}
+
+// Write the profile to w as a gzip compressed protocol buffer.
+func (p *profile) write(w io.Writer) error {
+	b := &profileWriter{
+		strings:   []string{""},
+		stringMap: map[string]int{"": 0},
+		locs:      make(map[uintptr]uint64),
+		mappings:  readMappings(),
+	}
+
+	for _, t := range p.sampleTypes {
+		b.valueType(tagProfile_SampleType, t)
+	}
+
+	var locs []uint64
+	for _, s := range p.samples {
+		locs = locs[:0]
+		for _, pc := range s.stack {
+			locs = append(locs, b.location(pc))
+		}
+		start := b.pb.startMessage()
+		b.pb.uint64s(tagSample_Location, locs)
+		b.pb.int64s(tagSample_Value, s.values)
+		b.pb.endMessage(tagProfile_Sample, start)
+	}
+
+	for i, m := range b.mappings {
+		start := b.pb.startMessage()
+		b.pb.uint64Opt(tagMapping_ID, uint64(i+1))
+		b.pb.uint64Opt(tagMapping_Start, m.start)
+		b.pb.uint64Opt(tagMapping_Limit, m.end)
+		b.pb.uint64Opt(tagMapping_Offset, m.offset)
+		b.pb.int64Opt(tagMapping_Filename, b.stringIndex(m.file))
+		b.pb.endMessage(tagProfile_Mapping, start)
+	}
+
+	// Write locations in ID order, so that the output is deterministic.
+	addrs := make([]uintptr, len(b.locs))
+	for addr, id := range b.locs {
+		addrs[id-1] = addr
+	}
+	for i, addr := range addrs {
+		start := b.pb.startMessage()
+		b.pb.uint64Opt(tagLocation_ID, uint64(i+1))
+		for j, m := range b.mappings {
+			if uint64(addr) >= m.start && uint64(addr) < m.end {
+				b.pb.uint64Opt(tagLocation_MappingID, uint64(j+1))
+				break
+			}
+		}
+		b.pb.uint64Opt(tagLocation_Address, uint64(addr))
+		b.pb.endMessage(tagProfile_Location, start)
+	}
+
+	b.pb.int64Opt(tagProfile_TimeNanos, p.timeNanos)
+	b.pb.int64Opt(tagProfile_DurationNanos, p.durationNanos)
+	b.valueType(tagProfile_PeriodType, p.periodType)
+	b.pb.int64Opt(tagProfile_Period, p.period)
+	for _, comment := range p.comments {
+		b.pb.int64(tagProfile_Comment, b.stringIndex(comment))
+	}
+	if p.defaultSampleType != "" {
+		b.pb.int64(tagProfile_DefaultSampleType, b.stringIndex(p.defaultSampleType))
+	}
+
+	// The string table must be written last, as the code above may still add
+	// strings to it.
+	b.pb.strings(tagProfile_StringTable, b.strings)
+
+	zw := gzip.NewWriter(w)
+	if _, err := zw.Write(b.pb.data); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// Return the executable memory mappings of the current process. The pprof tool
+// needs them to find the binary for each address.
+func readMappings() []memMap {
+	var mappings []memMap
+	if data, err := os.ReadFile("/proc/self/maps"); err == nil {
+		// Each line looks like this:
+		//   00400000-00452000 r-xp 00000000 08:02 173521   /usr/bin/dbus-daemon
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		for scanner.Scan() {
+			fields := strings.Fields(scanner.Text())
+			if len(fields) < 6 || !strings.Contains(fields[1], "x") {
+				continue
+			}
+			addrs := strings.SplitN(fields[0], "-", 2)
+			if len(addrs) != 2 {
+				continue
+			}
+			start, err1 := strconv.ParseUint(addrs[0], 16, 64)
+			end, err2 := strconv.ParseUint(addrs[1], 16, 64)
+			offset, err3 := strconv.ParseUint(fields[2], 16, 64)
+			if err1 != nil || err2 != nil || err3 != nil {
+				continue
+			}
+			mappings = append(mappings, memMap{
+				start:  start,
+				end:    end,
+				offset: offset,
+				file:   fields[5],
+			})
+		}
+	}
+	if len(mappings) == 0 {
+		// Not on Linux, or /proc is not available. Assume the executable is
+		// loaded at the addresses it was linked at, which is true for
+		// non-PIE binaries and WebAssembly.
+		file, _ := os.Executable()
+		mappings = append(mappings, memMap{
+			start: 0,
+			end:   ^uint64(0),
+			file:  file,
+		})
+	}
+	return mappings
+}
@@ -0,0 +1,110 @@
+package pprof
+
+// A minimal protocol buffer encoder, just enough to write profile.proto
+// messages without depending on a protobuf library.
+
+type protobuf struct {
+	data []byte
+	tmp  [16]byte
+}
+
+type msgOffset int
+
+func (b *protobuf) varint(x uint64) {
+	for x >= 128 {
+		b.data = append(b.data, byte(x)|0x80)
+		x >>= 7
+	}
+	b.data = append(b.data, byte(x))
+}
+
+func (b *protobuf) length(tag int, length int) {
+	b.varint(uint64(tag)<<3 | 2)
+	b.varint(uint64(length))
+}
+
+func (b *protobuf) uint64(tag int, x uint64) {
+	// varint format
+	b.varint(uint64(tag)<<3 | 0)
+	b.varint(x)
+}
+
+// Write a non-zero uint64. Zero values are the default, so they can be left
+// out.
+func (b *protobuf) uint64Opt(tag int, x uint64) {
+	if x == 0 {
+		return
+	}
+	b.uint64(tag, x)
+}
+
+// Write a list of uint64 values in packed format.
+func (b *protobuf) uint64s(tag int, x []uint64) {
+	if len(x) == 0 {
+		return
+	}
+	start := b.startMessage()
+	for _, u := range x {
+		b.varint(u)
+	}
+	b.endMessage(tag, start)
+}
+
+func (b *protobuf) int64(tag int, x int64) {
+	u := uint64(x)
+	b.uint64(tag, u)
+}
+
+func (b *protobuf) int64Opt(tag int, x int64) {
+	if x == 0 {
+		return
+	}
+	b.int64(tag, x)
+}
+
+// Write a list of int64 values in packed format.
+func (b *protobuf) int64s(tag int, x []int64) {
+	if len(x) == 0 {
+		return
+	}
+	start := b.startMessage()
+	for _, u := range x {
+		b.varint(uint64(u))
+	}
+	b.endMessage(tag, start)
+}
+
+func (b *protobuf) string(tag int, x string) {
+	b.length(tag, len(x))
+	b.data = append(b.data, x...)
+}
+
+func (b *protobuf) strings(tag int, x []string) {
+	for _, s := range x {
+		b.string(tag, s)
+	}
+}
+
+func (b *protobuf) boolOpt(tag int, x bool) {
+	if x {
+		b.uint64(tag, 1)
+	}
+}
+
+// Start a nested message. The message contents must be written next, followed
+// by a call to endMessage.
+func (b *protobuf) startMessage() msgOffset {
+	return msgOffset(len(b.data))
+}
+
+// Finish a nested message: insert the tag and length before the message
+// contents that were written since startMessage.
+func (b *protobuf) endMessage(tag int, start msgOffset) {
+	n1 := int(start)
+	n2 := len(b.data)
+	b.length(tag, n2-n1)
+	n3 := len(b.data)
+	copy(b.tmp[:], b.data[n2:n3])
+	copy(b.data[n1+(n3-n2):], b.data[n1:n2])
+	copy(b.data[n1:], b.tmp[:n3-n2])
+}
@@ -17,7 +17,7 @@ func callMain()
 
 func GOMAXPROCS(n int) int {
 	// Note: setting GOMAXPROCS is ignored.
-	return 1
+	return numCPU()
 }
 
 func GOROOT() string {
@@ -9,10 +9,14 @@
 #include <stdint.h>
 #include <ucontext.h>
 #include <string.h>
+#include <sys/time.h>
 
 void tinygo_handle_fatal_signal(int sig, uintptr_t addr);
+void tinygo_cpuprofile_signal(uintptr_t pc, uintptr_t fp);
 
-static void signal_handler(int sig, siginfo_t *info, void *context) {
+// Return the instruction pointer (program counter) stored in the context passed
+// to a SA_SIGINFO signal handler.
+static uintptr_t context_pc(void *context) {
 	ucontext_t* uctx = context;
 	uintptr_t addr = 0;
 	#if __APPLE__
@@ -38,7 +42,36 @@ static void signal_handler(int sig, siginfo_t *info, void *context) {
 	#else
 		#error unknown platform
 	#endif
-	tinygo_handle_fatal_signal(sig, addr);
+	return addr;
+}
+
+// Return the frame pointer stored in the context passed to a SA_SIGINFO signal
+// handler, or 0 if it isn't known for this architecture.
+static uintptr_t context_fp(void *context) {
+	ucontext_t* uctx = context;
+	uintptr_t fp = 0;
+	#if __APPLE__
+		#if __arm64__
+			fp = uctx->uc_mcontext->__ss.__fp;
+		#elif __x86_64__
+			fp = uctx->uc_mcontext->__ss.__rbp;
+		#endif
+	#elif __linux__
+		#if __arm__
+			fp = uctx->uc_mcontext.arm_fp;
+		#elif __i386__
+			fp = uctx->uc_mcontext.gregs[REG_EBP];
+		#elif __x86_64__
+			fp = uctx->uc_mcontext.gregs[REG_RBP];
+		#elif __aarch64__
+			fp = uctx->uc_mcontext.regs[29];
+		#endif
+	#endif
+	return fp;
+}
+
+static void signal_handler(int sig, siginfo_t *info, void *context) {
+	tinygo_handle_fatal_signal(sig, context_pc(context));
 }
 
 void tinygo_register_fatal_signals(void) {
@@ -54,3 +87,33 @@ void tinygo_register_fatal_signals(void) {
 	sigaction(SIGILL, &act, NULL);
 	sigaction(SIGSEGV, &act, NULL);
 }
+
+static void profile_signal_handler(int sig, siginfo_t *info, void *context) {
+	tinygo_cpuprofile_signal(context_pc(context), context_fp(context));
+}
+
+// Start sending SIGPROF to the process hz times per second of consumed CPU
+// time. Every signal records the interrupted call stack.
+void tinygo_cpuprofile_start(int32_t hz) {
+	struct sigaction act = { 0 };
+	// SA_RESTART: don't interrupt blocking system calls with a profiling signal
+	act.sa_flags = SA_SIGINFO | SA_RESTART;
+	act.sa_sigaction = &profile_signal_handler;
+	sigaction(SIGPROF, &act, NULL);
+
+	struct itimerval it = { 0 };
+	it.it_interval.tv_usec = 1000000 / hz;
+	it.it_value = it.it_interval;
+	setitimer(ITIMER_PROF, &it, NULL);
+}
+
+// Stop the profiling timer started by tinygo_cpuprofile_start.
+void tinygo_cpuprofile_stop(void) {
+	struct itimerval it = { 0 };
+	setitimer(ITIMER_PROF, &it, NULL);
+
+	// A signal might still be pending, ignore it.
+	struct sigaction act = { 0 };
+	act.sa_handler = SIG_IGN;
+	sigaction(SIGPROF, &act, NULL);
+}
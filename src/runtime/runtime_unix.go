@@ -282,8 +282,8 @@ func syscall_Exit(code int) {
 	exit(code)
 }
 
-// TinyGo does not yet support any form of parallelism on an OS, so these can be
-// left empty.
+// Preemption can't be disabled when goroutines are threads, but other threads
+// only spin while waiting for a pinned goroutine so these can be left empty.
 
 //go:linkname procPin sync/atomic.runtime_procPin
 func procPin() {
@@ -299,6 +299,10 @@ var heapMaxSize uintptr
 var heapStart, heapEnd uintptr
 
 func preinit() {
+	// Map the shadow memory before the heap is used, when built with
+	// -sanitize=address.
+	asanInit()
+
 	// Allocate a large chunk of virtual memory. Because it is virtual, it won't
 	// really be allocated in RAM. Memory will only be allocated when it is
 	// first touched.
@@ -438,6 +442,12 @@ func tinygo_signal_handler(s int32) {
 		// goroutines.
 		signalFutex.WakeAll()
 	}
+
+	if hasParallelism {
+		// There is no scheduler that calls checkSignals, so resume the
+		// goroutine waiting for signals directly.
+		checkSignals()
+	}
 }
 
 // Task waiting for a signal to arrive, or nil if it is running or there are no
@@ -455,6 +465,16 @@ func signal_recv() uint32 {
 				// We expect only a single goroutine to call signal_recv.
 				runtimePanic("signal_recv called concurrently")
 			}
+			if hasParallelism && receivedSignals.Load() != 0 {
+				// A signal arrived after the check above, and the signal
+				// handler may not have seen this goroutine as the waiter.
+				if signalRecvWaiter.Swap(nil) != nil {
+					// Not resumed by the signal handler, so don't pause.
+					continue
+				}
+				// The signal handler resumed this goroutine, so the call to
+				// Pause below will return immediately.
+			}
 			task.Pause()
 			continue
 		}
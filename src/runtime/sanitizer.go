@@ -0,0 +1,61 @@
+//go:build tinygo.asan || tinygo.ubsan
+
+package runtime
+
+import "unsafe"
+
+// Support code shared by the sanitizer runtimes (-sanitize=address and
+// -sanitize=undefined).
+
+//export getpid
+func libc_getpid() int32
+
+// Print the stack trace of the current goroutine, in the format used by the
+// sanitizers in compiler-rt. The pc is the program counter of the instruction
+// (in C code) that triggered the report: frames inside the sanitizer runtime
+// are dropped, so that the stack trace starts at this program counter.
+//
+//go:noinline
+func sanitizerPrintStack(pc uintptr) {
+	var pcs [stackMaxFrames]uintptr
+	n := Callers(1, pcs[:])
+	start := 0
+	for i := 0; i < n; i++ {
+		if pcs[i] == pc {
+			start = i
+			break
+		}
+	}
+	if n == 0 || pcs[start] != pc {
+		// The program counter wasn't found in the call stack, so print it
+		// on its own.
+		sanitizerPrintFrame(0, pc)
+		return
+	}
+	for i := start; i < n; i++ {
+		sanitizerPrintFrame(i-start, pcs[i])
+	}
+}
+
+// Print a single stack frame: the return address and the function, file and
+// line of the call instruction right before it.
+func sanitizerPrintFrame(index int, pc uintptr) {
+	print("    #", index, " ", unsafe.Pointer(pc))
+	f := findFunc(pc - 1)
+	if f.valid() {
+		file, line := f.fileLine(pc - 1)
+		print(" in ", f.name(), " ", file, ":", line)
+	}
+	println()
+}
+
+// Return the location of the given program counter as "file:line in func",
+// for use in the SUMMARY line of a report.
+func sanitizerLocation(pc uintptr) (name, file string, line int) {
+	f := findFunc(pc - 1)
+	if !f.valid() {
+		return "", "", 0
+	}
+	file, line = f.fileLine(pc - 1)
+	return f.name(), file, line
+}
@@ -6,6 +6,9 @@ const schedulerDebug = false
 
 var mainExited bool
 
+// Queue of timers, sorted by the time at which they expire.
+var timerQueue *timerNode
+
 // Simple logging, for debugging.
 func scheduleLog(msg string) {
 	if schedulerDebug {
@@ -42,3 +45,35 @@ func fips_setIndicator(indicator uint8) {
 	// This indicator is stored per goroutine.
 	task.Current().FipsIndicator = indicator
 }
+
+// timerQueueAdd adds the given timer node to the timer queue. It must not be in
+// the queue already. The caller must make sure the timer queue isn't modified
+// concurrently.
+// This function is very similar to addSleepTask but for timerQueue instead of
+// sleepQueue.
+func timerQueueAdd(tim *timerNode) {
+	q := &timerQueue
+	for ; *q != nil; q = &(*q).next {
+		if tim.whenTicks() < (*q).whenTicks() {
+			// this will finish earlier than the next - insert here
+			break
+		}
+	}
+	tim.next = *q
+	*q = tim
+}
+
+// timerQueueRemove removes a timer from the timer queue, returning true if the
+// timer was present in the timer queue. The caller must make sure the timer
+// queue isn't modified concurrently.
+func timerQueueRemove(tim *timer) bool {
+	for t := &timerQueue; *t != nil; t = &(*t).next {
+		if (*t).timer == tim {
+			scheduleLog("removed timer")
+			*t = (*t).next
+			return true
+		}
+	}
+	scheduleLog("did not remove timer")
+	return false
+}
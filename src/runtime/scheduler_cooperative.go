@@ -32,7 +32,6 @@ var (
 	runqueue           task.Queue
 	sleepQueue         *task.Task
 	sleepQueueBaseTime timeUnit
-	timerQueue         *timerNode
 )
 
 // deadlock is called when a goroutine cannot proceed any more, but is in theory
@@ -44,20 +43,33 @@ var (
 //go:noinline
 func deadlock() {
 	// call yield without requesting a wakeup
+	traceBlock("forever")
 	task.Pause()
 	panic("unreachable")
 }
 
 // Add this task to the end of the run queue.
 func scheduleTask(t *task.Task) {
+	traceGoUnblock(t)
 	runqueue.Push(t)
 }
 
+// NumGoroutine returns the number of goroutines that currently exist.
+func NumGoroutine() int {
+	return task.Count()
+}
+
 func Gosched() {
-	runqueue.Push(task.Current())
+	scheduleTask(task.Current())
 	task.Pause()
 }
 
+// numCPU returns the number of CPUs that can run goroutines in parallel. The
+// cooperative scheduler runs all goroutines on a single CPU.
+func numCPU() int {
+	return 1
+}
+
 // Add this task to the sleep queue, assuming its state is set to sleeping.
 func addSleepTask(t *task.Task, duration timeUnit) {
 	if schedulerDebug {
@@ -96,44 +108,53 @@ func addSleepTask(t *task.Task, duration timeUnit) {
 
 // addTimer adds the given timer node to the timer queue. It must not be in the
 // queue already.
-// This function is very similar to addSleepTask but for timerQueue instead of
-// sleepQueue.
 func addTimer(tim *timerNode) {
 	mask := interrupt.Disable()
-
-	// Add to timer queue.
-	q := &timerQueue
-	for ; *q != nil; q = &(*q).next {
-		if tim.whenTicks() < (*q).whenTicks() {
-			// this will finish earlier than the next - insert here
-			break
-		}
-	}
-	tim.next = *q
-	*q = tim
+	timerQueueAdd(tim)
 	interrupt.Restore(mask)
 }
 
 // removeTimer is the implementation of time.stopTimer. It removes a timer from
 // the timer queue, returning true if the timer is present in the timer queue.
 func removeTimer(tim *timer) bool {
-	removedTimer := false
 	mask := interrupt.Disable()
-	for t := &timerQueue; *t != nil; t = &(*t).next {
-		if (*t).timer == tim {
-			scheduleLog("removed timer")
-			*t = (*t).next
-			removedTimer = true
-			break
-		}
-	}
-	if !removedTimer {
-		scheduleLog("did not remove timer")
-	}
+	removedTimer := timerQueueRemove(tim)
 	interrupt.Restore(mask)
 	return removedTimer
 }
 
+// walkTasks calls fn for every goroutine except the current one, together with
+// a short description of its state: goroutines in the runqueue are runnable,
+// goroutines in the sleep queue are sleeping and all other goroutines are
+// blocked (on a channel or lock for example). Interrupts must be disabled while
+// calling walkTasks.
+func walkTasks(fn func(t *task.Task, state string)) {
+	current := task.Current()
+	task.All(func(t *task.Task) {
+		if t == current {
+			return
+		}
+		state := "waiting"
+		if taskInQueue(t, runqueue.Peek()) {
+			state = "runnable"
+		} else if taskInQueue(t, sleepQueue) {
+			state = "sleep"
+		}
+		fn(t, state)
+	})
+}
+
+// taskInQueue returns whether t is part of the queue (linked via Next) starting
+// at q.
+func taskInQueue(t, q *task.Task) bool {
+	for ; q != nil; q = q.Next {
+		if q == t {
+			return true
+		}
+	}
+	return false
+}
+
 func schedulerRunQueue() *task.Queue {
 	return &runqueue
 }
@@ -163,7 +184,7 @@ func scheduler(returnAtDeadlock bool) {
 			sleepQueueBaseTime += timeUnit(t.Data)
 			sleepQueue = t.Next
 			t.Next = nil
-			runqueue.Push(t)
+			scheduleTask(t)
 		}
 
 		// Check for expired timers to trigger.
@@ -227,7 +248,9 @@ func scheduler(returnAtDeadlock bool) {
 
 		// Run the given task.
 		scheduleLogTask("  run:", t)
+		traceGoStart(t)
 		t.Resume()
+		traceGoPark(t)
 	}
 }
 
@@ -240,6 +263,7 @@ func sleep(duration int64) {
 	}
 
 	addSleepTask(task.Current(), nanosecondsToTicks(duration))
+	traceBlock("sleep")
 	task.Pause()
 }
 
@@ -37,10 +37,22 @@ func scheduleTask(t *task.Task) {
 	// Pause() will panic, so this should not be reachable.
 }
 
+// NumGoroutine returns the number of goroutines that currently exist. Without
+// a scheduler, that's only the main goroutine.
+func NumGoroutine() int {
+	return 1
+}
+
 func Gosched() {
 	// There are no other goroutines, so there's nothing to schedule.
 }
 
+// numCPU returns the number of CPUs that can run goroutines in parallel. There
+// are no other goroutines, so that is just one.
+func numCPU() int {
+	return 1
+}
+
 func addTimer(tim *timerNode) {
 	runtimePanic("timers not supported without a scheduler")
 }
@@ -50,6 +62,11 @@ func removeTimer(tim *timer) bool {
 	return false
 }
 
+// walkTasks calls fn for every goroutine waiting to be run. There are none
+// without a scheduler.
+func walkTasks(fn func(t *task.Task, state string)) {
+}
+
 func schedulerRunQueue() *task.Queue {
 	// This function is not actually used, it is only called when hasScheduler
 	// is true.
@@ -0,0 +1,158 @@
+//go:build scheduler.threads
+
+package runtime
+
+// This file implements the "scheduler" for -scheduler=threads. There is no
+// real scheduler: every goroutine runs in its own OS thread (taken from a pool
+// of idle threads if possible, see internal/task) and the operating system
+// decides which goroutine runs when. Blocking operations (channels, sync
+// primitives) pause the thread using a futex.
+
+import "internal/task"
+
+// There is no cooperative scheduler (with a runqueue etc).
+const hasScheduler = false
+
+// Goroutines run in parallel, so certain operations need to be locked.
+const hasParallelism = true
+
+// run is called by the program entry point to execute the go program.
+// The main goroutine runs directly on the main thread.
+func run() {
+	task.Init(stackTop)
+	initHeap()
+	initRand()
+	initAll()
+	callMain()
+	mainExited = true
+}
+
+// Pause the current goroutine (thread) for a given time.
+//
+//go:linkname sleep time.Sleep
+func sleep(duration int64) {
+	if duration <= 0 {
+		return
+	}
+
+	sleepTicks(nanosecondsToTicks(duration))
+}
+
+// deadlock is called when a goroutine cannot proceed any more. The thread
+// stays paused forever.
+func deadlock() {
+	task.Pause()
+	runtimePanic("unreachable")
+}
+
+// scheduleTask resumes the given (paused) goroutine.
+func scheduleTask(t *task.Task) {
+	t.Resume()
+}
+
+// NumGoroutine returns the number of goroutines that currently exist.
+func NumGoroutine() int {
+	return task.Count()
+}
+
+func Gosched() {
+	// Each goroutine runs in its own thread and the operating system preempts
+	// threads when needed, so there's nothing to do here.
+}
+
+// numCPU returns the number of CPUs that can run goroutines in parallel.
+func numCPU() int {
+	return task.NumCPU()
+}
+
+// Lock for the timer queue.
+var timerQueueLock task.PMutex
+
+// Futex used to wake the timer goroutine when a timer is added. The value is
+// incremented for every added timer.
+var timerFutex task.Futex
+
+// Whether the timer goroutine has been started.
+var timerRunnerStarted bool
+
+// timerRunner is a goroutine that runs timer callbacks when they expire.
+func timerRunner() {
+	for {
+		timerQueueLock.Lock()
+
+		if timerQueue == nil {
+			// No timers in the queue, wait until one is added.
+			val := timerFutex.Load()
+			timerQueueLock.Unlock()
+			timerFutex.Wait(val)
+			continue
+		}
+
+		now := ticks()
+		if now < timerQueue.whenTicks() {
+			// Wait until the first timer expires, or until a timer is added.
+			val := timerFutex.Load()
+			timeout := ticksToNanoseconds(timerQueue.whenTicks() - now)
+			timerQueueLock.Unlock()
+			timerFutex.WaitUntil(val, uint64(timeout))
+			continue
+		}
+
+		// Pop timer from queue.
+		tn := timerQueue
+		timerQueue = tn.next
+		tn.next = nil
+		timerQueueLock.Unlock()
+
+		// Run the callback stored in this timer node.
+		delay := ticksToNanoseconds(now - tn.whenTicks())
+		tn.callback(tn, delay)
+	}
+}
+
+// addTimer adds the given timer node to the timer queue. It must not be in the
+// queue already.
+func addTimer(tim *timerNode) {
+	timerQueueLock.Lock()
+	timerQueueAdd(tim)
+	startRunner := !timerRunnerStarted
+	timerRunnerStarted = true
+	timerQueueLock.Unlock()
+
+	if startRunner {
+		go timerRunner()
+	}
+
+	// Wake up the timer goroutine, the new timer may expire before the one it
+	// is currently waiting for.
+	timerFutex.Add(1)
+	timerFutex.Wake()
+}
+
+// removeTimer is the implementation of time.stopTimer. It removes a timer from
+// the timer queue, returning true if the timer is present in the timer queue.
+func removeTimer(tim *timer) bool {
+	timerQueueLock.Lock()
+	removedTimer := timerQueueRemove(tim)
+	timerQueueLock.Unlock()
+	return removedTimer
+}
+
+// walkTasks calls fn for every goroutine except the current one. Goroutines
+// run in their own thread, so they are all reported as running and their call
+// stack is not available.
+func walkTasks(fn func(t *task.Task, state string)) {
+	current := task.Current()
+	task.All(func(t *task.Task) {
+		if t != current {
+			fn(t, "running")
+		}
+	})
+}
+
+func schedulerRunQueue() *task.Queue {
+	// This function is not actually used, it is only called when hasScheduler
+	// is true.
+	runtimePanic("unreachable: no runqueue with the threads scheduler")
+	return nil
+}
@@ -19,6 +19,7 @@ func sliceAppend(srcBuf, elemsBuf unsafe.Pointer, srcLen, srcCap, elemsLen, elem
 
 		// Append the new elements in-place.
 		memmove(unsafe.Add(srcBuf, srcLen*elemSize), elemsBuf, elemsLen*elemSize)
+		gcWriteBarrier(srcBuf)
 	}
 
 	return srcBuf, newLen, srcCap
@@ -32,6 +33,9 @@ func sliceCopy(dst, src unsafe.Pointer, dstLen, srcLen uintptr, elemSize uintptr
 		n = dstLen
 	}
 	memmove(dst, src, n*elemSize)
+	if n != 0 {
+		gcWriteBarrier(dst)
+	}
 	return int(n)
 }
 
@@ -1,24 +1,137 @@
 package runtime
 
+import (
+	"internal/task"
+	"runtime/interrupt"
+)
+
+// A Func represents a Go function in the running binary.
 type Func struct {
+	info funcInfo
 }
 
+// FuncForPC returns a *Func describing the function that contains the
+// given program counter address, or else nil. This is only possible when the
+// program was built with the -symtab flag.
 func FuncForPC(pc uintptr) *Func {
-	return nil
+	f := findFunc(pc)
+	if !f.valid() {
+		return nil
+	}
+	return &Func{info: f}
 }
 
+// Name returns the name of the function.
 func (f *Func) Name() string {
-	return ""
+	if f == nil {
+		return ""
+	}
+	return f.info.name()
 }
 
-func (f *Func) FileLine(pc uintptr) (file string, line int) {
-	return "", 0
+// Entry returns the entry address of the function.
+func (f *Func) Entry() uintptr {
+	if f == nil {
+		return 0
+	}
+	return f.info.entry()
 }
 
-func Caller(skip int) (pc uintptr, file string, line int, ok bool) {
-	return 0, "", 0, false
+// FileLine returns the file name and line number of the
+// source code corresponding to the program counter pc.
+// The result will not be accurate if pc is not a program
+// counter within f.
+func (f *Func) FileLine(pc uintptr) (file string, line int) {
+	if f == nil {
+		return "", 0
+	}
+	return f.info.fileLine(pc)
 }
 
+// Stack formats a stack trace of the calling goroutine into buf
+// and returns the number of bytes written to buf.
+// If all is true, Stack formats stack traces of all other goroutines
+// into buf after the trace for the current goroutine.
+//
+// Function names, files and lines are only available when the program was
+// built with the -symtab flag. Without it, only the goroutine headers are
+// written. Goroutines are numbered in the order they are written, as TinyGo
+// doesn't keep goroutine IDs. With -scheduler=threads, only the headers of
+// the other goroutines are written.
+//
+//go:noinline
 func Stack(buf []byte, all bool) int {
-	return 0
+	w := stackWriter{buf: buf}
+	var pcs [stackMaxFrames]uintptr
+	n := Callers(2, pcs[:])
+	w.goroutine(1, "running", pcs[:n])
+	if all {
+		id := 2
+		mask := interrupt.Disable()
+		walkTasks(func(t *task.Task, state string) {
+			n := taskCallers(t, pcs[:])
+			w.writeString("\n")
+			w.goroutine(id, state, pcs[:n])
+			id++
+		})
+		interrupt.Restore(mask)
+	}
+	return w.n
+}
+
+// Maximum number of frames printed per goroutine by Stack.
+const stackMaxFrames = 32
+
+// stackWriter formats stack traces into a fixed buffer, without allocating.
+// Output that doesn't fit in the buffer is dropped.
+type stackWriter struct {
+	buf []byte
+	n   int
+}
+
+func (w *stackWriter) writeString(s string) {
+	w.n += copy(w.buf[w.n:], s)
+}
+
+func (w *stackWriter) writeUint(n uintptr, base uintptr) {
+	var digits [20]byte
+	i := len(digits)
+	for {
+		i--
+		digits[i] = "0123456789abcdef"[n%base]
+		n /= base
+		if n == 0 {
+			break
+		}
+	}
+	w.n += copy(w.buf[w.n:], digits[i:])
+}
+
+// goroutine writes the header and stack trace of a single goroutine, in the
+// same format as the gc toolchain.
+func (w *stackWriter) goroutine(id int, state string, pcs []uintptr) {
+	w.writeString("goroutine ")
+	w.writeUint(uintptr(id), 10)
+	w.writeString(" [")
+	w.writeString(state)
+	w.writeString("]:\n")
+	// Don't use CallersFrames, which allocates. With -scheduler=threads,
+	// goroutines are walked while holding a lock that the GC needs.
+	frames := Frames{callers: pcs}
+	for {
+		frame, more := frames.Next()
+		if frame.Function != "" {
+			w.writeString(frame.Function)
+			w.writeString("(...)\n\t")
+			w.writeString(frame.File)
+			w.writeString(":")
+			w.writeUint(uintptr(frame.Line), 10)
+			w.writeString(" +0x")
+			w.writeUint(frame.PC-frame.Entry, 16)
+			w.writeString("\n")
+		}
+		if !more {
+			break
+		}
+	}
 }
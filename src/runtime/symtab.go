@@ -1,26 +1,75 @@
 package runtime
 
+// Frames may be used to get function/file/line information for a
+// slice of PC values returned by Callers.
+//
+// Function names, files and lines are only available when the program was
+// built with the -symtab flag. Without it, Next never returns any frames.
 type Frames struct {
-	//
+	// callers is a slice of PCs that have not yet been expanded to frames.
+	callers []uintptr
 }
 
+// Frame is the information returned by Frames for each call frame.
 type Frame struct {
+	// PC is the program counter for the location in this frame.
+	// For a frame that calls another frame, this will be the
+	// program counter of a call instruction. Because of pipelining,
+	// it may be slightly off.
 	PC uintptr
 
+	// Func is the Func value of this call frame. This may be nil
+	// for non-Go code or fully inlined functions.
 	Func *Func
 
+	// Function is the package path-qualified function name of
+	// this call frame. If non-empty, this string uniquely
+	// identifies a single function in the program.
+	// This may be the empty string if not known.
 	Function string
 
+	// File and Line are the file name and line number of the
+	// location in this frame. For non-leaf frames, this will be
+	// the location of a call. These may be the empty string and
+	// zero, respectively, if not known.
 	File string
 	Line int
 
+	// Entry point program counter for the function; may be zero
+	// if not known.
 	Entry uintptr
 }
 
+// CallersFrames takes a slice of PCs returned by Callers and
+// prepares to return function/file/line information.
+// Do not change the slice until you are done with the Frames.
 func CallersFrames(callers []uintptr) *Frames {
-	return nil
+	return &Frames{callers: callers}
 }
 
+// Next returns a Frame representing the next call frame in the slice
+// of PC values, and reports whether there are more frames to return.
+// PCs for which no function is known are skipped.
 func (ci *Frames) Next() (frame Frame, more bool) {
+	for len(ci.callers) > 0 {
+		pc := ci.callers[0]
+		ci.callers = ci.callers[1:]
+
+		// The PCs returned by Callers are return addresses, so look up the
+		// instruction before it: the call instruction.
+		f := findFunc(pc - 1)
+		if !f.valid() {
+			continue
+		}
+		if pc > f.entry() {
+			pc--
+		}
+		frame.PC = pc
+		frame.Func = &Func{info: f}
+		frame.Function = f.name()
+		frame.File, frame.Line = f.fileLine(pc)
+		frame.Entry = f.entry()
+		return frame, len(ci.callers) > 0
+	}
 	return Frame{}, false
 }
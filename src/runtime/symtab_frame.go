@@ -0,0 +1,13 @@
+//go:build tinygo.symtab && !tinygo.riscv
+
+package runtime
+
+import "unsafe"
+
+// Layout of a frame record: the frame pointer points to the saved frame pointer
+// of the caller, followed by the return address. This is the case on x86,
+// ARM (both r11 and the r7 frame pointer in Thumb mode) and AArch64.
+const (
+	framePointerOffset = 0
+	frameReturnOffset  = int(unsafe.Sizeof(uintptr(0)))
+)
@@ -0,0 +1,13 @@
+//go:build tinygo.symtab && tinygo.riscv
+
+package runtime
+
+import "unsafe"
+
+// Layout of a frame record on RISC-V: the frame pointer (s0) points to the
+// stack pointer on entry, right above the return address and the saved frame
+// pointer of the caller.
+const (
+	framePointerOffset = -2 * int(unsafe.Sizeof(uintptr(0)))
+	frameReturnOffset  = -int(unsafe.Sizeof(uintptr(0)))
+)
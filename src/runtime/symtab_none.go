@@ -0,0 +1,52 @@
+//go:build !tinygo.symtab
+
+package runtime
+
+import "internal/task"
+
+// No symbol table is available, because the program wasn't built with the
+// -symtab flag.
+
+type funcInfo struct{}
+
+func findFunc(pc uintptr) funcInfo {
+	return funcInfo{}
+}
+
+func (f funcInfo) valid() bool {
+	return false
+}
+
+func (f funcInfo) entry() uintptr {
+	return 0
+}
+
+func (f funcInfo) name() string {
+	return ""
+}
+
+func (f funcInfo) fileLine(pc uintptr) (file string, line int) {
+	return "", 0
+}
+
+// Callers fills the slice pc with the return program counters of function
+// invocations on the calling goroutine's stack. This is only supported when
+// the program was built with the -symtab flag, otherwise it returns 0.
+func Callers(skip int, pc []uintptr) int {
+	return 0
+}
+
+// Caller reports file and line number information about function invocations
+// on the calling goroutine's stack. This is only supported when the program
+// was built with the -symtab flag, otherwise ok is always false.
+func Caller(skip int) (pc uintptr, file string, line int, ok bool) {
+	return 0, "", 0, false
+}
+
+func signalCallers(fp uintptr, pc []uintptr) int {
+	return 0
+}
+
+func taskCallers(t *task.Task, pc []uintptr) int {
+	return 0
+}
@@ -0,0 +1,292 @@
+//go:build tinygo.symtab
+
+package runtime
+
+// Symbol table support, enabled with the -symtab flag.
+//
+// The builder embeds a table in the binary (see builder/symtab.go) that maps
+// program counters to function names and to file/line pairs. It is derived from
+// the DWARF debug information of the linked program. Call stacks are walked
+// using frame pointers, which are kept in every function when -symtab is used.
+//
+// The table has the following layout:
+//
+//	header  symtabHeader
+//	funcs   [numFuncs]symtabFunc (sorted by entry address)
+//	files   [numFiles]uint32     (offsets of file names in the blob)
+//	blob    strings and line tables
+//
+// Strings are stored as a uvarint length followed by the string bytes. A line
+// table is stored as a uvarint row count, followed by the rows. Each row is a
+// uvarint address delta (relative to the previous row or the function entry), a
+// uvarint file index and a zigzag encoded line delta.
+
+import (
+	"internal/task"
+	"unsafe"
+)
+
+//go:extern tinygo_symtab
+var symtabData [0]byte
+
+type symtabHeader struct {
+	numFuncs uint32
+	numFiles uint32
+}
+
+type symtabFunc struct {
+	entry uintptr
+	size  uint32
+	name  uint32 // offset in blob
+	lines uint32 // offset in blob
+}
+
+//export llvm.frameaddress.p0
+func frameAddress(level uint32) unsafe.Pointer
+
+func symtabHeaderPtr() *symtabHeader {
+	return (*symtabHeader)(unsafe.Pointer(&symtabData))
+}
+
+func symtabFuncs() []symtabFunc {
+	header := symtabHeaderPtr()
+	return unsafe.Slice((*symtabFunc)(unsafe.Add(unsafe.Pointer(header), unsafe.Sizeof(symtabHeader{}))), header.numFuncs)
+}
+
+func symtabFiles() []uint32 {
+	funcs := symtabFuncs()
+	start := unsafe.Add(unsafe.Pointer(unsafe.SliceData(funcs)), uintptr(len(funcs))*unsafe.Sizeof(symtabFunc{}))
+	return unsafe.Slice((*uint32)(start), symtabHeaderPtr().numFiles)
+}
+
+// Return a pointer into the blob at the given offset.
+func symtabBlob(offset uint32) unsafe.Pointer {
+	files := symtabFiles()
+	start := unsafe.Add(unsafe.Pointer(unsafe.SliceData(files)), uintptr(len(files))*4)
+	return unsafe.Add(start, offset)
+}
+
+// Read an unsigned varint at p, and return it together with a pointer to the
+// next byte.
+func symtabUvarint(p unsafe.Pointer) (uintptr, unsafe.Pointer) {
+	var x uintptr
+	var shift uint
+	for {
+		b := *(*byte)(p)
+		p = unsafe.Add(p, 1)
+		x |= uintptr(b&0x7f) << shift
+		if b < 0x80 {
+			return x, p
+		}
+		shift += 7
+	}
+}
+
+// Read a string from the blob.
+func symtabString(offset uint32) string {
+	length, p := symtabUvarint(symtabBlob(offset))
+	s := _string{
+		ptr:    (*byte)(p),
+		length: length,
+	}
+	return *(*string)(unsafe.Pointer(&s))
+}
+
+// funcInfo refers to a function in the symbol table.
+type funcInfo struct {
+	fn *symtabFunc
+}
+
+// findFunc returns the function that contains the given PC, if it exists.
+func findFunc(pc uintptr) funcInfo {
+	funcs := symtabFuncs()
+
+	// Binary search for the last function that starts at or before pc.
+	low, high := 0, len(funcs)
+	for low < high {
+		mid := int(uint(low+high) >> 1)
+		if funcs[mid].entry <= pc {
+			low = mid + 1
+		} else {
+			high = mid
+		}
+	}
+	if low == 0 {
+		return funcInfo{}
+	}
+	fn := &funcs[low-1]
+	if pc-fn.entry >= uintptr(fn.size) {
+		return funcInfo{}
+	}
+	return funcInfo{fn: fn}
+}
+
+func (f funcInfo) valid() bool {
+	return f.fn != nil
+}
+
+func (f funcInfo) entry() uintptr {
+	return f.fn.entry
+}
+
+func (f funcInfo) name() string {
+	return symtabString(f.fn.name)
+}
+
+// fileLine returns the file and line for the given PC inside this function.
+func (f funcInfo) fileLine(pc uintptr) (file string, line int) {
+	count, p := symtabUvarint(symtabBlob(f.fn.lines))
+	addr := f.fn.entry
+	rowLine := 0
+	fileIndex := -1
+	for i := uintptr(0); i < count; i++ {
+		var delta, rowFile, lineDelta uintptr
+		delta, p = symtabUvarint(p)
+		rowFile, p = symtabUvarint(p)
+		lineDelta, p = symtabUvarint(p)
+		addr += delta
+		rowLine += int(lineDelta>>1) ^ -int(lineDelta&1) // zigzag decode
+		if addr > pc {
+			break
+		}
+		fileIndex = int(rowFile)
+		line = rowLine
+	}
+	if fileIndex < 0 {
+		return "", 0
+	}
+	return symtabString(symtabFiles()[fileIndex]), line
+}
+
+// Return the PC in the calling function, right after the call to callerPC.
+//
+//go:noinline
+func callerPC() uintptr {
+	pc := uintptr(returnAddress(0))
+	if GOARCH == "arm" {
+		// Remove the Thumb bit.
+		pc &^= 1
+	}
+	return pc
+}
+
+// Return the highest frame pointer that can be walked on the current stack.
+// Goroutine stacks start with a zero frame pointer, so walking stops there
+// without needing a limit. The system stack however may start with a frame
+// pointer set by the C runtime.
+//
+//go:inline
+func currentStackLimit() uintptr {
+	if task.OnSystemStack() {
+		return stackTop
+	}
+	return ^uintptr(0)
+}
+
+// Walk the frame pointer chain starting at the frame pointer fp (and stopping
+// at limit), and store the return addresses in pc (skipping the first skip
+// frames). It returns the number of return addresses stored.
+//
+//go:inline
+func walkFrames(fp, limit uintptr, skip int, pc []uintptr) int {
+	n := 0
+	for n < len(pc) && fp != 0 && fp < limit && fp%unsafe.Alignof(fp) == 0 {
+		ret := *(*uintptr)(unsafe.Add(unsafe.Pointer(fp), frameReturnOffset))
+		next := *(*uintptr)(unsafe.Add(unsafe.Pointer(fp), framePointerOffset))
+		if GOARCH == "arm" {
+			// Remove the Thumb bit.
+			ret &^= 1
+		}
+		if ret == 0 {
+			break
+		}
+		if skip > 0 {
+			skip--
+		} else {
+			pc[n] = ret
+			n++
+		}
+		if next <= fp {
+			// The stack grows down, so the frame pointer of the caller must be
+			// at a higher address. If it isn't, it's not a valid frame
+			// pointer (for example because it was used as a general purpose
+			// register in C code).
+			break
+		}
+		fp = next
+	}
+	return n
+}
+
+// signalCallers stores the return addresses of the code that was interrupted
+// by a signal in pc, starting at the frame pointer fp of the interrupted code.
+// The signal handler runs on the same stack, so the frame pointer must be above
+// the current frame. If it isn't, the interrupted code doesn't keep a frame
+// pointer (C code for example) and no return addresses are stored.
+//
+//go:inline
+func signalCallers(fp uintptr, pc []uintptr) int {
+	if fp <= uintptr(frameAddress(0)) {
+		return 0
+	}
+	return walkFrames(fp, currentStackLimit(), 0, pc)
+}
+
+// taskCallers stores the return addresses on the stack of the paused goroutine
+// t in pc, and returns the number of return addresses stored.
+func taskCallers(t *task.Task, pc []uintptr) int {
+	resume, fp := t.PausedFrame()
+	if resume == 0 || len(pc) == 0 {
+		return 0
+	}
+	if GOARCH == "arm" {
+		resume &^= 1
+	}
+	pc[0] = resume
+	return 1 + walkFrames(fp, ^uintptr(0), 0, pc[1:])
+}
+
+// Callers fills the slice pc with the return program counters of function
+// invocations on the calling goroutine's stack. The argument skip is the number
+// of stack frames to skip before recording in pc, with 0 identifying the frame
+// for Callers itself and 1 identifying the caller of Callers.
+// It returns the number of entries written to pc.
+//
+// To translate these PCs into symbolic information such as function
+// names and line numbers, use CallersFrames.
+//
+//go:noinline
+func Callers(skip int, pc []uintptr) int {
+	if len(pc) == 0 {
+		return 0
+	}
+	n := 0
+	if skip == 0 {
+		// The frame for Callers itself.
+		pc[0] = callerPC()
+		n = 1
+	} else {
+		skip--
+	}
+	return n + walkFrames(uintptr(frameAddress(0)), currentStackLimit(), skip, pc[n:])
+}
+
+// Caller reports file and line number information about function invocations
+// on the calling goroutine's stack. The argument skip is the number of stack
+// frames to ascend, with 0 identifying the caller of Caller. The return values
+// report the program counter, file name, and line number within the file of
+// the corresponding call. The boolean ok is false if it was not possible to
+// recover the information.
+//
+//go:noinline
+func Caller(skip int) (pc uintptr, file string, line int, ok bool) {
+	var pcs [1]uintptr
+	if walkFrames(uintptr(frameAddress(0)), currentStackLimit(), skip, pcs[:]) == 0 {
+		return 0, "", 0, false
+	}
+	frame, _ := CallersFrames(pcs[:]).Next()
+	if frame.PC == 0 {
+		return 0, "", 0, false
+	}
+	return frame.PC, frame.File, frame.Line, true
+}
@@ -0,0 +1,482 @@
+package runtime
+
+// This file implements the execution tracer used by the runtime/trace package.
+//
+// The trace is written in the format introduced in Go 1.22, which is read by
+// `go tool trace` from Go 1.22 onwards. The entire trace is a single generation
+// with a single thread (M 0) running a single P (P 0), which is an accurate
+// description of the cooperative scheduler. Goroutines that already exist when
+// the trace starts are described lazily, with a status event just before their
+// first event.
+//
+// Events are stored in batches of at most 64kB that are kept in memory until
+// the trace is stopped. Stack traces are not recorded.
+//
+// The garbage collector can't emit events directly, because emitting an event
+// may allocate memory. Instead it records the time and heap size of each cycle,
+// and these are emitted the next time an event is emitted from a goroutine.
+
+import (
+	"internal/task"
+	"runtime/interrupt"
+)
+
+// Event types, see internal/trace/tracev2 in the Go source tree.
+const (
+	traceEvEventBatch      = 1
+	traceEvStrings         = 4
+	traceEvString          = 5
+	traceEvFrequency       = 8
+	traceEvProcStatus      = 13
+	traceEvGoCreate        = 14
+	traceEvGoStart         = 16
+	traceEvGoDestroy       = 17
+	traceEvGoStop          = 19
+	traceEvGoBlock         = 20
+	traceEvGoUnblock       = 21
+	traceEvGoStatus        = 25
+	traceEvGCBegin         = 29
+	traceEvGCEnd           = 30
+	traceEvHeapAlloc       = 37
+	traceEvUserTaskBegin   = 40
+	traceEvUserTaskEnd     = 41
+	traceEvUserRegionBegin = 42
+	traceEvUserRegionEnd   = 43
+	traceEvUserLog         = 44
+)
+
+// Goroutine and P states, as used in status events.
+const (
+	traceGoRunnable  = 1
+	traceGoRunning   = 2
+	traceGoWaiting   = 4
+	traceProcRunning = 1
+)
+
+const (
+	traceHeader       = "go 1.22 trace\x00\x00\x00"
+	traceMaxBatchSize = 64 * 1024
+
+	// Free space needed in a batch before writing an event to it. This is
+	// enough for the largest event plus all pending GC events.
+	traceBatchReserve = 512
+
+	// Number of GC cycles that are remembered until the next event is emitted.
+	// Any more cycles are not recorded.
+	traceMaxPendingGC = 4
+
+	traceMaxVarintLen = 10
+)
+
+// Trace state of a single goroutine.
+type traceGoroutine struct {
+	id      uint64
+	seq     uint64 // sequence number of the last GoStart or GoUnblock event
+	status  uint8  // last status as written to the trace
+	yielded bool   // the goroutine added itself to the runqueue (Gosched)
+}
+
+// A GC cycle that hasn't been written to the trace yet.
+type traceGCCycle struct {
+	start     int64
+	end       int64
+	heapAlloc uint64
+}
+
+// traceEnabled is set while a trace is running. It is checked by all hooks in
+// the runtime, so the tracer is removed entirely by the optimizer in programs
+// that don't use it.
+var traceEnabled bool
+
+var traceState struct {
+	batches    [][]byte // completed batches, ready to be read
+	batch      []byte   // events of the batch that is being written
+	batchStart int64    // base timestamp of the current batch
+	lastTime   int64    // timestamp of the last event in the current batch
+	goroutines map[*task.Task]*traceGoroutine
+	current    *task.Task // running goroutine, or nil when in the scheduler
+	nextGoID   uint64
+	strings    map[string]uint64
+
+	// Reason for the next GoBlock event, see traceBlock.
+	blockReason string
+
+	// GC cycles, see traceGCStart and traceGCDone.
+	gcSeq      uint64
+	gcStart    int64
+	pendingGC  [traceMaxPendingGC]traceGCCycle
+	numPending int
+}
+
+// trace_supported returns whether tracing is supported. The tracer only
+// supports a single thread running goroutines.
+//
+//go:linkname trace_supported runtime/trace.runtime_supported
+func trace_supported() bool {
+	return !hasParallelism
+}
+
+//go:linkname trace_start runtime/trace.runtime_start
+func trace_start() bool {
+	if traceEnabled {
+		return false
+	}
+	state := &traceState
+	state.goroutines = make(map[*task.Task]*traceGoroutine)
+	state.strings = make(map[string]uint64)
+	state.current = nil
+	state.nextGoID = 1
+	state.blockReason = ""
+	state.gcSeq = 0
+	state.numPending = 0
+
+	// Timestamps are in nanoseconds.
+	freq := traceAppendUvarint([]byte{traceEvFrequency}, 1e9)
+	state.batches = [][]byte{[]byte(traceHeader), traceBatch(0, freq)}
+	traceNewBatch()
+
+	traceEnabled = true
+	traceEvent(traceEvProcStatus, 0, traceProcRunning)
+	traceCurrentGoroutine()
+	return true
+}
+
+//go:linkname trace_stop runtime/trace.runtime_stop
+func trace_stop() {
+	if !traceEnabled {
+		return
+	}
+	state := &traceState
+	if traceCurrentGoroutine() != nil {
+		traceFlushGC()
+	}
+	traceEnabled = false
+	state.batches = append(state.batches, traceBatch(state.batchStart, state.batch))
+	state.batch = nil
+
+	// Write the string table.
+	batch := []byte{traceEvStrings}
+	for s, id := range state.strings {
+		if len(batch)+len(s)+2*traceMaxVarintLen > traceMaxBatchSize {
+			state.batches = append(state.batches, traceBatch(0, batch))
+			batch = []byte{traceEvStrings}
+		}
+		batch = append(batch, traceEvString)
+		batch = traceAppendUvarint(batch, id)
+		batch = traceAppendUvarint(batch, uint64(len(s)))
+		batch = append(batch, s...)
+	}
+	state.batches = append(state.batches, traceBatch(0, batch))
+	state.goroutines = nil
+	state.strings = nil
+	state.current = nil
+}
+
+// trace_read returns the next chunk of trace data, or nil when all data has
+// been read.
+//
+//go:linkname trace_read runtime/trace.runtime_read
+func trace_read() []byte {
+	state := &traceState
+	if traceEnabled || len(state.batches) == 0 {
+		return nil
+	}
+	data := state.batches[0]
+	state.batches[0] = nil
+	state.batches = state.batches[1:]
+	return data
+}
+
+//go:linkname trace_isEnabled runtime/trace.runtime_isEnabled
+func trace_isEnabled() bool {
+	return traceEnabled
+}
+
+//go:linkname trace_userTaskCreate runtime/trace.userTaskCreate
+func trace_userTaskCreate(id, parentID uint64, taskType string) {
+	if !traceEnabled {
+		return
+	}
+	name := traceString(taskType)
+	if traceCurrentGoroutine() != nil {
+		traceEvent(traceEvUserTaskBegin, id, parentID, name, 0)
+	}
+}
+
+//go:linkname trace_userTaskEnd runtime/trace.userTaskEnd
+func trace_userTaskEnd(id uint64) {
+	if !traceEnabled {
+		return
+	}
+	if traceCurrentGoroutine() != nil {
+		traceEvent(traceEvUserTaskEnd, id, 0)
+	}
+}
+
+//go:linkname trace_userRegion runtime/trace.userRegion
+func trace_userRegion(id, mode uint64, regionType string) {
+	if !traceEnabled {
+		return
+	}
+	name := traceString(regionType)
+	typ := uint8(traceEvUserRegionBegin)
+	if mode != 0 {
+		typ = traceEvUserRegionEnd
+	}
+	if traceCurrentGoroutine() != nil {
+		traceEvent(typ, id, name, 0)
+	}
+}
+
+//go:linkname trace_userLog runtime/trace.userLog
+func trace_userLog(id uint64, category, message string) {
+	if !traceEnabled {
+		return
+	}
+	key := traceString(category)
+	value := traceString(message)
+	if traceCurrentGoroutine() != nil {
+		traceEvent(traceEvUserLog, id, key, value, 0)
+	}
+}
+
+// traceGoCreate is called by the task package when a new goroutine is created.
+func traceGoCreate(t *task.Task) {
+	if !traceEnabled {
+		return
+	}
+	traceCurrentGoroutine()
+	g := traceNewGoroutine(t, traceGoRunnable)
+	traceEvent(traceEvGoCreate, g.id, 0, 0)
+}
+
+// traceGoExit is called by the task package when the current goroutine exits.
+func traceGoExit() {
+	if !traceEnabled {
+		return
+	}
+	t := traceCurrentGoroutine()
+	if t == nil {
+		return
+	}
+	traceEvent(traceEvGoDestroy)
+	delete(traceState.goroutines, t)
+	traceState.current = nil
+}
+
+// traceGoUnblock is called when a goroutine is added to the runqueue.
+func traceGoUnblock(t *task.Task) {
+	if !traceEnabled || interrupt.In() {
+		// Interrupts can't allocate memory, so the GoUnblock event is written
+		// by traceGoStart instead.
+		return
+	}
+	if t == traceState.current {
+		// The running goroutine yields, as in Gosched.
+		traceState.goroutines[t].yielded = true
+		return
+	}
+	g := traceGetGoroutine(t, traceGoWaiting)
+	if g.status != traceGoWaiting {
+		// Already runnable, because it was just created.
+		return
+	}
+	g.seq++
+	g.status = traceGoRunnable
+	traceEvent(traceEvGoUnblock, g.id, g.seq, 0)
+}
+
+// traceGoStart is called by the scheduler right before running a goroutine.
+func traceGoStart(t *task.Task) {
+	if !traceEnabled {
+		return
+	}
+	g := traceGetGoroutine(t, traceGoRunnable)
+	if g.status == traceGoWaiting {
+		// Woken up from an interrupt.
+		g.seq++
+		traceEvent(traceEvGoUnblock, g.id, g.seq, 0)
+	}
+	g.seq++
+	g.status = traceGoRunning
+	g.yielded = false
+	traceEvent(traceEvGoStart, g.id, g.seq)
+	traceState.current = t
+}
+
+// traceGoPark is called by the scheduler after a goroutine paused, either
+// because it blocked, yielded, or exited.
+func traceGoPark(t *task.Task) {
+	if !traceEnabled {
+		return
+	}
+	state := &traceState
+	reason := state.blockReason
+	state.blockReason = ""
+	if state.current != t {
+		// The goroutine exited, or tracing started while the scheduler was
+		// running it.
+		return
+	}
+	g := state.goroutines[t]
+	if g.yielded {
+		g.status = traceGoRunnable
+		traceEvent(traceEvGoStop, traceString("yield"), 0)
+	} else {
+		if reason == "" {
+			reason = "sync"
+		}
+		g.status = traceGoWaiting
+		traceEvent(traceEvGoBlock, traceString(reason), 0)
+	}
+	state.current = nil
+}
+
+// traceBlock records why the current goroutine is about to block. It is called
+// right before task.Pause.
+func traceBlock(reason string) {
+	if traceEnabled {
+		traceState.blockReason = reason
+	}
+}
+
+// traceGCStart is called by the GC at the start of a cycle.
+func traceGCStart() {
+	if traceEnabled {
+		traceState.gcStart = nanotime()
+	}
+}
+
+// traceGCDone is called by the GC at the end of a cycle, with the number of
+// bytes that are still allocated on the heap.
+func traceGCDone(heapAlloc uint64) {
+	state := &traceState
+	if !traceEnabled || state.numPending == len(state.pendingGC) {
+		return
+	}
+	state.pendingGC[state.numPending] = traceGCCycle{
+		start:     state.gcStart,
+		end:       nanotime(),
+		heapAlloc: heapAlloc,
+	}
+	state.numPending++
+}
+
+// traceCurrentGoroutine returns the current goroutine, describing it in the
+// trace if it hasn't been seen before. It returns nil when not running in a
+// goroutine.
+func traceCurrentGoroutine() *task.Task {
+	t := task.Current()
+	if t != nil && traceState.current == nil {
+		// The trace was started while this goroutine was running.
+		traceGetGoroutine(t, traceGoRunning)
+		traceState.current = t
+	}
+	return t
+}
+
+// traceGetGoroutine returns the trace state of the given goroutine. If it
+// hasn't been seen before, it is described in the trace with the given status.
+func traceGetGoroutine(t *task.Task, status uint8) *traceGoroutine {
+	g := traceState.goroutines[t]
+	if g == nil {
+		g = traceNewGoroutine(t, status)
+		traceEvent(traceEvGoStatus, g.id, 0, uint64(status))
+	}
+	return g
+}
+
+func traceNewGoroutine(t *task.Task, status uint8) *traceGoroutine {
+	state := &traceState
+	g := &traceGoroutine{
+		id:     state.nextGoID,
+		status: status,
+	}
+	state.nextGoID++
+	state.goroutines[t] = g
+	return g
+}
+
+// traceString returns the ID of s in the string table, adding it if needed.
+func traceString(s string) uint64 {
+	state := &traceState
+	id, ok := state.strings[s]
+	if !ok {
+		id = uint64(len(state.strings) + 1)
+		state.strings[s] = id
+	}
+	return id
+}
+
+// traceEvent writes an event with the current time to the current batch.
+func traceEvent(typ uint8, args ...uint64) {
+	state := &traceState
+	if len(state.batch)+traceBatchReserve > traceMaxBatchSize {
+		state.batches = append(state.batches, traceBatch(state.batchStart, state.batch))
+		traceNewBatch()
+	}
+	if state.current != nil {
+		// GC events must be attributed to a goroutine.
+		traceFlushGC()
+	}
+	traceWriteEvent(typ, nanotime(), args)
+}
+
+// traceFlushGC writes all GC cycles that happened since the last event.
+func traceFlushGC() {
+	state := &traceState
+	for i := 0; i < state.numPending; i++ {
+		cycle := &state.pendingGC[i]
+		// Both GCBegin and GCEnd take a sequence number.
+		traceWriteEvent(traceEvGCBegin, cycle.start, []uint64{state.gcSeq + 1, 0})
+		traceWriteEvent(traceEvGCEnd, cycle.end, []uint64{state.gcSeq + 2})
+		state.gcSeq += 2
+		traceWriteEvent(traceEvHeapAlloc, cycle.end, []uint64{cycle.heapAlloc})
+	}
+	state.numPending = 0
+}
+
+func traceWriteEvent(typ uint8, now int64, args []uint64) {
+	state := &traceState
+	if now < state.lastTime {
+		// Events in a batch must be ordered in time.
+		now = state.lastTime
+	}
+	state.batch = append(state.batch, typ)
+	state.batch = traceAppendUvarint(state.batch, uint64(now-state.lastTime))
+	for _, arg := range args {
+		state.batch = traceAppendUvarint(state.batch, arg)
+	}
+	state.lastTime = now
+}
+
+// traceNewBatch starts a new, empty batch of events.
+func traceNewBatch() {
+	state := &traceState
+	state.batch = make([]byte, 0, traceMaxBatchSize)
+	now := nanotime()
+	if now < state.lastTime {
+		now = state.lastTime
+	}
+	state.batchStart = now
+	state.lastTime = now
+}
+
+// traceBatch returns the batch data prefixed with a batch header.
+func traceBatch(start int64, data []byte) []byte {
+	buf := make([]byte, 0, 1+4*traceMaxVarintLen+len(data))
+	buf = append(buf, traceEvEventBatch)
+	buf = traceAppendUvarint(buf, 1) // generation
+	buf = traceAppendUvarint(buf, 0) // thread (M)
+	buf = traceAppendUvarint(buf, uint64(start))
+	buf = traceAppendUvarint(buf, uint64(len(data)))
+	return append(buf, data...)
+}
+
+func traceAppendUvarint(buf []byte, x uint64) []byte {
+	for x >= 0x80 {
+		buf = append(buf, byte(x)|0x80)
+		x >>= 7
+	}
+	return append(buf, byte(x))
+}
@@ -1,42 +1,55 @@
-// Stubs for the runtime/trace package
+// Package trace contains facilities for programs to generate traces for the
+// Go execution tracer.
+//
+// The trace is kept in memory while tracing and written to the writer passed
+// to Start when Stop is called. It can be viewed with `go tool trace`.
+// Goroutine stack traces are not recorded. Tracing is not supported with
+// -scheduler=threads.
 package trace
 
 import (
-	"context"
 	"errors"
 	"io"
 )
 
-func Start(w io.Writer) error {
-	return errors.New("not implemented")
-}
-
-func Stop() {}
-
-func NewTask(pctx context.Context, taskType string) (ctx context.Context, task *Task) {
-	return context.TODO(), nil
-}
-
-type Task struct{}
-
-func (t *Task) End() {}
-
-func Log(ctx context.Context, category, message string) {}
+// Writer of the trace that is currently running.
+var traceWriter io.Writer
 
-func Logf(ctx context.Context, category, format string, args ...any) {}
-
-func WithRegion(ctx context.Context, regionType string, fn func()) {
-	fn()
-}
-
-func StartRegion(ctx context.Context, regionType string) *Region {
+// Start enables tracing for the current program. While tracing, the trace is
+// buffered in memory and written to w when Stop is called. Start returns an
+// error if tracing is already enabled or not supported.
+func Start(w io.Writer) error {
+	if !runtime_supported() {
+		return errors.New("tracing is not supported with -scheduler=threads")
+	}
+	if !runtime_start() {
+		return errors.New("tracing is already enabled")
+	}
+	traceWriter = w
 	return nil
 }
 
-type Region struct{}
-
-func (r *Region) End() {}
-
-func IsEnabled() bool {
-	return false
+// Stop stops the current tracing, if any, and writes the trace to the writer
+// passed to Start.
+func Stop() {
+	if !IsEnabled() {
+		return
+	}
+	runtime_stop()
+	w := traceWriter
+	traceWriter = nil
+	for {
+		data := runtime_read()
+		if data == nil {
+			break
+		}
+		w.Write(data)
+	}
 }
+
+// Implemented in the runtime.
+func runtime_supported() bool
+func runtime_start() bool
+func runtime_stop()
+func runtime_read() []byte
+func runtime_isEnabled() bool
@@ -0,0 +1,300 @@
+//go:build tinygo.ubsan
+
+package runtime
+
+// This file implements the runtime side of UndefinedBehaviorSanitizer, which is
+// enabled with -sanitize=undefined. C code (outside the standard library) is
+// compiled with -fsanitize=undefined -fsanitize-minimal-runtime, which calls
+// one of the handlers below when undefined behavior is detected.
+//
+// The handlers follow the minimal runtime in compiler-rt: recoverable checks
+// print a message once per location and continue, the _abort variants (used
+// with -fno-sanitize-recover) and checks that can't be recovered from print a
+// message and exit the program. Unlike the minimal runtime in compiler-rt, the
+// location is printed as function, file and line instead of as a bare address.
+
+import (
+	"internal/task"
+	"unsafe"
+)
+
+// Maximum number of locations that are reported. Further errors are not
+// printed, to avoid flooding the output.
+const ubsanMaxReported = 20
+
+var (
+	ubsanLock     task.PMutex
+	ubsanReported [ubsanMaxReported]uintptr
+	ubsanCount    int
+)
+
+// Report a recoverable error at the given program counter, unless it has been
+// reported before.
+func ubsanReport(check string, pc uintptr) {
+	ubsanLock.Lock()
+	for i := 0; i < ubsanCount; i++ {
+		if ubsanReported[i] == pc {
+			ubsanLock.Unlock()
+			return
+		}
+	}
+	if ubsanCount == ubsanMaxReported {
+		ubsanLock.Unlock()
+		return
+	}
+	ubsanReported[ubsanCount] = pc
+	ubsanCount++
+	ubsanLock.Unlock()
+
+	printlock()
+	ubsanPrintMessage(check, pc)
+	printunlock()
+}
+
+// Report an error at the given program counter including a stack trace, and
+// exit the program.
+func ubsanAbort(check string, pc uintptr) {
+	printlock()
+	ubsanPrintMessage(check, pc)
+	sanitizerPrintStack(pc)
+	printunlock()
+	exit(1)
+}
+
+func ubsanPrintMessage(check string, pc uintptr) {
+	print("ubsan: ", check, " by ", unsafe.Pointer(pc))
+	if name, file, line := sanitizerLocation(pc); name != "" {
+		print(" in ", name, " ", file, ":", line)
+	}
+	println()
+}
+
+//export __ubsan_handle_type_mismatch_minimal
+func ubsan_type_mismatch() {
+	ubsanReport("type-mismatch", uintptr(returnAddress(0)))
+}
+
+//export __ubsan_handle_type_mismatch_minimal_abort
+func ubsan_type_mismatch_abort() {
+	ubsanAbort("type-mismatch", uintptr(returnAddress(0)))
+}
+
+//export __ubsan_handle_alignment_assumption_minimal
+func ubsan_alignment_assumption() {
+	ubsanReport("alignment-assumption", uintptr(returnAddress(0)))
+}
+
+//export __ubsan_handle_alignment_assumption_minimal_abort
+func ubsan_alignment_assumption_abort() {
+	ubsanAbort("alignment-assumption", uintptr(returnAddress(0)))
+}
+
+//export __ubsan_handle_add_overflow_minimal
+func ubsan_add_overflow() {
+	ubsanReport("add-overflow", uintptr(returnAddress(0)))
+}
+
+//export __ubsan_handle_add_overflow_minimal_abort
+func ubsan_add_overflow_abort() {
+	ubsanAbort("add-overflow", uintptr(returnAddress(0)))
+}
+
+//export __ubsan_handle_sub_overflow_minimal
+func ubsan_sub_overflow() {
+	ubsanReport("sub-overflow", uintptr(returnAddress(0)))
+}
+
+//export __ubsan_handle_sub_overflow_minimal_abort
+func ubsan_sub_overflow_abort() {
+	ubsanAbort("sub-overflow", uintptr(returnAddress(0)))
+}
+
+//export __ubsan_handle_mul_overflow_minimal
+func ubsan_mul_overflow() {
+	ubsanReport("mul-overflow", uintptr(returnAddress(0)))
+}
+
+//export __ubsan_handle_mul_overflow_minimal_abort
+func ubsan_mul_overflow_abort() {
+	ubsanAbort("mul-overflow", uintptr(returnAddress(0)))
+}
+
+//export __ubsan_handle_negate_overflow_minimal
+func ubsan_negate_overflow() {
+	ubsanReport("negate-overflow", uintptr(returnAddress(0)))
+}
+
+//export __ubsan_handle_negate_overflow_minimal_abort
+func ubsan_negate_overflow_abort() {
+	ubsanAbort("negate-overflow", uintptr(returnAddress(0)))
+}
+
+//export __ubsan_handle_divrem_overflow_minimal
+func ubsan_divrem_overflow() {
+	ubsanReport("divrem-overflow", uintptr(returnAddress(0)))
+}
+
+//export __ubsan_handle_divrem_overflow_minimal_abort
+func ubsan_divrem_overflow_abort() {
+	ubsanAbort("divrem-overflow", uintptr(returnAddress(0)))
+}
+
+//export __ubsan_handle_shift_out_of_bounds_minimal
+func ubsan_shift_out_of_bounds() {
+	ubsanReport("shift-out-of-bounds", uintptr(returnAddress(0)))
+}
+
+//export __ubsan_handle_shift_out_of_bounds_minimal_abort
+func ubsan_shift_out_of_bounds_abort() {
+	ubsanAbort("shift-out-of-bounds", uintptr(returnAddress(0)))
+}
+
+//export __ubsan_handle_out_of_bounds_minimal
+func ubsan_out_of_bounds() {
+	ubsanReport("out-of-bounds", uintptr(returnAddress(0)))
+}
+
+//export __ubsan_handle_out_of_bounds_minimal_abort
+func ubsan_out_of_bounds_abort() {
+	ubsanAbort("out-of-bounds", uintptr(returnAddress(0)))
+}
+
+//export __ubsan_handle_local_out_of_bounds_minimal
+func ubsan_local_out_of_bounds() {
+	ubsanReport("local-out-of-bounds", uintptr(returnAddress(0)))
+}
+
+//export __ubsan_handle_local_out_of_bounds_minimal_abort
+func ubsan_local_out_of_bounds_abort() {
+	ubsanAbort("local-out-of-bounds", uintptr(returnAddress(0)))
+}
+
+//export __ubsan_handle_builtin_unreachable_minimal
+func ubsan_builtin_unreachable() {
+	ubsanAbort("builtin-unreachable", uintptr(returnAddress(0)))
+}
+
+//export __ubsan_handle_missing_return_minimal
+func ubsan_missing_return() {
+	ubsanAbort("missing-return", uintptr(returnAddress(0)))
+}
+
+//export __ubsan_handle_vla_bound_not_positive_minimal
+func ubsan_vla_bound_not_positive() {
+	ubsanReport("vla-bound-not-positive", uintptr(returnAddress(0)))
+}
+
+//export __ubsan_handle_vla_bound_not_positive_minimal_abort
+func ubsan_vla_bound_not_positive_abort() {
+	ubsanAbort("vla-bound-not-positive", uintptr(returnAddress(0)))
+}
+
+//export __ubsan_handle_float_cast_overflow_minimal
+func ubsan_float_cast_overflow() {
+	ubsanReport("float-cast-overflow", uintptr(returnAddress(0)))
+}
+
+//export __ubsan_handle_float_cast_overflow_minimal_abort
+func ubsan_float_cast_overflow_abort() {
+	ubsanAbort("float-cast-overflow", uintptr(returnAddress(0)))
+}
+
+//export __ubsan_handle_load_invalid_value_minimal
+func ubsan_load_invalid_value() {
+	ubsanReport("load-invalid-value", uintptr(returnAddress(0)))
+}
+
+//export __ubsan_handle_load_invalid_value_minimal_abort
+func ubsan_load_invalid_value_abort() {
+	ubsanAbort("load-invalid-value", uintptr(returnAddress(0)))
+}
+
+//export __ubsan_handle_invalid_builtin_minimal
+func ubsan_invalid_builtin() {
+	ubsanReport("invalid-builtin", uintptr(returnAddress(0)))
+}
+
+//export __ubsan_handle_invalid_builtin_minimal_abort
+func ubsan_invalid_builtin_abort() {
+	ubsanAbort("invalid-builtin", uintptr(returnAddress(0)))
+}
+
+//export __ubsan_handle_function_type_mismatch_minimal
+func ubsan_function_type_mismatch() {
+	ubsanReport("function-type-mismatch", uintptr(returnAddress(0)))
+}
+
+//export __ubsan_handle_function_type_mismatch_minimal_abort
+func ubsan_function_type_mismatch_abort() {
+	ubsanAbort("function-type-mismatch", uintptr(returnAddress(0)))
+}
+
+//export __ubsan_handle_implicit_conversion_minimal
+func ubsan_implicit_conversion() {
+	ubsanReport("implicit-conversion", uintptr(returnAddress(0)))
+}
+
+//export __ubsan_handle_implicit_conversion_minimal_abort
+func ubsan_implicit_conversion_abort() {
+	ubsanAbort("implicit-conversion", uintptr(returnAddress(0)))
+}
+
+//export __ubsan_handle_nonnull_arg_minimal
+func ubsan_nonnull_arg() {
+	ubsanReport("nonnull-arg", uintptr(returnAddress(0)))
+}
+
+//export __ubsan_handle_nonnull_arg_minimal_abort
+func ubsan_nonnull_arg_abort() {
+	ubsanAbort("nonnull-arg", uintptr(returnAddress(0)))
+}
+
+//export __ubsan_handle_nonnull_return_minimal
+func ubsan_nonnull_return() {
+	ubsanReport("nonnull-return", uintptr(returnAddress(0)))
+}
+
+//export __ubsan_handle_nonnull_return_minimal_abort
+func ubsan_nonnull_return_abort() {
+	ubsanAbort("nonnull-return", uintptr(returnAddress(0)))
+}
+
+//export __ubsan_handle_nullability_arg_minimal
+func ubsan_nullability_arg() {
+	ubsanReport("nullability-arg", uintptr(returnAddress(0)))
+}
+
+//export __ubsan_handle_nullability_arg_minimal_abort
+func ubsan_nullability_arg_abort() {
+	ubsanAbort("nullability-arg", uintptr(returnAddress(0)))
+}
+
+//export __ubsan_handle_nullability_return_minimal
+func ubsan_nullability_return() {
+	ubsanReport("nullability-return", uintptr(returnAddress(0)))
+}
+
+//export __ubsan_handle_nullability_return_minimal_abort
+func ubsan_nullability_return_abort() {
+	ubsanAbort("nullability-return", uintptr(returnAddress(0)))
+}
+
+//export __ubsan_handle_pointer_overflow_minimal
+func ubsan_pointer_overflow() {
+	ubsanReport("pointer-overflow", uintptr(returnAddress(0)))
+}
+
+//export __ubsan_handle_pointer_overflow_minimal_abort
+func ubsan_pointer_overflow_abort() {
+	ubsanAbort("pointer-overflow", uintptr(returnAddress(0)))
+}
+
+//export __ubsan_handle_cfi_check_fail_minimal
+func ubsan_cfi_check_fail() {
+	ubsanReport("cfi-check-fail", uintptr(returnAddress(0)))
+}
+
+//export __ubsan_handle_cfi_check_fail_minimal_abort
+func ubsan_cfi_check_fail_abort() {
+	ubsanAbort("cfi-check-fail", uintptr(returnAddress(0)))
+}
@@ -18,6 +18,9 @@ type RWMutex struct {
 	// Iff the mutex is write-locked, it contains rwMutexStateWLocked.
 	// While the mutex is read-locked, it contains the current number of readers.
 	state uint32
+
+	// lock protects the fields above when goroutines run in parallel.
+	lock task.PMutex
 }
 
 const (
@@ -27,29 +30,38 @@ const (
 )
 
 func (rw *RWMutex) Lock() {
+	rw.lock.Lock()
 	if rw.state == 0 {
 		// The mutex is completely unlocked.
 		// Lock without waiting.
 		rw.state = rwMutexStateWLocked
+		rw.lock.Unlock()
 		return
 	}
 
 	// Wait for the lock to be released.
+	// The goroutine may be resumed between the unlock and the call to Pause,
+	// in which case Pause will return immediately.
 	rw.waitingWriters.Push(task.Current())
+	rw.lock.Unlock()
 	task.Pause()
 }
 
 func (rw *RWMutex) Unlock() {
+	rw.lock.Lock()
+
 	switch rw.state {
 	case rwMutexStateWLocked:
 		// This is correct.
 
 	case rwMutexStateUnlocked:
 		// The mutex is already unlocked.
+		rw.lock.Unlock()
 		panic("sync: unlock of unlocked RWMutex")
 
 	default:
 		// The mutex is read-locked instead of write-locked.
+		rw.lock.Unlock()
 		panic("sync: write-unlock of read-locked RWMutex")
 	}
 
@@ -64,32 +76,41 @@ func (rw *RWMutex) Unlock() {
 		// Nothing is waiting for the lock.
 		rw.state = rwMutexStateUnlocked
 	}
+	rw.lock.Unlock()
 }
 
 func (rw *RWMutex) RLock() {
+	rw.lock.Lock()
 	if rw.state == rwMutexStateWLocked {
 		// Wait for the write lock to be released.
 		rw.waitingReaders.Push(task.Current())
+		rw.lock.Unlock()
 		task.Pause()
 		return
 	}
 
 	if rw.state == rwMutexMaxReaders {
+		rw.lock.Unlock()
 		panic("sync: too many readers on RWMutex")
 	}
 
 	// Increase the reader count.
 	rw.state++
+	rw.lock.Unlock()
 }
 
 func (rw *RWMutex) RUnlock() {
+	rw.lock.Lock()
+
 	switch rw.state {
 	case rwMutexStateUnlocked:
 		// The mutex is already unlocked.
+		rw.lock.Unlock()
 		panic("sync: unlock of unlocked RWMutex")
 
 	case rwMutexStateWLocked:
 		// The mutex is write-locked instead of read-locked.
+		rw.lock.Unlock()
 		panic("sync: read-unlock of write-locked RWMutex")
 	}
 
@@ -100,6 +121,7 @@ func (rw *RWMutex) RUnlock() {
 		// Try to unblock a writer.
 		rw.maybeUnblockWriter()
 	}
+	rw.lock.Unlock()
 }
 
 func (rw *RWMutex) maybeUnblockReaders() bool {
@@ -0,0 +1,166 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+// This file has been modified for use by the TinyGo compiler.
+
+// Support for test coverage.
+
+package testing
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sync/atomic"
+)
+
+func initCoverFlags() {
+	coverProfile = flag.String("test.coverprofile", "", "write a coverage profile to `file` (or - for stdout)")
+}
+
+var coverProfile *string
+
+// Lines around the coverage profile when it is written to stdout, so that
+// tinygo test can extract it from the test output.
+const (
+	coverProfileBegin = "tinygo: coverage profile begin"
+	coverProfileEnd   = "tinygo: coverage profile end"
+)
+
+// CoverBlock records the coverage data for a single basic block.
+// The fields are 1-indexed, as in an editor: The opening line of
+// the file is number 1, for example. Columns are measured
+// in bytes.
+// NOTE: This struct is internal to the testing infrastructure and may change.
+// It is not covered (yet) by the Go 1 compatibility guidelines.
+type CoverBlock struct {
+	Line0 uint32 // Line number for block start.
+	Col0  uint16 // Column number for block start.
+	Line1 uint32 // Line number for block end.
+	Col1  uint16 // Column number for block end.
+	Stmts uint16 // Number of statements included in this block.
+}
+
+var cover Cover
+
+// Cover records information about test coverage checking.
+// NOTE: This struct is internal to the testing infrastructure and may change.
+// It is not covered (yet) by the Go 1 compatibility guidelines.
+type Cover struct {
+	Mode            string
+	Counters        map[string][]uint32
+	Blocks          map[string][]CoverBlock
+	CoveredPackages string
+}
+
+// Coverage reports the current code coverage as a fraction in the range [0, 1].
+// If coverage is not enabled, Coverage returns 0.
+//
+// When running a large set of sequential test cases, checking Coverage after each one
+// can be useful for identifying which test cases exercise new code paths.
+// It is not a replacement for the reports generated by 'go test -cover' and
+// 'go tool cover'.
+func Coverage() float64 {
+	var n, d int64
+	for _, counters := range cover.Counters {
+		for i := range counters {
+			if atomic.LoadUint32(&counters[i]) > 0 {
+				n++
+			}
+			d++
+		}
+	}
+	if d == 0 {
+		return 0
+	}
+	return float64(n) / float64(d)
+}
+
+// RegisterCover records the coverage data accumulators for the tests.
+// NOTE: This function is internal to the testing infrastructure and may change.
+// It is not covered (yet) by the Go 1 compatibility guidelines.
+func RegisterCover(c Cover) {
+	cover = c
+}
+
+// coverRegister is called from an init function in every file that was
+// instrumented by tinygo test -cover. The pos slice contains three values per
+// block: the start line, the end line, and the start and end column packed
+// together.
+//
+// This may be called before the testing package is initialized.
+func coverRegister(mode, file string, counters, pos []uint32, numStmt []uint16) {
+	if cover.Counters == nil {
+		cover.Counters = make(map[string][]uint32)
+		cover.Blocks = make(map[string][]CoverBlock)
+	}
+	cover.Mode = mode
+	blocks := make([]CoverBlock, len(counters))
+	for i := range blocks {
+		blocks[i] = CoverBlock{
+			Line0: pos[3*i+0],
+			Col0:  uint16(pos[3*i+2]),
+			Line1: pos[3*i+1],
+			Col1:  uint16(pos[3*i+2] >> 16),
+			Stmts: numStmt[i],
+		}
+	}
+	cover.Counters[file] = counters
+	cover.Blocks[file] = blocks
+}
+
+// coverReport reports the coverage percentage and writes a coverage profile if
+// requested.
+func coverReport() {
+	var w io.Writer
+	switch *coverProfile {
+	case "":
+	case "-":
+		// Write the profile to stdout. This is used by tinygo test, and works
+		// on systems without a filesystem.
+		fmt.Println(coverProfileBegin)
+		w = os.Stdout
+	default:
+		f, err := os.Create(*coverProfile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "testing: %s\n", err)
+			os.Exit(2)
+		}
+		defer f.Close()
+		w = f
+	}
+	if w != nil {
+		fmt.Fprintf(w, "mode: %s\n", cover.Mode)
+	}
+
+	var active, total int64
+	for name, counts := range cover.Counters {
+		blocks := cover.Blocks[name]
+		for i := range counts {
+			stmts := int64(blocks[i].Stmts)
+			total += stmts
+			count := atomic.LoadUint32(&counts[i]) // for -covermode=atomic
+			if count > 0 {
+				active += stmts
+			}
+			if w != nil {
+				fmt.Fprintf(w, "%s:%d.%d,%d.%d %d %d\n", name,
+					blocks[i].Line0, blocks[i].Col0,
+					blocks[i].Line1, blocks[i].Col1,
+					stmts,
+					count)
+			}
+		}
+	}
+	if *coverProfile == "-" {
+		fmt.Println(coverProfileEnd)
+	}
+
+	if total == 0 {
+		fmt.Println("coverage: [no statements]")
+		return
+	}
+	fmt.Printf("coverage: %.1f%% of statements%s\n", 100*float64(active)/float64(total), cover.CoveredPackages)
+}
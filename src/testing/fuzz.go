@@ -1,12 +1,40 @@
 package testing
 
 import (
-	"errors"
+	"flag"
 	"fmt"
+	"os"
+	"path/filepath"
 	"reflect"
 	"time"
 )
 
+func initFuzzFlags() {
+	matchFuzz = flag.String("test.fuzz", "", "run the fuzz test matching `regexp`")
+	flag.Var(&fuzzTime, "test.fuzztime", "time to spend fuzzing, or `Nx` to run N inputs; default is to run indefinitely")
+}
+
+var (
+	matchFuzz *string
+	fuzzTime  benchTimeFlag
+)
+
+// Directory (relative to the package directory) with the seed corpus of each
+// fuzz test, and where failing inputs are written.
+const corpusDir = "testdata/fuzz"
+
+// Maximum size in bytes of a []byte or string input created by the mutator.
+const maxFuzzInputSize = 1 << 16
+
+// Coverage counters, one for each basic block (modulo the size of the slice).
+// It returns nil if the program wasn't instrumented for fuzzing.
+// Implemented in the runtime.
+func fuzzCounters() []uint8
+
+// Set a function to call right before the program aborts because of a panic.
+// Implemented in the runtime.
+func fuzzSetCrashHandler(handler func())
+
 // InternalFuzzTarget is an internal type but exported because it is
 // cross-package; it is part of the implementation of the "go test" command.
 type InternalFuzzTarget struct {
@@ -31,6 +59,7 @@ type InternalFuzzTarget struct {
 // that are allowed in the (*F).Fuzz function are (*F).Failed and (*F).Name.
 type F struct {
 	common
+	t           *T // test that reports the result of this fuzz test
 	fuzzContext *fuzzContext
 	testContext *testContext
 
@@ -120,11 +149,321 @@ var supportedTypes = map[reflect.Type]bool{
 // (set with -fuzztime), or the test process is interrupted by a signal. F.Fuzz
 // should be called exactly once, unless F.Skip or F.Fail is called beforehand.
 func (f *F) Fuzz(ff interface{}) {
-	f.failed = true
-	f.result.N = 0
-	f.result.T = 0
-	f.result.Error = errors.New("operation not implemented")
-	return
+	if f.fuzzCalled {
+		panic("testing: F.Fuzz called more than once")
+	}
+	f.fuzzCalled = true
+	if f.failed || f.skipped {
+		return
+	}
+
+	// Check the signature of the fuzz function.
+	fn := reflect.ValueOf(ff)
+	fnType := fn.Type()
+	if fnType.Kind() != reflect.Func {
+		panic("testing: F.Fuzz must receive a function")
+	}
+	if fnType.NumIn() < 2 || fnType.In(0) != reflect.TypeOf((*T)(nil)) {
+		panic("testing: fuzz target must receive at least two arguments, where the first argument is a *T")
+	}
+	if fnType.NumOut() != 0 {
+		panic("testing: fuzz target must not return a value")
+	}
+	var types []reflect.Type
+	for i := 1; i < fnType.NumIn(); i++ {
+		t := fnType.In(i)
+		if !supportedTypes[t] {
+			panic(fmt.Sprintf("testing: unsupported type for fuzzing %v", t))
+		}
+		types = append(types, t)
+	}
+
+	// Check the entries added with F.Add, and load the seed corpus from
+	// testdata/fuzz/<Name>.
+	for _, e := range f.corpus {
+		if err := checkCorpusValues(e.Values, types); err != nil {
+			f.Errorf("%s: %v", e.Path, err)
+			return
+		}
+	}
+	entries, err := readCorpus(filepath.Join(corpusDir, f.name), types)
+	if err != nil {
+		f.Error(err)
+		return
+	}
+	f.corpus = append(f.corpus, entries...)
+
+	switch f.fuzzContext.mode {
+	case seedCorpusOnly:
+		// Run every entry in the seed corpus as a subtest.
+		for _, e := range f.corpus {
+			values := e.Values
+			f.t.Run(filepath.Base(e.Path), func(t *T) {
+				f.call(fn, t, values)
+			})
+		}
+	case fuzzCoordinator:
+		f.fuzz(fn, types)
+	}
+}
+
+// call runs the fuzz function with the given *T and values.
+func (f *F) call(fn reflect.Value, t *T, values []interface{}) {
+	args := make([]reflect.Value, 0, len(values)+1)
+	args = append(args, reflect.ValueOf(t))
+	for _, v := range values {
+		args = append(args, reflect.ValueOf(v))
+	}
+	f.inFuzzFn = true
+	fn.Call(args)
+	f.inFuzzFn = false
+}
+
+// fuzz is the fuzzing engine. It runs the seed corpus to collect the baseline
+// coverage, and then keeps running mutated inputs until the fuzz function
+// fails or until -test.fuzztime has passed. Inputs that reach new code (as
+// reported by the coverage counters inserted by the compiler) are added to the
+// corpus in memory, so that they will be mutated further.
+func (f *F) fuzz(fn reflect.Value, types []reflect.Type) {
+	counters := fuzzCounters()
+	if counters == nil {
+		f.Error("fuzzing requires a test binary built using tinygo test -fuzz")
+		return
+	}
+	seen := make([]uint8, len(counters))
+
+	corpus := f.corpus
+	if len(corpus) == 0 {
+		// Start with the zero value of all types.
+		var values []interface{}
+		for _, t := range types {
+			values = append(values, reflect.Zero(t).Interface())
+		}
+		corpus = append(corpus, corpusEntry{Values: values, Path: "zero"})
+	}
+
+	// Save the input and report the failure when the program is about to abort
+	// on a panic, because it can't be recovered from.
+	var current []interface{}
+	var currentT *T
+	fuzzSetCrashHandler(func() {
+		currentT.Fail()
+		f.reportFailure(currentT, current)
+		t := f.t
+		t.failed = true
+		t.duration = time.Since(t.start)
+		t.report()
+		t.parent.output.WriteTo(os.Stdout)
+	})
+	defer fuzzSetCrashHandler(nil)
+
+	// run runs a single input and returns whether it reached new code.
+	run := func(values []interface{}) (ok, interesting bool) {
+		current = values
+		currentT = &T{
+			common: common{
+				output: &logger{},
+				name:   f.name,
+				parent: &f.common,
+				level:  f.level + 1,
+				indent: "    ",
+			},
+			context: f.testContext,
+		}
+		for i := range counters {
+			counters[i] = 0
+		}
+		currentT.start = time.Now()
+		f.call(fn, currentT, values)
+		currentT.duration = time.Since(currentT.start)
+		currentT.runCleanup()
+		f.result.N++
+		if currentT.Failed() {
+			f.reportFailure(currentT, values)
+			return false, false
+		}
+		for i, count := range counters {
+			if count == 0 {
+				continue
+			}
+			if bucket := counterBucket(count); seen[i]&bucket == 0 {
+				seen[i] |= bucket
+				interesting = true
+			}
+		}
+		return true, interesting
+	}
+
+	start := time.Now()
+	lastStatus := start
+	printStatus := func(format string, args ...interface{}) {
+		elapsed := time.Since(start).Round(time.Second)
+		fmt.Printf("fuzz: elapsed: %s, "+format+"\n", append([]interface{}{elapsed}, args...)...)
+	}
+
+	// Gather the baseline coverage, by running the seed corpus.
+	printStatus("gathering baseline coverage: 0/%d completed", len(corpus))
+	for _, e := range corpus {
+		if ok, _ := run(e.Values); !ok {
+			return
+		}
+	}
+	printStatus("gathering baseline coverage: %d/%d completed, now fuzzing with 1 workers", len(corpus), len(corpus))
+	baseline := len(corpus)
+
+	m := newMutator()
+	lastN := f.result.N
+	for execs := 0; ; execs++ {
+		now := time.Now()
+		if fuzzTime.n > 0 && execs >= fuzzTime.n || fuzzTime.d > 0 && now.Sub(start) >= fuzzTime.d {
+			break
+		}
+		if now.Sub(lastStatus) >= 3*time.Second {
+			rate := float64(f.result.N-lastN) / now.Sub(lastStatus).Seconds()
+			printStatus("execs: %d (%.0f/sec), new interesting: %d (total: %d)", f.result.N, rate, len(corpus)-baseline, len(corpus))
+			lastStatus = now
+			lastN = f.result.N
+		}
+
+		// Mutate a copy of a random corpus entry.
+		parent := corpus[m.rand(len(corpus))]
+		values := make([]interface{}, len(parent.Values))
+		copy(values, parent.Values)
+		m.mutate(values, maxFuzzInputSize)
+
+		ok, interesting := run(values)
+		if !ok {
+			break
+		}
+		if interesting {
+			corpus = append(corpus, corpusEntry{
+				Parent:     parent.Path,
+				Path:       fmt.Sprintf("input#%d", len(corpus)),
+				Values:     values,
+				Generation: parent.Generation + 1,
+			})
+		}
+	}
+	f.result.T = time.Since(start)
+	if !f.failed {
+		printStatus("execs: %d (%.0f/sec), new interesting: %d (total: %d)", f.result.N, float64(f.result.N)/f.result.T.Seconds(), len(corpus)-baseline, len(corpus))
+	}
+}
+
+// reportFailure writes the values that made t fail to the seed corpus, and
+// reports the failure in the output of the fuzz test.
+func (f *F) reportFailure(t *T, values []interface{}) {
+	t.report() // marks f as failed
+	path, err := writeCorpusFile(filepath.Join(corpusDir, f.name), values)
+	if err != nil {
+		f.Errorf("could not write failing input: %v", err)
+		return
+	}
+	f.result.Error = fmt.Errorf("failing input written to %s", path)
+	f.Logf("Failing input written to %s", path)
+	f.Logf("To re-run:\ntinygo test -run=%s/%s", f.name, filepath.Base(path))
+}
+
+// counterBucket returns the bit for the given counter value, similar to AFL:
+// a change in the number of times a block runs is only interesting if it
+// falls in a different bucket.
+func counterBucket(count uint8) uint8 {
+	switch {
+	case count <= 3:
+		return 1 << (count - 1)
+	case count <= 7:
+		return 1 << 3
+	case count <= 15:
+		return 1 << 4
+	case count <= 31:
+		return 1 << 5
+	case count <= 127:
+		return 1 << 6
+	default:
+		return 1 << 7
+	}
+}
+
+// runFuzzTests runs the seed corpus of all fuzz tests that match -test.run,
+// as if they were normal tests.
+func runFuzzTests(matchString func(pat, str string) (bool, error), fuzzTests []InternalFuzzTarget) (ran, ok bool) {
+	if len(fuzzTests) == 0 {
+		return false, true
+	}
+	var tests []InternalTest
+	for _, ft := range fuzzTests {
+		fn := ft.Fn
+		tests = append(tests, InternalTest{
+			Name: ft.Name,
+			F: func(t *T) {
+				runFuzzTest(t, fn, seedCorpusOnly)
+			},
+		})
+	}
+	return runTests(matchString, tests)
+}
+
+// runFuzzing runs the fuzz test that matches -test.fuzz, if any. It returns
+// false if the fuzz test failed.
+func runFuzzing(matchString func(pat, str string) (bool, error), fuzzTests []InternalFuzzTarget) (ok bool) {
+	if *matchFuzz == "" {
+		return true
+	}
+	match := newMatcher(matchString, *matchFuzz, "-test.fuzz", flagSkipRegexp)
+	var target *InternalFuzzTarget
+	var names []string
+	for i := range fuzzTests {
+		if _, matched, _ := match.fullName(nil, fuzzTests[i].Name); matched {
+			target = &fuzzTests[i]
+			names = append(names, fuzzTests[i].Name)
+		}
+	}
+	if len(names) == 0 {
+		fmt.Fprintln(os.Stderr, "testing: warning: no fuzz tests to fuzz")
+		return true
+	}
+	if len(names) > 1 {
+		fmt.Fprintf(os.Stderr, "testing: will not fuzz, -fuzz matches more than one fuzz test: %v\n", names)
+		return false
+	}
+
+	t := &T{
+		common: common{
+			output: &logger{logToStdout: flagVerbose},
+		},
+		context: newTestContext(allMatcher()),
+	}
+	tRunner(t, func(t *T) {
+		t.Run(target.Name, func(t *T) {
+			runFuzzTest(t, target.Fn, fuzzCoordinator)
+		})
+	})
+	return !t.Failed()
+}
+
+// runFuzzTest runs the fuzz test fn within t, which reports the result.
+func runFuzzTest(t *T, fn func(f *F), mode fuzzMode) {
+	f := &F{
+		common: common{
+			output: t.output,
+			indent: t.indent,
+			name:   t.name,
+			parent: t.parent,
+			level:  t.level,
+			start:  t.start,
+		},
+		t:           t,
+		fuzzContext: &fuzzContext{mode: mode},
+		testContext: t.context,
+	}
+	fn(f)
+	f.runCleanup()
+	if f.failed {
+		t.Fail()
+	}
+	if f.skipped {
+		t.skip()
+	}
 }
 
 // fuzzContext holds fields common to all fuzz tests.
@@ -135,6 +474,15 @@ type fuzzContext struct {
 
 type fuzzMode uint8
 
+const (
+	// seedCorpusOnly runs the seed corpus as normal tests.
+	seedCorpusOnly fuzzMode = iota
+
+	// fuzzCoordinator runs the fuzzing engine, which generates new inputs.
+	// Unlike upstream Go, the inputs are run in the same process.
+	fuzzCoordinator
+)
+
 // fuzzResult contains the results of a fuzz run.
 type fuzzResult struct {
 	N     int           // The number of iterations.
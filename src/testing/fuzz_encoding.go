@@ -0,0 +1,274 @@
+package testing
+
+// This file implements the corpus file format used by "go test" for fuzz
+// inputs, so that corpus files can be shared with the upstream Go toolchain.
+// A corpus file looks like this:
+//
+//	go test fuzz v1
+//	[]byte("hello")
+//	int(42)
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Header on the first line of every corpus file.
+const corpusFileHeader = "go test fuzz v1"
+
+// readCorpus reads all corpus files in dir. It is not an error if dir doesn't
+// exist.
+func readCorpus(dir string, types []reflect.Type) ([]corpusEntry, error) {
+	files, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("reading seed corpus from testdata: %v", err)
+	}
+	var entries []corpusEntry
+	for _, file := range files {
+		if file.IsDir() {
+			continue
+		}
+		path := filepath.Join(dir, file.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read corpus file: %v", err)
+		}
+		values, err := unmarshalCorpusFile(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal %q: %v", path, err)
+		}
+		if err := checkCorpusValues(values, types); err != nil {
+			return nil, fmt.Errorf("%q: %v", path, err)
+		}
+		entries = append(entries, corpusEntry{Path: path, Values: values, IsSeed: true})
+	}
+	return entries, nil
+}
+
+// writeCorpusFile writes the values to a new corpus file in dir, named after
+// the hash of its contents. It returns the path of the file.
+func writeCorpusFile(dir string, values []interface{}) (string, error) {
+	data := marshalCorpusFile(values)
+	path := filepath.Join(dir, fmt.Sprintf("%x", sha256.Sum256(data))[:16])
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(path, data, 0666); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// checkCorpusValues checks that the values of a corpus entry match the
+// arguments of the fuzz function.
+func checkCorpusValues(values []interface{}, types []reflect.Type) error {
+	if len(values) != len(types) {
+		return fmt.Errorf("wrong number of values in corpus entry: %d, want %d", len(values), len(types))
+	}
+	for i, v := range values {
+		if reflect.TypeOf(v) != types[i] {
+			return fmt.Errorf("mismatched types in corpus entry: %v, want %v", reflect.TypeOf(v), types[i])
+		}
+	}
+	return nil
+}
+
+// marshalCorpusFile encodes the values in the corpus file format.
+func marshalCorpusFile(values []interface{}) []byte {
+	b := bytes.NewBufferString(corpusFileHeader + "\n")
+	for _, val := range values {
+		switch t := val.(type) {
+		case int, int8, int16, int64, uint, uint16, uint32, uint64, bool:
+			fmt.Fprintf(b, "%T(%v)\n", t, t)
+		case float32:
+			if math.IsNaN(float64(t)) && math.Float32bits(t) != math.Float32bits(float32(math.NaN())) {
+				// Keep the exact bits of non-standard NaN values.
+				fmt.Fprintf(b, "math.Float32frombits(0x%x)\n", math.Float32bits(t))
+			} else {
+				fmt.Fprintf(b, "float32(%v)\n", t)
+			}
+		case float64:
+			if math.IsNaN(t) && math.Float64bits(t) != math.Float64bits(math.NaN()) {
+				fmt.Fprintf(b, "math.Float64frombits(0x%x)\n", math.Float64bits(t))
+			} else {
+				fmt.Fprintf(b, "float64(%v)\n", t)
+			}
+		case string:
+			fmt.Fprintf(b, "string(%q)\n", t)
+		case rune: // int32
+			// Only valid runes can be written as a rune literal.
+			if utf8.ValidRune(t) {
+				fmt.Fprintf(b, "rune(%q)\n", t)
+			} else {
+				fmt.Fprintf(b, "int32(%v)\n", t)
+			}
+		case byte: // uint8
+			fmt.Fprintf(b, "byte(%q)\n", t)
+		case []byte:
+			fmt.Fprintf(b, "[]byte(%q)\n", t)
+		default:
+			panic(fmt.Sprintf("unsupported type: %T", t))
+		}
+	}
+	return b.Bytes()
+}
+
+// unmarshalCorpusFile decodes a corpus file.
+func unmarshalCorpusFile(data []byte) ([]interface{}, error) {
+	lines := strings.Split(string(data), "\n")
+	if len(lines) < 2 {
+		return nil, errors.New("must include version and at least one value")
+	}
+	if strings.TrimSpace(lines[0]) != corpusFileHeader {
+		return nil, fmt.Errorf("unknown encoding version: %s", lines[0])
+	}
+	var values []interface{}
+	for _, line := range lines[1:] {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		v, err := parseCorpusValue(line)
+		if err != nil {
+			return nil, fmt.Errorf("malformed line %q: %v", line, err)
+		}
+		values = append(values, v)
+	}
+	if len(values) == 0 {
+		return nil, errors.New("must include version and at least one value")
+	}
+	return values, nil
+}
+
+// parseCorpusValue parses a single line of a corpus file, which is a
+// conversion of a literal to one of the supported types, like int(5).
+func parseCorpusValue(line string) (interface{}, error) {
+	open := strings.IndexByte(line, '(')
+	if open < 0 || line[len(line)-1] != ')' {
+		return nil, errors.New("expected call expression")
+	}
+	typ := line[:open]
+	arg := strings.TrimSpace(line[open+1 : len(line)-1])
+
+	switch typ {
+	case "[]byte", "string":
+		s, err := strconv.Unquote(arg)
+		if err != nil {
+			return nil, err
+		}
+		if typ == "string" {
+			return s, nil
+		}
+		return []byte(s), nil
+	case "bool":
+		return strconv.ParseBool(arg)
+	case "float32":
+		f, err := strconv.ParseFloat(arg, 32)
+		return float32(f), err
+	case "float64":
+		return strconv.ParseFloat(arg, 64)
+	case "math.Float32frombits":
+		bits, err := strconv.ParseUint(arg, 0, 32)
+		return math.Float32frombits(uint32(bits)), err
+	case "math.Float64frombits":
+		bits, err := strconv.ParseUint(arg, 0, 64)
+		return math.Float64frombits(bits), err
+	case "int", "int8", "int16", "int32", "rune", "int64":
+		n, err := parseCorpusInt(arg, typ)
+		switch typ {
+		case "int":
+			return int(n), err
+		case "int8":
+			return int8(n), err
+		case "int16":
+			return int16(n), err
+		case "int32", "rune":
+			return int32(n), err
+		default:
+			return n, err
+		}
+	case "uint", "uint8", "byte", "uint16", "uint32", "uint64":
+		n, err := parseCorpusUint(arg, typ)
+		switch typ {
+		case "uint":
+			return uint(n), err
+		case "uint8", "byte":
+			return uint8(n), err
+		case "uint16":
+			return uint16(n), err
+		case "uint32":
+			return uint32(n), err
+		default:
+			return n, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported type %s", typ)
+	}
+}
+
+// parseCorpusInt parses an integer or character literal for a signed integer
+// type.
+func parseCorpusInt(arg, typ string) (int64, error) {
+	if strings.HasPrefix(arg, "'") {
+		r, err := parseCharLiteral(arg)
+		return int64(r), err
+	}
+	return strconv.ParseInt(arg, 0, intTypeBits(typ))
+}
+
+// parseCorpusUint parses an integer or character literal for an unsigned
+// integer type.
+func parseCorpusUint(arg, typ string) (uint64, error) {
+	if strings.HasPrefix(arg, "'") {
+		r, err := parseCharLiteral(arg)
+		if bits := intTypeBits(typ); err == nil && bits < 32 && uint64(r) >= 1<<bits {
+			err = fmt.Errorf("character literal %s overflows %s", arg, typ)
+		}
+		return uint64(r), err
+	}
+	return strconv.ParseUint(arg, 0, intTypeBits(typ))
+}
+
+// parseCharLiteral parses a Go character literal like 'a' or '\x00'.
+func parseCharLiteral(arg string) (rune, error) {
+	s, err := strconv.Unquote(arg)
+	if err != nil {
+		return 0, err
+	}
+	if len(s) == 1 {
+		// Either an ASCII character, or a byte escape like '\xff'.
+		return rune(s[0]), nil
+	}
+	r, size := utf8.DecodeRuneInString(s)
+	if r == utf8.RuneError || size != len(s) {
+		return 0, fmt.Errorf("invalid character literal %s", arg)
+	}
+	return r, nil
+}
+
+// intTypeBits returns the size in bits of the given integer type name.
+func intTypeBits(typ string) int {
+	switch typ {
+	case "int8", "uint8", "byte":
+		return 8
+	case "int16", "uint16":
+		return 16
+	case "int32", "rune", "uint32":
+		return 32
+	case "int", "uint":
+		return strconv.IntSize
+	default:
+		return 64
+	}
+}
@@ -0,0 +1,242 @@
+package testing
+
+// This file implements the mutator of the fuzzing engine, which creates new
+// inputs from existing corpus entries. It is a simplified version of the
+// mutator in upstream Go (internal/fuzz).
+
+import (
+	"math"
+	"math/bits"
+	"math/rand"
+	"time"
+)
+
+type mutator struct {
+	r *rand.Rand
+}
+
+func newMutator() *mutator {
+	return &mutator{r: rand.New(rand.NewSource(time.Now().UnixNano()))}
+}
+
+// rand returns a random number in [0, n).
+func (m *mutator) rand(n int) int {
+	return m.r.Intn(n)
+}
+
+// chooseLen returns a random length in [1, n], preferring short lengths.
+func (m *mutator) chooseLen(n int) int {
+	switch x := m.rand(100); {
+	case x < 90 && n > 8:
+		return m.rand(8) + 1
+	case x < 99 && n > 32:
+		return m.rand(32) + 1
+	default:
+		return m.rand(n) + 1
+	}
+}
+
+// chooseRange returns a random range [pos, pos+n) within a slice of length
+// size, with n > 0.
+func (m *mutator) chooseRange(size int) (pos, n int) {
+	pos = m.rand(size)
+	n = m.chooseLen(size - pos)
+	return pos, n
+}
+
+// mutate changes one of the values in vals. Byte slices and strings are never
+// made longer than maxBytes.
+func (m *mutator) mutate(vals []interface{}, maxBytes int) {
+	i := m.rand(len(vals))
+	switch v := vals[i].(type) {
+	case int:
+		vals[i] = int(m.mutateInt(int64(v), math.MinInt, math.MaxInt))
+	case int8:
+		vals[i] = int8(m.mutateInt(int64(v), math.MinInt8, math.MaxInt8))
+	case int16:
+		vals[i] = int16(m.mutateInt(int64(v), math.MinInt16, math.MaxInt16))
+	case int32:
+		vals[i] = int32(m.mutateInt(int64(v), math.MinInt32, math.MaxInt32))
+	case int64:
+		vals[i] = m.mutateInt(v, math.MinInt64, math.MaxInt64)
+	case uint:
+		vals[i] = uint(m.mutateUint(uint64(v), math.MaxUint))
+	case uint8:
+		vals[i] = uint8(m.mutateUint(uint64(v), math.MaxUint8))
+	case uint16:
+		vals[i] = uint16(m.mutateUint(uint64(v), math.MaxUint16))
+	case uint32:
+		vals[i] = uint32(m.mutateUint(uint64(v), math.MaxUint32))
+	case uint64:
+		vals[i] = m.mutateUint(v, math.MaxUint64)
+	case float32:
+		vals[i] = float32(m.mutateFloat(float64(v)))
+	case float64:
+		vals[i] = m.mutateFloat(v)
+	case bool:
+		vals[i] = !v
+	case string:
+		vals[i] = string(m.mutateBytes([]byte(v), maxBytes))
+	case []byte:
+		// Don't modify the bytes of the parent corpus entry.
+		vals[i] = m.mutateBytes(append([]byte(nil), v...), maxBytes)
+	default:
+		panic("testing: unsupported type to mutate")
+	}
+}
+
+// Integer values that often trigger edge cases.
+var interestingInts = []int64{
+	-128, -1, 0, 1, 16, 32, 64, 100, 127,
+	-32768, -129, 128, 255, 256, 512, 1000, 1024, 4096, 32767,
+	-2147483648, -100663046, -32769, 32768, 65535, 65536, 100663045, 2147483647,
+}
+
+func (m *mutator) mutateInt(v, minValue, maxValue int64) int64 {
+	switch m.rand(4) {
+	case 0:
+		// Add a small number.
+		n := int64(m.rand(16) + 1)
+		if v > maxValue-n {
+			return minValue + (n - (maxValue - v) - 1) // wrap around
+		}
+		return v + n
+	case 1:
+		// Subtract a small number.
+		n := int64(m.rand(16) + 1)
+		if v < minValue+n {
+			return maxValue - (n - (v - minValue) - 1) // wrap around
+		}
+		return v - n
+	case 2:
+		// Replace with an interesting value that fits in the type.
+		n := interestingInts[m.rand(len(interestingInts))]
+		if n < minValue || n > maxValue {
+			return v
+		}
+		return n
+	default:
+		// Flip a single bit, and sign-extend the result from the size of the
+		// type to make sure it fits.
+		size := bits.OnesCount64(uint64(maxValue)) + 1
+		v ^= 1 << m.rand(size)
+		return v << (64 - size) >> (64 - size)
+	}
+}
+
+func (m *mutator) mutateUint(v, maxValue uint64) uint64 {
+	switch m.rand(4) {
+	case 0:
+		return (v + uint64(m.rand(16)+1)) & maxValue
+	case 1:
+		return (v - uint64(m.rand(16)+1)) & maxValue
+	case 2:
+		return uint64(interestingInts[m.rand(len(interestingInts))]) & maxValue
+	default:
+		return (v ^ 1<<m.rand(64)) & maxValue
+	}
+}
+
+func (m *mutator) mutateFloat(v float64) float64 {
+	switch m.rand(5) {
+	case 0:
+		return v + float64(m.rand(16)+1)
+	case 1:
+		return v - float64(m.rand(16)+1)
+	case 2:
+		return v * float64(m.rand(16)+1)
+	case 3:
+		return v / float64(m.rand(16)+1)
+	default:
+		return -v
+	}
+}
+
+// mutateBytes returns a mutated version of b, which may be modified in place.
+func (m *mutator) mutateBytes(b []byte, maxBytes int) []byte {
+	for {
+		switch m.rand(9) {
+		case 0:
+			// Remove a range of bytes.
+			if len(b) <= 1 {
+				continue
+			}
+			pos, n := m.chooseRange(len(b))
+			return append(b[:pos], b[pos+n:]...)
+		case 1:
+			// Insert random bytes.
+			n := m.chooseLen(16)
+			if len(b)+n > maxBytes {
+				continue
+			}
+			pos := m.rand(len(b) + 1)
+			insert := make([]byte, n)
+			for i := range insert {
+				insert[i] = byte(m.rand(256))
+			}
+			return append(b[:pos], append(insert, b[pos:]...)...)
+		case 2:
+			// Duplicate a range of bytes.
+			if len(b) == 0 {
+				continue
+			}
+			src, n := m.chooseRange(len(b))
+			if len(b)+n > maxBytes {
+				continue
+			}
+			dst := m.rand(len(b) + 1)
+			chunk := append([]byte(nil), b[src:src+n]...)
+			return append(b[:dst], append(chunk, b[dst:]...)...)
+		case 3:
+			// Overwrite a range of bytes with another range.
+			if len(b) <= 1 {
+				continue
+			}
+			src, n := m.chooseRange(len(b))
+			dst := m.rand(len(b))
+			copy(b[dst:], b[src:src+n])
+			return b
+		case 4:
+			// Flip a single bit.
+			if len(b) == 0 {
+				continue
+			}
+			b[m.rand(len(b))] ^= 1 << m.rand(8)
+			return b
+		case 5:
+			// Set a byte to a random value.
+			if len(b) == 0 {
+				continue
+			}
+			b[m.rand(len(b))] = byte(m.rand(256))
+			return b
+		case 6:
+			// Add or subtract a small number to a byte.
+			if len(b) == 0 {
+				continue
+			}
+			pos := m.rand(len(b))
+			if m.rand(2) == 0 {
+				b[pos] += byte(m.rand(16) + 1)
+			} else {
+				b[pos] -= byte(m.rand(16) + 1)
+			}
+			return b
+		case 7:
+			// Overwrite a byte with an interesting value.
+			if len(b) == 0 {
+				continue
+			}
+			b[m.rand(len(b))] = byte(interestingInts[m.rand(9)])
+			return b
+		default:
+			// Swap two bytes.
+			if len(b) <= 1 {
+				continue
+			}
+			i, j := m.rand(len(b)), m.rand(len(b))
+			b[i], b[j] = b[j], b[i]
+			return b
+		}
+	}
+}
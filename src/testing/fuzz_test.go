@@ -0,0 +1,102 @@
+package testing
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+)
+
+func TestCorpusFileRoundTrip(t *T) {
+	values := []interface{}{
+		[]byte("hello\x00\xff"),
+		"wörld\n",
+		true,
+		byte('\xfe'),
+		rune('ü'),
+		int32(-1),
+		float32(1.5),
+		math.Inf(-1),
+		math.Float64frombits(0x7ff8000000000001), // non-standard NaN
+		int(-42),
+		int8(-128),
+		int16(300),
+		int64(math.MinInt64),
+		uint(7),
+		uint16(65535),
+		uint32(1 << 31),
+		uint64(math.MaxUint64),
+	}
+	data := marshalCorpusFile(values)
+	got, err := unmarshalCorpusFile(data)
+	if err != nil {
+		t.Fatalf("could not unmarshal corpus file: %v\n%s", err, data)
+	}
+	if len(got) != len(values) {
+		t.Fatalf("expected %d values, got %d", len(values), len(got))
+	}
+	for i := range values {
+		if f, ok := values[i].(float64); ok && math.IsNaN(f) {
+			if math.Float64bits(f) != math.Float64bits(got[i].(float64)) {
+				t.Errorf("value %d: NaN bits changed: %x", i, math.Float64bits(got[i].(float64)))
+			}
+			continue
+		}
+		if !reflect.DeepEqual(values[i], got[i]) {
+			t.Errorf("value %d: expected %#v, got %#v", i, values[i], got[i])
+		}
+	}
+}
+
+func TestCorpusFileParse(t *T) {
+	data := []byte("go test fuzz v1\n[]byte(\"a\\x00\")\nstring(`raw`)\nbyte('x')\nrune('\\u00e9')\nint(0x10)\nuint8(255)\nfloat64(+Inf)\n")
+	want := []interface{}{[]byte("a\x00"), "raw", byte('x'), rune('é'), int(16), uint8(255), math.Inf(1)}
+	got, err := unmarshalCorpusFile(data)
+	if err != nil {
+		t.Fatalf("could not unmarshal corpus file: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %#v, got %#v", want, got)
+	}
+
+	for _, bad := range []string{
+		"",
+		"go test fuzz v1\n",
+		"go test fuzz v2\nint(1)\n",
+		"go test fuzz v1\nint8(128)\n",
+		"go test fuzz v1\nbyte('€')\n",
+		"go test fuzz v1\ncomplex128(1)\n",
+		"go test fuzz v1\nstring(\"unterminated)\n",
+	} {
+		if _, err := unmarshalCorpusFile([]byte(bad)); err == nil {
+			t.Errorf("expected an error for %q", bad)
+		}
+	}
+}
+
+func TestMutator(t *T) {
+	m := newMutator()
+	for i := 0; i < 1000; i++ {
+		values := []interface{}{[]byte("abc"), "def", int8(127), uint16(0), float64(1), true}
+		orig := []byte("abc")
+		parent := values[0]
+		for j := 0; j < 20; j++ {
+			m.mutate(values, 64)
+		}
+		if !bytes.Equal(parent.([]byte), orig) {
+			t.Fatalf("mutator modified the bytes of the parent input")
+		}
+		if len(values[0].([]byte)) > 64 || len(values[1].(string)) > 64 {
+			t.Fatalf("mutator made an input longer than the maximum")
+		}
+		if err := checkCorpusValues(values, []reflect.Type{
+			reflect.TypeOf([]byte(nil)),
+			reflect.TypeOf(""),
+			reflect.TypeOf(int8(0)),
+			reflect.TypeOf(uint16(0)),
+			reflect.TypeOf(float64(0)),
+			reflect.TypeOf(false),
+		}); err != nil {
+			t.Fatalf("mutator changed the type of a value: %v", err)
+		}
+	}
+}
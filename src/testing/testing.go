@@ -54,6 +54,8 @@ func Init() {
 	flag.IntVar(&flagCount, "test.count", 1, "run each test or benchmark `count` times")
 
 	initBenchmarkFlags()
+	initFuzzFlags()
+	initCoverFlags()
 }
 
 // common holds the elements common between T and B and
@@ -110,11 +112,11 @@ func Short() bool {
 	return flagShort
 }
 
-// CoverMode reports what the test coverage mode is set to.
-//
-// Test coverage is not supported; this returns the empty string.
+// CoverMode reports what the test coverage mode is set to. The
+// values are "set", "count", or "atomic". The return value will be
+// empty if test coverage is not enabled.
 func CoverMode() string {
-	return ""
+	return cover.Mode
 }
 
 // Verbose reports whether the -test.v flag is set.
@@ -539,6 +541,8 @@ type M struct {
 	Tests      []InternalTest
 	Benchmarks []InternalBenchmark
 
+	fuzzTargets []InternalFuzzTarget
+
 	deps testDeps
 
 	// value to pass to os.Exit, the outer test func main
@@ -589,16 +593,20 @@ func (m *M) Run() (code int) {
 	}
 
 	testRan, testOk := runTests(m.deps.MatchString, m.Tests)
-	if !testRan && *matchBenchmarks == "" {
+	fuzzTargetsRan, fuzzTargetsOk := runFuzzTests(m.deps.MatchString, m.fuzzTargets)
+	if !testRan && !fuzzTargetsRan && *matchBenchmarks == "" && *matchFuzz == "" {
 		fmt.Fprintln(os.Stderr, "testing: warning: no tests to run")
 	}
-	if !testOk || !runBenchmarks(m.deps.MatchString, m.Benchmarks) {
+	if !testOk || !fuzzTargetsOk || !runBenchmarks(m.deps.MatchString, m.Benchmarks) || !runFuzzing(m.deps.MatchString, m.fuzzTargets) {
 		fmt.Println("FAIL")
 		m.exitCode = 1
 	} else {
 		fmt.Println("PASS")
 		m.exitCode = 0
 	}
+	if cover.Mode != "" {
+		coverReport()
+	}
 	return
 }
 
@@ -668,9 +676,10 @@ type InternalExample struct {
 func MainStart(deps interface{}, tests []InternalTest, benchmarks []InternalBenchmark, fuzzTargets []InternalFuzzTarget, examples []InternalExample) *M {
 	Init()
 	return &M{
-		Tests:      tests,
-		Benchmarks: benchmarks,
-		deps:       deps.(testDeps),
+		Tests:       tests,
+		Benchmarks:  benchmarks,
+		fuzzTargets: fuzzTargets,
+		deps:        deps.(testDeps),
 	}
 }
 
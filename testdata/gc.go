@@ -1,6 +1,9 @@
 package main
 
-import "runtime"
+import (
+	"runtime"
+	"unsafe"
+)
 
 var xorshift32State uint32 = 1
 
@@ -19,7 +22,10 @@ func randuint32() uint32 {
 
 func main() {
 	testNonPointerHeap()
+	testPointerHeap()
 	testKeepAlive()
+	testPinner()
+	testInterfaceUintptr()
 }
 
 var scalarSlices [4][]byte
@@ -68,6 +74,99 @@ func testNonPointerHeap() {
 	println("ok")
 }
 
+type listNode struct {
+	next  *listNode
+	value uint32
+	data  []byte
+}
+
+var list *listNode
+var garbage []byte
+
+func testPointerHeap() {
+	numNodes := 100
+	if ^uintptr(0) <= 0xffff {
+		// Small heap on 16-bit devices.
+		numNodes = 16
+	}
+	// Create a linked list with garbage allocated in between the nodes, so
+	// that the nodes will be moved when the heap is compacted.
+	for i := 0; i < numNodes; i++ {
+		node := &listNode{next: list, value: randuint32()}
+		node.data = make([]byte, 8)
+		for j := range node.data {
+			node.data[j] = byte(node.value) + byte(j)
+		}
+		list = node
+		garbage = make([]byte, randuint32()%64)
+	}
+	garbage = nil
+	runtime.GC()
+
+	// Check that the list is still intact.
+	count := 0
+	for node := list; node != nil; node = node.next {
+		for j, b := range node.data {
+			if b != byte(node.value)+byte(j) {
+				panic("list node was overwritten!")
+			}
+		}
+		count++
+	}
+	if count != numNodes {
+		panic("list has the wrong length!")
+	}
+	list = nil
+	println("ok")
+}
+
+func testPinner() {
+	var pinner runtime.Pinner
+	x := new(int)
+	*x = 3
+	pinner.Pin(x)
+	runtime.GC()
+	pinner.Unpin()
+	println("pinned:", *x)
+}
+
+type uintptrHolder struct {
+	value any
+}
+
+var (
+	holder      *uintptrHolder
+	holderSaved *[1]uintptr
+)
+
+// An interface can hold a uintptr that happens to point into the heap. The GC
+// can't tell it apart from a pointer, but it must not be changed when the
+// object it points to is moved.
+func testInterfaceUintptr() {
+	setupInterfaceUintptr()
+	runtime.GC()
+	println("interface uintptr:", holder.value.(uintptr) == holderSaved[0])
+	holder = nil
+	holderSaved = nil
+	list = nil
+}
+
+// Create a node that is only referenced precisely (from the list head), with
+// free space before it so that it would move when the heap is compacted. Its
+// address is also stored as a uintptr in an interface and in a pointer-free
+// object.
+//
+//go:noinline
+func setupInterfaceUintptr() {
+	list = &listNode{}
+	garbage = make([]byte, 64)
+	list.next = &listNode{value: 5}
+	garbage = nil
+	addr := uintptr(unsafe.Pointer(list.next))
+	holder = &uintptrHolder{value: addr}
+	holderSaved = &[1]uintptr{addr}
+}
+
 func testKeepAlive() {
 	// There isn't much we can test, but at least we can test that
 	// runtime.KeepAlive compiles correctly.
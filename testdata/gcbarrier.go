@@ -0,0 +1,141 @@
+package main
+
+// Test for the write barrier of -gc=incremental. Pointers are moved between
+// heap objects while a GC cycle is in progress, so that they often end up in
+// objects that have already been scanned. If the GC misses such a store, the
+// object that was moved is freed while it is still reachable, and it will be
+// overwritten by the garbage that is allocated in between.
+
+import (
+	"sync/atomic"
+	"unsafe"
+)
+
+type node struct {
+	id    uint32
+	check uint32
+}
+
+func (n *node) ok() bool {
+	return n != nil && n.check == ^n.id
+}
+
+// Every holder contains nodes in each of the ways a pointer can be stored.
+type holder struct {
+	child    *node
+	atomic   atomic.Pointer[node]
+	unsafe   unsafe.Pointer // only accessed using the sync/atomic functions
+	children []*node        // modified using copy
+	list     []*node        // modified using append
+}
+
+const (
+	numHolders      = 16
+	nodesPerHolder  = 7
+	numNodes        = numHolders * nodesPerHolder
+	numRounds       = 2000
+	garbageSize     = 48
+	garbagePerRound = 8
+)
+
+var holders []*holder
+var garbage []byte
+
+func main() {
+	nextID := uint32(0)
+	newNode := func() *node {
+		n := &node{id: nextID, check: ^nextID}
+		nextID++
+		return n
+	}
+	holders = make([]*holder, numHolders)
+	for i := range holders {
+		h := &holder{}
+		h.child = newNode()
+		h.atomic.Store(newNode())
+		atomic.StorePointer(&h.unsafe, unsafe.Pointer(newNode()))
+		h.children = []*node{newNode(), newNode()}
+		h.list = []*node{newNode(), newNode()}
+		holders[i] = h
+	}
+
+	for round := 0; round < numRounds; round++ {
+		src := holders[(round*7)%numHolders]
+		dst := holders[(round*7+3)%numHolders]
+		swapField(src, dst)
+		churn()
+		swapAtomic(src, dst)
+		churn()
+		swapUnsafe(src, dst)
+		churn()
+		swapCopy(src, dst)
+		churn()
+		swapAppend(src, dst)
+		churn()
+	}
+
+	println("write barrier:", check())
+}
+
+// Allocate some garbage, which also does a bit of GC work.
+func churn() {
+	for i := 0; i < garbagePerRound; i++ {
+		garbage = make([]byte, garbageSize)
+	}
+}
+
+//go:noinline
+func swapField(a, b *holder) {
+	a.child, b.child = b.child, a.child
+}
+
+//go:noinline
+func swapAtomic(a, b *holder) {
+	n := b.atomic.Swap(a.atomic.Load())
+	if !a.atomic.CompareAndSwap(a.atomic.Load(), n) {
+		panic("CompareAndSwap failed")
+	}
+}
+
+//go:noinline
+func swapUnsafe(a, b *holder) {
+	n := atomic.SwapPointer(&b.unsafe, atomic.LoadPointer(&a.unsafe))
+	if !atomic.CompareAndSwapPointer(&a.unsafe, atomic.LoadPointer(&a.unsafe), n) {
+		panic("CompareAndSwapPointer failed")
+	}
+}
+
+//go:noinline
+func swapCopy(a, b *holder) {
+	tmp := make([]*node, len(a.children))
+	copy(tmp, a.children)
+	copy(a.children, b.children)
+	copy(b.children, tmp)
+}
+
+//go:noinline
+func swapAppend(a, b *holder) {
+	// The first append allocates a new buffer, the others store into the
+	// existing buffers of the holders.
+	tmp := append([]*node(nil), a.list...)
+	a.list = append(a.list[:0], b.list...)
+	b.list = append(b.list[:0], tmp...)
+}
+
+// Check that every node is still reachable exactly once and hasn't been
+// overwritten.
+func check() string {
+	var seen [numNodes]bool
+	for _, h := range holders {
+		nodes := []*node{h.child, h.atomic.Load(), (*node)(atomic.LoadPointer(&h.unsafe))}
+		nodes = append(nodes, h.children...)
+		nodes = append(nodes, h.list...)
+		for _, n := range nodes {
+			if !n.ok() || n.id >= numNodes || seen[n.id] {
+				return "node was freed while still reachable"
+			}
+			seen[n.id] = true
+		}
+	}
+	return "ok"
+}
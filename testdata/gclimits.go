@@ -0,0 +1,52 @@
+package main
+
+import (
+	"math"
+	"runtime"
+	"runtime/debug"
+)
+
+var live [][]byte
+var sink []byte
+
+func main() {
+	// Check the default settings.
+	println("gc percent:", debug.SetGCPercent(50))
+	println("gc percent:", debug.SetGCPercent(100))
+	println("memory limit is max:", debug.SetMemoryLimit(-1) == math.MaxInt64)
+
+	// Keep some memory alive, and allocate lots of garbage. The GC would
+	// normally grow the heap to twice the live memory, but the memory limit
+	// should prevent that.
+	const limit = 1024 * 1024
+	debug.SetMemoryLimit(limit)
+	for i := 0; i < 768; i++ {
+		live = append(live, make([]byte, 1024))
+	}
+	for i := 0; i < 10000; i++ {
+		sink = make([]byte, 1024)
+	}
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	println("heap within limit:", ms.Sys <= limit*4/3)
+	println("memory limit:", debug.SetMemoryLimit(math.MaxInt64))
+	live = nil
+
+	// With the GC disabled, the heap should grow instead.
+	debug.SetGCPercent(-1)
+	runtime.ReadMemStats(&ms)
+	numGC := ms.NumGC
+	for i := 0; i < 10000; i++ {
+		sink = make([]byte, 1024)
+	}
+	runtime.ReadMemStats(&ms)
+	println("gc disabled:", ms.NumGC == numGC)
+	println("gc percent:", debug.SetGCPercent(100))
+
+	// The out of memory hook can't easily be tested without running out of
+	// memory, but it can at least be set and removed.
+	debug.SetOutOfMemoryHook(func(size uintptr) {
+		println("out of memory:", size)
+	})
+	debug.SetOutOfMemoryHook(nil)
+}
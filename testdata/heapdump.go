@@ -0,0 +1,67 @@
+package main
+
+// Test for the heap introspection functions in runtime/debug. The heap dump
+// that is printed at the end contains addresses, so instead of comparing the
+// output with a .txt file, TestHeapDump in main_test.go parses it and checks it
+// against the other values printed here.
+
+import (
+	"runtime"
+	"runtime/debug"
+	"unsafe"
+)
+
+type object struct {
+	next  *object
+	value [100]byte
+}
+
+var target *object
+
+// Global, so that ReadMemStats doesn't need to allocate it.
+var memStats runtime.MemStats
+
+func main() {
+	target = &object{}
+	runtime.GC()
+	blockSize := debug.HeapBlockSize()
+	addr := uintptr(unsafe.Pointer(target))
+
+	// Find the object allocated above and count the blocks of all objects.
+	// The closure is created before walking the heap, so that no memory is
+	// allocated between the walk and ReadMemStats.
+	found := false
+	usedBlocks := uintptr(0)
+	walk := func(obj debug.HeapObject) bool {
+		if obj.Addr == addr && obj.Blocks*blockSize >= unsafe.Sizeof(object{}) {
+			found = true
+		}
+		usedBlocks += obj.Blocks
+		return true
+	}
+	debug.WalkHeap(walk)
+	runtime.ReadMemStats(&memStats)
+	println("found allocation:", found)
+	println("heap in use adds up:", uint64(usedBlocks*blockSize) == memStats.HeapInuse)
+
+	// Every run of free blocks in bucket i has between 1<<i and 2<<i-1 blocks.
+	runs := debug.HeapFreeRuns()
+	runtime.ReadMemStats(&memStats)
+	freeBlocks := memStats.HeapIdle / uint64(blockSize)
+	minBlocks, maxBlocks := uint64(0), uint64(0)
+	for i, n := range runs {
+		minBlocks += uint64(n) << i
+		maxBlocks += uint64(n) * (2<<i - 1)
+	}
+	println("free runs add up:", minBlocks <= freeBlocks && freeBlocks <= maxBlocks)
+
+	// Print the values that TestHeapDump compares with the heap dump. Nothing
+	// is allocated from here on, so the heap dump shows the same heap.
+	println("target:", addr)
+	for i, n := range runs {
+		if n != 0 {
+			println("free runs:", i, n)
+		}
+	}
+	debug.PrintHeapDump()
+}
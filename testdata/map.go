@@ -130,6 +130,8 @@ func main() {
 
 	mapgrow()
 
+	mapgrowiterate()
+
 	interfacerehash()
 }
 
@@ -277,6 +279,40 @@ func mapgrow() {
 	println("done")
 }
 
+// Iterate over a map that is in the middle of growing, and modify it while
+// iterating over it.
+func mapgrowiterate() {
+	// The map starts growing when the 97th entry is inserted.
+	const n = 100
+	m := make(map[int]int)
+	for i := 0; i < n; i++ {
+		m[i] = i
+	}
+
+	seen := make([]bool, n)
+	count := 0
+	for k, v := range m {
+		if v != k && v != -k {
+			println("unexpected value:", k, v)
+		}
+		if k >= n {
+			// Entry added during iteration.
+			continue
+		}
+		if seen[k] {
+			println("saw key twice:", k)
+		}
+		seen[k] = true
+		count++
+
+		// Grow the map some more and update an entry that may not have been
+		// seen yet.
+		m[k+n] = k + n
+		m[(k+1)%n] = -((k + 1) % n)
+	}
+	println("mapgrowiterate:", count, len(m))
+}
+
 type Counter interface {
 	count() int
 }
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"runtime"
+	"runtime/metrics"
+)
+
+var sink []byte
+
+func main() {
+	descs := metrics.All()
+	samples := make([]metrics.Sample, len(descs)+1)
+	for i, desc := range descs {
+		samples[i].Name = desc.Name
+	}
+	samples[len(descs)].Name = "/unknown/metric:bytes"
+	metrics.Read(samples)
+	allUint64 := true
+	for _, sample := range samples[:len(descs)] {
+		if sample.Value.Kind() != metrics.KindUint64 {
+			allUint64 = false
+		}
+	}
+	println("all known metrics are uint64:", allUint64)
+	println("unknown metric is bad:", samples[len(descs)].Value.Kind() == metrics.KindBad)
+
+	// Check that a few metrics actually change.
+	read := func(name string) uint64 {
+		sample := []metrics.Sample{{Name: name}}
+		metrics.Read(sample)
+		return sample[0].Value.Uint64()
+	}
+	allocs := read("/gc/heap/allocs:bytes")
+	sink = make([]byte, 1000)
+	println("allocated bytes increased:", read("/gc/heap/allocs:bytes")-allocs >= 1000)
+
+	cycles := read("/gc/cycles/forced:gc-cycles")
+	runtime.GC()
+	println("forced GC cycles:", read("/gc/cycles/forced:gc-cycles")-cycles)
+
+	goroutines := read("/sched/goroutines:goroutines")
+	ch := make(chan struct{})
+	go func() {
+		<-ch
+	}()
+	println("goroutine started:", read("/sched/goroutines:goroutines")-goroutines)
+	ch <- struct{}{}
+	runtime.Gosched()
+	println("goroutine exited:", read("/sched/goroutines:goroutines") == goroutines)
+
+	total := read("/memory/classes/total:bytes")
+	classes := read("/memory/classes/heap/free:bytes") + read("/memory/classes/heap/objects:bytes") + read("/memory/classes/heap/released:bytes") + read("/memory/classes/metadata/other:bytes")
+	println("memory classes add up:", total == classes)
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"io"
+	"runtime"
+	"runtime/pprof"
+	"time"
+)
+
+var sink [][]byte
+
+func main() {
+	// Record every allocation.
+	runtime.MemProfileRate = 1
+	for i := 0; i < 100; i++ {
+		sink = append(sink, make([]byte, 100))
+	}
+	n, _ := runtime.MemProfile(nil, true)
+	println("heap profile has records:", n > 0)
+
+	var buf bytes.Buffer
+	if err := pprof.WriteHeapProfile(&buf); err != nil {
+		println("could not write heap profile:", err.Error())
+	}
+	println("heap profile is valid:", isValid(buf.Bytes()))
+	heap := decodeProfile(buf.Bytes())
+	println("heap profile sample types:", heap.sampleTypes())
+	println("heap profile locations are valid:", heap.locationsValid(1))
+
+	buf.Reset()
+	if err := pprof.StartCPUProfile(&buf); err != nil {
+		println("could not start CPU profile:", err.Error())
+	}
+	if err := pprof.StartCPUProfile(&buf); err == nil {
+		println("expected an error when starting a second CPU profile")
+	}
+	x := spin()
+	pprof.StopCPUProfile()
+	println("cpu profile is valid:", isValid(buf.Bytes()), x != 0)
+	cpu := decodeProfile(buf.Bytes())
+	println("cpu profile sample types:", cpu.sampleTypes())
+
+	// Call stacks can only be recorded when the program is built with -symtab,
+	// which is when runtime.Callers works.
+	minDepth := 1
+	if runtime.Callers(0, make([]uintptr, 1)) != 0 {
+		minDepth = 2
+	}
+	println("cpu profile locations are valid:", cpu.locationsValid(minDepth))
+
+	for _, p := range pprof.Profiles() {
+		buf.Reset()
+		if err := p.WriteTo(&buf, 0); err != nil {
+			println("could not write profile:", p.Name(), err.Error())
+		}
+		println("profile", p.Name(), "is valid:", isValid(buf.Bytes()))
+	}
+}
+
+// Use the CPU for a while, so that the CPU profile has some samples.
+//
+//go:noinline
+func spin() uint64 {
+	x := uint64(1)
+	start := time.Now()
+	for time.Since(start) < 200*time.Millisecond {
+		for i := 0; i < 100_000; i++ {
+			x = x*31 + uint64(i)
+		}
+	}
+	return x
+}
+
+// Check whether the profile is a non-empty gzip stream.
+func isValid(data []byte) bool {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return false
+	}
+	raw, err := io.ReadAll(r)
+	return err == nil && len(raw) > 0
+}
+
+// The parts of a decoded profile that are checked by this test.
+type profile struct {
+	strings     []string
+	types       [][2]int64 // sample types as (type, unit) string indices
+	samples     [][]uint64 // location IDs of every sample
+	numValues   []int      // number of values of every sample
+	locations   map[uint64]uint64
+	decodeError bool
+}
+
+// Decode a gzipped profile in the protobuf format described in
+// https://github.com/google/pprof/blob/main/proto/profile.proto.
+func decodeProfile(data []byte) *profile {
+	p := &profile{locations: map[uint64]uint64{}}
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		p.decodeError = true
+		return p
+	}
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		p.decodeError = true
+		return p
+	}
+	p.decodeError = !decodeMessage(raw, func(field int, value uint64, data []byte) bool {
+		switch field {
+		case 1: // sample_type
+			var typ [2]int64
+			ok := decodeMessage(data, func(field int, value uint64, data []byte) bool {
+				if field == 1 || field == 2 {
+					typ[field-1] = int64(value)
+				}
+				return true
+			})
+			p.types = append(p.types, typ)
+			return ok
+		case 2: // sample
+			var locs []uint64
+			values := 0
+			ok := decodeMessage(data, func(field int, value uint64, data []byte) bool {
+				switch field {
+				case 1: // location_id
+					if data == nil {
+						locs = append(locs, value)
+						return true
+					}
+					return decodePacked(data, func(value uint64) {
+						locs = append(locs, value)
+					})
+				case 2: // value
+					if data == nil {
+						values++
+						return true
+					}
+					return decodePacked(data, func(value uint64) {
+						values++
+					})
+				}
+				return true
+			})
+			p.samples = append(p.samples, locs)
+			p.numValues = append(p.numValues, values)
+			return ok
+		case 4: // location
+			var id, address uint64
+			ok := decodeMessage(data, func(field int, value uint64, data []byte) bool {
+				switch field {
+				case 1:
+					id = value
+				case 3:
+					address = value
+				}
+				return true
+			})
+			p.locations[id] = address
+			return ok
+		case 6: // string_table
+			p.strings = append(p.strings, string(data))
+		}
+		return true
+	})
+	return p
+}
+
+// Call fn for every field in the message. Varint fields are passed as value
+// and length-delimited fields as data. It returns false if the message is
+// malformed or fn returns false.
+func decodeMessage(msg []byte, fn func(field int, value uint64, data []byte) bool) bool {
+	for len(msg) > 0 {
+		key, n := binary.Uvarint(msg)
+		if n <= 0 {
+			return false
+		}
+		msg = msg[n:]
+		switch key & 7 {
+		case 0: // varint
+			value, n := binary.Uvarint(msg)
+			if n <= 0 {
+				return false
+			}
+			msg = msg[n:]
+			if !fn(int(key>>3), value, nil) {
+				return false
+			}
+		case 2: // length-delimited
+			length, n := binary.Uvarint(msg)
+			if n <= 0 || uint64(len(msg)-n) < length {
+				return false
+			}
+			data := msg[n : n+int(length)]
+			msg = msg[n+int(length):]
+			if !fn(int(key>>3), 0, data) {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// Call fn for every varint in a packed repeated field.
+func decodePacked(data []byte, fn func(value uint64)) bool {
+	for len(data) > 0 {
+		value, n := binary.Uvarint(data)
+		if n <= 0 {
+			return false
+		}
+		data = data[n:]
+		fn(value)
+	}
+	return true
+}
+
+// Return the sample types of the profile as type/unit pairs.
+func (p *profile) sampleTypes() string {
+	if p.decodeError {
+		return "<invalid profile>"
+	}
+	s := ""
+	for i, typ := range p.types {
+		if typ[0] >= int64(len(p.strings)) || typ[1] >= int64(len(p.strings)) {
+			return "<invalid string index>"
+		}
+		if i > 0 {
+			s += " "
+		}
+		s += p.strings[typ[0]] + "/" + p.strings[typ[1]]
+	}
+	return s
+}
+
+// Check that the profile has samples, that every sample has a value for every
+// sample type and refers to valid locations, and that at least one sample has
+// a call stack of minDepth frames or more.
+func (p *profile) locationsValid(minDepth int) bool {
+	if p.decodeError || len(p.samples) == 0 {
+		return false
+	}
+	deepEnough := false
+	for i, locs := range p.samples {
+		if len(locs) == 0 || p.numValues[i] != len(p.types) {
+			return false
+		}
+		for _, id := range locs {
+			if address, ok := p.locations[id]; !ok || address == 0 {
+				return false
+			}
+		}
+		if len(locs) >= minDepth {
+			deepEnough = true
+		}
+	}
+	return deepEnough
+}
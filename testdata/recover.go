@@ -1,6 +1,7 @@
 package main
 
 import (
+	"reflect"
 	"runtime"
 	"sync"
 )
@@ -32,6 +33,9 @@ func main() {
 
 	println("\n# runtime.Goexit")
 	runtimeGoexit()
+
+	println("\n# reflect call with wrong argument type")
+	reflectCallWrongType()
 }
 
 func recoverSimple() {
@@ -127,6 +131,16 @@ func runtimeGoexit() {
 	wg.Wait()
 }
 
+func reflectCallWrongType() {
+	defer func() {
+		printitf("recovered:", recover())
+	}()
+	fn := reflect.ValueOf(func(err error) {
+		println("unreachable")
+	})
+	fn.Call([]reflect.Value{reflect.ValueOf(5)})
+}
+
 func printitf(msg string, itf interface{}) {
 	switch itf := itf.(type) {
 	case string:
@@ -364,6 +364,18 @@ func main() {
 	println("\nv.Interface() method")
 	testInterfaceMethod()
 
+	println("\nfunction calls")
+	testCall()
+
+	println("\ntype constructors")
+	testTypeConstructors()
+
+	println("\nchannels")
+	testChannels()
+
+	println("\nMakeFunc")
+	testMakeFunc()
+
 	// Test reflect.DeepEqual.
 	var selfref1, selfref2 selfref
 	selfref1.x = &selfref1
@@ -573,6 +585,164 @@ func testInterfaceMethod() {
 	}
 }
 
+type counter struct {
+	n int
+}
+
+func (c *counter) Add(delta int) int {
+	c.n += delta
+	return c.n
+}
+
+func (c counter) Format(prefix string, args ...interface{}) string {
+	return prefix + ":" + strconv.Itoa(c.n) + ":" + strconv.Itoa(len(args))
+}
+
+func divmod(a, b int) (int, int) {
+	return a / b, a % b
+}
+
+func testCall() {
+	fn := reflect.ValueOf(divmod)
+	println("type:", fn.Type().String(), fn.Type().NumIn(), fn.Type().NumOut())
+	out := fn.Call([]reflect.Value{reflect.ValueOf(17), reflect.ValueOf(5)})
+	println("divmod:", out[0].Int(), out[1].Int())
+
+	c := &counter{}
+	ct := reflect.TypeOf(c)
+	for i := 0; i < ct.NumMethod(); i++ {
+		m := ct.Method(i)
+		println("method:", m.Name, m.Type.String(), m.Type.IsVariadic())
+	}
+	add := reflect.ValueOf(c).MethodByName("Add")
+	add.Call([]reflect.Value{reflect.ValueOf(3)})
+	println("add:", add.Call([]reflect.Value{reflect.ValueOf(4)})[0].Int(), c.n)
+	format := reflect.ValueOf(c).Elem().Method(0)
+	println("format:", format.Call([]reflect.Value{reflect.ValueOf("n"), reflect.ValueOf(1), reflect.ValueOf("x")})[0].String())
+	m, ok := ct.MethodByName("Format")
+	println("format by name:", ok, m.Func.Call([]reflect.Value{reflect.ValueOf(c), reflect.ValueOf("m")})[0].String())
+	_, ok = ct.MethodByName("Missing")
+	println("missing:", ok)
+}
+
+func testTypeConstructors() {
+	// Types that exist in the program must be found.
+	intType := reflect.TypeOf(0)
+	stringType := reflect.TypeOf("")
+	println("slice exists:", reflect.SliceOf(intType) == reflect.TypeOf([]int{}))
+	println("array exists:", reflect.ArrayOf(3, intType) == reflect.TypeOf([3]int{}))
+	println("map exists:", reflect.MapOf(stringType, intType) == reflect.TypeOf(map[string]int{}))
+	println("func exists:", reflect.FuncOf([]reflect.Type{intType}, []reflect.Type{stringType}, false) == reflect.TypeOf(func(int) string { return "" }))
+	println("struct exists:", reflect.StructOf([]reflect.StructField{{Name: "X", Type: intType, Tag: `json:"x"`}}) == reflect.TypeOf(struct {
+		X int `json:"x"`
+	}{}))
+
+	// Types that don't exist in the program must be created.
+	pointType := reflect.TypeOf(point{})
+	st := reflect.SliceOf(pointType)
+	println("slice:", st.String(), st == reflect.SliceOf(pointType), st.Elem() == pointType)
+	s := reflect.MakeSlice(st, 2, 2)
+	s.Index(1).Field(0).SetInt(3)
+	s = reflect.Append(s, reflect.ValueOf(point{5, 6}))
+	println("slice value:", s.Len(), s.Index(1).Field(0).Int(), s.Index(2).Field(1).Int())
+	at := reflect.ArrayOf(2, pointType)
+	println("array:", at.String(), at.Len(), at.Size(), at.Comparable())
+	a := reflect.New(at).Elem()
+	a.Index(1).Set(reflect.ValueOf(point{7, 8}))
+	println("array value:", a.Index(1).Field(1).Int(), reflect.PointerTo(at).String())
+	mt := reflect.MapOf(pointType, stringType)
+	println("map:", mt.String(), mt.Key() == pointType)
+	m := reflect.MakeMap(mt)
+	m.SetMapIndex(reflect.ValueOf(point{1, 2}), reflect.ValueOf("one-two"))
+	println("map value:", m.Len(), m.MapIndex(reflect.ValueOf(point{1, 2})).String())
+	ft := reflect.FuncOf([]reflect.Type{pointType, reflect.SliceOf(intType)}, []reflect.Type{stringType, pointType}, true)
+	println("func:", ft.String(), ft.NumIn(), ft.NumOut())
+	structType := reflect.StructOf([]reflect.StructField{
+		{Name: "Pos", Type: pointType, Tag: `json:"pos"`},
+		{Name: "name", PkgPath: "main", Type: stringType},
+	})
+	println("struct:", structType.String(), structType.NumField())
+	f := structType.Field(1)
+	println("struct field:", f.Name, f.PkgPath, f.Type.String(), structType.Field(0).Tag.Get("json"))
+	sv := reflect.New(structType).Elem()
+	sv.Field(0).Set(reflect.ValueOf(point{9, 10}))
+	println("struct value:", sv.Field(0).Field(0).Int(), sv.Field(1).String() == "", sv.Field(1).CanSet())
+	pt := reflect.PointerTo(reflect.PointerTo(reflect.PointerTo(reflect.PointerTo(reflect.PointerTo(pointType)))))
+	println("pointer:", pt.String(), pt.Elem().Elem().Elem().Elem().Elem() == pointType)
+}
+
+func testChannels() {
+	ct := reflect.ChanOf(reflect.BothDir, reflect.TypeOf(point{}))
+	println("chan type:", ct.String(), ct.ChanDir().String(), ct == reflect.ChanOf(reflect.BothDir, reflect.TypeOf(point{})))
+	ch := reflect.MakeChan(ct, 1)
+	println("chan:", ch.Len(), ch.Cap())
+	ch.Send(reflect.ValueOf(point{1, 2}))
+	println("try send full:", ch.TrySend(reflect.ValueOf(point{3, 4})), ch.Len())
+	v, ok := ch.Recv()
+	println("recv:", v.Field(0).Int(), v.Field(1).Int(), ok)
+	v, ok = ch.TryRecv()
+	println("try recv empty:", v.IsValid(), ok)
+	println("try send:", ch.TrySend(reflect.ValueOf(point{5, 6})))
+	v, ok = ch.TryRecv()
+	println("try recv:", v.Field(0).Int(), ok)
+
+	// Select with a default case, a ready receive and a nil channel.
+	c1 := make(chan int, 1)
+	c2 := make(chan string)
+	cases := []reflect.SelectCase{
+		{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(c1)},
+		{Dir: reflect.SelectSend, Chan: reflect.ValueOf(c2), Send: reflect.ValueOf("hello")},
+		{Dir: reflect.SelectRecv, Chan: reflect.ValueOf((chan int)(nil))},
+		{Dir: reflect.SelectDefault},
+	}
+	chosen, recv, recvOK := reflect.Select(cases)
+	println("select default:", chosen, recv.IsValid(), recvOK)
+	c1 <- 5
+	chosen, recv, recvOK = reflect.Select(cases)
+	println("select recv:", chosen, recv.Int(), recvOK)
+
+	// Blocking select, that is completed by another goroutine.
+	go func() {
+		println("received from reflect.Select:", <-c2)
+	}()
+	chosen, _, _ = reflect.Select(cases[:3])
+	println("select send:", chosen)
+
+	// Closed channels.
+	close(c1)
+	chosen, recv, recvOK = reflect.Select(cases[:1])
+	println("select closed:", chosen, recv.Int(), recvOK)
+	ch.Close()
+	v, ok = ch.Recv()
+	println("recv closed:", v.Field(0).Int(), ok)
+}
+
+func testMakeFunc() {
+	// A function that swaps its two parameters.
+	var swap func(int, string) (string, int)
+	fv := reflect.MakeFunc(reflect.TypeOf(swap), func(args []reflect.Value) []reflect.Value {
+		return []reflect.Value{args[1], args[0]}
+	})
+	reflect.ValueOf(&swap).Elem().Set(fv)
+	s, n := swap(5, "five")
+	println("swap:", s, n)
+	out := fv.Call([]reflect.Value{reflect.ValueOf(6), reflect.ValueOf("six")})
+	println("swap call:", out[0].String(), out[1].Int())
+
+	// Large parameters and results, and an interface result.
+	var sum func(point, ...int) (point, interface{})
+	fv = reflect.MakeFunc(reflect.TypeOf(sum), func(args []reflect.Value) []reflect.Value {
+		p := args[0].Interface().(point)
+		for _, x := range args[1].Interface().([]int) {
+			p.X += int16(x)
+		}
+		return []reflect.Value{reflect.ValueOf(p), reflect.ValueOf(args[1].Len())}
+	})
+	sum = fv.Interface().(func(point, ...int) (point, interface{}))
+	p, count := sum(point{1, 2}, 3, 4, 5)
+	println("sum:", p.X, p.Y, count.(int))
+}
+
 var xorshift32State uint32 = 1
 
 func xorshift32(x uint32) uint32 {
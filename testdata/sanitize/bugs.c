@@ -0,0 +1,26 @@
+#include <stdlib.h>
+
+// Volatile, so that the invalid accesses aren't optimized away.
+volatile int sink;
+
+void heapOverflow(void) {
+	volatile char *buf = malloc(16);
+	buf[16] = 1;
+	free((void *)buf);
+}
+
+void useAfterFree(void) {
+	volatile int *p = malloc(sizeof(int));
+	free((void *)p);
+	sink = *p;
+}
+
+void unreachable(int x) {
+	if (x) {
+		__builtin_unreachable();
+	}
+}
+
+void goHeapOverflow(char *buf, int len) {
+	((volatile char *)buf)[len] = 1;
+}
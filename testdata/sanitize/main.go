@@ -0,0 +1,35 @@
+package main
+
+// Test program for -sanitize=address,undefined. The C functions in bugs.c each
+// contain a bug that should be reported by one of the sanitizers. The bug to
+// trigger is selected with the first command line argument.
+
+// void heapOverflow(void);
+// void goHeapOverflow(char *buf, int len);
+// void useAfterFree(void);
+// void unreachable(int x);
+import "C"
+
+import (
+	"os"
+	"unsafe"
+)
+
+// Buffer allocated by the Go heap, which adds a redzone after every object. It
+// is a global so that it isn't allocated on the stack instead.
+var goBuffer []byte
+
+func main() {
+	switch os.Args[1] {
+	case "heap-overflow":
+		C.heapOverflow()
+	case "go-heap-overflow":
+		goBuffer = make([]byte, 16)
+		C.goHeapOverflow((*C.char)(unsafe.Pointer(&goBuffer[0])), C.int(len(goBuffer)))
+	case "use-after-free":
+		C.useAfterFree()
+	case "unreachable":
+		C.unreachable(1)
+	}
+	println("no error was reported")
+}
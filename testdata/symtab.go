@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"time"
+)
+
+type T struct{}
+
+//go:noinline
+func (t *T) method() {
+	printCaller("method")
+}
+
+//go:noinline
+func helper() {
+	printCaller("helper")
+}
+
+//go:noinline
+func printCaller(name string) {
+	pc, file, line, ok := runtime.Caller(1)
+	println(name+":", filepath.Base(file), line, ok, runtime.FuncForPC(pc).Name())
+}
+
+//go:noinline
+func callers() {
+	pcs := make([]uintptr, 8)
+	n := runtime.Callers(1, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	for i := 0; i < 2; i++ {
+		frame, _ := frames.Next()
+		println("frame:", frame.Function, filepath.Base(frame.File), frame.Line)
+	}
+}
+
+func main() {
+	_, file, line, ok := runtime.Caller(0)
+	println("main:", filepath.Base(file), line, ok)
+	helper()
+	(&T{}).method()
+	func() {
+		printCaller("closure")
+	}()
+	callers()
+	println("unknown:", runtime.FuncForPC(0) == nil)
+
+	// Stack traces.
+	println("debug.Stack:", bytes.Contains(debug.Stack(), []byte("runtime/debug.Stack(")))
+	go sleeper()
+	go receiver(make(chan int))
+	time.Sleep(time.Millisecond)
+	buf := make([]byte, 4096)
+	printStack(buf[:runtime.Stack(buf, false)])
+	printStack(buf[:runtime.Stack(buf, true)])
+}
+
+//go:noinline
+func sleeper() {
+	time.Sleep(time.Hour)
+}
+
+//go:noinline
+func receiver(ch chan int) {
+	<-ch
+}
+
+// Print the goroutine headers and functions in the main package in a stack
+// trace, leaving out everything that depends on the environment.
+func printStack(trace []byte) {
+	for _, line := range strings.Split(string(trace), "\n") {
+		if strings.HasPrefix(line, "goroutine ") || strings.HasPrefix(line, "main.") {
+			println(line)
+		}
+	}
+}
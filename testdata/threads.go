@@ -0,0 +1,295 @@
+package main
+
+// Test for -scheduler=threads, where goroutines run in parallel. The output
+// must not depend on the order in which goroutines run.
+
+import (
+	"bytes"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"runtime/pprof"
+	"runtime/trace"
+	"sync"
+	"time"
+)
+
+const numWorkers = 8
+
+var (
+	sink       []byte
+	workerSink [numWorkers][]byte
+)
+
+type node struct {
+	next  *node
+	value int
+}
+
+func main() {
+	testMutex()
+	testRWMutex()
+	testChannels()
+	testSelect()
+	testGC()
+	testTimers()
+	testCond()
+	testThreadReuse()
+	testStack()
+	testTrace()
+	testOutOfMemory() // must be last, it exits the program
+}
+
+// Increment a counter from many goroutines at once.
+func testMutex() {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	counter := 0
+	for i := 0; i < numWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			for j := 0; j < 10000; j++ {
+				mu.Lock()
+				counter++
+				mu.Unlock()
+			}
+			wg.Done()
+		}()
+	}
+	wg.Wait()
+	println("mutex counter:", counter)
+}
+
+// Readers and writers accessing a map at the same time.
+func testRWMutex() {
+	var mu sync.RWMutex
+	var wg sync.WaitGroup
+	m := map[int]int{}
+	for i := 0; i < numWorkers; i++ {
+		wg.Add(2)
+		go func() {
+			for j := 0; j < 1000; j++ {
+				mu.Lock()
+				m[j%100] += 1
+				mu.Unlock()
+			}
+			wg.Done()
+		}()
+		go func() {
+			for j := 0; j < 1000; j++ {
+				mu.RLock()
+				_ = m[j%100]
+				mu.RUnlock()
+			}
+			wg.Done()
+		}()
+	}
+	wg.Wait()
+	sum := 0
+	for _, v := range m {
+		sum += v
+	}
+	println("rwmutex sum:", sum, "keys:", len(m))
+}
+
+// Fan out work over a channel and collect the results.
+func testChannels() {
+	jobs := make(chan int)
+	results := make(chan int, 4)
+	for i := 0; i < numWorkers; i++ {
+		go func() {
+			for n := range jobs {
+				results <- n * n
+			}
+		}()
+	}
+	go func() {
+		for i := 1; i <= 1000; i++ {
+			jobs <- i
+		}
+		close(jobs)
+	}()
+	sum := 0
+	for i := 0; i < 1000; i++ {
+		sum += <-results
+	}
+	println("channel sum:", sum)
+}
+
+// Receive from multiple channels that are written to in parallel.
+func testSelect() {
+	a := make(chan int)
+	b := make(chan int)
+	done := make(chan struct{})
+	for i := 0; i < numWorkers; i++ {
+		go func(i int) {
+			for j := 0; j < 100; j++ {
+				if i%2 == 0 {
+					a <- 1
+				} else {
+					b <- 1
+				}
+			}
+			done <- struct{}{}
+		}(i)
+	}
+	numA, numB, numDone := 0, 0, 0
+	for numDone < numWorkers {
+		select {
+		case n := <-a:
+			numA += n
+		case n := <-b:
+			numB += n
+		case <-done:
+			numDone++
+		}
+	}
+	println("select:", numA, numB)
+}
+
+// Allocate lots of memory from multiple goroutines, to make sure the GC
+// doesn't free memory that is still referenced from another thread.
+func testGC() {
+	var wg sync.WaitGroup
+	ok := make([]bool, numWorkers)
+	for i := 0; i < numWorkers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			var list *node
+			for j := 0; j < 1000; j++ {
+				list = &node{next: list, value: j}
+				// Create some garbage.
+				_ = make([]byte, 100)
+			}
+			sum := 0
+			for n := list; n != nil; n = n.next {
+				sum += n.value
+			}
+			ok[i] = sum == 999*1000/2
+			wg.Done()
+		}(i)
+	}
+	runtime.GC()
+	wg.Wait()
+	numOK := 0
+	for _, b := range ok {
+		if b {
+			numOK++
+		}
+	}
+	println("gc:", numOK, "of", numWorkers, "lists intact")
+}
+
+// Timers run in a separate goroutine.
+func testTimers() {
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	fired := 0
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		time.AfterFunc(time.Duration(i)*time.Millisecond, func() {
+			mu.Lock()
+			fired++
+			mu.Unlock()
+			wg.Done()
+		})
+	}
+	wg.Wait()
+	println("timers fired:", fired)
+
+	timer := time.NewTimer(time.Hour)
+	println("timer stopped:", timer.Stop())
+}
+
+// Wake up goroutines waiting on a condition variable.
+func testCond() {
+	var mu sync.Mutex
+	cond := sync.NewCond(&mu)
+	ready := false
+	var wg sync.WaitGroup
+	for i := 0; i < numWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			mu.Lock()
+			for !ready {
+				cond.Wait()
+			}
+			mu.Unlock()
+			wg.Done()
+		}()
+	}
+	time.Sleep(time.Millisecond)
+	mu.Lock()
+	ready = true
+	cond.Broadcast()
+	mu.Unlock()
+	wg.Wait()
+	println("cond: all goroutines woken")
+}
+
+// Start many short-lived goroutines one after another, so that the threads of
+// exited goroutines are reused.
+func testThreadReuse() {
+	done := make(chan int)
+	sum := 0
+	for i := 0; i < 1000; i++ {
+		go func(i int) {
+			done <- i
+		}(i)
+		sum += <-done
+	}
+	println("thread reuse sum:", sum)
+}
+
+// runtime.Stack lists goroutines that run in other threads, including those
+// that are blocked.
+func testStack() {
+	block := make(chan struct{})
+	for i := 0; i < 3; i++ {
+		go func() {
+			<-block
+		}()
+	}
+	buf := make([]byte, 4096)
+	n := runtime.Stack(buf, true)
+	headers := bytes.Count(buf[:n], []byte("goroutine "))
+	close(block)
+	println("stack goroutines:", headers >= 4)
+}
+
+// Tracing is not supported with multiple threads.
+func testTrace() {
+	var buf bytes.Buffer
+	err := trace.Start(&buf)
+	println("trace:", err.Error())
+}
+
+// Run out of memory while the heap profiler records every allocation and other
+// goroutines are allocating as well. The out of memory hook allocates memory
+// itself, which must not deadlock on the heap lock. The allocation can't
+// succeed after the hook returns, so the hook exits the program.
+func testOutOfMemory() {
+	runtime.MemProfileRate = 1
+	stop := make(chan struct{})
+	for i := 0; i < numWorkers; i++ {
+		go func(i int) {
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				workerSink[i] = make([]byte, 100)
+			}
+		}(i)
+	}
+	debug.SetOutOfMemoryHook(func(size uintptr) {
+		close(stop)
+		var buf bytes.Buffer
+		err := pprof.WriteHeapProfile(&buf)
+		println("out of memory hook:", size >= 1<<30, err == nil && buf.Len() > 0)
+		os.Exit(0)
+	})
+	sink = make([]byte, 1<<30)
+	println("allocation did not fail")
+}
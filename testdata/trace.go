@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"runtime"
+	"runtime/trace"
+	"strings"
+	"time"
+)
+
+func main() {
+	var buf bytes.Buffer
+	println("enabled before start:", trace.IsEnabled())
+	if err := trace.Start(&buf); err != nil {
+		println("could not start trace:", err.Error())
+		return
+	}
+	println("enabled after start:", trace.IsEnabled())
+	if err := trace.Start(&buf); err != nil {
+		println("second start:", err.Error())
+	}
+
+	ctx, task := trace.NewTask(context.Background(), "mytask")
+	trace.WithRegion(ctx, "myregion", func() {
+		ch := make(chan int)
+		done := make(chan struct{})
+		go func() {
+			for n := range ch {
+				trace.Logf(ctx, "worker", "received %d", n)
+			}
+			close(done)
+		}()
+		for i := 0; i < 3; i++ {
+			ch <- i
+		}
+		close(ch)
+		<-done
+	})
+	region := trace.StartRegion(ctx, "sleep")
+	time.Sleep(time.Millisecond)
+	region.End()
+	runtime.GC()
+	task.End()
+
+	trace.Stop()
+	println("enabled after stop:", trace.IsEnabled())
+
+	data := buf.String()
+	println("header:", strings.HasPrefix(data, "go 1.22 trace\x00\x00\x00"))
+	for _, s := range []string{"mytask", "myregion", "worker", "received 2", "chan receive"} {
+		println("contains", s+":", strings.Contains(data, s))
+	}
+
+	// Stopping again is a no-op.
+	trace.Stop()
+	println("size unchanged:", buf.Len() == len(data))
+
+	// TestTraceParse passes a file to write the trace to, so that it can be
+	// checked with the trace parser.
+	if len(os.Args) > 1 {
+		if err := os.WriteFile(os.Args[1], buf.Bytes(), 0666); err != nil {
+			println("could not write trace:", err.Error())
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// testEvent is a single event in the output of tinygo test -json. It has the
+// same format as the events written by go test -json (see go doc test2json),
+// so that tools that read go test -json output also work with TinyGo.
+type testEvent struct {
+	Time    *time.Time `json:",omitempty"`
+	Action  string
+	Package string   `json:",omitempty"`
+	Test    string   `json:",omitempty"`
+	Elapsed *float64 `json:",omitempty"`
+	Output  string   `json:",omitempty"`
+}
+
+// testJSONWriter converts the verbose output of a test binary to JSON events,
+// like go tool test2json. Everything the test binary writes (whether it runs
+// natively or in an emulator) is passed through it.
+type testJSONWriter struct {
+	out     io.Writer
+	pkg     string // import path of the package under test
+	test    string // test that is currently running, if any
+	line    []byte // incomplete line
+	started bool
+	err     error
+}
+
+// Prefixes of the lines printed by the testing package when a test starts or
+// is paused or continued.
+var testJSONUpdates = []string{
+	"=== RUN   ",
+	"=== PAUSE ",
+	"=== CONT  ",
+	"=== NAME  ",
+}
+
+// Prefixes of the lines printed by the testing package when a test finishes.
+// These may be indented for subtests.
+var testJSONReports = []string{
+	"--- PASS: ",
+	"--- FAIL: ",
+	"--- SKIP: ",
+	"--- BENCH: ",
+}
+
+func (w *testJSONWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) != 0 {
+		i := bytes.IndexByte(p, '\n')
+		if i < 0 {
+			w.line = append(w.line, p...)
+			break
+		}
+		w.line = append(w.line, p[:i+1]...)
+		p = p[i+1:]
+		w.handleLine()
+	}
+	if w.err != nil {
+		return 0, w.err
+	}
+	return n, nil
+}
+
+// Finish writes any remaining output, followed by the final event for the
+// package with the given action ("pass", "fail", or "skip").
+func (w *testJSONWriter) Finish(action string, elapsed time.Duration) error {
+	if len(w.line) != 0 {
+		w.handleLine()
+	}
+	seconds := roundElapsed(elapsed.Seconds())
+	w.event(testEvent{Action: action, Elapsed: &seconds})
+	return w.err
+}
+
+// handleLine converts the line in w.line to one or more events.
+func (w *testJSONWriter) handleLine() {
+	line := string(w.line)
+	w.line = w.line[:0]
+	// Output from emulators may use CRLF line endings.
+	text := strings.TrimRight(line, "\r\n")
+
+	for _, prefix := range testJSONUpdates {
+		if !strings.HasPrefix(text, prefix) {
+			continue
+		}
+		name := strings.TrimSpace(text[len(prefix):])
+		action := strings.ToLower(strings.TrimSpace(prefix[4:]))
+		w.test = name
+		switch action {
+		case "name":
+			// Only used to attribute the following output to this test.
+			w.output(line)
+		case "pause":
+			w.output(line)
+			w.event(testEvent{Action: action, Test: name})
+		default:
+			w.event(testEvent{Action: action, Test: name})
+			w.output(line)
+		}
+		return
+	}
+
+	report := strings.TrimLeft(text, " ")
+	for _, prefix := range testJSONReports {
+		if !strings.HasPrefix(report, prefix) {
+			continue
+		}
+		name := strings.TrimSpace(report[len(prefix):])
+		e := testEvent{Action: strings.ToLower(prefix[4 : len(prefix)-2])}
+		if i := strings.Index(name, " ("); i >= 0 && strings.HasSuffix(name, "s)") {
+			if seconds, err := strconv.ParseFloat(name[i+2:len(name)-2], 64); err == nil {
+				e.Elapsed = &seconds
+			}
+			name = name[:i]
+		}
+		e.Test = name
+		w.test = name
+		w.output(line)
+		w.event(e)
+		// Output after the result of a subtest belongs to the parent test.
+		// The testing package prints the result of a subtest before the
+		// result of its parent.
+		w.test = ""
+		if i := strings.LastIndexByte(name, '/'); i >= 0 {
+			w.test = name[:i]
+		}
+		return
+	}
+
+	if text == "PASS" || text == "FAIL" {
+		// Final result of the test binary, not of a single test.
+		w.test = ""
+	}
+	w.output(line)
+}
+
+// output writes an output event for the given line.
+func (w *testJSONWriter) output(line string) {
+	w.event(testEvent{Action: "output", Test: w.test, Output: line})
+}
+
+// event writes a single event, and a start event before the first event.
+func (w *testJSONWriter) event(e testEvent) {
+	if w.err != nil {
+		return
+	}
+	if !w.started {
+		w.started = true
+		w.event(testEvent{Action: "start"})
+	}
+	now := time.Now()
+	e.Time = &now
+	e.Package = w.pkg
+	data, err := json.Marshal(e)
+	if err != nil {
+		w.err = err
+		return
+	}
+	_, w.err = w.out.Write(append(data, '\n'))
+}
+
+// roundElapsed rounds the elapsed time to milliseconds, like the times printed
+// by go test.
+func roundElapsed(seconds float64) float64 {
+	f, _ := strconv.ParseFloat(strconv.FormatFloat(seconds, 'f', 3, 64), 64)
+	return f
+}
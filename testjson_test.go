@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestTestJSON(t *testing.T) {
+	output := "=== RUN   TestFoo\r\n" +
+		"=== RUN   TestFoo/bar\n" +
+		"    some output\n" +
+		"    --- FAIL: TestFoo/bar (0.50s)\n" +
+		"    more output\n" +
+		"--- FAIL: TestFoo (1.25s)\n" +
+		"=== RUN   TestSkip\n" +
+		"--- SKIP: TestSkip (0.00s)\n" +
+		"FAIL\n" +
+		"FAIL\texample.com/foo\t1.500s\n"
+
+	var buf bytes.Buffer
+	w := &testJSONWriter{out: &buf, pkg: "example.com/foo"}
+	// Write the output in small pieces, to test line splitting.
+	for i := 0; i < len(output); i += 7 {
+		end := i + 7
+		if end > len(output) {
+			end = len(output)
+		}
+		w.Write([]byte(output[i:end]))
+	}
+	if err := w.Finish("fail", 1500e6); err != nil {
+		t.Fatal(err)
+	}
+
+	type event struct {
+		Action  string
+		Test    string
+		Elapsed float64
+		Output  string
+	}
+	expected := []event{
+		{Action: "start"},
+		{Action: "run", Test: "TestFoo"},
+		{Action: "output", Test: "TestFoo", Output: "=== RUN   TestFoo\r\n"},
+		{Action: "run", Test: "TestFoo/bar"},
+		{Action: "output", Test: "TestFoo/bar", Output: "=== RUN   TestFoo/bar\n"},
+		{Action: "output", Test: "TestFoo/bar", Output: "    some output\n"},
+		{Action: "output", Test: "TestFoo/bar", Output: "    --- FAIL: TestFoo/bar (0.50s)\n"},
+		{Action: "fail", Test: "TestFoo/bar", Elapsed: 0.5},
+		{Action: "output", Test: "TestFoo", Output: "    more output\n"},
+		{Action: "output", Test: "TestFoo", Output: "--- FAIL: TestFoo (1.25s)\n"},
+		{Action: "fail", Test: "TestFoo", Elapsed: 1.25},
+		{Action: "run", Test: "TestSkip"},
+		{Action: "output", Test: "TestSkip", Output: "=== RUN   TestSkip\n"},
+		{Action: "output", Test: "TestSkip", Output: "--- SKIP: TestSkip (0.00s)\n"},
+		{Action: "skip", Test: "TestSkip"},
+		{Action: "output", Output: "FAIL\n"},
+		{Action: "output", Output: "FAIL\texample.com/foo\t1.500s\n"},
+		{Action: "fail", Elapsed: 1.5},
+	}
+
+	var events []event
+	decoder := json.NewDecoder(&buf)
+	for decoder.More() {
+		var e struct {
+			event
+			Package string
+		}
+		if err := decoder.Decode(&e); err != nil {
+			t.Fatal("could not decode event:", err)
+		}
+		if e.Package != "example.com/foo" {
+			t.Errorf("unexpected package %q in event %#v", e.Package, e.event)
+		}
+		events = append(events, e.event)
+	}
+	if !reflect.DeepEqual(events, expected) {
+		t.Errorf("unexpected events:\n got: %+v\nwant: %+v", events, expected)
+	}
+}
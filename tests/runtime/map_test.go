@@ -0,0 +1,61 @@
+package main
+
+import (
+	"strconv"
+	"testing"
+)
+
+// Map sizes just before the map grows. The next insert starts growing the map.
+var mapGrowSizes = []int{6<<4 + 1, 6<<8 + 1, 6<<12 + 1}
+
+// BenchmarkMapInsert measures the average time of an insert into a growing map.
+func BenchmarkMapInsert(b *testing.B) {
+	for _, n := range mapGrowSizes {
+		b.Run(strconv.Itoa(n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				m := make(map[int]int)
+				for j := 0; j < n; j++ {
+					m[j] = j
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkMapGrowInsert measures the time of the insert that starts growing
+// the map. Maps grow incrementally, so this should not depend much on the size
+// of the map.
+func BenchmarkMapGrowInsert(b *testing.B) {
+	for _, n := range mapGrowSizes {
+		b.Run(strconv.Itoa(n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				m := make(map[int]int)
+				for j := 0; j < n; j++ {
+					m[j] = j
+				}
+				b.StartTimer()
+				m[n] = n
+			}
+		})
+	}
+}
+
+// BenchmarkMapIterate measures iterating over a map that was just grown.
+func BenchmarkMapIterate(b *testing.B) {
+	for _, n := range mapGrowSizes {
+		b.Run(strconv.Itoa(n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				m := make(map[int]int)
+				for j := 0; j <= n; j++ {
+					m[j] = j
+				}
+				b.StartTimer()
+				for k, v := range m {
+					total += uint64(k + v)
+				}
+			}
+		})
+	}
+}
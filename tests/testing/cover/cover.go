@@ -0,0 +1,20 @@
+package cover
+
+// Sign returns a description of the sign of n.
+func Sign(n int) string {
+	if n < 0 {
+		return "negative"
+	} else if n == 0 {
+		return "zero"
+	}
+	return "positive"
+}
+
+// Sum returns the sum of all numbers.
+func Sum(numbers ...int) int {
+	total := 0
+	for _, n := range numbers {
+		total += n
+	}
+	return total
+}
@@ -0,0 +1,12 @@
+package cover
+
+import "testing"
+
+func TestSign(t *testing.T) {
+	if s := Sign(-3); s != "negative" {
+		t.Errorf("unexpected result: %s", s)
+	}
+	if s := Sign(0); s != "zero" {
+		t.Errorf("unexpected result: %s", s)
+	}
+}
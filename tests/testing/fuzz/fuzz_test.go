@@ -0,0 +1,42 @@
+package fuzz_test
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// parseList parses a comma separated list of integers.
+func parseList(s string) ([]int, error) {
+	var list []int
+	for _, field := range strings.Split(s, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, n)
+	}
+	return list, nil
+}
+
+func FuzzParseList(f *testing.F) {
+	f.Add("1,2,3")
+	f.Add(" 4 , -5")
+	f.Fuzz(func(t *testing.T, s string) {
+		list, err := parseList(s)
+		if err != nil {
+			return
+		}
+		var parts []string
+		for _, n := range list {
+			parts = append(parts, strconv.Itoa(n))
+		}
+		list2, err := parseList(strings.Join(parts, ","))
+		if err != nil {
+			t.Fatalf("could not parse formatted list %v: %v", list, err)
+		}
+		if len(list) != len(list2) {
+			t.Errorf("list changed after round trip: %v != %v", list, list2)
+		}
+	})
+}
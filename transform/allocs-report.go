@@ -0,0 +1,164 @@
+package transform
+
+// This file lists all heap allocations that remain in the program after
+// optimization, together with the reason why they couldn't be moved to the
+// stack by OptimizeAllocs.
+
+import (
+	"go/token"
+	"sort"
+	"strings"
+
+	"tinygo.org/x/go-llvm"
+)
+
+// HeapAlloc is a single call to runtime.alloc that remains in the optimized
+// program.
+type HeapAlloc struct {
+	Pos       token.Position // position of the allocation (may be unknown)
+	Function  string         // function that contains the allocation
+	Package   string         // package of the function, derived from its name
+	Size      uint64         // allocation size in bytes, or 0 if not constant
+	Reason    string         // why this object could not be stack allocated
+	Interrupt bool           // the allocation may happen in an interrupt handler
+	Loop      bool           // the allocation is inside a loop
+}
+
+// HeapAllocs returns all heap allocations in the module, sorted by position.
+// It should be called on the fully optimized module, after OptimizeAllocs has
+// removed all heap allocations that can be stack allocated.
+func HeapAllocs(mod llvm.Module, maxStackAlloc uint64) []HeapAlloc {
+	allocator := mod.NamedFunction("runtime.alloc")
+	if allocator.IsNil() {
+		// No heap allocations at all.
+		return nil
+	}
+
+	interruptFunctions := interruptReachable(mod)
+
+	var allocs []HeapAlloc
+	for _, heapalloc := range getUses(allocator) {
+		if heapalloc.IsACallInst().IsNil() || heapalloc.CalledValue() != allocator {
+			// Not a call (for example, a function pointer).
+			continue
+		}
+		fn := heapalloc.InstructionParent().Parent()
+		alloc := HeapAlloc{
+			Pos:       getPosition(heapalloc),
+			Function:  fn.Name(),
+			Package:   functionPackage(fn.Name()),
+			Reason:    heapAllocReason(heapalloc, maxStackAlloc),
+			Interrupt: interruptFunctions[fn],
+			Loop:      blockInLoop(heapalloc.InstructionParent()),
+		}
+		if !heapalloc.Operand(0).IsAConstantInt().IsNil() {
+			alloc.Size = heapalloc.Operand(0).ZExtValue()
+		}
+		if alloc.Reason == "" {
+			// This can happen when OptimizeAllocs was not run (for example,
+			// with -opt=0) or when the allocation became non-escaping after
+			// OptimizeAllocs ran.
+			alloc.Reason = "not optimized"
+		}
+		allocs = append(allocs, alloc)
+	}
+
+	sort.SliceStable(allocs, func(i, j int) bool {
+		a, b := allocs[i].Pos, allocs[j].Pos
+		if a.Filename != b.Filename {
+			return a.Filename < b.Filename
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+	return allocs
+}
+
+// interruptReachable returns the set of functions that may be called from an
+// interrupt handler. Only direct calls are followed.
+func interruptReachable(mod llvm.Module) map[llvm.Value]bool {
+	reachable := make(map[llvm.Value]bool)
+	var worklist []llvm.Value
+	for fn := mod.FirstFunction(); !fn.IsNil(); fn = llvm.NextFunction(fn) {
+		// The "tinygo-interrupt" attribute is added by LowerInterrupts, the
+		// "signal" attribute is used for //go:interrupt on AVR.
+		if !fn.GetStringAttributeAtIndex(-1, "tinygo-interrupt").IsNil() || !fn.GetStringAttributeAtIndex(-1, "signal").IsNil() {
+			reachable[fn] = true
+			worklist = append(worklist, fn)
+		}
+	}
+	for len(worklist) != 0 {
+		fn := worklist[len(worklist)-1]
+		worklist = worklist[:len(worklist)-1]
+		for bb := fn.FirstBasicBlock(); !bb.IsNil(); bb = llvm.NextBasicBlock(bb) {
+			for inst := bb.FirstInstruction(); !inst.IsNil(); inst = llvm.NextInstruction(inst) {
+				if inst.IsACallInst().IsNil() && inst.IsAInvokeInst().IsNil() {
+					continue
+				}
+				callee := inst.CalledValue()
+				if callee.IsAFunction().IsNil() || reachable[callee] {
+					continue
+				}
+				reachable[callee] = true
+				worklist = append(worklist, callee)
+			}
+		}
+	}
+	return reachable
+}
+
+// blockInLoop returns whether the given basic block is part of a loop, that is,
+// whether it can reach itself through the control flow graph.
+func blockInLoop(block llvm.BasicBlock) bool {
+	visited := make(map[llvm.BasicBlock]bool)
+	worklist := blockSuccessors(block)
+	for len(worklist) != 0 {
+		bb := worklist[len(worklist)-1]
+		worklist = worklist[:len(worklist)-1]
+		if bb == block {
+			return true
+		}
+		if visited[bb] {
+			continue
+		}
+		visited[bb] = true
+		worklist = append(worklist, blockSuccessors(bb)...)
+	}
+	return false
+}
+
+// blockSuccessors returns the basic blocks that the given block may jump to.
+func blockSuccessors(block llvm.BasicBlock) []llvm.BasicBlock {
+	terminator := block.LastInstruction()
+	if terminator.IsNil() {
+		return nil
+	}
+	var successors []llvm.BasicBlock
+	for i := 0; i < terminator.OperandsCount(); i++ {
+		operand := terminator.Operand(i)
+		if operand.IsBasicBlock() {
+			successors = append(successors, operand.AsBasicBlock())
+		}
+	}
+	return successors
+}
+
+// functionPackage returns the package path of a function based on its symbol
+// name, such as "main" for "(*main.T).String" or "internal/task" for
+// "internal/task.start". Functions that don't look like Go functions return
+// the empty string.
+func functionPackage(name string) string {
+	name = strings.TrimLeft(name, "(*")
+	if i := strings.IndexByte(name, '['); i >= 0 {
+		// Strip type parameters, which may contain other package paths.
+		name = name[:i]
+	}
+	slash := strings.LastIndexByte(name, '/')
+	dot := strings.IndexByte(name[slash+1:], '.')
+	if dot < 0 {
+		return ""
+	}
+	return name[:slash+1+dot]
+}
@@ -38,24 +38,15 @@ func OptimizeAllocs(mod llvm.Module, printAllocs *regexp.Regexp, maxStackAlloc u
 	maxAlign := int64(targetData.ABITypeAlignment(complex128Type))
 
 	for _, heapalloc := range getUses(allocator) {
-		logAllocs := printAllocs != nil && printAllocs.MatchString(heapalloc.InstructionParent().Parent().Name())
-		if heapalloc.Operand(0).IsAConstantInt().IsNil() {
-			// Do not allocate variable length arrays on the stack.
-			if logAllocs {
-				logAlloc(logger, heapalloc, "size is not constant")
+		if reason := heapAllocReason(heapalloc, maxStackAlloc); reason != "" {
+			// The object must be allocated on the heap.
+			if printAllocs != nil && printAllocs.MatchString(heapalloc.InstructionParent().Parent().Name()) {
+				logAlloc(logger, heapalloc, reason)
 			}
 			continue
 		}
 
 		size := heapalloc.Operand(0).ZExtValue()
-		if size > maxStackAlloc {
-			// The maximum size for a stack allocation.
-			if logAllocs {
-				logAlloc(logger, heapalloc, fmt.Sprintf("object size %d exceeds maximum stack allocation size %d", size, maxStackAlloc))
-			}
-			continue
-		}
-
 		if size == 0 {
 			// If the size is 0, the pointer is allowed to alias other
 			// zero-sized pointers. Use the pointer to the global that would
@@ -68,31 +59,8 @@ func OptimizeAllocs(mod llvm.Module, printAllocs *regexp.Regexp, maxStackAlloc u
 			continue
 		}
 
-		// In general the pattern is:
-		//     %0 = call i8* @runtime.alloc(i32 %size, i8* null)
-		//     %1 = bitcast i8* %0 to type*
-		//     (use %1 only)
-		// But the bitcast might sometimes be dropped when allocating an *i8.
-		// The 'bitcast' variable below is thus usually a bitcast of the
-		// heapalloc but not always.
-		bitcast := heapalloc // instruction that creates the value
-		if uses := getUses(heapalloc); len(uses) == 1 && !uses[0].IsABitCastInst().IsNil() {
-			// getting only bitcast use
-			bitcast = uses[0]
-		}
-
-		if at := valueEscapesAt(bitcast); !at.IsNil() {
-			if logAllocs {
-				atPos := getPosition(at)
-				msg := "escapes at unknown line"
-				if atPos.Line != 0 {
-					msg = fmt.Sprintf("escapes at line %d", atPos.Line)
-				}
-				logAlloc(logger, heapalloc, msg)
-			}
-			continue
-		}
 		// The pointer value does not escape.
+		bitcast := allocValue(heapalloc)
 
 		// Determine the appropriate alignment of the alloca.
 		attr := heapalloc.GetCallSiteEnumAttribute(0, llvm.AttributeKindID("align"))
@@ -127,6 +95,54 @@ func OptimizeAllocs(mod llvm.Module, printAllocs *regexp.Regexp, maxStackAlloc u
 	}
 }
 
+// heapAllocReason returns why the given runtime.alloc call must remain a heap
+// allocation, or the empty string if it can be replaced with a stack
+// allocation. Zero-sized allocations never need to be on the heap.
+func heapAllocReason(heapalloc llvm.Value, maxStackAlloc uint64) string {
+	if heapalloc.Operand(0).IsAConstantInt().IsNil() {
+		// Do not allocate variable length arrays on the stack.
+		return "size is not constant"
+	}
+
+	size := heapalloc.Operand(0).ZExtValue()
+	if size > maxStackAlloc {
+		// The maximum size for a stack allocation.
+		return fmt.Sprintf("object size %d exceeds maximum stack allocation size %d", size, maxStackAlloc)
+	}
+
+	if size == 0 {
+		return ""
+	}
+
+	if at := valueEscapesAt(allocValue(heapalloc)); !at.IsNil() {
+		atPos := getPosition(at)
+		if atPos.Line != 0 {
+			return fmt.Sprintf("escapes at line %d", atPos.Line)
+		}
+		return "escapes at unknown line"
+	}
+	return ""
+}
+
+// allocValue returns the instruction that creates the value for the given
+// runtime.alloc call.
+//
+// In general the pattern is:
+//
+//	%0 = call i8* @runtime.alloc(i32 %size, i8* null)
+//	%1 = bitcast i8* %0 to type*
+//	(use %1 only)
+//
+// But the bitcast might sometimes be dropped when allocating an *i8.
+// The returned value is thus usually a bitcast of the heapalloc but not always.
+func allocValue(heapalloc llvm.Value) llvm.Value {
+	if uses := getUses(heapalloc); len(uses) == 1 && !uses[0].IsABitCastInst().IsNil() {
+		// getting only bitcast use
+		return uses[0]
+	}
+	return heapalloc
+}
+
 // valueEscapesAt returns the instruction where the given value may escape and a
 // nil llvm.Value if it definitely doesn't. The value must be an instruction.
 func valueEscapesAt(value llvm.Value) llvm.Value {
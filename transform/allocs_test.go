@@ -14,6 +14,40 @@ import (
 	"tinygo.org/x/go-llvm"
 )
 
+func TestHeapAllocs(t *testing.T) {
+	t.Parallel()
+
+	ctx := llvm.NewContext()
+	defer ctx.Dispose()
+	buf, err := llvm.NewMemoryBufferFromFile("testdata/allocs-report.ll")
+	if err != nil {
+		t.Fatal("could not read file:", err)
+	}
+	mod, err := ctx.ParseIR(buf)
+	if err != nil {
+		t.Fatalf("could not load module:\n%v", err)
+	}
+	defer mod.Dispose()
+
+	allocs := transform.HeapAllocs(mod, 256)
+	sort.Slice(allocs, func(i, j int) bool {
+		return allocs[i].Function < allocs[j].Function
+	})
+	expected := []transform.HeapAlloc{
+		{Function: "(*main.T).handle", Package: "main", Size: 16, Reason: "escapes at unknown line", Interrupt: true},
+		{Function: "github.com/foo/bar.big", Package: "github.com/foo/bar", Size: 1024, Reason: "object size 1024 exceeds maximum stack allocation size 256"},
+		{Function: "main.loop", Package: "main", Reason: "size is not constant", Loop: true},
+	}
+	if len(allocs) != len(expected) {
+		t.Fatalf("expected %d heap allocations, got %d: %+v", len(expected), len(allocs), allocs)
+	}
+	for i, alloc := range allocs {
+		if alloc != expected[i] {
+			t.Errorf("unexpected heap allocation:\n got: %+v\nwant: %+v", alloc, expected[i])
+		}
+	}
+}
+
 func TestAllocs(t *testing.T) {
 	t.Parallel()
 	testTransform(t, "testdata/allocs", func(mod llvm.Module) {
@@ -180,12 +180,12 @@ func (p *lowerInterfacesPass) run() error {
 		}
 	}
 
-	// Find all interface type asserts and interface method thunks.
-	var interfaceAssertFunctions []llvm.Value
+	// Find all interface method thunks.
 	var interfaceInvokeFunctions []llvm.Value
 	for fn := p.mod.FirstFunction(); !fn.IsNil(); fn = llvm.NextFunction(fn) {
 		methodsAttr := fn.GetStringAttributeAtIndex(-1, "tinygo-methods")
-		if methodsAttr.IsNil() {
+		invokeAttr := fn.GetStringAttributeAtIndex(-1, "tinygo-invoke")
+		if methodsAttr.IsNil() || invokeAttr.IsNil() {
 			continue
 		}
 		if !hasUses(fn) {
@@ -193,14 +193,46 @@ func (p *lowerInterfacesPass) run() error {
 			continue
 		}
 		p.addInterface(methodsAttr.GetStringValue())
+		interfaceInvokeFunctions = append(interfaceInvokeFunctions, fn)
+	}
+
+	// Check whether the program may look up methods, call functions, create
+	// functions or check interface implementations using reflection. If not,
+	// the method tables, call trampolines, MakeFunc stubs and implements
+	// trampolines emitted by the compiler can be removed.
+	reflectMethods := p.usesReflect(interfaceInvokeFunctions, "Method", "MethodByName")
+	reflectCall := p.usesReflect(interfaceInvokeFunctions, "Call", "CallSlice")
+	reflectMakeFunc := hasUses(p.mod.NamedFunction("reflect.MakeFunc"))
+	reflectImplements := p.usesReflect(interfaceInvokeFunctions, "Implements", "AssignableTo")
+
+	// The method types in interface type codes are only used by
+	// reflect.Type.Method and MethodByName. Remove them if they aren't used, so
+	// that the function type codes can be removed by the next globaldce pass.
+	if !reflectMethods {
+		p.removeInterfaceMethodTypes()
+	}
+
+	// Remove the implements trampolines before looking for type asserts, so
+	// that type assert functions only referenced by these trampolines don't
+	// need to be defined.
+	if !reflectImplements {
+		p.removeImplementsTrampolines()
+	}
+
+	// Find all interface type asserts.
+	var interfaceAssertFunctions []llvm.Value
+	for fn := p.mod.FirstFunction(); !fn.IsNil(); fn = llvm.NextFunction(fn) {
+		methodsAttr := fn.GetStringAttributeAtIndex(-1, "tinygo-methods")
 		invokeAttr := fn.GetStringAttributeAtIndex(-1, "tinygo-invoke")
-		if invokeAttr.IsNil() {
-			// Type assert.
-			interfaceAssertFunctions = append(interfaceAssertFunctions, fn)
-		} else {
-			// Interface invoke.
-			interfaceInvokeFunctions = append(interfaceInvokeFunctions, fn)
+		if methodsAttr.IsNil() || !invokeAttr.IsNil() {
+			continue
 		}
+		if !hasUses(fn) {
+			// Don't bother defining this function.
+			continue
+		}
+		p.addInterface(methodsAttr.GetStringValue())
+		interfaceAssertFunctions = append(interfaceAssertFunctions, fn)
 	}
 
 	// Find all the interfaces that are implemented per type.
@@ -310,9 +342,9 @@ func (p *lowerInterfacesPass) run() error {
 		} else {
 			// The type does not exist in the program, so lower to a constant
 			// false. This is trivially further optimized.
-			// TODO: eventually it'll be necessary to handle reflect.PtrTo and
-			// reflect.New calls which create new types not present in the
-			// original program.
+			// Types created at runtime by reflect.SliceOf and similar
+			// functions can't match either: they are only identical to a type
+			// in the program if that type exists.
 			use.ReplaceAllUsesWith(llvmFalse)
 		}
 		use.EraseFromParentAsInstruction()
@@ -330,6 +362,24 @@ func (p *lowerInterfacesPass) run() error {
 	zero := llvm.ConstInt(p.ctx.Int32Type(), 0, false)
 	for _, name := range typeNames {
 		t := p.types[name]
+		if !t.methodSet.IsNil() && reflectMethods {
+			if table := p.getMethodTable(t.methodSet); !table.IsNil() {
+				// Replace the method set with one that only contains the
+				// reflect method table, see src/reflect/type.go.
+				methods := p.ctx.ConstStruct([]llvm.Value{
+					llvm.ConstInt(p.uintptrType, 0, false),
+					table,
+				}, false)
+				methodsGlobal := llvm.AddGlobal(p.mod, methods.Type(), t.methodSet.Name()+"$reflect")
+				methodsGlobal.SetInitializer(methods)
+				methodsGlobal.SetLinkage(llvm.InternalLinkage)
+				methodsGlobal.SetGlobalConstant(true)
+				methodsGlobal.SetUnnamedAddr(true)
+				initializer := p.builder.CreateInsertValue(t.typecode.Initializer(), methodsGlobal, 0, "")
+				t.typecode.SetInitializer(initializer)
+				continue
+			}
+		}
 		if !t.methodSet.IsNil() {
 			initializer := t.typecode.Initializer()
 			var newInitializerFields []llvm.Value
@@ -366,9 +416,175 @@ func (p *lowerInterfacesPass) run() error {
 		}
 	}
 
+	// Remove the trampolines used by reflect.Value.Call and the stubs used by
+	// reflect.MakeFunc if they aren't used, so they can be removed by the next
+	// globaldce pass.
+	if !reflectCall || !reflectMakeFunc {
+		for _, name := range typeNames {
+			t := p.types[name]
+			if !strings.HasPrefix(name, "func:") {
+				continue
+			}
+			initializer := t.typecode.Initializer()
+			if initializer.Type().StructElementTypesCount() < 5 {
+				continue // old-style signature type struct
+			}
+			if !reflectCall {
+				initializer = p.builder.CreateInsertValue(initializer, llvm.ConstNull(p.ptrType), 3, "")
+			}
+			if !reflectMakeFunc {
+				initializer = p.builder.CreateInsertValue(initializer, llvm.ConstNull(p.ptrType), 4, "")
+			}
+			t.typecode.SetInitializer(initializer)
+		}
+	}
+
+	// Define the list of types used by reflect.SliceOf and similar functions,
+	// if it is referenced.
+	if global := p.mod.NamedGlobal("reflect.types"); !global.IsNil() && global.IsDeclaration() {
+		p.defineReflectTypes(global, typeNames)
+	}
+
 	return nil
 }
 
+// defineReflectTypes defines the reflect.types global, which lists all unnamed
+// slice, array, map, channel, function and struct types in the program. The
+// reflect package uses it to return the existing type from functions like
+// reflect.SliceOf, so that types stay comparable by pointer.
+// See src/reflect/newtype.go for details.
+func (p *lowerInterfacesPass) defineReflectTypes(global llvm.Value, typeNames []string) {
+	var typecodes []llvm.Value
+	if hasUses(global) {
+		for _, name := range typeNames {
+			switch name[:strings.IndexByte(name, ':')+1] {
+			case "slice:", "array:", "map:", "chan:", "func:", "struct:":
+			default:
+				continue
+			}
+			t := p.types[name]
+			typecode := t.typecode
+			if t.typecode.Initializer().Type().StructElementTypes()[0] != p.ctx.Int8Type() {
+				// The method set was kept, see above.
+				typecode = t.typecodeGEP
+			}
+			typecodes = append(typecodes, typecode)
+		}
+	}
+
+	// The layout is {uintptr, [n]ptr}.
+	initializer := p.ctx.ConstStruct([]llvm.Value{
+		llvm.ConstInt(p.uintptrType, uint64(len(typecodes)), false),
+		llvm.ConstArray(p.ptrType, typecodes),
+	}, false)
+	newGlobal := llvm.AddGlobal(p.mod, initializer.Type(), "")
+	newGlobal.SetInitializer(initializer)
+	newGlobal.SetLinkage(llvm.InternalLinkage)
+	newGlobal.SetGlobalConstant(true)
+	newGlobal.SetAlignment(p.targetData.ABITypeAlignment(p.uintptrType))
+	global.ReplaceAllUsesWith(newGlobal)
+	global.EraseFromParentAsGlobal()
+	newGlobal.SetName("reflect.types")
+}
+
+// removeInterfaceMethodTypes removes the method types from all interface type
+// codes. Each method is stored as a name, a signature and a type, see
+// interfaceMethod in src/reflect/type.go.
+func (p *lowerInterfacesPass) removeInterfaceMethodTypes() {
+	for _, t := range p.types {
+		if !strings.HasPrefix(t.name, "interface:") {
+			continue
+		}
+		initializer := t.typecode.Initializer()
+		if initializer.Type().StructElementTypesCount() < 5 {
+			continue // old-style interface type struct
+		}
+		methods := p.builder.CreateExtractValue(initializer, 4, "")
+		for i := 2; i < methods.Type().ArrayLength(); i += 3 {
+			methods = p.builder.CreateInsertValue(methods, llvm.ConstNull(p.ptrType), i, "")
+		}
+		initializer = p.builder.CreateInsertValue(initializer, methods, 4, "")
+		t.typecode.SetInitializer(initializer)
+	}
+}
+
+// removeImplementsTrampolines removes the trampolines used by
+// reflect.Type.Implements from all interface type codes.
+func (p *lowerInterfacesPass) removeImplementsTrampolines() {
+	for _, t := range p.types {
+		if !strings.HasPrefix(t.name, "interface:") {
+			continue
+		}
+		initializer := t.typecode.Initializer()
+		if initializer.Type().StructElementTypesCount() < 4 {
+			continue // old-style interface type struct
+		}
+		trampoline := p.builder.CreateExtractValue(initializer, 3, "")
+		if !trampoline.IsAConstantExpr().IsNil() && trampoline.Opcode() == llvm.IntToPtr {
+			// Stored as a data pointer, see getTypeCode in compiler/interface.go.
+			trampoline = trampoline.Operand(0).Operand(0)
+		}
+		if trampoline.IsAFunction().IsNil() {
+			continue
+		}
+		trampoline.ReplaceAllUsesWith(llvm.ConstNull(trampoline.Type()))
+		trampoline.EraseFromParentAsFunction()
+	}
+}
+
+// usesReflect returns whether any of the given methods of reflect.Value or
+// reflect.Type may be called in the program. Calls from interface method
+// wrappers and from the given methods themselves are ignored: calls through an
+// interface are detected using the interface invoke functions instead.
+func (p *lowerInterfacesPass) usesReflect(interfaceInvokeFunctions []llvm.Value, methods ...string) bool {
+	var names []string
+	for _, method := range methods {
+		for _, fn := range interfaceInvokeFunctions {
+			invoke := fn.GetStringAttributeAtIndex(-1, "tinygo-invoke").GetStringValue()
+			if strings.HasPrefix(invoke, "reflect/methods."+method+"(") {
+				return true
+			}
+		}
+		names = append(names, "(reflect.Value)."+method, "(*reflect.rawType)."+method)
+	}
+	isReflectMethod := func(name string) bool {
+		for _, n := range names {
+			if n == name {
+				return true
+			}
+		}
+		return false
+	}
+	for _, name := range names {
+		for _, use := range getUses(p.mod.NamedFunction(name)) {
+			if use.IsAInstruction().IsNil() {
+				// Referenced from a method set.
+				continue
+			}
+			caller := use.InstructionParent().Parent().Name()
+			if strings.HasSuffix(caller, "$invoke") || isReflectMethod(caller) {
+				continue
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// getMethodTable returns the reflect method table stored in the given method
+// set, or a nil value if there is none.
+func (p *lowerInterfacesPass) getMethodTable(methodSet llvm.Value) llvm.Value {
+	set := methodSet.Initializer()
+	if set.Type().StructElementTypesCount() < 4 {
+		return llvm.Value{}
+	}
+	table := p.builder.CreateExtractValue(set, 3, "")
+	if table.IsNull() {
+		return llvm.Value{}
+	}
+	return table
+}
+
 // addTypeMethods reads the method set of the given type info struct. It
 // retrieves the signatures and the references to the method functions
 // themselves for later type<->interface matching.
@@ -86,11 +86,19 @@ func LowerInterrupts(mod llvm.Module) []error {
 			// This interrupt has at least one handler.
 			// Replace the callHandlers call with (possibly multiple) calls to
 			// these handlers.
+			// Mark the interrupt vector and the handlers as interrupts, so
+			// that later passes and reports know they run in interrupt
+			// context.
+			interruptAttr := ctx.CreateStringAttribute("tinygo-interrupt", "")
+			call.InstructionParent().Parent().AddFunctionAttr(interruptAttr)
 			builder.SetInsertPointBefore(call)
 			for _, handler := range handlers {
 				initializer := handler.Initializer()
 				context := builder.CreateExtractValue(initializer, 0, "")
 				funcPtr := builder.CreateExtractValue(initializer, 1, "").Operand(0)
+				if !funcPtr.IsAFunction().IsNil() {
+					funcPtr.AddFunctionAttr(interruptAttr)
+				}
 				builder.CreateCall(funcPtr.GlobalValueType(), funcPtr, []llvm.Value{
 					num,
 					context,
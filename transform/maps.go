@@ -17,15 +17,20 @@ func OptimizeMaps(mod llvm.Module) {
 
 	hashmapBinarySet := mod.NamedFunction("runtime.hashmapBinarySet")
 	hashmapStringSet := mod.NamedFunction("runtime.hashmapStringSet")
+	hashmapBinaryDelete := mod.NamedFunction("runtime.hashmapBinaryDelete")
+	hashmapStringDelete := mod.NamedFunction("runtime.hashmapStringDelete")
 
 	for _, makeInst := range getUses(hashmapMake) {
 		updateInsts := []llvm.Value{}
-		unknownUses := false // are there any uses other than setting a value?
+		unknownUses := false // are there any uses other than updating the map?
 
 		for _, use := range getUses(makeInst) {
 			if use := use.IsACallInst(); !use.IsNil() {
 				switch use.CalledValue() {
-				case hashmapBinarySet, hashmapStringSet:
+				// Interface keys are not included: hashing a key that isn't
+				// comparable panics, so removing the call would change the
+				// behavior of the program.
+				case hashmapBinarySet, hashmapStringSet, hashmapBinaryDelete, hashmapStringDelete:
 					updateInsts = append(updateInsts, use)
 				default:
 					unknownUses = true
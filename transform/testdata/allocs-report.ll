@@ -0,0 +1,45 @@
+target datalayout = "e-m:e-p:32:32-i64:64-v128:64:128-a:0:32-n32-S64"
+target triple = "armv7m-none-eabi"
+
+declare nonnull ptr @runtime.alloc(i32, ptr)
+
+declare void @runtime.escape(ptr)
+
+; Variable sized allocation in a loop.
+define void @main.loop(i32 %n) {
+entry:
+  br label %loop
+
+loop:
+  %i = phi i32 [ 0, %entry ], [ %next, %loop ]
+  %buf = call ptr @runtime.alloc(i32 %n, ptr null)
+  call void @runtime.escape(ptr %buf)
+  %next = add i32 %i, 1
+  %done = icmp eq i32 %next, 10
+  br i1 %done, label %exit, label %loop
+
+exit:
+  ret void
+}
+
+; Interrupt vector, as created by LowerInterrupts.
+define void @IRQHandler() #0 {
+  call void @"(*main.T).handle"(ptr null)
+  ret void
+}
+
+; Allocation that is called from an interrupt handler.
+define internal void @"(*main.T).handle"(ptr %context) {
+  %obj = call ptr @runtime.alloc(i32 16, ptr null)
+  call void @runtime.escape(ptr %obj)
+  ret void
+}
+
+; Allocation that is too big for the stack.
+define void @"github.com/foo/bar.big"() {
+  %obj = call ptr @runtime.alloc(i32 1024, ptr null)
+  store i8 1, ptr %obj
+  ret void
+}
+
+attributes #0 = { "tinygo-interrupt" }
@@ -24,12 +24,12 @@ entry:
   ret void
 }
 
-define void @UARTE0_UART0_IRQHandler() {
+define void @UARTE0_UART0_IRQHandler() #0 {
   call void @"(*machine.UART).handleInterrupt$bound"(i32 2, ptr @machine.UART0)
   ret void
 }
 
-define internal void @interruptSWVector(i32 %num) {
+define internal void @interruptSWVector(i32 %num) #0 {
 entry:
   switch i32 %num, label %switch.done [
     i32 2, label %switch.body2
@@ -47,10 +47,12 @@ switch.done:                                      ; preds = %entry
   ret void
 }
 
-define internal void @"(*machine.UART).handleInterrupt$bound"(i32 %0, ptr nocapture %context) {
+define internal void @"(*machine.UART).handleInterrupt$bound"(i32 %0, ptr nocapture %context) #0 {
 entry:
   call void @"(*machine.UART).handleInterrupt"(ptr %context, i32 %0, ptr undef)
   ret void
 }
 
 declare void @"(*machine.UART).handleInterrupt"(ptr nocapture, i32, ptr nocapture readnone)
+
+attributes #0 = { "tinygo-interrupt" }
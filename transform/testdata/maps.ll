@@ -12,6 +12,9 @@ declare void @runtime.hashmapStringSet(ptr nocapture, ptr, i32, ptr nocapture re
 ; func(map[string]int, string, unsafe.Pointer)
 declare i1 @runtime.hashmapStringGet(ptr nocapture, ptr, i32, ptr nocapture)
 
+; func(map[string]int, string)
+declare void @runtime.hashmapStringDelete(ptr nocapture, ptr, i32)
+
 define void @testUnused() {
     ; create the map
     %map = call ptr @runtime.hashmapMake(i8 4, i8 4, i32 0)
@@ -23,6 +26,18 @@ define void @testUnused() {
     ret void
 }
 
+define void @testUnusedDelete() {
+    ; create the map
+    %map = call ptr @runtime.hashmapMake(i8 4, i8 4, i32 0)
+    ; create the value to be stored
+    %hashmap.value = alloca i32
+    store i32 42, ptr %hashmap.value
+    ; store the value and delete it again
+    call void @runtime.hashmapStringSet(ptr %map, ptr @answer, i32 6, ptr %hashmap.value)
+    call void @runtime.hashmapStringDelete(ptr %map, ptr @answer, i32 6)
+    ret void
+}
+
 ; Note that the following function should ideally be optimized (it could simply
 ; return 42), but isn't at the moment.
 define i32 @testReadonly() {
@@ -9,10 +9,16 @@ declare void @runtime.hashmapStringSet(ptr nocapture, ptr, i32, ptr nocapture re
 
 declare i1 @runtime.hashmapStringGet(ptr nocapture, ptr, i32, ptr nocapture)
 
+declare void @runtime.hashmapStringDelete(ptr nocapture, ptr, i32)
+
 define void @testUnused() {
   ret void
 }
 
+define void @testUnusedDelete() {
+  ret void
+}
+
 define i32 @testReadonly() {
   %map = call ptr @runtime.hashmapMake(i8 4, i8 4, i32 0)
   %hashmap.value = alloca i32, align 4